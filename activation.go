@@ -0,0 +1,119 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+var listenFdsStart = 3 // used by tests
+
+// activated holds the listeners passed to the process by systemd.
+var activated activatedListeners
+
+type activatedListeners struct {
+	once sync.Once
+	mu   sync.Mutex
+	lns  []net.Listener // not yet taken
+}
+
+// load takes the listeners passed to the process, once.
+func (a *activatedListeners) load() {
+	a.once.Do(func() {
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+		// Don't pass the listeners to child processes.
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+			f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+			ln, err := net.FileListener(f)
+			f.Close()
+			if err == nil {
+				a.lns = append(a.lns, ln)
+			}
+		}
+	})
+}
+
+// ActivationListeners returns the listeners passed to the process
+// by systemd socket activation, as described by sd_listen_fds(3),
+// that have not already been taken by ListenActivated. File
+// descriptors that are not stream listeners, such as datagram
+// sockets, are ignored.
+//
+// The LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES environment variables
+// are unset, so that they are not inherited by child processes.
+func ActivationListeners() []net.Listener {
+	activated.load()
+	activated.mu.Lock()
+	defer activated.mu.Unlock()
+	lns := activated.lns
+	activated.lns = nil
+	return lns
+}
+
+// ListenActivated returns the listener passed to the process by
+// systemd socket activation that is bound to the network address,
+// if there is one, using a zero ListenConfig to resolve the address.
+// Otherwise, it announces on the address like Listen.
+//
+// See ListenConfig.ListenActivated for details.
+func ListenActivated(network, address string) (net.Listener, error) {
+	var lc ListenConfig
+	return lc.ListenActivated(context.Background(), network, address)
+}
+
+// ListenActivated returns the listener passed to the process by
+// systemd socket activation that is bound to any of the addresses
+// selected by the IPFilter for the network address, so that a daemon
+// may support socket activation without changing its configuration.
+// If there is none, it announces on the address like Listen.
+//
+// Each passed listener is returned at most once.
+func (lc *ListenConfig) ListenActivated(ctx context.Context, network, address string) (net.Listener, error) {
+	addrs, err := lc.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	activated.load()
+	activated.mu.Lock()
+	for i, ln := range activated.lns {
+		for j := 0; j < addrs.Len(); j++ {
+			if sameAddr(ln.Addr(), addrs.NetAddr(j)) {
+				activated.lns = append(activated.lns[:i], activated.lns[i+1:]...)
+				activated.mu.Unlock()
+				return ln, nil
+			}
+		}
+	}
+	activated.mu.Unlock()
+	return lc.Listen(ctx, network, address)
+}
+
+// sameAddr reports whether a listener bound to a is bound to b.
+func sameAddr(a, b net.Addr) bool {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		b, ok := b.(*net.TCPAddr)
+		return ok && a.Port == b.Port && (a.IP.Equal(b.IP) || a.IP.IsUnspecified() && b.IP == nil)
+	case *net.UnixAddr:
+		b, ok := b.(*net.UnixAddr)
+		return ok && a.Name == b.Name
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// DefaultResolveParallelism is the number of hosts that ResolveAll
+// looks up at once if given a non-positive limit.
+const DefaultResolveParallelism = 8
+
+// A Resolution is the result of looking up a host with ResolveAll.
+type Resolution struct {
+	Host string   // host that was looked up
+	IPs  []net.IP // its IP addresses, if found
+	Err  error    // error looking it up, if any
+}
+
+// ResolveAll looks up the given hosts with r, or DefaultResolver if r
+// is nil, resolving at most limit of them at once. It returns one
+// Resolution for each host, in the same order as hosts.
+//
+// The context is passed to r if it is a ContextResolver. Hosts not yet
+// looked up when the context is done are reported with its error.
+func ResolveAll(ctx context.Context, r Resolver, hosts []string, limit int) []Resolution {
+	if limit <= 0 {
+		limit = DefaultResolveParallelism
+	}
+	resolver := withContext(ctx, r)
+	results := make([]Resolution, len(hosts))
+	var (
+		sem = make(chan struct{}, limit)
+		wg  sync.WaitGroup
+	)
+	for i, host := range hosts {
+		results[i].Host = host
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(res *Resolution) {
+			defer func() { <-sem; wg.Done() }()
+			res.IPs, res.Err = resolver.Resolve(res.Host)
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveAll(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var active, peak atomic.Int32
+	r := resolverFunc(func(host string) ([]net.IP, error) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if host == "bad" {
+			return nil, errNotFound
+		}
+		return []net.IP{net.IPv4(192, 0, 2, byte(len(host)))}, nil
+	})
+
+	hosts := []string{"a", "bb", "bad", "cccc", "ddddd"}
+	results := ResolveAll(context.Background(), r, hosts, 2)
+	if len(results) != len(hosts) {
+		t.Fatalf("expected %d results; got %d", len(hosts), len(results))
+	}
+	for i, res := range results {
+		if res.Host != hosts[i] {
+			t.Errorf("%d: expected host %q; got %q", i, hosts[i], res.Host)
+		}
+		if res.Host == "bad" {
+			if res.Err != errNotFound {
+				t.Errorf("%q: expected error %v; got %v", res.Host, errNotFound, res.Err)
+			}
+			continue
+		}
+		if want := []net.IP{net.IPv4(192, 0, 2, byte(len(res.Host)))}; res.Err != nil || !reflect.DeepEqual(res.IPs, want) {
+			t.Errorf("%q: expected %v; got %v, %v", res.Host, want, res.IPs, res.Err)
+		}
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("expected at most 2 concurrent lookups; got %d", p)
+	}
+}
+
+func TestResolveAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := ResolveAll(ctx, staticResolver{net.IPv4(192, 0, 2, 1)}, []string{"a", "b", "c"}, 1)
+	for _, res := range results {
+		if res.Err != context.Canceled {
+			t.Errorf("%q: expected error %v; got %v", res.Host, context.Canceled, res.Err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// bindInterfaceControl returns a Control function that binds the
+// socket to the named interface before calling control, if it is
+// non-nil.
+func bindInterfaceControl(name string, control func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if strings.HasPrefix(network, "unix") {
+			if control != nil {
+				return control(network, address, c)
+			}
+			return nil
+		}
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return err
+		}
+		if cerr := c.Control(func(fd uintptr) { err = bindToInterface(fd, network, ifi) }); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+		if control != nil {
+			return control(network, address, c)
+		}
+		return nil
+	}
+}
+
+// interfaceFamily returns a filter that discards the addresses of
+// families that the named interface lacks before applying filter.
+// If the interface's addresses can't be listed, none are discarded.
+func interfaceFamily(name string, filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return filter(ips)
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return filter(ips)
+		}
+		var v4, v6 bool
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ipnet.IP.To4() != nil {
+					v4 = true
+				} else {
+					v6 = true
+				}
+			}
+		}
+		switch {
+		case v4 && !v6:
+			ips = filterIPs(ipv4only, ips)
+		case v6 && !v4:
+			ips = filterIPs(ipv6only, ips)
+		}
+		return filter(ips)
+	}
+}
@@ -2,18 +2,13 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// +build !go1.3
-
 package nett
 
 import (
 	"net"
-	"time"
+	"syscall"
 )
 
-func (d *Dialer) netDialer(deadline time.Time) net.Dialer {
-	return net.Dialer{
-		Deadline:  deadline,
-		LocalAddr: d.LocalAddr,
-	}
+func bindToInterface(fd uintptr, network string, ifi *net.Interface) error {
+	return syscall.BindToDevice(int(fd), ifi.Name)
 }
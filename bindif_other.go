@@ -0,0 +1,17 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package nett
+
+import (
+	"errors"
+	"net"
+)
+
+func bindToInterface(fd uintptr, network string, ifi *net.Interface) error {
+	return errors.New("binding to an interface is not supported on this system")
+}
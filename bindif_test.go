@@ -0,0 +1,83 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func loopbackInterface(t *testing.T) *net.Interface {
+	ifts, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("net.Interfaces failed: %v", err)
+	}
+	for i := range ifts {
+		if ifts[i].Flags&net.FlagLoopback != 0 && ifts[i].Flags&net.FlagUp != 0 {
+			return &ifts[i]
+		}
+	}
+	t.Skip("no loopback interface")
+	return nil
+}
+
+func TestInterfaceFamily(t *testing.T) {
+	ifi := loopbackInterface(t)
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		t.Skipf("Addrs failed: %v", err)
+	}
+	var v4, v6 bool
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			v4 = v4 || ipnet.IP.To4() != nil
+			v6 = v6 || ipnet.IP.To4() == nil
+		}
+	}
+	ips := interfaceFamily(ifi.Name, allIPs)(parseIPs("192.0.2.1", "2001:db8::1"))
+	for _, ip := range ips {
+		if ip.To4() != nil && !v4 && v6 || ip.To4() == nil && v4 && !v6 {
+			t.Errorf("expected %v to be discarded for %s", ip, ifi.Name)
+		}
+	}
+	if len(ips) == 0 {
+		t.Errorf("expected the addresses of %s's families to remain", ifi.Name)
+	}
+	if ips := interfaceFamily("nonexistent0", allIPs)(parseIPs("192.0.2.1", "2001:db8::1")); len(ips) != 2 {
+		t.Errorf("expected no addresses to be discarded for a missing interface; got %v", ips)
+	}
+}
+
+func TestDialInterface(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "linux":
+	default:
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	ifi := loopbackInterface(t)
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	d := &Dialer{Interface: ifi.Name}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if errors.Is(err, os.ErrPermission) {
+		t.Skipf("binding to %s isn't permitted: %v", ifi.Name, err)
+	}
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+
+	d.Interface = "nonexistent0"
+	if _, err := d.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("expected dialing with a missing interface to fail")
+	}
+}
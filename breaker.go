@@ -0,0 +1,122 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// A CircuitBreaker fails dials fast to hosts whose recent dials
+// have repeatedly failed, protecting callers from waiting on, and
+// backends from being hammered by, dials that are likely to fail.
+//
+// After Threshold consecutive failed dials to a host, the breaker
+// opens for Cooldown, during which dials to the host fail with a
+// *CircuitOpenError. Once the cooldown has passed, a single trial
+// dial is allowed: if it succeeds the breaker closes, otherwise
+// it opens again.
+//
+// A CircuitBreaker is safe for concurrent use by multiple goroutines.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failed dials to
+	// a host that opens the breaker. If zero, 5 is used.
+	Threshold int
+
+	// Cooldown is the amount of time the breaker stays open.
+	// If zero, 30 seconds is used.
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breakerState
+}
+
+type breakerState struct {
+	failures int
+	until    time.Time // when the open breaker allows a trial
+	trial    bool      // a trial dial is in progress
+}
+
+// A CircuitOpenError is returned for dials to a host while its
+// circuit breaker is open.
+type CircuitOpenError struct {
+	Host  string    // the host being dialed
+	Until time.Time // when a trial dial will be allowed
+}
+
+func (e *CircuitOpenError) Error() string   { return "circuit breaker open for " + e.Host }
+func (e *CircuitOpenError) Timeout() bool   { return false }
+func (e *CircuitOpenError) Temporary() bool { return true }
+
+func (b *CircuitBreaker) threshold() int {
+	if b.Threshold > 0 {
+		return b.Threshold
+	}
+	return 5
+}
+
+func (b *CircuitBreaker) cooldown() time.Duration {
+	if b.Cooldown > 0 {
+		return b.Cooldown
+	}
+	return 30 * time.Second
+}
+
+// allow returns a *CircuitOpenError if dials to host must fail fast.
+func (b *CircuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.hosts[host]
+	if s == nil || s.failures < b.threshold() {
+		return nil
+	}
+	if s.trial || timeNow().Before(s.until) {
+		return &CircuitOpenError{Host: host, Until: s.until}
+	}
+	s.trial = true
+	return nil
+}
+
+// record records the outcome of a dial to host that was allowed.
+func (b *CircuitBreaker) record(ctx context.Context, host string, err error) {
+	if err != nil && canceled(ctx, err) {
+		// The caller gave up; the host isn't to blame.
+		b.mu.Lock()
+		if s := b.hosts[host]; s != nil {
+			s.trial = false
+		}
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.hosts, host)
+		return
+	}
+	if b.hosts == nil {
+		b.hosts = make(map[string]*breakerState)
+	}
+	s := b.hosts[host]
+	if s == nil {
+		s = &breakerState{}
+		b.hosts[host] = s
+	}
+	s.failures++
+	s.trial = false
+	if s.failures >= b.threshold() {
+		s.until = timeNow().Add(b.cooldown())
+	}
+}
+
+// hostOf returns the host of address, or address if it has no port.
+func hostOf(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
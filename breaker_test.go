@@ -0,0 +1,99 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		Breaker:  &CircuitBreaker{Threshold: 2, Cooldown: time.Minute},
+	}
+	dial := func() error {
+		c, err := d.Dial("tcp", "foo.com:"+port)
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+	var open *CircuitOpenError
+	for i := 0; i < 2; i++ {
+		if err := dial(); !isConnRefused(err) {
+			t.Fatalf("dial %d: expected connection refused; got %v", i, err)
+		}
+	}
+	if err := dial(); !errors.As(err, &open) || open.Host != "foo.com" {
+		t.Fatalf("expected *CircuitOpenError for foo.com; got %v", err)
+	}
+
+	// A failed trial reopens the breaker.
+	now = now.Add(time.Minute)
+	if err := dial(); !isConnRefused(err) {
+		t.Fatalf("trial: expected connection refused; got %v", err)
+	}
+	if err := dial(); !errors.As(err, &open) {
+		t.Fatalf("expected *CircuitOpenError; got %v", err)
+	}
+
+	// A successful trial closes the breaker.
+	ln, err = net.Listen("tcp4", "127.0.0.1:"+port)
+	if err != nil {
+		t.Skipf("can't listen on port %s again: %v", port, err)
+	}
+	defer ln.Close()
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := dial(); err != nil {
+			t.Fatalf("dial %d: unexpected error: %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		Breaker:  &CircuitBreaker{Threshold: 1, Cooldown: time.Minute},
+		// Cancel the dial while the net package is dialing.
+		Control: func(network, address string, c syscall.RawConn) error {
+			cancel()
+			return nil
+		},
+	}
+	if _, err := d.DialContext(ctx, "tcp", "foo.com:"+port); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the dial to be canceled; got %v", err)
+	}
+	d.Control = nil
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("expected the canceled dial not to open the breaker; got %v", err)
+	}
+	c.Close()
+}
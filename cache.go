@@ -0,0 +1,727 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheResolver looks up the IP addresses of a host
+// and caches the results. Concurrent lookups of a host
+// that is not cached are coalesced into one.
+type CacheResolver struct {
+	// Resolver resolves hosts that are not cached.
+	// If Resolver is nil, DefaultResolver will be used.
+	//
+	// If Resolver is a TTLResolver, resolved hosts are
+	// cached for the time to live that it reports.
+	Resolver Resolver
+	// TTL is the time to live for resolved hosts whose
+	// time to live is unknown. If TTL is zero, such
+	// hosts do not expire.
+	TTL time.Duration
+	// MinTTL and MaxTTL, if positive, clamp the time to live
+	// of resolved hosts, whether reported by the Resolver or
+	// given by TTL, so that pathologically short or long times
+	// to live are not honored. MaxTTL also applies to hosts
+	// that would otherwise not expire.
+	MinTTL, MaxTTL time.Duration
+	// NegativeTTL is the time to live for failed lookups,
+	// during which resolving the host again returns the
+	// same error. If NegativeTTL is zero, failed lookups
+	// are not cached.
+	NegativeTTL time.Duration
+	// MaxStale is how long after they expire resolved hosts
+	// may be served from the cache while they are resolved
+	// again in the background. If the lookup fails, they
+	// continue to be served until MaxStale has passed, so
+	// that transient resolution failures don't fail dials.
+	// If MaxStale is zero, expired hosts are not served.
+	MaxStale time.Duration
+	// RefreshAhead is how long before they expire resolved
+	// hosts that are in use are resolved again in the
+	// background, so that callers rarely wait for a lookup.
+	// A host is in use once it has been served from the
+	// cache RefreshHits times since it was resolved. If
+	// RefreshAhead is zero, hosts are not refreshed early.
+	RefreshAhead time.Duration
+	// RefreshHits is the number of times a host must be
+	// served from the cache to be refreshed early. If zero,
+	// a default of 1 is used.
+	RefreshHits int
+	// MaxEntries is the maximum number of hosts to cache.
+	// When it is exceeded, the least recently used host is
+	// evicted. If MaxEntries is zero, the cache is unbounded.
+	//
+	// To reduce contention, the cache is split into shards by
+	// the hash of each host. A large MaxEntries is divided
+	// among the shards, each evicting its own least recently
+	// used host, so that the hosts evicted are only roughly
+	// the least recently used of all.
+	MaxEntries int
+	// Recorder, if non-nil, records whether each
+	// resolved host was found in the cache.
+	Recorder Recorder
+	// OnQuery, if non-nil, is called after every lookup
+	// made with the CacheResolver, whether it was served
+	// from the cache or not, so that slow or failing
+	// lookups may be logged. It may be called concurrently.
+	OnQuery func(Query)
+	// Logger, if non-nil, logs cache misses and the serving of
+	// expired hosts at the Debug level, and failed lookups at
+	// the Warn level.
+	Logger Logger
+
+	once   sync.Once
+	shards []cacheShard
+}
+
+const (
+	// cacheShards is the number of shards of an unbounded cache.
+	cacheShards = 32
+
+	// minShardEntries is the fewest entries that a shard of a
+	// bounded cache may hold.
+	minShardEntries = 64
+)
+
+// A cacheShard is a part of a CacheResolver's cache.
+type cacheShard struct {
+	hits, misses, expired, evictions atomic.Int64
+
+	mu    sync.RWMutex
+	max   int // maximum number of entries, or zero if unbounded
+	cache map[string]*cacheItem
+	lru   *list.List // of keys, most recently used first
+	calls map[string]*cacheCall
+
+	_ [64]byte // avoid false sharing between shards
+}
+
+// init creates the shards of the cache.
+func (r *CacheResolver) init() {
+	n := cacheShards
+	if r.MaxEntries > 0 {
+		if n = r.MaxEntries / minShardEntries; n < 1 {
+			n = 1
+		} else if n > cacheShards {
+			n = cacheShards
+		}
+	}
+	r.shards = make([]cacheShard, n)
+	if r.MaxEntries > 0 {
+		// Divide the entries as evenly as possible.
+		for i := range r.shards {
+			r.shards[i].max = r.MaxEntries / n
+			if i < r.MaxEntries%n {
+				r.shards[i].max++
+			}
+		}
+	}
+}
+
+// allShards returns the shards of the cache.
+func (r *CacheResolver) allShards() []cacheShard {
+	r.once.Do(r.init)
+	return r.shards
+}
+
+// shard returns the shard of the cache holding key.
+func (r *CacheResolver) shard(key string) *cacheShard {
+	shards := r.allShards()
+	if len(shards) == 1 {
+		return &shards[0]
+	}
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &shards[h%uint32(len(shards))]
+}
+
+// A cacheItem is the cached result of a lookup. Its key is the host
+// for addresses of both families, or the record type, such as "IP4"
+// for IPv4 addresses or "TXT", and a space followed by the name or
+// address looked up otherwise.
+type cacheItem struct {
+	ips        []net.IP
+	records    []string   // of TXT or PTR records
+	srvs       []*net.SRV // of SRV records
+	err        error
+	ttl        time.Time
+	refreshing bool          // guarded by cacheShard.mu
+	elem       *list.Element // position in cacheShard.lru
+	hits       atomic.Int64
+}
+
+// remaining returns how much longer the item remains cached, which is
+// negative if it does not expire and zero if it has expired.
+func (item *cacheItem) remaining() time.Duration {
+	if item.ttl.IsZero() {
+		return -1
+	}
+	if ttl := item.ttl.Sub(timeNow()); ttl > 0 {
+		return ttl
+	}
+	return 0
+}
+
+// live reports whether item is a successful lookup that hasn't
+// expired.
+func (item *cacheItem) live() bool {
+	return item != nil && item.err == nil && item.remaining() != 0
+}
+
+// A cacheFetch looks up the value of a cacheItem, returning it with
+// how long to cache it for, or a negative duration if it should not
+// expire.
+type cacheFetch func() (*cacheItem, time.Duration, error)
+
+// Resolve returns a host's IP addresses.
+func (r *CacheResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL returns a host's IP addresses and how much longer they
+// remain cached. The time to live is negative if they do not expire,
+// and zero if they have expired and are being served while stale.
+func (r *CacheResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	item, err := r.get(host, host, func() (*cacheItem, time.Duration, error) {
+		ips, ttl, err := r.resolve(host)
+		return &cacheItem{ips: ips}, ttl, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return copyIPs(item.ips), item.remaining(), nil
+}
+
+// ResolveFamily returns a host's IP addresses of the family of
+// network, "ip4" or "ip6", and how much longer they remain cached, as
+// ResolveTTL does. They are cached apart from the host's addresses of
+// the other family and of both, with their own time to live, so that
+// an answer for one family is never served for another. If the
+// underlying Resolver is a FamilyResolver, only the addresses of the
+// family are looked up.
+//
+// Like Resolve, lookups are not canceled by ctx, since they may be
+// shared by concurrent callers and refreshed in the background.
+func (r *CacheResolver) ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	item, err := r.get(familyKey(network, host), host, func() (*cacheItem, time.Duration, error) {
+		ips, ttl, err := r.resolveFamily(network, host)
+		return &cacheItem{ips: ips}, ttl, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return copyIPs(item.ips), item.remaining(), nil
+}
+
+// familyKey returns the key of the addresses of host of the family of
+// network.
+func familyKey(network, host string) string {
+	if network == "ip6" {
+		return "IP6 " + host
+	}
+	return "IP4 " + host
+}
+
+// A Query describes a lookup made with a CacheResolver.
+type Query struct {
+	Name     string        // host, name or address looked up
+	Type     string        // "IP", "IP4", "IP6", "SRV", "TXT" or "PTR"
+	Duration time.Duration // time the lookup took
+	Cached   bool          // whether it was served from the cache
+	Err      error         // error of the lookup, if any
+}
+
+// get returns the cached item for key, or fetches it if it's not
+// cached. The name being looked up is passed to the Recorder and
+// OnQuery.
+func (r *CacheResolver) get(key, name string, fetch cacheFetch) (*cacheItem, error) {
+	if r.OnQuery == nil {
+		item, _, err := r.find(key, name, fetch)
+		return item, err
+	}
+	start := time.Now()
+	item, cached, err := r.find(key, name, fetch)
+	q := Query{Name: name, Type: "IP", Duration: time.Since(start), Cached: cached, Err: err}
+	if i := strings.IndexByte(key, ' '); i >= 0 {
+		q.Type = key[:i]
+	}
+	r.OnQuery(q)
+	return item, err
+}
+
+// find is like get, also reporting whether the item was cached.
+func (r *CacheResolver) find(key, name string, fetch cacheFetch) (*cacheItem, bool, error) {
+	s := r.shard(key)
+	s.mu.RLock()
+	item, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok {
+		now := timeNow()
+		fresh := item.ttl.IsZero() || now.Before(item.ttl)
+		stale := !fresh && item.err == nil && now.Before(item.ttl.Add(r.MaxStale))
+		if !fresh {
+			s.expired.Add(1)
+		}
+		if fresh || stale {
+			s.hits.Add(1)
+			if stale && r.Logger != nil {
+				r.Logger.Debug("nett: serving expired host", "name", name)
+			}
+			if r.Recorder != nil {
+				r.Recorder.CacheLookup(name, true)
+			}
+			if published.Load() {
+				globalCounters.CacheLookup(name, true)
+			}
+			if s.max > 0 {
+				s.touch(key, item)
+			}
+			if stale || (fresh && r.refreshAhead(item, now)) {
+				r.refresh(s, key, item, fetch)
+			}
+			if item.err != nil {
+				return nil, true, item.err
+			}
+			return item, true, nil
+		}
+	}
+	s.misses.Add(1)
+	if r.Recorder != nil {
+		r.Recorder.CacheLookup(name, false)
+	}
+	if published.Load() {
+		globalCounters.CacheLookup(name, false)
+	}
+	if r.Logger != nil {
+		r.Logger.Debug("nett: cache miss", "name", name)
+	}
+	item, err := r.lookup(s, key, item, fetch)
+	if err != nil && r.Logger != nil {
+		r.Logger.Warn("nett: lookup failed", "name", name, "err", err)
+	}
+	return item, false, err
+}
+
+// cacheCall is a lookup that is in progress.
+type cacheCall struct {
+	done chan struct{}
+	item *cacheItem
+	err  error
+}
+
+// lookup fetches the item for key and replaces its old item with the
+// result. If the fetch fails and old is still live, as when it is
+// prefetched, old is kept, as by refresh. Concurrent lookups of the
+// same key share the result of a single fetch.
+func (r *CacheResolver) lookup(s *cacheShard, key string, old *cacheItem, fetch cacheFetch) (*cacheItem, error) {
+	s.mu.Lock()
+	if c, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+		<-c.done
+		return c.item, c.err
+	}
+	c := &cacheCall{done: make(chan struct{})}
+	if s.calls == nil {
+		s.calls = make(map[string]*cacheCall)
+	}
+	s.calls[key] = c
+	s.mu.Unlock()
+
+	item, ttl, err := fetch()
+	if err == nil || !old.live() {
+		if err != nil && r.NegativeTTL > 0 {
+			item, ttl = &cacheItem{}, r.NegativeTTL
+		}
+		r.store(s, key, old, item, ttl, err)
+	}
+	c.item, c.err = item, err
+
+	s.mu.Lock()
+	delete(s.calls, key)
+	s.mu.Unlock()
+	close(c.done)
+	return item, err
+}
+
+// refreshAhead counts a hit of the fresh item and reports whether
+// it should be refreshed before it expires.
+func (r *CacheResolver) refreshAhead(item *cacheItem, now time.Time) bool {
+	hits := item.hits.Add(1)
+	if r.RefreshAhead <= 0 || item.ttl.IsZero() || item.err != nil {
+		return false
+	}
+	min := int64(r.RefreshHits)
+	if min <= 0 {
+		min = 1
+	}
+	return hits >= min && item.ttl.Sub(now) <= r.RefreshAhead
+}
+
+// refresh fetches the item for key in the background to replace it,
+// unless a refresh is already in progress. If it fails, the item
+// is kept.
+func (r *CacheResolver) refresh(s *cacheShard, key string, item *cacheItem, fetch cacheFetch) {
+	s.mu.Lock()
+	refreshing := item.refreshing
+	item.refreshing = true
+	s.mu.Unlock()
+	if refreshing {
+		return
+	}
+	go func() {
+		fresh, ttl, err := fetch()
+		if err != nil {
+			s.mu.Lock()
+			item.refreshing = false
+			s.mu.Unlock()
+			if r.Logger != nil {
+				r.Logger.Warn("nett: refresh failed", "key", key, "err", err)
+			}
+			return
+		}
+		r.store(s, key, item, fresh, ttl, nil)
+	}()
+}
+
+// store replaces the cached item for key in s, if it is still old,
+// with the result of a lookup that is valid for ttl. If the result
+// isn't cacheable, old is evicted.
+func (r *CacheResolver) store(s *cacheShard, key string, old, item *cacheItem, ttl time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.cache[key]; ok && cur != old {
+		return
+	}
+	if ttl == 0 || (err != nil && r.NegativeTTL <= 0) {
+		s.remove(key)
+		return
+	}
+	item.err = err
+	if ttl > 0 {
+		item.ttl = timeNow().Add(ttl)
+	}
+	if s.cache == nil {
+		s.cache = make(map[string]*cacheItem)
+		s.lru = list.New()
+	}
+	if cur, ok := s.cache[key]; ok {
+		item.elem = cur.elem
+		s.lru.MoveToFront(item.elem)
+	} else {
+		item.elem = s.lru.PushFront(key)
+	}
+	s.cache[key] = item
+	for s.max > 0 && len(s.cache) > s.max {
+		s.remove(s.lru.Back().Value.(string))
+		s.evictions.Add(1)
+	}
+}
+
+// remove evicts the cached item for key.
+// The caller must hold s.mu.
+func (s *cacheShard) remove(key string) {
+	if item, ok := s.cache[key]; ok {
+		s.lru.Remove(item.elem)
+		delete(s.cache, key)
+	}
+}
+
+// touch marks key as the most recently used, if item is still cached.
+func (s *cacheShard) touch(key string, item *cacheItem) {
+	s.mu.Lock()
+	if s.cache[key] == item {
+		s.lru.MoveToFront(item.elem)
+	}
+	s.mu.Unlock()
+}
+
+// Remove evicts the cached lookups of host, including those of its
+// records other than addresses, so that it is resolved again.
+func (r *CacheResolver) Remove(host string) {
+	shards := r.allShards()
+	for i := range shards {
+		s := &shards[i]
+		s.mu.Lock()
+		for key := range s.cache {
+			if key == host || strings.HasSuffix(key, " "+host) {
+				s.remove(key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Flush evicts every cached lookup.
+func (r *CacheResolver) Flush() {
+	shards := r.allShards()
+	for i := range shards {
+		s := &shards[i]
+		s.mu.Lock()
+		s.cache = nil
+		s.lru = nil
+		s.mu.Unlock()
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (r *CacheResolver) Len() int {
+	n := 0
+	shards := r.allShards()
+	for i := range shards {
+		s := &shards[i]
+		s.mu.RLock()
+		n += len(s.cache)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// CacheStats holds statistics about a CacheResolver's cache.
+type CacheStats struct {
+	Hits      int64 // lookups served from the cache
+	Misses    int64 // lookups not served from the cache
+	Expired   int64 // lookups that found an expired entry
+	Evictions int64 // entries evicted to stay within MaxEntries
+	Entries   int   // current number of entries
+}
+
+// Stats returns statistics about the cache since it was created.
+func (r *CacheResolver) Stats() CacheStats {
+	var stats CacheStats
+	shards := r.allShards()
+	for i := range shards {
+		s := &shards[i]
+		stats.Hits += s.hits.Load()
+		stats.Misses += s.misses.Load()
+		stats.Expired += s.expired.Load()
+		stats.Evictions += s.evictions.Load()
+	}
+	stats.Entries = r.Len()
+	return stats
+}
+
+// Prefetch resolves the given hosts and caches their addresses,
+// replacing any that are already cached, so that later lookups of
+// them are served from the cache, such as at startup before serving
+// requests. The addresses of each family cached by ResolveFamily for
+// a host are also replaced, but only if they are already cached. A
+// failed lookup doesn't replace addresses that haven't expired. At
+// most DefaultResolveParallelism hosts are resolved at once, and none
+// are once ctx is done. It returns the errors of any lookups that
+// failed or were not made.
+func (r *CacheResolver) Prefetch(ctx context.Context, hosts ...string) error {
+	var errs []error
+	for _, res := range ResolveAll(ctx, cachePrefetcher{r}, hosts, 0) {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KeepFresh prefetches the given hosts every interval until ctx is
+// done, so that they stay cached and are never resolved by a caller.
+// It blocks until then, returning the context's error, so it is
+// typically run in its own goroutine. Failed lookups are retried at
+// the next interval.
+func (r *CacheResolver) KeepFresh(ctx context.Context, interval time.Duration, hosts ...string) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		r.Prefetch(ctx, hosts...)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// cachePrefetcher is a Resolver that looks up hosts for a
+// CacheResolver and replaces what it has cached for them.
+type cachePrefetcher struct {
+	r *CacheResolver
+}
+
+func (p cachePrefetcher) Resolve(host string) ([]net.IP, error) {
+	item, err := p.prefetch(host, true, func() (*cacheItem, time.Duration, error) {
+		ips, ttl, err := p.r.resolve(host)
+		return &cacheItem{ips: ips}, ttl, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, network := range []string{"ip4", "ip6"} {
+		network := network
+		_, err := p.prefetch(familyKey(network, host), false, func() (*cacheItem, time.Duration, error) {
+			ips, ttl, err := p.r.resolveFamily(network, host)
+			return &cacheItem{ips: ips}, ttl, err
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return item.ips, errors.Join(errs...)
+}
+
+// prefetch replaces the item cached for key with one fetched anew. If
+// add is false, nothing is fetched unless an item is already cached.
+func (p cachePrefetcher) prefetch(key string, add bool, fetch cacheFetch) (*cacheItem, error) {
+	s := p.r.shard(key)
+	s.mu.RLock()
+	old := s.cache[key]
+	s.mu.RUnlock()
+	if old == nil && !add {
+		return nil, nil
+	}
+	return p.r.lookup(s, key, old, fetch)
+}
+
+// resolve looks up host with the underlying Resolver and returns its
+// addresses and how long to cache them for.
+func (r *CacheResolver) resolve(host string) ([]net.IP, time.Duration, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	ips, ttl, err := resolveTTL(resolver, host)
+	if ttl < 0 {
+		ttl = r.defaultTTL()
+	}
+	return ips, r.clampTTL(ttl), err
+}
+
+// resolveFamily is like resolve, but only returns the addresses of the
+// family of network.
+func (r *CacheResolver) resolveFamily(network, host string) ([]net.IP, time.Duration, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	var (
+		ips []net.IP
+		ttl time.Duration
+		err error
+	)
+	if fr, ok := resolver.(FamilyResolver); ok {
+		ips, ttl, err = fr.ResolveFamily(context.Background(), network, host)
+	} else if ips, ttl, err = resolveTTL(resolver, host); err == nil {
+		var a []net.IP
+		for _, ip := range ips {
+			if (ip.To4() != nil) == (network == "ip4") {
+				a = append(a, ip)
+			}
+		}
+		if ips = a; len(ips) == 0 {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+	}
+	if ttl < 0 {
+		ttl = r.defaultTTL()
+	}
+	return ips, r.clampTTL(ttl), err
+}
+
+// defaultTTL returns how long to cache results whose time to live is
+// unknown, or a negative duration if they should not expire.
+func (r *CacheResolver) defaultTTL() time.Duration {
+	if r.TTL <= 0 {
+		return -1
+	}
+	return r.TTL
+}
+
+// clampTTL returns ttl clamped between MinTTL and MaxTTL.
+func (r *CacheResolver) clampTTL(ttl time.Duration) time.Duration {
+	if r.MinTTL > 0 && ttl >= 0 && ttl < r.MinTTL {
+		ttl = r.MinTTL
+	}
+	if r.MaxTTL > 0 && (ttl < 0 || ttl > r.MaxTTL) {
+		ttl = r.MaxTTL
+	}
+	return ttl
+}
+
+func copyIPs(ips []net.IP) []net.IP {
+	a := make([]net.IP, len(ips))
+	copy(a, ips)
+	return a
+}
+
+// cacheEntry is the saved form of a cacheItem.
+type cacheEntry struct {
+	Key     string     `json:"key"`
+	IPs     []net.IP   `json:"ips,omitempty"`
+	Records []string   `json:"records,omitempty"`
+	SRVs    []*net.SRV `json:"srvs,omitempty"`
+	Expires time.Time  `json:"expires,omitempty"`
+}
+
+// Save writes the successful lookups in the cache that have not
+// expired to w, so that they may be restored by Load, such as when
+// a process restarts.
+func (r *CacheResolver) Save(w io.Writer) error {
+	now := timeNow()
+	var entries []cacheEntry
+	shards := r.allShards()
+	for i := range shards {
+		s := &shards[i]
+		s.mu.RLock()
+		if s.lru != nil {
+			// Save the least recently used first, so that
+			// Load leaves them first to be evicted.
+			for e := s.lru.Back(); e != nil; e = e.Prev() {
+				key := e.Value.(string)
+				item := s.cache[key]
+				if item.err != nil || (!item.ttl.IsZero() && !now.Before(item.ttl)) {
+					continue
+				}
+				entries = append(entries, cacheEntry{key, item.ips, item.records, item.srvs, item.ttl})
+			}
+		}
+		s.mu.RUnlock()
+	}
+	if entries == nil {
+		entries = []cacheEntry{}
+	}
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// Load reads lookups written by Save from rd and adds those that have
+// not expired to the cache. Hosts that are already cached are kept.
+func (r *CacheResolver) Load(rd io.Reader) error {
+	var entries []cacheEntry
+	if err := json.NewDecoder(rd).Decode(&entries); err != nil {
+		return err
+	}
+	now := timeNow()
+	for _, e := range entries {
+		ttl := time.Duration(-1)
+		if !e.Expires.IsZero() {
+			if ttl = e.Expires.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		r.store(r.shard(e.Key), e.Key, nil, &cacheItem{ips: e.IPs, records: e.Records, srvs: e.SRVs}, ttl, nil)
+	}
+	return nil
+}
@@ -0,0 +1,814 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheResolver(t *testing.T) {
+	defer func(lookupFn func(string) ([]net.IP, error), timeFn func() time.Time) {
+		lookupIPs = lookupFn
+		timeNow = timeFn
+	}(lookupIPs, timeNow)
+	lookups := 0
+	ips := []net.IP{net.IPv6loopback}
+	lookupIPs = func(string) ([]net.IP, error) {
+		lookups++
+		return ips, nil
+	}
+	start := time.Now()
+	now := start
+	ttl := time.Second
+	timeNow = func() time.Time { return now }
+	resolver := &CacheResolver{TTL: ttl}
+	validate := func(host string, expLookups int) {
+		ips0, err := resolver.Resolve(host)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if lookups != expLookups {
+			t.Fatalf("lookups: expected %d; got %d", expLookups, lookups)
+		}
+		if !reflect.DeepEqual(ips, ips0) {
+			t.Fatalf("ips: expected %v; got %v", ips, ips0)
+		}
+		ips0[0] = nil
+		if reflect.DeepEqual(ips, ips0) {
+			t.Fatal("ips: expected copy; got same")
+		}
+	}
+	validate("foo.com", 1)       // lookup foo.com
+	now = start.Add(ttl / 2)     //
+	validate("bar.net", 2)       // lookup bar.net
+	validate("foo.com", 2)       // cached
+	now = start.Add(ttl)         // expire foo.com
+	validate("foo.com", 3)       // lookup foo.com
+	validate("bar.net", 3)       // cached
+	now = start.Add(ttl + ttl/2) // expire bar.net
+	validate("foo.com", 3)       // cached
+	validate("bar.net", 4)       // lookup bar.net
+}
+
+func TestCacheResolverNegativeTTL(t *testing.T) {
+	defer func(lookupFn func(string) ([]net.IP, error), timeFn func() time.Time) {
+		lookupIPs = lookupFn
+		timeNow = timeFn
+	}(lookupIPs, timeNow)
+	lookups := 0
+	errNoHost := errors.New("no such host")
+	lookupIPs = func(string) ([]net.IP, error) {
+		lookups++
+		return nil, errNoHost
+	}
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+	resolver := &CacheResolver{TTL: time.Hour, NegativeTTL: time.Second}
+	validate := func(expLookups int) {
+		if _, err := resolver.Resolve("foo.com"); err != errNoHost {
+			t.Fatalf("expected %v; got %v", errNoHost, err)
+		}
+		if lookups != expLookups {
+			t.Fatalf("lookups: expected %d; got %d", expLookups, lookups)
+		}
+	}
+	validate(1)                      // lookup
+	validate(1)                      // cached
+	now = start.Add(time.Second)     // expire
+	validate(2)                      // lookup
+	resolver.NegativeTTL = 0         //
+	now = start.Add(3 * time.Second) // expire
+	validate(3)                      // lookup
+	validate(4)                      // not cached
+}
+
+func TestCacheResolverMaxStale(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	var mu sync.Mutex
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	setNow := func(t time.Time) {
+		mu.Lock()
+		now = t
+		mu.Unlock()
+	}
+
+	errLookup := errors.New("lookup failed")
+	results := make(chan error)
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(string) ([]net.IP, error) {
+			return []net.IP{net.IPv6loopback}, <-results
+		}),
+		TTL:      time.Second,
+		MaxStale: time.Minute,
+	}
+	resolve := func() {
+		if _, err := resolver.Resolve("foo.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// refreshed waits for the background lookup to finish.
+	refreshed := func() {
+		for {
+			s := resolver.shard("foo.com")
+			s.mu.RLock()
+			refreshing := s.cache["foo.com"].refreshing
+			s.mu.RUnlock()
+			if !refreshing {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	go func() { results <- nil }()
+	resolve()
+
+	// Stale results are served while they're resolved in the background,
+	// and continue to be served if that fails.
+	setNow(start.Add(2 * time.Second))
+	resolve()
+	results <- errLookup
+	refreshed()
+	resolve()
+	results <- nil
+	refreshed()
+
+	// Once MaxStale has passed since the refreshed result expired,
+	// hosts are resolved synchronously.
+	setNow(start.Add(2*time.Second + time.Minute + time.Second))
+	go func() { results <- errLookup }()
+	if _, err := resolver.Resolve("foo.com"); err != errLookup {
+		t.Fatalf("expected %v; got %v", errLookup, err)
+	}
+}
+
+func TestCacheResolverRefreshAhead(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	var mu sync.Mutex
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	lookups := make(chan struct{})
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(string) ([]net.IP, error) {
+			lookups <- struct{}{}
+			return []net.IP{net.IPv6loopback}, nil
+		}),
+		TTL:          10 * time.Second,
+		RefreshAhead: time.Second,
+		RefreshHits:  2,
+	}
+	go func() { <-lookups }()
+	resolver.Resolve("foo.com")
+	s := resolver.shard("foo.com")
+	s.mu.RLock()
+	item := s.cache["foo.com"]
+	s.mu.RUnlock()
+
+	// A host isn't refreshed until it's in use and about to expire.
+	resolver.Resolve("foo.com")
+	mu.Lock()
+	now = start.Add(9500 * time.Millisecond)
+	mu.Unlock()
+	resolver.Resolve("foo.com")
+	select {
+	case <-lookups:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected background lookup")
+	}
+	for {
+		s.mu.RLock()
+		replaced := s.cache["foo.com"] != item
+		s.mu.RUnlock()
+		if replaced {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheResolverMaxEntries(t *testing.T) {
+	lookups := 0
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(string) ([]net.IP, error) {
+			lookups++
+			return []net.IP{net.IPv6loopback}, nil
+		}),
+		MaxEntries: 2,
+	}
+	validate := func(host string, expLookups, expLen int) {
+		resolver.Resolve(host)
+		if lookups != expLookups {
+			t.Fatalf("lookups: expected %d; got %d", expLookups, lookups)
+		}
+		if n := resolver.Len(); n != expLen {
+			t.Fatalf("Len: expected %d; got %d", expLen, n)
+		}
+	}
+	validate("foo.com", 1, 1) // lookup foo.com
+	validate("bar.net", 2, 2) // lookup bar.net
+	validate("foo.com", 2, 2) // cached
+	validate("baz.org", 3, 2) // lookup baz.org, evict bar.net
+	validate("foo.com", 3, 2) // cached
+	validate("bar.net", 4, 2) // lookup bar.net, evict baz.org
+	validate("baz.org", 5, 2) // lookup baz.org, evict foo.com
+}
+
+func TestCacheResolverCoalesce(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(string) ([]net.IP, error) {
+			lookups.Add(1)
+			<-release
+			return []net.IP{net.IPv6loopback}, nil
+		}),
+	}
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if ips, err := resolver.Resolve("foo.com"); err != nil || len(ips) != 1 {
+				t.Errorf("unexpected result: %v, %v", ips, err)
+			}
+		}()
+	}
+	for {
+		s := resolver.shard("foo.com")
+		s.mu.RLock()
+		_, ok := s.calls["foo.com"]
+		s.mu.RUnlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("lookups: expected 1; got %d", n)
+	}
+}
+
+type resolverFunc func(host string) ([]net.IP, error)
+
+func (fn resolverFunc) Resolve(host string) ([]net.IP, error) { return fn(host) }
+
+// ttlResolver resolves every host to its IPs with the given TTL.
+type ttlResolver struct {
+	ips     []net.IP
+	ttl     time.Duration
+	lookups *int
+}
+
+func (r ttlResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+func (r ttlResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	*r.lookups++
+	return copyIPs(r.ips), r.ttl, nil
+}
+
+func TestCacheResolverRecordTTL(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+
+	tests := []struct {
+		recordTTL time.Duration
+		ttl       time.Duration
+		elapsed   time.Duration
+		lookups   int
+	}{
+		{recordTTL: 2 * time.Second, ttl: time.Hour, elapsed: time.Second, lookups: 1},
+		{recordTTL: 2 * time.Second, ttl: time.Hour, elapsed: 2 * time.Second, lookups: 2},
+		{recordTTL: 0, ttl: time.Hour, elapsed: 0, lookups: 2},
+		{recordTTL: -1, ttl: time.Hour, elapsed: time.Minute, lookups: 1},
+		{recordTTL: -1, ttl: 0, elapsed: 1000 * time.Hour, lookups: 1},
+	}
+	for _, tt := range tests {
+		now = start
+		lookups := 0
+		r := &CacheResolver{
+			Resolver: ttlResolver{[]net.IP{net.IPv6loopback}, tt.recordTTL, &lookups},
+			TTL:      tt.ttl,
+		}
+		r.Resolve("foo.com")
+		now = start.Add(tt.elapsed)
+		r.Resolve("foo.com")
+		if lookups != tt.lookups {
+			t.Errorf("record TTL %v, TTL %v, after %v: expected %d lookups; got %d", tt.recordTTL, tt.ttl, tt.elapsed, tt.lookups, lookups)
+		}
+	}
+}
+
+func TestCacheResolverSaveLoad(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+
+	lookups := 0
+	inner := resolverFunc(func(host string) ([]net.IP, error) {
+		lookups++
+		if host == "bad.com" {
+			return nil, errors.New("lookup failed")
+		}
+		return []net.IP{net.IPv6loopback}, nil
+	})
+	r := &CacheResolver{Resolver: inner, TTL: time.Minute, NegativeTTL: time.Minute}
+	r.Resolve("foo.com")
+	r.Resolve("bad.com")
+	now = start.Add(30 * time.Second)
+	r.Resolve("bar.com")
+
+	var buf bytes.Buffer
+	if err := r.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// foo.com has expired by the time the cache
+	// is loaded and failed lookups aren't saved.
+	now = start.Add(time.Minute)
+	lookups = 0
+	r = &CacheResolver{Resolver: inner, TTL: time.Minute}
+	if err := r.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if n := r.Len(); n != 1 {
+		t.Fatalf("Len: expected 1; got %d", n)
+	}
+	if ips, err := r.Resolve("bar.com"); err != nil || !reflect.DeepEqual(ips, []net.IP{net.IPv6loopback}) {
+		t.Fatalf("unexpected result: %v, %v", ips, err)
+	}
+	if lookups != 0 {
+		t.Error("expected bar.com to be loaded")
+	}
+}
+
+func TestCacheResolverStats(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+
+	r := &CacheResolver{Resolver: staticResolver{net.IPv6loopback}, TTL: time.Minute, MaxEntries: 2}
+	r.Resolve("foo.com") // miss
+	r.Resolve("foo.com") // hit
+	r.Resolve("bar.com") // miss
+	r.Resolve("baz.com") // miss, evict foo.com
+	now = start.Add(time.Minute)
+	r.Resolve("baz.com") // expired, miss
+	want := CacheStats{Hits: 1, Misses: 4, Expired: 1, Evictions: 1, Entries: 2}
+	if got := r.Stats(); got != want {
+		t.Errorf("expected %+v; got %+v", want, got)
+	}
+}
+
+func TestCacheResolverRemoveFlush(t *testing.T) {
+	r := &CacheResolver{Resolver: withTXT{staticResolver{net.IPv6loopback}, []string{"txt"}}}
+	r.Resolve("foo.com")
+	r.ResolveTXT(context.Background(), "foo.com")
+	r.Resolve("bar.com")
+	r.Remove("foo.com")
+	if n := r.Len(); n != 1 {
+		t.Fatalf("Len after Remove: expected 1; got %d", n)
+	}
+	r.Flush()
+	if n := r.Len(); n != 0 {
+		t.Fatalf("Len after Flush: expected 0; got %d", n)
+	}
+	r.Resolve("foo.com")
+	if n := r.Len(); n != 1 {
+		t.Fatalf("Len after Flush and Resolve: expected 1; got %d", n)
+	}
+}
+
+func TestCacheResolverClampTTL(t *testing.T) {
+	tests := []struct {
+		min, max, ttl, want time.Duration
+	}{
+		{0, 0, 0, 0},
+		{0, 0, -1, -1},
+		{time.Second, 0, 0, time.Second},
+		{time.Second, 0, -1, -1},
+		{time.Second, time.Hour, time.Minute, time.Minute},
+		{0, time.Hour, 48 * time.Hour, time.Hour},
+		{0, time.Hour, -1, time.Hour},
+	}
+	for _, tt := range tests {
+		r := &CacheResolver{MinTTL: tt.min, MaxTTL: tt.max}
+		if got := r.clampTTL(tt.ttl); got != tt.want {
+			t.Errorf("MinTTL %v, MaxTTL %v: clampTTL(%v): expected %v; got %v", tt.min, tt.max, tt.ttl, tt.want, got)
+		}
+	}
+}
+
+func TestCacheResolverPrefetch(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var lookups atomic.Int32
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(host string) ([]net.IP, error) {
+			lookups.Add(1)
+			if host == "bad.com" {
+				return nil, errNotFound
+			}
+			return []net.IP{net.IPv4(192, 0, 2, byte(lookups.Load()))}, nil
+		}),
+	}
+	if err := resolver.Prefetch(context.Background(), "foo.com", "bar.com", "bad.com"); !errors.Is(err, errNotFound) {
+		t.Fatalf("expected error %v; got %v", errNotFound, err)
+	}
+	if n := lookups.Load(); n != 3 {
+		t.Fatalf("expected 3 lookups; got %d", n)
+	}
+	for _, host := range []string{"foo.com", "bar.com"} {
+		if _, err := resolver.Resolve(host); err != nil {
+			t.Fatalf("Resolve(%q) failed: %v", host, err)
+		}
+	}
+	if s := resolver.Stats(); s.Hits != 2 || s.Misses != 0 {
+		t.Errorf("expected 2 hits and 0 misses; got %+v", s)
+	}
+
+	// Prefetching again replaces what is cached.
+	if err := resolver.Prefetch(context.Background(), "foo.com"); err != nil {
+		t.Fatalf("Prefetch failed: %v", err)
+	}
+	ips, err := resolver.Resolve("foo.com")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := []net.IP{net.IPv4(192, 0, 2, 4)}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+}
+
+func TestCacheResolverPrefetchFailure(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	var fail bool
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(host string) ([]net.IP, error) {
+			if fail {
+				return nil, errLookup
+			}
+			return []net.IP{net.IPv4(192, 0, 2, 1)}, nil
+		}),
+	}
+	ctx := context.Background()
+	if _, _, err := resolver.ResolveFamily(ctx, "ip4", "foo.com"); err != nil {
+		t.Fatalf("ResolveFamily failed: %v", err)
+	}
+	if err := resolver.Prefetch(ctx, "foo.com"); err != nil {
+		t.Fatalf("Prefetch failed: %v", err)
+	}
+
+	// A failed prefetch keeps the entries that are still valid.
+	fail = true
+	if err := resolver.Prefetch(ctx, "foo.com"); !errors.Is(err, errLookup) {
+		t.Fatalf("expected error %v; got %v", errLookup, err)
+	}
+	if n := resolver.Len(); n != 2 {
+		t.Fatalf("expected 2 entries; got %d", n)
+	}
+	if _, err := resolver.Resolve("foo.com"); err != nil {
+		t.Errorf("Resolve failed: %v", err)
+	}
+	if _, _, err := resolver.ResolveFamily(ctx, "ip4", "foo.com"); err != nil {
+		t.Errorf("ResolveFamily failed: %v", err)
+	}
+	if s := resolver.Stats(); s.Hits != 2 {
+		t.Errorf("expected 2 hits; got %+v", s)
+	}
+}
+
+func TestCacheResolverKeepFresh(t *testing.T) {
+	var lookups atomic.Int32
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(host string) ([]net.IP, error) {
+			lookups.Add(1)
+			return []net.IP{net.IPv6loopback}, nil
+		}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- resolver.KeepFresh(ctx, time.Millisecond, "foo.com") }()
+	for lookups.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected error %v; got %v", context.Canceled, err)
+	}
+	if n := resolver.Len(); n != 1 {
+		t.Errorf("expected 1 entry; got %d", n)
+	}
+}
+
+func TestCacheResolverOnQuery(t *testing.T) {
+	errNotFound := errors.New("not found")
+	var queries []Query
+	resolver := &CacheResolver{
+		Resolver: withTXT{resolverFunc(func(host string) ([]net.IP, error) {
+			if host == "bad.com" {
+				return nil, errNotFound
+			}
+			return []net.IP{net.IPv6loopback}, nil
+		}), []string{"v=spf1 -all"}},
+		OnQuery: func(q Query) { queries = append(queries, q) },
+	}
+	resolver.Resolve("foo.com")
+	resolver.Resolve("foo.com")
+	resolver.Resolve("bad.com")
+	resolver.ResolveTXT(context.Background(), "foo.com")
+
+	want := []Query{
+		{Name: "foo.com", Type: "IP"},
+		{Name: "foo.com", Type: "IP", Cached: true},
+		{Name: "bad.com", Type: "IP", Err: errNotFound},
+		{Name: "foo.com", Type: "TXT"},
+	}
+	for i := range queries {
+		if queries[i].Duration < 0 {
+			t.Errorf("%d: unexpected negative duration: %v", i, queries[i].Duration)
+		}
+		queries[i].Duration = 0
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("expected queries %+v; got %+v", want, queries)
+	}
+}
+
+func TestCacheResolverShardedMaxEntries(t *testing.T) {
+	resolver := &CacheResolver{
+		Resolver:   staticResolver{net.IPv6loopback},
+		MaxEntries: 1000,
+	}
+	if n := len(resolver.allShards()); n != 1000/minShardEntries {
+		t.Fatalf("expected %d shards; got %d", 1000/minShardEntries, n)
+	}
+	for i := 0; i < 5000; i++ {
+		resolver.Resolve(fmt.Sprintf("host%d.com", i))
+	}
+	if n := resolver.Len(); n > 1000 || n < 900 {
+		t.Errorf("expected about 1000 entries; got %d", n)
+	}
+	if s := resolver.Stats(); s.Evictions != int64(5000-s.Entries) {
+		t.Errorf("expected %d evictions; got %d", 5000-s.Entries, s.Evictions)
+	}
+}
+
+// benchmarkCacheResolver measures lookups of cached hosts by about
+// 10,000 concurrent goroutines.
+func benchmarkCacheResolver(b *testing.B, resolver *CacheResolver) {
+	hosts := make([]string, 1024)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("host%d.com", i)
+		resolver.Resolve(hosts[i])
+	}
+	b.SetParallelism(10000/runtime.GOMAXPROCS(0) + 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(hosts))
+		for pb.Next() {
+			if _, err := resolver.Resolve(hosts[i%len(hosts)]); err != nil {
+				b.Error(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheResolver(b *testing.B) {
+	benchmarkCacheResolver(b, &CacheResolver{Resolver: staticResolver{net.IPv6loopback}})
+}
+
+func BenchmarkCacheResolverMaxEntries(b *testing.B) {
+	benchmarkCacheResolver(b, &CacheResolver{Resolver: staticResolver{net.IPv6loopback}, MaxEntries: 4096})
+}
+
+func TestCacheResolverResolveTTL(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+
+	lookups := 0
+	resolver := &CacheResolver{
+		Resolver: ttlResolver{ips: []net.IP{net.IPv6loopback}, ttl: 10 * time.Second, lookups: &lookups},
+	}
+	tests := []struct {
+		elapsed time.Duration
+		ttl     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{4 * time.Second, 6 * time.Second},
+		{10 * time.Second, 10 * time.Second}, // expired and resolved again
+	}
+	for _, tt := range tests {
+		now = start.Add(tt.elapsed)
+		_, ttl, err := ResolveWithTTL(resolver, "foo.com")
+		if err != nil {
+			t.Fatalf("ResolveWithTTL failed: %v", err)
+		}
+		if ttl != tt.ttl {
+			t.Errorf("after %v: expected TTL %v; got %v", tt.elapsed, tt.ttl, ttl)
+		}
+	}
+
+	if _, ttl, _ := ResolveWithTTL(&CacheResolver{Resolver: staticResolver{net.IPv6loopback}}, "foo.com"); ttl >= 0 {
+		t.Errorf("expected negative TTL for hosts that don't expire; got %v", ttl)
+	}
+}
+
+// familyTTLResolver resolves every host to the IPs of each family with
+// the TTL of the family, counting the lookups of each.
+type familyTTLResolver struct {
+	ip4, ip6   net.IP
+	ttl4, ttl6 time.Duration
+	lookups    map[string]int
+}
+
+func (r familyTTLResolver) Resolve(host string) ([]net.IP, error) {
+	r.lookups["ip"]++
+	return []net.IP{r.ip4, r.ip6}, nil
+}
+
+func (r familyTTLResolver) ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	r.lookups[network]++
+	if network == "ip6" {
+		return []net.IP{r.ip6}, r.ttl6, nil
+	}
+	return []net.IP{r.ip4}, r.ttl4, nil
+}
+
+func TestCacheResolverFamily(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	start := time.Now()
+	now := start
+	timeNow = func() time.Time { return now }
+
+	inner := familyTTLResolver{
+		ip4:     net.IPv4(127, 0, 0, 1).To4(),
+		ip6:     net.IPv6loopback,
+		ttl4:    10 * time.Second,
+		ttl6:    30 * time.Second,
+		lookups: make(map[string]int),
+	}
+	resolver := &CacheResolver{Resolver: inner, TTL: time.Minute}
+	ctx := context.Background()
+	for _, tt := range []struct {
+		network string
+		ip      net.IP
+		ttl     time.Duration
+	}{
+		{"ip4", inner.ip4, 10 * time.Second},
+		{"ip6", inner.ip6, 30 * time.Second},
+	} {
+		ips, ttl, err := resolver.ResolveFamily(ctx, tt.network, "foo.com")
+		if err != nil {
+			t.Fatalf("ResolveFamily(%q) failed: %v", tt.network, err)
+		}
+		if !reflect.DeepEqual(ips, []net.IP{tt.ip}) || ttl != tt.ttl {
+			t.Errorf("ResolveFamily(%q): expected %v for %v; got %v for %v", tt.network, tt.ip, tt.ttl, ips, ttl)
+		}
+	}
+	if ips, err := resolver.Resolve("foo.com"); err != nil || len(ips) != 2 {
+		t.Errorf("Resolve: expected both addresses; got %v, %v", ips, err)
+	}
+	if n := resolver.Len(); n != 3 {
+		t.Errorf("expected 3 entries; got %d", n)
+	}
+
+	// The IPv4 addresses expire before the IPv6 addresses.
+	now = start.Add(15 * time.Second)
+	for _, network := range []string{"ip4", "ip6", "ip4", "ip6"} {
+		resolver.ResolveFamily(ctx, network, "foo.com")
+	}
+	resolver.Resolve("foo.com")
+	want := map[string]int{"ip": 1, "ip4": 2, "ip6": 1}
+	if !reflect.DeepEqual(inner.lookups, want) {
+		t.Errorf("expected lookups %v; got %v", want, inner.lookups)
+	}
+
+	resolver.Remove("foo.com")
+	if n := resolver.Len(); n != 0 {
+		t.Errorf("expected Remove to evict every family; %d entries remain", n)
+	}
+}
+
+func TestCacheResolverPrefetchFamily(t *testing.T) {
+	inner := familyTTLResolver{
+		ip4:     net.IPv4(127, 0, 0, 1).To4(),
+		ip6:     net.IPv6loopback,
+		ttl4:    time.Minute,
+		ttl6:    time.Minute,
+		lookups: make(map[string]int),
+	}
+	resolver := &CacheResolver{Resolver: inner}
+	ctx := context.Background()
+	if _, _, err := resolver.ResolveFamily(ctx, "ip6", "foo.com"); err != nil {
+		t.Fatalf("ResolveFamily failed: %v", err)
+	}
+	// The cached IPv6 addresses are refreshed with the host's
+	// addresses, but the IPv4 addresses, which aren't cached, are
+	// not looked up.
+	if err := resolver.Prefetch(ctx, "foo.com"); err != nil {
+		t.Fatalf("Prefetch failed: %v", err)
+	}
+	if want := map[string]int{"ip": 1, "ip6": 2}; !reflect.DeepEqual(inner.lookups, want) {
+		t.Errorf("expected lookups %v; got %v", want, inner.lookups)
+	}
+	if n := resolver.Len(); n != 2 {
+		t.Errorf("expected 2 entries; got %d", n)
+	}
+}
+
+func TestCacheResolverFamilyFilter(t *testing.T) {
+	resolver := &CacheResolver{Resolver: staticResolver{net.IPv6loopback}}
+	if _, err := resolver.Resolve("foo.com"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	// The cached AAAA-only answer isn't served for IPv4.
+	_, _, err := resolver.ResolveFamily(context.Background(), "ip4", "foo.com")
+	var derr *net.DNSError
+	if !errors.As(err, &derr) || !derr.IsNotFound {
+		t.Errorf("ResolveFamily(\"ip4\"): expected not found error; got %v", err)
+	}
+	ips, _, err := resolver.ResolveFamily(context.Background(), "ip6", "foo.com")
+	if err != nil || !reflect.DeepEqual(ips, []net.IP{net.IPv6loopback}) {
+		t.Errorf("ResolveFamily(\"ip6\"): expected %v; got %v, %v", net.IPv6loopback, ips, err)
+	}
+}
+
+func TestDialerCacheFamily(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var (
+		mu    sync.Mutex
+		types []string
+	)
+	d := &Dialer{Resolver: &CacheResolver{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		OnQuery: func(q Query) {
+			mu.Lock()
+			types = append(types, q.Type)
+			mu.Unlock()
+		},
+	}}
+	for _, network := range []string{"tcp4", "tcp"} {
+		c, err := d.Dial(network, net.JoinHostPort("foo.com", port))
+		if err != nil {
+			t.Fatalf("Dial(%q) failed: %v", network, err)
+		}
+		c.Close()
+	}
+	if want := []string{"IP4", "IP"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("expected lookups of types %v; got %v", want, types)
+	}
+}
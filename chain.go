@@ -0,0 +1,116 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// ChainResolver resolves hosts with the first of its resolvers to
+// succeed, such as a DoHResolver falling back to DefaultResolver.
+// It satisfies TTLResolver.
+type ChainResolver struct {
+	// Resolvers are tried in order until one succeeds.
+	Resolvers []Resolver
+
+	// Concurrent queries all of the resolvers at once and
+	// returns the first successful result, instead of trying
+	// them in turn. With ResolveContext, the other lookups are
+	// canceled once one succeeds.
+	Concurrent bool
+}
+
+// ChainResolvers returns a ChainResolver that tries the resolvers in
+// order until one succeeds.
+func ChainResolvers(resolvers ...Resolver) *ChainResolver {
+	return &ChainResolver{Resolvers: resolvers}
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+// If every resolver fails, the error of the last is returned.
+func (r *ChainResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses and
+// their time to live, if the resolver that succeeded reports it.
+func (r *ChainResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	return r.first(host, func(resolver Resolver) ([]net.IP, time.Duration, error) {
+		return resolveTTL(resolver, host)
+	})
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *ChainResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ips, _, err := r.first(host, func(resolver Resolver) ([]net.IP, time.Duration, error) {
+		ips, err := resolveContext(ctx, resolver, host)
+		return ips, -1, err
+	})
+	return ips, err
+}
+
+// firstLookup calls lookup with each of the resolvers in turn until
+// one succeeds. If none do, it returns the error of the last resolver
+// that supports the lookup, if any.
+func (r *ChainResolver) firstLookup(name string, lookup func(Resolver) error) error {
+	if len(r.Resolvers) == 0 {
+		return &net.DNSError{Err: "no resolvers", Name: name}
+	}
+	var err error
+	for _, resolver := range r.Resolvers {
+		lerr := lookup(resolver)
+		if lerr == nil {
+			return nil
+		}
+		if err == nil || !errors.Is(lerr, ErrUnsupportedLookup) {
+			err = lerr
+		}
+	}
+	return err
+}
+
+type resolveResult struct {
+	ips []net.IP
+	ttl time.Duration
+	err error
+}
+
+// first returns the first successful result of looking up host with
+// each of the resolvers using fn.
+func (r *ChainResolver) first(host string, fn func(Resolver) ([]net.IP, time.Duration, error)) ([]net.IP, time.Duration, error) {
+	if len(r.Resolvers) == 0 {
+		return nil, 0, &net.DNSError{Err: "no resolvers", Name: host}
+	}
+	if !r.Concurrent {
+		var res resolveResult
+		for _, resolver := range r.Resolvers {
+			if res.ips, res.ttl, res.err = fn(resolver); res.err == nil {
+				return res.ips, res.ttl, nil
+			}
+		}
+		return nil, 0, res.err
+	}
+	results := make(chan resolveResult, len(r.Resolvers))
+	for _, resolver := range r.Resolvers {
+		go func(resolver Resolver) {
+			ips, ttl, err := fn(resolver)
+			results <- resolveResult{ips, ttl, err}
+		}(resolver)
+	}
+	var res resolveResult
+	for range r.Resolvers {
+		if res = <-results; res.err == nil {
+			return res.ips, res.ttl, nil
+		}
+	}
+	return nil, 0, res.err
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChainResolver(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	failing := resolverFunc(func(string) ([]net.IP, error) { return nil, errLookup })
+	ips := []net.IP{net.IPv6loopback}
+
+	for _, concurrent := range []bool{false, true} {
+		r := ChainResolvers(failing, staticResolver(ips))
+		r.Concurrent = concurrent
+		got, err := r.Resolve("foo.com")
+		if err != nil {
+			t.Fatalf("Concurrent %v: Resolve failed: %v", concurrent, err)
+		}
+		if !reflect.DeepEqual(got, ips) {
+			t.Errorf("Concurrent %v: expected %v; got %v", concurrent, ips, got)
+		}
+
+		r = ChainResolvers(failing, failing)
+		r.Concurrent = concurrent
+		if _, err := r.Resolve("foo.com"); err != errLookup {
+			t.Errorf("Concurrent %v: expected %v; got %v", concurrent, errLookup, err)
+		}
+	}
+}
+
+// canceledResolver waits for the context of a lookup to be done and
+// then closes itself.
+type canceledResolver chan struct{}
+
+func (canceledResolver) Resolve(host string) ([]net.IP, error) {
+	return nil, errors.New("unexpected Resolve")
+}
+
+func (r canceledResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	<-ctx.Done()
+	close(r)
+	return nil, ctx.Err()
+}
+
+func TestChainResolverCancelLosers(t *testing.T) {
+	ips := []net.IP{net.IPv6loopback}
+	loser := make(canceledResolver)
+	r := ChainResolvers(loser, staticResolver(ips))
+	r.Concurrent = true
+	got, err := r.ResolveContext(context.Background(), "foo.com")
+	if err != nil {
+		t.Fatalf("ResolveContext failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, ips) {
+		t.Errorf("expected %v; got %v", ips, got)
+	}
+	select {
+	case <-loser:
+	case <-time.After(5 * time.Second):
+		t.Error("the losing lookup wasn't canceled")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A Config holds the settings of a Dialer and its Resolver in a form
+// that may be read from a configuration file, so that operators may
+// tune them without changing code. Its zero value configures the zero
+// Dialer. Fields are tagged for both JSON and YAML, and durations are
+// written as strings such as "1.5s".
+type Config struct {
+	// Timeout, KeepAlive, FallbackDelay, FastFallback and
+	// HappyEyeballs set the fields of the Dialer of the same name.
+	Timeout       Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	KeepAlive     Duration `json:"keepAlive,omitempty" yaml:"keepAlive,omitempty"`
+	FallbackDelay Duration `json:"fallbackDelay,omitempty" yaml:"fallbackDelay,omitempty"`
+	FastFallback  bool     `json:"fastFallback,omitempty" yaml:"fastFallback,omitempty"`
+	HappyEyeballs bool     `json:"happyEyeballs,omitempty" yaml:"happyEyeballs,omitempty"`
+
+	// Filter is the address selection policy of the Dialer's
+	// IPFilter, as parsed by ParseAddrsFilter, such as
+	// "ipv4|max(2)". If empty, the IPFilter is nil.
+	Filter string `json:"filter,omitempty" yaml:"filter,omitempty"`
+
+	// Resolver configures the Dialer's Resolver.
+	Resolver ResolverConfig `json:"resolver" yaml:"resolver"`
+}
+
+// A ResolverConfig holds the settings of a Resolver.
+type ResolverConfig struct {
+	// Type is the type of the Resolver:
+	//
+	//	system  DefaultResolver
+	//	dns     DNSResolver with Upstreams as its Servers
+	//	doh     DoHResolver for each of the URLs of Upstreams
+	//	dot     DoTResolver for each of the addresses of Upstreams
+	//
+	// If empty, system is used. If there are several DoH or DoT
+	// servers, they are tried in order by a ChainResolver.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Upstreams holds the servers queried by the Resolver. It
+	// must be empty for the system resolver, and may be empty
+	// for a DNSResolver to use the system's nameservers.
+	Upstreams []string `json:"upstreams,omitempty" yaml:"upstreams,omitempty"`
+
+	// Timeout sets the Timeout of the DNS resolvers.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Cache, if non-nil, caches the lookups of the Resolver with
+	// a CacheResolver.
+	Cache *CacheConfig `json:"cache,omitempty" yaml:"cache,omitempty"`
+}
+
+// A CacheConfig holds the settings of a CacheResolver, which set the
+// fields of the same name.
+type CacheConfig struct {
+	TTL          Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	MinTTL       Duration `json:"minTTL,omitempty" yaml:"minTTL,omitempty"`
+	MaxTTL       Duration `json:"maxTTL,omitempty" yaml:"maxTTL,omitempty"`
+	NegativeTTL  Duration `json:"negativeTTL,omitempty" yaml:"negativeTTL,omitempty"`
+	MaxStale     Duration `json:"maxStale,omitempty" yaml:"maxStale,omitempty"`
+	RefreshAhead Duration `json:"refreshAhead,omitempty" yaml:"refreshAhead,omitempty"`
+	MaxEntries   int      `json:"maxEntries,omitempty" yaml:"maxEntries,omitempty"`
+}
+
+// A Duration is a time.Duration that is encoded as text in the format
+// of time.Duration's String method, such as "1m30s", and decoded as
+// by time.ParseDuration.
+type Duration time.Duration
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads a Config encoded as JSON from rd. Unknown fields
+// are rejected, so that misspelled settings are not silently ignored.
+func LoadConfig(rd io.Reader) (*Config, error) {
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return &cfg, nil
+}
+
+// BuildDialer returns a Dialer configured by cfg, or an error if its
+// settings are invalid.
+func BuildDialer(cfg *Config) (*Dialer, error) {
+	d := &Dialer{
+		Timeout:       time.Duration(cfg.Timeout),
+		KeepAlive:     time.Duration(cfg.KeepAlive),
+		FallbackDelay: time.Duration(cfg.FallbackDelay),
+		FastFallback:  cfg.FastFallback,
+		HappyEyeballs: cfg.HappyEyeballs,
+	}
+	if cfg.Filter != "" {
+		filter, err := ParseAddrsFilter(cfg.Filter)
+		if err != nil {
+			return nil, err
+		}
+		d.IPFilter = filter
+	}
+	r, err := cfg.Resolver.build()
+	if err != nil {
+		return nil, err
+	}
+	d.Resolver = r
+	return d, nil
+}
+
+// build returns the Resolver configured by c, or nil for an uncached
+// DefaultResolver.
+func (c *ResolverConfig) build() (Resolver, error) {
+	timeout := time.Duration(c.Timeout)
+	var r Resolver
+	switch c.Type {
+	case "", "system":
+		if len(c.Upstreams) > 0 {
+			return nil, errors.New("invalid resolver config: the system resolver has no upstreams")
+		}
+	case "dns":
+		r = &DNSResolver{Servers: c.Upstreams, Timeout: timeout}
+	case "doh", "dot":
+		if len(c.Upstreams) == 0 {
+			return nil, fmt.Errorf("invalid resolver config: %s resolver has no upstreams", c.Type)
+		}
+		rs := make([]Resolver, len(c.Upstreams))
+		for i, upstream := range c.Upstreams {
+			if c.Type == "doh" {
+				rs[i] = &DoHResolver{URL: upstream, Timeout: timeout}
+			} else {
+				rs[i] = &DoTResolver{Address: upstream, Timeout: timeout}
+			}
+		}
+		if r = rs[0]; len(rs) > 1 {
+			r = ChainResolvers(rs...)
+		}
+	default:
+		return nil, fmt.Errorf("invalid resolver config: unknown type %q", c.Type)
+	}
+	if cc := c.Cache; cc != nil {
+		r = &CacheResolver{
+			Resolver:     r,
+			TTL:          time.Duration(cc.TTL),
+			MinTTL:       time.Duration(cc.MinTTL),
+			MaxTTL:       time.Duration(cc.MaxTTL),
+			NegativeTTL:  time.Duration(cc.NegativeTTL),
+			MaxStale:     time.Duration(cc.MaxStale),
+			RefreshAhead: time.Duration(cc.RefreshAhead),
+			MaxEntries:   cc.MaxEntries,
+		}
+	}
+	return r, nil
+}
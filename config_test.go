@@ -0,0 +1,116 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`{
+		"timeout": "5s",
+		"keepAlive": "30s",
+		"fallbackDelay": "-1ns",
+		"happyEyeballs": true,
+		"filter": "ipv4|max(2)",
+		"resolver": {
+			"type": "doh",
+			"upstreams": ["https://dns.google/dns-query", "https://cloudflare-dns.com/dns-query"],
+			"timeout": "2s",
+			"cache": {"ttl": "1m", "maxStale": "10m", "maxEntries": 1000}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	d, err := BuildDialer(cfg)
+	if err != nil {
+		t.Fatalf("BuildDialer failed: %v", err)
+	}
+	if d.Timeout != 5*time.Second || d.KeepAlive != 30*time.Second || d.FallbackDelay != -1 || !d.HappyEyeballs {
+		t.Errorf("unexpected Dialer: %+v", d)
+	}
+	ips := parseIPs("192.0.2.1", "2001:db8::1", "192.0.2.2", "192.0.2.3")
+	if got, want := d.IPFilter(ips), parseIPs("192.0.2.1", "192.0.2.2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPFilter: expected %v; got %v", want, got)
+	}
+	cache, ok := d.Resolver.(*CacheResolver)
+	if !ok {
+		t.Fatalf("expected a *CacheResolver; got %T", d.Resolver)
+	}
+	if cache.TTL != time.Minute || cache.MaxStale != 10*time.Minute || cache.MaxEntries != 1000 {
+		t.Errorf("unexpected CacheResolver: %+v", cache)
+	}
+	chain, ok := cache.Resolver.(*ChainResolver)
+	if !ok || len(chain.Resolvers) != 2 {
+		t.Fatalf("expected a ChainResolver of 2 resolvers; got %#v", cache.Resolver)
+	}
+	if r, ok := chain.Resolvers[1].(*DoHResolver); !ok || r.URL != "https://cloudflare-dns.com/dns-query" || r.Timeout != 2*time.Second {
+		t.Errorf("unexpected second resolver: %#v", chain.Resolvers[1])
+	}
+
+	// The config is encoded as it was decoded.
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	cfg2, err := LoadConfig(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatalf("LoadConfig of %s failed: %v", b, err)
+	}
+	if !reflect.DeepEqual(cfg, cfg2) {
+		t.Errorf("expected %+v; got %+v", cfg, cfg2)
+	}
+}
+
+func TestBuildDialer(t *testing.T) {
+	tests := []struct {
+		cfg      Config
+		resolver Resolver
+	}{
+		{Config{}, nil},
+		{Config{Resolver: ResolverConfig{Type: "system", Cache: &CacheConfig{}}}, &CacheResolver{}},
+		{
+			Config{Resolver: ResolverConfig{Type: "dns", Upstreams: []string{"192.0.2.53"}}},
+			&DNSResolver{Servers: []string{"192.0.2.53"}},
+		},
+		{
+			Config{Resolver: ResolverConfig{Type: "dot", Upstreams: []string{"dns.google:853"}, Timeout: Duration(time.Second)}},
+			&DoTResolver{Address: "dns.google:853", Timeout: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		d, err := BuildDialer(&tt.cfg)
+		if err != nil {
+			t.Errorf("BuildDialer(%+v) failed: %v", tt.cfg, err)
+			continue
+		}
+		if !reflect.DeepEqual(d.Resolver, tt.resolver) {
+			t.Errorf("BuildDialer(%+v): expected Resolver %#v; got %#v", tt.cfg, tt.resolver, d.Resolver)
+		}
+	}
+}
+
+func TestBuildDialerErrors(t *testing.T) {
+	for _, cfg := range []Config{
+		{Filter: "ipv5"},
+		{Resolver: ResolverConfig{Type: "ldap"}},
+		{Resolver: ResolverConfig{Type: "doh"}},
+		{Resolver: ResolverConfig{Upstreams: []string{"192.0.2.53"}}},
+	} {
+		if _, err := BuildDialer(&cfg); err == nil {
+			t.Errorf("BuildDialer(%+v): expected error", cfg)
+		}
+	}
+	for _, s := range []string{`{"timeout": 5}`, `{"timeout": "5 s"}`, `{"timout": "5s"}`} {
+		if _, err := LoadConfig(strings.NewReader(s)); err == nil {
+			t.Errorf("LoadConfig(%s): expected error", s)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats holds the counters of a connection wrapped by WrapStats.
+type ConnStats struct {
+	BytesRead    int64
+	BytesWritten int64
+	Reads        int64 // calls to Read
+	Writes       int64 // calls to Write
+
+	// FirstByte is the time from when the connection was wrapped
+	// until the first byte was read, or zero if none has been.
+	FirstByte time.Duration
+
+	// Duration is the time from when the connection was wrapped
+	// until it was closed, or until now if it is open.
+	Duration time.Duration
+}
+
+// A ConnRecorder is a Recorder that also records the counters of
+// each connection established by a Dialer. If the Recorder of a
+// Dialer is a ConnRecorder, the Dialer's connections are wrapped by
+// WrapStats.
+type ConnRecorder interface {
+	Recorder
+
+	// ConnClosed is called once a connection to address on the
+	// network passed to Dial is closed.
+	ConnClosed(network, address string, stats ConnStats)
+}
+
+// A StatsConn is a net.Conn that counts the bytes and operations of
+// the connection it wraps.
+type StatsConn struct {
+	net.Conn
+
+	start         time.Time
+	read, written atomic.Int64
+	reads, writes atomic.Int64
+	firstByte     atomic.Int64 // as a time.Duration
+	closeOnce     sync.Once
+	closed        atomic.Int64 // duration, once closed
+	done          func(ConnStats)
+}
+
+// WrapStats returns c wrapped to count its bytes and operations. If
+// done is non-nil, it is called with the final counters the first
+// time the connection is closed.
+func WrapStats(c net.Conn, done func(stats ConnStats)) *StatsConn {
+	return &StatsConn{Conn: c, start: time.Now(), done: done}
+}
+
+// Stats returns the current counters of the connection.
+func (c *StatsConn) Stats() ConnStats {
+	d := time.Duration(c.closed.Load())
+	if d == 0 {
+		d = time.Since(c.start)
+	}
+	return ConnStats{
+		BytesRead:    c.read.Load(),
+		BytesWritten: c.written.Load(),
+		Reads:        c.reads.Load(),
+		Writes:       c.writes.Load(),
+		FirstByte:    time.Duration(c.firstByte.Load()),
+		Duration:     d,
+	}
+}
+
+func (c *StatsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.reads.Add(1)
+	if n > 0 {
+		if c.read.Add(int64(n)) == int64(n) {
+			c.firstByte.Store(int64(time.Since(c.start)))
+		}
+	}
+	return n, err
+}
+
+func (c *StatsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.writes.Add(1)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+func (c *StatsConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.closed.Store(int64(time.Since(c.start)))
+		if c.done != nil {
+			c.done(c.Stats())
+		}
+	})
+	return err
+}
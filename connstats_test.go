@@ -0,0 +1,79 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestWrapStats(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	var final ConnStats
+	c := WrapStats(a, func(stats ConnStats) { final = stats })
+	go func() {
+		b.Write([]byte("hello"))
+		io.Copy(io.Discard, b)
+	}()
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	c.Write([]byte("hi"))
+	c.Write([]byte("there"))
+	stats := c.Stats()
+	if stats.BytesRead != 5 || stats.BytesWritten != 7 || stats.Writes != 2 || stats.Reads == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if stats.FirstByte <= 0 || stats.FirstByte > stats.Duration {
+		t.Errorf("unexpected first byte latency %v", stats.FirstByte)
+	}
+	c.Close()
+	c.Close()
+	if final.BytesWritten != 7 || final.Duration <= 0 {
+		t.Errorf("unexpected final stats: %+v", final)
+	}
+}
+
+// connRecorder is a ConnRecorder that records its events.
+type connRecorder struct {
+	eventRecorder
+}
+
+func (r *connRecorder) ConnClosed(network, address string, stats ConnStats) {
+	r.add("ConnClosed %s %s %d %d", network, address, stats.BytesRead, stats.BytesWritten)
+}
+
+func TestConnRecorder(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c.Write([]byte("hello"))
+		c.Close()
+	}()
+
+	rec := &connRecorder{}
+	d := &Dialer{Recorder: rec}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Write([]byte("x"))
+	io.ReadAll(c)
+	c.Close()
+	want := "ConnClosed tcp " + ln.Addr().String() + " 5 1"
+	if n := len(rec.events); n == 0 || rec.events[n-1] != want {
+		t.Errorf("expected %q; got %q", want, rec.events)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters is a Recorder that counts lookups and dial attempts, for
+// systems that want totals rather than individual events.
+//
+// Counters are safe for concurrent use by multiple goroutines.
+type Counters struct {
+	lookups      atomic.Int64
+	lookupErrors atomic.Int64
+	lookupTime   atomic.Int64 // nanoseconds
+	cacheHits    atomic.Int64
+	cacheMisses  atomic.Int64
+	dialAttempts atomic.Int64
+	dialErrors   atomic.Int64
+	dialTime     atomic.Int64 // nanoseconds
+}
+
+// A CountersSnapshot holds the values of Counters at a point in time.
+type CountersSnapshot struct {
+	Lookups      int64         // host names resolved
+	LookupErrors int64         // lookups that failed
+	LookupTime   time.Duration // total time spent in lookups
+	CacheHits    int64         // CacheResolver lookups served from the cache
+	CacheMisses  int64         // CacheResolver lookups not served from the cache
+	DialAttempts int64         // addresses dialed
+	DialErrors   int64         // dial attempts that failed
+	DialTime     time.Duration // total time spent in dial attempts
+}
+
+// Lookup counts a lookup.
+func (c *Counters) Lookup(host string, d time.Duration, err error) {
+	c.lookups.Add(1)
+	c.lookupTime.Add(int64(d))
+	if err != nil {
+		c.lookupErrors.Add(1)
+	}
+}
+
+// CacheLookup counts a cache hit or miss.
+func (c *Counters) CacheLookup(host string, hit bool) {
+	if hit {
+		c.cacheHits.Add(1)
+	} else {
+		c.cacheMisses.Add(1)
+	}
+}
+
+// DialAttempt counts a dial attempt.
+func (c *Counters) DialAttempt(network, address string, d time.Duration, err error) {
+	c.dialAttempts.Add(1)
+	c.dialTime.Add(int64(d))
+	if err != nil {
+		c.dialErrors.Add(1)
+	}
+}
+
+// Snapshot returns the current values of the counters.
+func (c *Counters) Snapshot() CountersSnapshot {
+	return CountersSnapshot{
+		Lookups:      c.lookups.Load(),
+		LookupErrors: c.lookupErrors.Load(),
+		LookupTime:   time.Duration(c.lookupTime.Load()),
+		CacheHits:    c.cacheHits.Load(),
+		CacheMisses:  c.cacheMisses.Load(),
+		DialAttempts: c.dialAttempts.Load(),
+		DialErrors:   c.dialErrors.Load(),
+		DialTime:     time.Duration(c.dialTime.Load()),
+	}
+}
+
+var (
+	// globalCounters count the lookups and dials of every Dialer and
+	// CacheResolver once Publish has been called.
+	globalCounters Counters
+	published      atomic.Bool
+	publishOnce    sync.Once
+)
+
+// Publish starts counting the lookups and dial attempts of every
+// Dialer and CacheResolver, in addition to any Recorder they have,
+// and publishes the counters with expvar as "nett", so that they are
+// served by the /debug/vars HTTP handler. It may be called more than
+// once.
+func Publish() {
+	publishOnce.Do(func() {
+		published.Store(true)
+		expvar.Publish("nett", expvar.Func(func() any { return Snapshot() }))
+	})
+}
+
+// Snapshot returns the counters of every Dialer and CacheResolver,
+// which are zero unless Publish has been called, such as to export
+// them to other systems.
+func Snapshot() CountersSnapshot {
+	return globalCounters.Snapshot()
+}
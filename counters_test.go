@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCounters(t *testing.T) {
+	var c Counters
+	c.Lookup("foo.com", time.Millisecond, nil)
+	c.Lookup("bar.com", 2*time.Millisecond, errors.New("fail"))
+	c.CacheLookup("foo.com", true)
+	c.CacheLookup("bar.com", false)
+	c.CacheLookup("baz.com", false)
+	c.DialAttempt("tcp4", "192.0.2.1:80", 3*time.Millisecond, errors.New("fail"))
+	want := CountersSnapshot{
+		Lookups:      2,
+		LookupErrors: 1,
+		LookupTime:   3 * time.Millisecond,
+		CacheHits:    1,
+		CacheMisses:  2,
+		DialAttempts: 1,
+		DialErrors:   1,
+		DialTime:     3 * time.Millisecond,
+	}
+	if got := c.Snapshot(); got != want {
+		t.Errorf("expected %+v; got %+v", want, got)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	Publish()
+	Publish()
+	before := Snapshot()
+	d := &Dialer{Resolver: &CacheResolver{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}, TTL: time.Hour}}
+	for i := 0; i < 2; i++ {
+		c, err := d.Dial("tcp", net.JoinHostPort("foo.com", port))
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		c.Close()
+	}
+	after := Snapshot()
+	if n := after.Lookups - before.Lookups; n != 2 {
+		t.Errorf("expected 2 lookups; got %d", n)
+	}
+	if n := after.CacheHits - before.CacheHits; n != 1 {
+		t.Errorf("expected 1 cache hit; got %d", n)
+	}
+	if n := after.CacheMisses - before.CacheMisses; n != 1 {
+		t.Errorf("expected 1 cache miss; got %d", n)
+	}
+	if n := after.DialAttempts - before.DialAttempts; n != 2 {
+		t.Errorf("expected 2 dial attempts; got %d", n)
+	}
+
+	v := expvar.Get("nett")
+	if v == nil {
+		t.Fatal("expected nett to be published")
+	}
+	var snap CountersSnapshot
+	if err := json.Unmarshal([]byte(v.String()), &snap); err != nil {
+		t.Fatalf("invalid expvar: %v", err)
+	}
+	if snap.DialAttempts < after.DialAttempts {
+		t.Errorf("expected at least %d dial attempts; got %d", after.DialAttempts, snap.DialAttempts)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"time"
+)
+
+// WrapTimeouts returns c wrapped to set its read deadline to now plus
+// readTimeout before each Read, and its write deadline to now plus
+// writeTimeout before each Write, so that each operation fails with
+// a timeout if it cannot make progress rather than blocking forever.
+// A zero timeout leaves the deadlines of that direction unchanged.
+func WrapTimeouts(c net.Conn, readTimeout, writeTimeout time.Duration) net.Conn {
+	return &timeoutConn{Conn: c, read: readTimeout, write: writeTimeout}
+}
+
+type timeoutConn struct {
+	net.Conn
+	read, write time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	if c.read > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.read)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	if c.write > 0 {
+		if err := c.Conn.SetWriteDeadline(time.Now().Add(c.write)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(b)
+}
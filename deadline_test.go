@@ -0,0 +1,40 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWrapTimeouts(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapTimeouts(a, 50*time.Millisecond, 20*time.Millisecond)
+	defer c.Close()
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(30 * time.Millisecond)
+			b.Write([]byte("x"))
+		}
+	}()
+	// Each read gets its own deadline, so the reads succeed despite
+	// taking longer than the timeout in total.
+	for i := 0; i < 3; i++ {
+		if _, err := c.Read(make([]byte, 1)); err != nil {
+			t.Fatalf("Read %d failed: %v", i, err)
+		}
+	}
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read: expected os.ErrDeadlineExceeded; got %v", err)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write: expected os.ErrDeadlineExceeded; got %v", err)
+	}
+}
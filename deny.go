@@ -0,0 +1,89 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"sync"
+)
+
+// A DenyList is a set of IP addresses and networks that must not be
+// dialed, such as those of known malicious hosts. Its Filter method
+// discards them and may be used as, or composed into, an IPFilter.
+// It may be updated while in use, such as when threat intelligence
+// changes.
+//
+// A DenyList is safe for concurrent use by multiple goroutines.
+type DenyList struct {
+	mu   sync.RWMutex
+	ips  map[string]bool // by IP address
+	nets []*net.IPNet
+}
+
+// NewDenyList returns a DenyList of the given addresses and networks.
+func NewDenyList(ips []net.IP, nets []*net.IPNet) *DenyList {
+	l := &DenyList{}
+	l.Set(ips, nets)
+	return l
+}
+
+// Set replaces the addresses and networks in the list.
+func (l *DenyList) Set(ips []net.IP, nets []*net.IPNet) {
+	m := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		m[ip.String()] = true
+	}
+	nets = append([]*net.IPNet(nil), nets...)
+	l.mu.Lock()
+	l.ips, l.nets = m, nets
+	l.mu.Unlock()
+}
+
+// Add adds ip to the list.
+func (l *DenyList) Add(ip net.IP) {
+	l.mu.Lock()
+	if l.ips == nil {
+		l.ips = make(map[string]bool)
+	}
+	l.ips[ip.String()] = true
+	l.mu.Unlock()
+}
+
+// AddNet adds the network n to the list.
+func (l *DenyList) AddNet(n *net.IPNet) {
+	l.mu.Lock()
+	l.nets = append(l.nets, n)
+	l.mu.Unlock()
+}
+
+// Remove removes ip from the list. It does not remove
+// networks that contain ip.
+func (l *DenyList) Remove(ip net.IP) {
+	l.mu.Lock()
+	delete(l.ips, ip.String())
+	l.mu.Unlock()
+}
+
+// Denied reports whether ip is in the list or any of its networks.
+func (l *DenyList) Denied(ip net.IP) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.denied(ip)
+}
+
+func (l *DenyList) denied(ip net.IP) bool {
+	return l.ips[ip.String()] || containsIP(l.nets, ip)
+}
+
+// Filter returns the addresses in ips that are not denied,
+// preserving their order.
+func (l *DenyList) Filter(ips []net.IP) []net.IP {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.ips) == 0 && len(l.nets) == 0 {
+		return ips
+	}
+	return selectIPs(ips, func(ip net.IP) bool { return !l.denied(ip) })
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestDenyList(t *testing.T) {
+	in := parseIPs("192.0.2.1", "192.0.2.2", "198.51.100.7", "2001:db8::1", "2001:db8::2")
+	l := NewDenyList(parseIPs("192.0.2.2", "2001:db8::1"), parseCIDRs(t, "198.51.100.0/24"))
+	tests := []struct {
+		desc   string
+		update func()
+		want   []net.IP
+	}{
+		{
+			desc:   "initial",
+			update: func() {},
+			want:   parseIPs("192.0.2.1", "2001:db8::2"),
+		},
+		{
+			desc:   "add",
+			update: func() { l.Add(net.ParseIP("192.0.2.1")) },
+			want:   parseIPs("2001:db8::2"),
+		},
+		{
+			desc:   "remove",
+			update: func() { l.Remove(net.ParseIP("2001:db8::1")) },
+			want:   parseIPs("2001:db8::1", "2001:db8::2"),
+		},
+		{
+			desc:   "add net",
+			update: func() { l.AddNet(parseCIDRs(t, "2001:db8::/64")[0]) },
+			want:   nil,
+		},
+		{
+			desc:   "set",
+			update: func() { l.Set(parseIPs("192.0.2.1"), nil) },
+			want:   parseIPs("192.0.2.2", "198.51.100.7", "2001:db8::1", "2001:db8::2"),
+		},
+	}
+	for _, tt := range tests {
+		tt.update()
+		if got := l.Filter(in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v; got %v", tt.desc, tt.want, got)
+		}
+	}
+	if !l.Denied(net.IPv4(192, 0, 2, 1)) {
+		t.Error("expected IPv4-mapped address to be denied")
+	}
+}
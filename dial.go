@@ -5,13 +5,28 @@
 package nett
 
 import (
+	"context"
+	"errors"
 	"net"
+	"strconv"
+	"syscall"
 	"time"
 )
 
-var errTimeout = error(&timeoutError{})
+var (
+	errTimeout  = error(&timeoutError{})
+	errCanceled = errors.New("operation was canceled")
+
+	// errRaceWon is the cause of the cancellation of the attempts of
+	// a race once it is decided.
+	errRaceWon = errors.New("nett: race won")
+)
 
 // A Dialer contains options for connecting to an address.
+//
+// A *Dialer satisfies the Dialer and ContextDialer interfaces of
+// golang.org/x/net/proxy, so it may be used as the forward dialer
+// of a proxy chain or passed to libraries accepting either one.
 type Dialer struct {
 	// Timeout is the maximum amount of time a dial will wait for
 	// a connect to complete. If Deadline is also set, it may fail
@@ -25,7 +40,9 @@ type Dialer struct {
 	Timeout time.Duration
 
 	// Deadline is the absolute point in time after which dials
-	// will fail. If Timeout is set, it may fail earlier.
+	// will fail. If Timeout is set, it may fail earlier. The
+	// earliest of Deadline, now+Timeout and the deadline of the
+	// context passed to DialContext applies.
 	//
 	// Zero means no deadline, or dependent on the operating system
 	// as with the Timeout option.
@@ -35,34 +52,335 @@ type Dialer struct {
 	// address. The address must be of a compatible type for the
 	// network being dialed.
 	//
+	// If it specifies an IP address, only remote addresses of the
+	// same family are dialed. If none remain after resolving the
+	// host, the dial fails with ErrNoSuitableAddress.
+	//
 	// If nil, a local address is automatically chosen.
 	LocalAddr net.Addr
 
+	// Interface, if non-empty, is the name of the network
+	// interface, such as "eth0", to which the sockets of IP
+	// connections are bound, so that they leave through it
+	// regardless of the routing table. It uses SO_BINDTODEVICE on
+	// Linux, which may require the CAP_NET_RAW capability, and
+	// IP_BOUND_IF or IPV6_BOUND_IF on macOS. On other operating
+	// systems, dials fail.
+	//
+	// If the interface only has addresses of one family, only
+	// remote addresses of that family are dialed.
+	Interface string
+
+	// UseMultipathTCP dials TCP connections with Multipath TCP, as
+	// with net.Dialer's SetMultipathTCP, so that a connection may
+	// aggregate or fail over between several paths. If the
+	// operating system or the server doesn't support it, plain TCP
+	// is used. It requires Go 1.21 or later; otherwise it is
+	// ignored.
+	UseMultipathTCP bool
+
+	// FastOpen enables TCP Fast Open, so that the first write of
+	// a TCP connection to a server that has issued a cookie to
+	// this host is sent in the SYN, saving a round trip. The dial
+	// of such a server returns before the handshake, so if the
+	// connection is refused, its first write or read fails and
+	// other addresses are not tried. If the operating system
+	// doesn't support it, connections are made without it. It is
+	// only supported on Linux.
+	//
+	// DialEarlyData enables TCP Fast Open for a single dial
+	// without these caveats.
+	FastOpen bool
+
 	// Resolver is used to resolve IP addresses from domain names.
+	// If it is a ContextResolver, the context of each dial is
+	// passed to it. If it is a FamilyResolver, only the addresses
+	// of the family of a family-specific network are looked up.
 	//
 	// If nil, DefaultResolver will be used.
 	Resolver Resolver
 
 	// IPFilter selects addresses from those available after
-	// resolving a host to a set of supported IPs.
+	// resolving a host to a set of supported IPs, without
+	// duplicates. If the network is family-specific, such as
+	// "tcp4" or "udp6", only addresses of that family are
+	// available, so the IPFilter can't select addresses that
+	// the connect would reject.
 	//
 	// When dialing a TCP connection if multiple addresses are
 	// returned, then a connection will attempt to be established
 	// with each address and the first that succeeds will be returned.
-	// With any other type of connection, only the first address
-	// returned will be dialed.
+	// With any other type of connection, the addresses are dialed
+	// in order until one succeeds.
+	//
+	// If every attempt fails, the error is a *DialError recording
+	// the selected addresses and each attempt.
 	//
 	// If nil, a single address is selected.
 	IPFilter func(ips []net.IP) []net.IP
 
+	// NetAddrFilter, if non-nil, selects the addresses to dial from
+	// those selected by IPFilter once MapPort is applied, so that the
+	// selection may depend on their ports. It is given the addresses
+	// as *net.TCPAddr, *net.UDPAddr or *net.IPAddr values, matching
+	// the network, and returns those to dial in the order to dial
+	// them. Addresses of other types are ignored. If it selects none,
+	// the dial fails with ErrNoSuitableAddress. It is not called for
+	// Unix networks.
+	NetAddrFilter func(addrs []net.Addr) []net.Addr
+
+	// UnixFilter, if non-nil, reports whether the address of a
+	// Unix domain socket may be dialed, such as UnixPathPrefix.
+	// If it returns false, the dial fails with
+	// ErrNoSuitableAddress.
+	UnixFilter func(name string) bool
+
 	// KeepAlive specifies the keep-alive period for an active
-	// network connection.
+	// network connection. It is applied to every TCP connection
+	// established by the Dialer, as with net.Dialer.
 	//
-	// If zero, keep-alives are not enabled. Network protocols
+	// If zero, keep-alives are enabled with a default period
+	// if supported by the protocol and operating system. If
+	// negative, keep-alives are disabled. Network protocols
 	// that do not support keep-alives ignore this field.
-	//
-	// Only functional for go1.3+.
 	KeepAlive time.Duration
+
+	// Control is called after creating the network connection but
+	// before actually dialing, as with net.Dialer. It is called once
+	// per address attempted and can be used to set socket options
+	// such as SO_MARK or IP_TOS.
+	//
+	// Network and address parameters passed to Control function are
+	// not necessarily the ones passed to Dial. For example, passing
+	// "tcp" to Dial will cause the Control function to be called
+	// with "tcp4" or "tcp6", and the address will be the resolved
+	// IP address and port.
+	//
+	// If Control returns an error, the attempt fails.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// Cancel is an optional channel whose closure indicates that
+	// the dial should be canceled. It aborts resolution as well as
+	// connection attempts, for callers that cannot pass a context
+	// to DialContext.
+	Cancel <-chan struct{}
+
+	// Proxy, if non-nil, tunnels TCP connections through a proxy
+	// server. The proxy server itself is dialed using the Dialer's
+	// other options, and LocalAddr applies to the connection to the
+	// proxy server.
+	//
+	// Unless ProxyResolve is set, the host being dialed is resolved
+	// and filtered locally and the proxy server is asked to connect
+	// to each selected address.
+	Proxy Proxy
+
+	// ProxyFunc, if non-nil, selects the proxy server used to dial
+	// each address, taking precedence over Proxy. The network and
+	// address passed to Dial are passed to ProxyFunc. If it returns
+	// a nil Proxy, the address is dialed directly.
+	//
+	// ProxyFromEnvironment may be used to honor the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyFunc func(network, address string) (Proxy, error)
+
+	// ProxyResolve delegates resolving the host being dialed to the
+	// proxy server instead of using Resolver and IPFilter.
+	// It has no effect if no proxy is used.
+	ProxyResolve bool
+
+	// MapPort, if non-nil, returns the port to connect to for each
+	// selected address, given the address's IP and the port of the
+	// address being dialed, such as to remap 443 to 8443 in a test
+	// environment or to use the port of a particular server. It is
+	// called for every address that is resolved locally, including
+	// those of proxy servers.
+	MapPort func(ip net.IP, port int) int
+
+	// Policy, if non-nil, is called before connecting to each
+	// address with the host being dialed and the address's IP and
+	// port, so that egress rules, such as blocking cloud metadata
+	// addresses or restricting ports, may be enforced centrally.
+	// If it returns an error, the address is not dialed and the
+	// attempt fails with the error.
+	//
+	// For dials through a proxy server, it is called with the
+	// destination rather than the proxy server's address, and with
+	// a nil IP if ProxyResolve is set.
+	Policy func(host string, ip net.IP, port int) error
+
+	// Retry, if non-nil, retries dials that fail. Timeout and
+	// Deadline bound the dial including its retries.
+	Retry *RetryPolicy
+
+	// Trace, if non-nil, holds hooks called at various stages of
+	// each dial. A trace attached to the dial's context with
+	// WithDialTrace is used in preference to it.
+	Trace *DialTrace
+
+	// Tracer, if non-nil, starts spans for each dial and for
+	// resolving, filtering and connecting to its addresses, as
+	// children of the span in the context of the dial. See
+	// SpanDial for their attributes.
+	Tracer Tracer
+
+	// Logger, if non-nil, logs failed dials at the Warn level, and
+	// failed attempts and attempts to dial fallback addresses at
+	// the Debug level.
+	Logger Logger
+
+	// Recorder, if non-nil, records the outcome and latency of the
+	// Dialer's lookups and of each address it dials. If it is a
+	// ConnRecorder, it also records the counters of each connection.
+	Recorder Recorder
+
+	// Limiter, if non-nil, limits the number of the Dialer's dials
+	// in progress to its MaxConcurrentDials.
+	Limiter *DialLimiter
+
+	// RateLimit, if non-nil, limits the rate at which the Dialer
+	// dials each host.
+	RateLimit *HostRateLimiter
+
+	// Breaker, if non-nil, fails dials fast to hosts whose recent
+	// dials have repeatedly failed.
+	Breaker *CircuitBreaker
+
+	// FailedAddrs, if non-nil, records the addresses that fail to
+	// connect and orders them after, or discards them in favor of,
+	// other addresses before IPFilter is applied.
+	FailedAddrs *FailedAddrCache
+
+	// Health, if non-nil, records the latency and outcome of each
+	// address the Dialer attempts and orders the addresses by them
+	// before IPFilter is applied, so that the historically fastest
+	// and most reliable addresses are preferred.
+	Health *AddressHealth
+
+	// History, if non-nil, records the address of each host name
+	// that most recently connected and moves it to the front of
+	// the host's addresses before IPFilter is applied, so that
+	// reconnecting tries it first.
+	History *SuccessHistory
+
+	// RTTs, if non-nil, records the connect time of each address
+	// the Dialer connects to, for use by SortByObservedLatency.
+	RTTs *RTTTable
+
+	// UDPProbe, if non-nil, validates each connection of a UDP dial
+	// before it is returned, such as with UDPReplyProbe. If it fails,
+	// the connection is closed and the next address is dialed, so
+	// that, unlike a connect, dead addresses can be detected if
+	// the IPFilter selects several addresses. It is called with the
+	// context of the attempt, whose deadline bounds the probe.
+	UDPProbe func(ctx context.Context, c net.Conn) error
+
+	// HappyEyeballs enables RFC 8305 ("Happy Eyeballs") dialing of
+	// TCP connections when the selected addresses contain both IPv4
+	// and IPv6 addresses. The selected addresses are ordered by
+	// Interleave, so that their families alternate, and dialed in
+	// that order. Each attempt starts once FallbackDelay has passed
+	// since the previous one started, or once it fails, while the
+	// earlier attempts continue, as with the connection attempt
+	// delay of RFC 8305. The first connection established is
+	// returned and the others are closed. A negative FallbackDelay
+	// doesn't disable it, as it does FastFallback; the default
+	// delay is used instead.
+	//
+	// It requires an IPFilter that selects addresses of both
+	// families, such as DualStack.
+	HappyEyeballs bool
+
+	// FastFallback enables dialing TCP connections as a net.Dialer
+	// does, so that a net.Dialer may be replaced by a Dialer
+	// without changing how it connects. If IPFilter is nil, every
+	// resolved address is selected, rather than a single address.
+	// Addresses of the same family as the first address are
+	// dialed in order, and if no connection has been established
+	// after FallbackDelay, addresses of the other family are
+	// dialed in order alongside them, as RFC 6555 ("Fast
+	// Fallback") describes. Unlike with HappyEyeballs, which takes
+	// precedence, the selected addresses are not interleaved. If
+	// FallbackDelay is negative, the selected addresses are
+	// dialed in order instead.
+	//
+	// A net.Dialer enables fast fallback by default, while a
+	// Dialer doesn't, so FastFallback must be set for a Dialer to
+	// behave the same.
+	FastFallback bool
+
+	// FallbackDelay specifies the length of time to wait before
+	// spawning a fallback connection when FastFallback is enabled,
+	// and before starting each attempt when HappyEyeballs is.
+	//
+	// If zero, a default delay of 300ms is used. If negative, it
+	// disables fast fallback, as with net.Dialer, so that the
+	// selected addresses are dialed in order, but the default
+	// delay is used by HappyEyeballs.
+	FallbackDelay time.Duration
+
+	// OnRaceLoser, if non-nil, is called with each connection
+	// established while racing addresses that lost to another,
+	// such as to seed a connection pool, instead of closing it.
+	// It becomes responsible for closing the connection, which
+	// is passed before any ProxyHeader is written. It may be
+	// called concurrently and after the dial has returned.
+	//
+	// The attempts still in progress when a connection wins are
+	// left to complete, until the deadline of the dial, but no new
+	// attempts are started. If nil, they are canceled, and losing
+	// connections are closed as soon as they are established.
+	OnRaceLoser func(c net.Conn)
+
+	// AttemptDelay is the minimum delay between starting attempts
+	// to connect to successive addresses when they are dialed in
+	// order, as they are for networks other than TCP, for each
+	// family when FastFallback is enabled, and when HappyEyeballs is
+	// enabled but they are of a single family. If zero, each
+	// attempt starts as soon as the previous one fails.
+	AttemptDelay time.Duration
+
+	// StaggerAttempts keeps the earlier attempts running when the
+	// addresses are dialed in order. Each attempt starts once
+	// AttemptDelay has passed since the previous one started, or
+	// once it fails, whichever comes first, and the first
+	// connection established is returned, as with the connection
+	// attempt delay of RFC 8305. If AttemptDelay is zero, every
+	// address is dialed at once.
+	StaggerAttempts bool
+}
+
+// Dialer must keep the method signatures of golang.org/x/net/proxy's
+// Dialer and ContextDialer interfaces.
+var (
+	_ interface {
+		Dial(network, addr string) (net.Conn, error)
+	} = (*Dialer)(nil)
+	_ interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	} = (*Dialer)(nil)
+)
+
+// Clone returns a copy of d that may be modified without affecting d,
+// such as to derive a Dialer with a different IPFilter for each tenant
+// of a server.
+//
+// The RetryPolicy and DialTrace are copied. The Resolver, Recorder,
+// Proxy, DialLimiter, HostRateLimiter, CircuitBreaker, FailedAddrCache,
+// AddressHealth, SuccessHistory and RTTTable, which are safe for
+// concurrent use and whose state is meant to be shared, are shared
+// with d, as are the IPFilter and Control functions.
+func (d *Dialer) Clone() *Dialer {
+	c := *d
+	if d.Retry != nil {
+		retry := *d.Retry
+		c.Retry = &retry
+	}
+	if d.Trace != nil {
+		trace := *d.Trace
+		c.Trace = &trace
+	}
+	return &c
 }
 
 // Return either now+Timeout or Deadline, whichever comes first.
@@ -78,6 +396,45 @@ func (d *Dialer) deadline() time.Time {
 	return d.Deadline
 }
 
+// dialContext returns a copy of ctx that is done no later than
+// the Dialer's deadline, or when its Cancel channel is closed.
+func (d *Dialer) dialContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelDeadline := d.withDeadline(ctx)
+	if d.Cancel == nil {
+		return ctx, cancelDeadline
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-d.Cancel:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		cancelDeadline()
+	}
+}
+
+// withDeadline returns a copy of ctx that is done no later than
+// the Dialer's deadline.
+func (d *Dialer) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline := d.deadline(); !deadline.IsZero() {
+		if old, ok := ctx.Deadline(); !ok || deadline.Before(old) {
+			return context.WithDeadline(ctx, deadline)
+		}
+	}
+	return ctx, func() {}
+}
+
+func (d *Dialer) fallbackDelay() time.Duration {
+	if d.FallbackDelay > 0 {
+		return d.FallbackDelay
+	}
+	return 300 * time.Millisecond
+}
+
 // Dial connects to the address on the named network.
 //
 // Known networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
@@ -91,12 +448,26 @@ func (d *Dialer) deadline() time.Time {
 // The functions JoinHostPort and SplitHostPort manipulate addresses
 // in this form.
 //
+// If host is a name followed by a zone, as in "printer.local%eth0",
+// the zone is used for each of its IPv6 addresses. Otherwise its
+// link-local IPv6 addresses are discarded before IPFilter is applied,
+// since they can't be dialed without a zone.
+//
 // Examples:
 //	Dial("tcp", "12.34.56.78:80")
 //	Dial("tcp", "google.com:http")
 //	Dial("tcp", "[2001:db8::1]:http")
 //	Dial("tcp", "[fe80::1%lo0]:80")
 //
+// For TCP and UDP networks, the address may instead have the form
+// "srv+name" to dial the targets of the SRV records with the name,
+// looked up with the Resolver, which must be an SRVResolver. The
+// targets are dialed in order of priority and weight until one
+// succeeds.
+//
+// Example:
+//	Dial("tcp", "srv+_grpc._tcp.example.com")
+//
 // For IP networks, the network must be "ip", "ip4" or "ip6" followed
 // by a colon and a protocol number or name and the addr must be a
 // literal IP address.
@@ -105,35 +476,362 @@ func (d *Dialer) deadline() time.Time {
 //	Dial("ip4:1", "127.0.0.1")
 //	Dial("ip6:ospf", "::1")
 //
-// For Unix networks, the address must be a file system path.
+// For Unix networks, the address must be a file system path or, on
+// Linux, a name in the abstract namespace beginning with "@".
 func (d *Dialer) Dial(network, address string) (net.Conn, error) {
-	deadline := d.deadline()
-	filter := d.IPFilter
-	if filter == nil {
-		filter = defaultIP
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using
+// the provided context.
+//
+// The provided Context must be non-nil. If the context expires before
+// the connection is complete, an error is returned. Once successfully
+// connected, any expiration of the context will not affect the
+// connection. Cancellation applies to both resolving the host and
+// establishing the connection.
+//
+// If the context has a ProxyHeader attached with WithProxyHeader,
+// it is written to the connection before it is returned.
+//
+// See func Dial for a description of the network and address
+// parameters.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (c net.Conn, err error) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	ctx, cancel := d.dialContext(ctx)
+	defer cancel()
+	if d.Tracer != nil {
+		var span Span
+		ctx, span = d.Tracer.Start(ctx, SpanDial)
+		span.SetAttribute("server.address", address)
+		span.SetAttribute("network.transport", network)
+		defer func() { endSpan(span, err) }()
+	}
+	if d.Limiter != nil {
+		release, err := d.Limiter.acquire(ctx)
+		if err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		defer release()
+	}
+	if d.Retry != nil {
+		c, err = d.Retry.dial(ctx, func() (net.Conn, error) {
+			return d.dialTarget(ctx, network, address)
+		})
+	} else {
+		c, err = d.dialTarget(ctx, network, address)
+	}
+	if err != nil {
+		if d.Logger != nil {
+			d.Logger.Warn("nett: dial failed", "network", network, "address", address, "err", err)
+		}
+		return nil, err
+	}
+	if _, ok := earlyData(ctx); !ok {
+		if err := writeProxyHeader(ctx, c); err != nil {
+			c.Close()
+			return nil, &net.OpError{Op: "dial", Net: network, Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+		}
+	}
+	if rec, ok := d.Recorder.(ConnRecorder); ok {
+		c = WrapStats(c, func(stats ConnStats) { rec.ConnClosed(network, address, stats) })
+	}
+	return c, nil
+}
+
+// dialOnce connects to the address, through a proxy if required.
+func (d *Dialer) dialOnce(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.RateLimit != nil {
+		if err := d.RateLimit.wait(ctx, hostOf(address)); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+	}
+	if d.Breaker == nil {
+		return d.dialDirectOrProxy(ctx, network, address)
+	}
+	host := hostOf(address)
+	if err := d.Breaker.allow(host); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	c, err := d.dialDirectOrProxy(ctx, network, address)
+	d.Breaker.record(ctx, host, err)
+	return c, err
+}
+
+func (d *Dialer) dialDirectOrProxy(ctx context.Context, network, address string) (net.Conn, error) {
+	p, err := d.proxy(network, address)
+	if err != nil {
+		return nil, &net.OpError{Op: "proxy", Net: network, Err: err}
+	}
+	if p != nil {
+		return d.dialProxy(ctx, p, network, address)
+	}
+	nd := d.netDialer()
+	data, early := earlyData(ctx)
+	if early && !d.FastOpen {
+		nd.Control = fastOpenControl(nd.Control)
+	}
+	fn := nd.DialContext
+	if early {
+		fn = writeEarlyData(data, fn)
+	}
+	return d.dial(ctx, d.policyDial(address, fn), network, address, false)
+}
+
+// dial resolves address and connects to the selected addresses
+// using fn. If proxied, the addresses are dialed via a proxy server
+// and need not match the family of the LocalAddr.
+func (d *Dialer) dial(ctx context.Context, fn dialFunc, network, address string, proxied bool) (net.Conn, error) {
+	ex, ctx := takeExplainer(ctx)
+	filter := ex.result("IPFilter", d.ipFilter())
+	if d.UDPProbe != nil && len(network) >= 3 && network[:3] == "udp" {
+		fn = probeUDPDial(d.UDPProbe, fn)
+	}
+	if d.FailedAddrs != nil {
+		filter = d.FailedAddrs.filter(ex.filter("FailedAddrs", filter))
+		fn = d.FailedAddrs.dial(fn)
+	}
+	if d.Health != nil {
+		filter = d.Health.filter(ex.filter("Health", filter))
+		fn = d.Health.dial(fn)
+	}
+	if d.History != nil {
+		if host := hostOf(address); net.ParseIP(host) == nil {
+			filter = d.History.filter(host, ex.filter("History", filter))
+			fn = d.History.dial(host, fn)
+		}
+	}
+	if d.RTTs != nil {
+		fn = d.RTTs.dial(fn)
+	}
+	if ip := localIP(d.LocalAddr); ip != nil && !ip.IsUnspecified() && !proxied {
+		filter = matchFamily(ip, ex.filter("LocalAddr", filter))
+	}
+	if d.Interface != "" && !proxied {
+		filter = interfaceFamily(d.Interface, ex.filter("Interface", filter))
+	}
+	filter = ex.filter("Network", filter)
+	fn = ex.dial(fn)
+	var resolver Resolver
+	if d.Tracer != nil {
+		resolver = newSpanResolver(ctx, d.Tracer, ex.resolver(forNetwork(d.Resolver, network)))
+		filter = spanFilter(ctx, d.Tracer, filter)
+		fn = spanDial(d.Tracer, fn)
+	} else {
+		resolver = withContext(ctx, ex.resolver(forNetwork(d.Resolver, network)))
+	}
+	if d.Recorder != nil {
+		resolver = recordResolver(d.Recorder, resolver)
+		fn = recordDial(d.Recorder, fn)
+	}
+	if d.Logger != nil {
+		fn = logDial(d.Logger, fn)
+	}
+	if published.Load() {
+		resolver = recordResolver(&globalCounters, resolver)
+		fn = recordDial(&globalCounters, fn)
+	}
+	if trace := d.trace(ctx); trace != nil {
+		resolver = trace.resolver(resolver)
+		filter = trace.filter(filter)
+		fn = trace.dial(fn)
+	}
+	addrs, err := resolveAddrsContext(ctx, resolver, filter, network, address)
+	if err == nil {
+		err = filterUnix(d.UnixFilter, addrs)
 	}
-	addrs, err := resolveAddrsDeadline(d.Resolver, filter, network, address, deadline)
 	if err != nil {
 		return nil, &net.OpError{Op: "dial", Net: network, Addr: nil, Err: err}
 	}
-	dialer := d.netDialer(deadline)
-	if addrs.Len() == 1 || len(network) < 3 || network[:3] != "tcp" {
-		return dialer.Dial(network, addrs.Addr(0))
+	if d.MapPort != nil {
+		mapPorts(addrs, d.MapPort)
 	}
-	return dialMulti(dialer, network, addrs)
+	if d.NetAddrFilter != nil {
+		if addrs, err = filterAddrs(addrs, d.NetAddrFilter); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: nil, Err: err}
+		}
+	}
+	if ex != nil {
+		ex.selected(addrs)
+		if !ex.connect {
+			return nil, errExplained
+		}
+	}
+	if d.OnRaceLoser != nil {
+		fn = keepRaceLosers(fn)
+	}
+	var (
+		c        net.Conn
+		attempts []*AttemptError
+	)
+	switch {
+	case addrs.Len() == 1:
+		if c, err = fn(ctx, network, addrs.Addr(0)); err != nil {
+			attempts = []*AttemptError{{addrs.Addr(0), err}}
+		}
+	case len(network) < 3 || network[:3] != "tcp":
+		c, attempts = d.dialInOrder(ctx, fn, network, addrs)
+	case d.HappyEyeballs, d.FastFallback && d.FallbackDelay >= 0:
+		primaries, fallbacks := addrs.(tcpList).partition()
+		if len(fallbacks) > 0 {
+			if d.Logger != nil {
+				fn = logFallback(d.Logger, fn, fallbacks)
+			}
+			if d.HappyEyeballs {
+				// Dial the interleaved addresses in order, rather
+				// than each family in turn.
+				c, attempts = dialStaggered(ctx, fn, network, addrs, d.fallbackDelay(), d.raceLoser())
+				break
+			}
+			inOrder := func(ctx context.Context, addrs addrList) (net.Conn, []*AttemptError) {
+				return d.dialInOrder(ctx, fn, network, addrs)
+			}
+			c, attempts = dialParallel(ctx, inOrder, primaries, fallbacks, d.fallbackDelay(), d.raceLoser())
+		} else {
+			c, attempts = d.dialInOrder(ctx, fn, network, primaries)
+		}
+	case d.FastFallback:
+		c, attempts = d.dialInOrder(ctx, fn, network, addrs)
+	default:
+		c, attempts = dialMulti(ctx, fn, network, addrs, d.raceLoser())
+	}
+	if c != nil {
+		return c, nil
+	}
+	return nil, newDialError(network, address, addrs, attempts)
 }
 
-func resolveAddrsDeadline(resolver Resolver, filter ipFilter, network, address string, deadline time.Time) (addrList, error) {
-	if deadline.IsZero() {
-		return resolveAddrList(resolver, filter, network, address)
+// ipFilter returns the filter selecting the addresses to dial.
+func (d *Dialer) ipFilter() ipFilter {
+	var filter ipFilter = d.IPFilter
+	if filter == nil {
+		if d.FastFallback {
+			filter = allIPs
+		} else {
+			filter = defaultIP
+		}
+	}
+	if d.HappyEyeballs {
+		filter = interleave(filter)
 	}
+	return filter
+}
+
+// mapPorts replaces the port of each address in addrs with the one
+// returned by mapPort.
+func mapPorts(addrs addrList, mapPort func(ip net.IP, port int) int) {
+	switch list := addrs.(type) {
+	case tcpList:
+		for _, addr := range list {
+			addr.Port = mapPort(addr.IP, addr.Port)
+		}
+	case udpList:
+		for _, addr := range list {
+			addr.Port = mapPort(addr.IP, addr.Port)
+		}
+	}
+}
+
+// filterAddrs returns the addresses of list selected by filter.
+func filterAddrs(list addrList, filter func(addrs []net.Addr) []net.Addr) (addrList, error) {
+	if _, ok := list.(unixList); ok {
+		return list, nil
+	}
+	addrs := make([]net.Addr, list.Len())
+	for i := range addrs {
+		addrs[i] = list.NetAddr(i)
+	}
+	addrs = filter(addrs)
+	switch list.(type) {
+	case tcpList:
+		var a tcpList
+		for _, addr := range addrs {
+			if addr, ok := addr.(*net.TCPAddr); ok {
+				a = append(a, addr)
+			}
+		}
+		list = a
+	case udpList:
+		var a udpList
+		for _, addr := range addrs {
+			if addr, ok := addr.(*net.UDPAddr); ok {
+				a = append(a, addr)
+			}
+		}
+		list = a
+	case ipList:
+		var a ipList
+		for _, addr := range addrs {
+			if addr, ok := addr.(*net.IPAddr); ok {
+				a = append(a, addr)
+			}
+		}
+		list = a
+	}
+	if list.Len() == 0 {
+		return nil, ErrNoSuitableAddress
+	}
+	return list, nil
+}
+
+// dialFunc connects to a single resolved address.
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
-	timeout := deadline.Sub(time.Now())
-	if timeout <= 0 {
-		return nil, errTimeout
+func (d *Dialer) netDialer() net.Dialer {
+	control := d.Control
+	if d.Interface != "" {
+		control = bindInterfaceControl(d.Interface, control)
+	}
+	if d.FastOpen {
+		control = fastOpenControl(control)
+	}
+	nd := net.Dialer{
+		LocalAddr: d.LocalAddr,
+		KeepAlive: d.KeepAlive,
+		Control:   control,
+	}
+	if d.UseMultipathTCP {
+		setMultipathTCPDialer(&nd, true)
+	}
+	return nd
+}
+
+// policyDial returns fn wrapped to check each address against the
+// Policy before dialing it, for a dial of target.
+func (d *Dialer) policyDial(target string, fn dialFunc) dialFunc {
+	if d.Policy == nil {
+		return fn
+	}
+	host := hostOf(target)
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		h, p, err := net.SplitHostPort(address)
+		if err != nil {
+			h = address
+		}
+		h, _ = splitHostZone(h)
+		ip := net.ParseIP(h)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		port, _ := strconv.Atoi(p)
+		if err := d.Policy(host, ip, port); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		return fn(ctx, network, address)
+	}
+}
+
+// resolveAddrsContext resolves the address list, giving up
+// if ctx is done before resolution is complete.
+func resolveAddrsContext(ctx context.Context, resolver Resolver, filter ipFilter, network, address string) (addrList, error) {
+	if ctx.Done() == nil {
+		return resolveAddrList(resolver, filter, network, address)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, mapErr(err)
 	}
-	t := time.NewTimer(timeout)
-	defer t.Stop()
 	type res struct {
 		addrList
 		error
@@ -144,22 +842,74 @@ func resolveAddrsDeadline(resolver Resolver, filter ipFilter, network, address s
 		resc <- res{addrs, err}
 	}()
 	select {
-	case <-t.C:
-		return nil, errTimeout
+	case <-ctx.Done():
+		return nil, mapErr(ctx.Err())
 	case r := <-resc:
 		return r.addrList, r.error
 	}
 }
 
+// raceLoser returns the function to which the losers of races are
+// handed.
+func (d *Dialer) raceLoser() func(net.Conn) {
+	if d.OnRaceLoser != nil {
+		return d.OnRaceLoser
+	}
+	return closeConn
+}
+
+func closeConn(c net.Conn) { c.Close() }
+
+// keepRaceLosers returns fn wrapped so that its attempts are not
+// canceled once a race is decided, but left to complete by their
+// deadline, so that the connections losing the race are handed to
+// OnRaceLoser instead of being abandoned. They are still canceled
+// if the dial is canceled before then.
+func keepRaceLosers(fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var (
+			attemptCtx context.Context
+			cancel     context.CancelFunc
+		)
+		if deadline, ok := ctx.Deadline(); ok {
+			attemptCtx, cancel = context.WithDeadline(withoutCancel{ctx}, deadline)
+		} else {
+			attemptCtx, cancel = context.WithCancel(withoutCancel{ctx})
+		}
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+				if context.Cause(ctx) != errRaceWon {
+					cancel()
+				}
+			case <-attemptCtx.Done():
+			}
+		}()
+		return fn(attemptCtx, network, address)
+	}
+}
+
+// withoutCancel is a context with the values of its parent, which is
+// never canceled.
+type withoutCancel struct{ context.Context }
+
+func (withoutCancel) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (withoutCancel) Done() <-chan struct{}       { return nil }
+func (withoutCancel) Err() error                  { return nil }
+
 // dialMulti attempts to establish connections to each destination of
 // the list of addresses. It will return the first established
-// connection and close the other connections. Otherwise it returns
-// error on the last attempt.
-func dialMulti(dialer net.Dialer, network string, addrs addrList) (net.Conn, error) {
+// connection and pass the other connections to lost. Otherwise it
+// returns the failed attempts in the order they finished.
+func dialMulti(ctx context.Context, dial dialFunc, network string, addrs addrList, lost func(net.Conn)) (net.Conn, []*AttemptError) {
 	type racer struct {
 		net.Conn
-		error
+		*AttemptError
 	}
+	// Abandon the remaining attempts once a winner is chosen.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errRaceWon)
 	addrsLen := addrs.Len()
 	// Sig controls the flow of dial results on lane. It passes a
 	// token to the next racer and also indicates the end of flow
@@ -168,31 +918,292 @@ func dialMulti(dialer net.Dialer, network string, addrs addrList) (net.Conn, err
 	lane := make(chan racer, 1)
 	for i := 0; i < addrsLen; i++ {
 		go func(i int) {
-			c, err := dialer.Dial(network, addrs.Addr(i))
+			c, err := dial(ctx, network, addrs.Addr(i))
 			if _, ok := <-sig; ok {
-				lane <- racer{c, err}
+				if err != nil {
+					lane <- racer{nil, &AttemptError{addrs.Addr(i), err}}
+				} else {
+					lane <- racer{c, nil}
+				}
 			} else if err == nil {
 				// We have to return the resources
 				// that belong to the other
 				// connections here for avoiding
 				// unnecessary resource starvation.
-				c.Close()
+				lost(c)
 			}
 		}(i)
 	}
 	defer close(sig)
-	lastErr := errTimeout
+	var attempts []*AttemptError
 	for i := 0; i < addrsLen; i++ {
 		sig <- true
 		racer := <-lane
-		if racer.error == nil {
+		if racer.AttemptError == nil {
 			return racer.Conn, nil
 		}
-		lastErr = racer.error
+		attempts = append(attempts, racer.AttemptError)
+	}
+	return nil, attempts
+}
+
+// dialParallel races two copies of inOrder, giving the first a
+// head start. It returns the first established connection and
+// passes the others to lost. Otherwise it returns the failed
+// attempts of the primary addresses followed by those of the
+// fallbacks.
+func dialParallel(ctx context.Context, inOrder func(ctx context.Context, addrs addrList) (net.Conn, []*AttemptError), primaries, fallbacks addrList, delay time.Duration, lost func(net.Conn)) (net.Conn, []*AttemptError) {
+	returned := make(chan struct{})
+	defer close(returned)
+
+	type dialResult struct {
+		net.Conn
+		attempts []*AttemptError
+		primary  bool
+		done     bool
+	}
+	results := make(chan dialResult) // unbuffered
+
+	startRacer := func(ctx context.Context, primary bool) {
+		addrs := primaries
+		if !primary {
+			addrs = fallbacks
+		}
+		c, attempts := inOrder(ctx, addrs)
+		select {
+		case results <- dialResult{Conn: c, attempts: attempts, primary: primary, done: true}:
+		case <-returned:
+			if c != nil {
+				lost(c)
+			}
+		}
+	}
+
+	var primary, fallback dialResult
+
+	// Start the main racer.
+	primaryCtx, primaryCancel := context.WithCancelCause(ctx)
+	defer primaryCancel(errRaceWon)
+	go startRacer(primaryCtx, true)
+
+	// Start the timer for the fallback racer.
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	for {
+		select {
+		case <-fallbackTimer.C:
+			fallbackCtx, fallbackCancel := context.WithCancelCause(ctx)
+			defer fallbackCancel(errRaceWon)
+			go startRacer(fallbackCtx, false)
+
+		case res := <-results:
+			if res.Conn != nil {
+				return res.Conn, nil
+			}
+			if res.primary {
+				primary = res
+			} else {
+				fallback = res
+			}
+			if primary.done && fallback.done {
+				return nil, append(primary.attempts, fallback.attempts...)
+			}
+			if res.primary && fallbackTimer.Stop() {
+				// If we were able to stop the timer, that means it
+				// was running (hadn't yet started the fallback), but
+				// we just got an error on the primary path, so start
+				// the fallback immediately (in 0 nanoseconds).
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
+
+// dialInOrder connects to a list of addresses in order, as configured
+// by AttemptDelay and StaggerAttempts.
+func (d *Dialer) dialInOrder(ctx context.Context, dial dialFunc, network string, addrs addrList) (net.Conn, []*AttemptError) {
+	if d.StaggerAttempts {
+		return dialStaggered(ctx, dial, network, addrs, d.AttemptDelay, d.raceLoser())
+	}
+	return dialSerial(ctx, dial, network, addrs, d.AttemptDelay)
+}
+
+// dialSerial connects to a list of addresses in sequence, returning
+// either the first successful connection, or every failed attempt.
+// Each attempt starts no sooner than delay after the previous one
+// started. If ctx is done, the address that would have been dialed
+// next is recorded as the final attempt.
+//
+// If ctx has a deadline, the time remaining is divided among the
+// remaining addresses so that a slow address cannot starve the
+// addresses after it.
+func dialSerial(ctx context.Context, dial dialFunc, network string, addrs addrList, delay time.Duration) (net.Conn, []*AttemptError) {
+	var (
+		attempts []*AttemptError
+		started  time.Time
+	)
+	for i := 0; i < addrs.Len(); i++ {
+		if delay > 0 && i > 0 {
+			if wait := delay - time.Since(started); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+				case <-t.C:
+				}
+				t.Stop()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			err := &net.OpError{Op: "dial", Net: network, Err: mapErr(ctx.Err())}
+			return nil, append(attempts, &AttemptError{addrs.Addr(i), err})
+		default:
+		}
+		started = time.Now()
+		dialCtx := ctx
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+			partialDeadline, err := partialDeadline(time.Now(), deadline, addrs.Len()-i)
+			if err != nil {
+				// Ran out of time.
+				err = &net.OpError{Op: "dial", Net: network, Err: err}
+				return nil, append(attempts, &AttemptError{addrs.Addr(i), err})
+			}
+			if partialDeadline.Before(deadline) {
+				var cancel context.CancelFunc
+				dialCtx, cancel = context.WithDeadline(ctx, partialDeadline)
+				defer cancel()
+			}
+		}
+		c, err := dial(dialCtx, network, addrs.Addr(i))
+		if err == nil {
+			return c, nil
+		}
+		attempts = append(attempts, &AttemptError{addrs.Addr(i), err})
+	}
+	return nil, attempts
+}
+
+// dialStaggered connects to a list of addresses in order, starting each
+// attempt once delay has passed since the previous one started or once
+// it fails, whichever comes first, while the earlier attempts continue.
+// It returns the first established connection and passes the others to
+// lost. Otherwise it returns the failed attempts in the order they
+// finished.
+func dialStaggered(ctx context.Context, dial dialFunc, network string, addrs addrList, delay time.Duration, lost func(net.Conn)) (net.Conn, []*AttemptError) {
+	type result struct {
+		net.Conn
+		*AttemptError
+	}
+	// Abandon the remaining attempts once a winner is chosen.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errRaceWon)
+	results := make(chan result, addrs.Len())
+	next, running := 0, 0
+	start := func() {
+		address := addrs.Addr(next)
+		next++
+		running++
+		go func() {
+			c, err := dial(ctx, network, address)
+			if err != nil {
+				results <- result{nil, &AttemptError{address, err}}
+			} else {
+				results <- result{c, nil}
+			}
+		}()
+	}
+	start()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var attempts []*AttemptError
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if next < addrs.Len() {
+				start()
+				timer.Reset(delay)
+			}
+		case res := <-results:
+			running--
+			if res.AttemptError == nil {
+				go func(running int) {
+					for ; running > 0; running-- {
+						if res := <-results; res.Conn != nil {
+							lost(res.Conn)
+						}
+					}
+				}(running)
+				return res.Conn, nil
+			}
+			attempts = append(attempts, res.AttemptError)
+			if next < addrs.Len() {
+				start()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(delay)
+			}
+		}
+	}
+	return nil, attempts
+}
+
+// partialDeadline returns the deadline to use for a single address,
+// when multiple addresses are pending.
+func partialDeadline(now, deadline time.Time, addrsRemaining int) (time.Time, error) {
+	if deadline.IsZero() {
+		return deadline, nil
+	}
+	timeRemaining := deadline.Sub(now)
+	if timeRemaining <= 0 {
+		return time.Time{}, errTimeout
+	}
+	// Tentatively allocate equal time to each remaining address.
+	timeout := timeRemaining / time.Duration(addrsRemaining)
+	// If the time per address is too short, steal from the end of the list.
+	const saneMinimum = 2 * time.Second
+	if timeout < saneMinimum {
+		if timeRemaining < saneMinimum {
+			timeout = timeRemaining
+		} else {
+			timeout = saneMinimum
+		}
+	}
+	return now.Add(timeout), nil
+}
+
+// localIP returns the IP address of a local address, if it has one.
+func localIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.IPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+// matchFamily returns a filter that discards the addresses that
+// are not of the same family as local before applying filter.
+func matchFamily(local net.IP, filter ipFilter) ipFilter {
+	family := ipv6only
+	if local.To4() != nil {
+		family = ipv4only
+	}
+	return func(ips []net.IP) []net.IP {
+		return filter(filterIPs(family, ips))
 	}
-	return nil, lastErr
 }
 
+// allIPs selects every address.
+func allIPs(ips []net.IP) []net.IP { return ips }
+
 // defaultIP gives priority to IPv4 addresses and selects the first address.
 func defaultIP(ips []net.IP) []net.IP {
 	if len(ips) <= 1 {
@@ -200,9 +1211,9 @@ func defaultIP(ips []net.IP) []net.IP {
 	}
 	v6 := -1
 	for i, ip := range ips {
-		if ipLen := len(ip); ipLen == net.IPv4len {
+		if ip.To4() != nil {
 			return ips[i : i+1]
-		} else if v6 == -1 && ipLen == net.IPv6len {
+		} else if v6 == -1 && len(ip) == net.IPv6len {
 			v6 = i
 		}
 	}
@@ -223,10 +1234,10 @@ func DualStack(ips []net.IP) []net.IP {
 		a          []net.IP
 	)
 	for _, ip := range ips {
-		if ipLen := len(ip); !ipv4 && ipLen == net.IPv4len {
+		if is4 := ip.To4() != nil; !ipv4 && is4 {
 			a = append(a, ip)
 			ipv4 = true
-		} else if !ipv6 && ipLen == net.IPv6len {
+		} else if !ipv6 && !is4 && len(ip) == net.IPv6len {
 			a = append(a, ip)
 			ipv6 = true
 		}
@@ -237,9 +1248,80 @@ func DualStack(ips []net.IP) []net.IP {
 	return a
 }
 
+// PreferIPv4 orders ips so that IPv4 addresses come before IPv6
+// addresses, preserving the relative order of each family. Unlike
+// the default IPFilter, it keeps the IPv6 addresses as fallbacks.
+func PreferIPv4(ips []net.IP) []net.IP {
+	return preferFamily(ips, true)
+}
+
+// PreferIPv6 orders ips so that IPv6 addresses come before IPv4
+// addresses, preserving the relative order of each family.
+func PreferIPv6(ips []net.IP) []net.IP {
+	return preferFamily(ips, false)
+}
+
+// preferFamily orders ips so that IPv4 addresses come first if ipv4,
+// or IPv6 addresses otherwise.
+func preferFamily(ips []net.IP, ipv4 bool) []net.IP {
+	a := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == ipv4 {
+			a = append(a, ip)
+		}
+	}
+	for _, ip := range ips {
+		if (ip.To4() != nil) != ipv4 {
+			a = append(a, ip)
+		}
+	}
+	return a
+}
+
+// Interleave orders ips so that IPv6 and IPv4 addresses alternate,
+// as recommended by RFC 8305 section 4, starting with the family of
+// the first address. The relative order of addresses of the same
+// family is preserved.
+//
+// It is applied to the selected addresses when HappyEyeballs is
+// enabled.
+func Interleave(ips []net.IP) []net.IP {
+	if len(ips) <= 2 {
+		return ips
+	}
+	var first, second []net.IP
+	firstV4 := ips[0].To4() != nil
+	for _, ip := range ips {
+		if (ip.To4() != nil) == firstV4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	a := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			a = append(a, first[i])
+		}
+		if i < len(second) {
+			a = append(a, second[i])
+		}
+	}
+	return a
+}
+
+// interleave returns a filter that applies Interleave to the
+// addresses selected by filter.
+func interleave(filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		return Interleave(filter(ips))
+	}
+}
+
 type addrList interface {
 	Len() int
 	Addr(i int) string
+	NetAddr(i int) net.Addr
 }
 
 type tcpList []*net.TCPAddr
@@ -247,17 +1329,141 @@ type udpList []*net.UDPAddr
 type ipList []*net.IPAddr
 type unixList []*net.UnixAddr
 
-func (list tcpList) Len() int          { return len(list) }
-func (list tcpList) Addr(i int) string { return list[i].String() }
+func (list tcpList) Len() int               { return len(list) }
+func (list tcpList) Addr(i int) string      { return list[i].String() }
+func (list tcpList) NetAddr(i int) net.Addr { return list[i] }
+
+// partition divides list into two lists of addresses: those of the
+// same family as the first address, and the rest, preserving order.
+func (list tcpList) partition() (primaries, fallbacks tcpList) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	v4 := list[0].IP.To4() != nil
+	for _, addr := range list {
+		if (addr.IP.To4() != nil) == v4 {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
+
+func (list udpList) Len() int               { return len(list) }
+func (list udpList) Addr(i int) string      { return list[i].String() }
+func (list udpList) NetAddr(i int) net.Addr { return list[i] }
+
+func (list ipList) Len() int               { return len(list) }
+func (list ipList) Addr(i int) string      { return list[i].String() }
+func (list ipList) NetAddr(i int) net.Addr { return list[i] }
+
+func (list unixList) Len() int               { return len(list) }
+func (list unixList) Addr(i int) string      { return list[i].String() }
+func (list unixList) NetAddr(i int) net.Addr { return list[i] }
+
+// An AttemptError records the failure to dial a single address.
+type AttemptError struct {
+	Addr string // the address that was dialed
+	Err  error  // the reason the attempt failed
+}
+
+func (e *AttemptError) Error() string { return e.Err.Error() }
+func (e *AttemptError) Unwrap() error { return e.Err }
+
+// A DialError is returned when a dial fails to connect to any of
+// the addresses selected for it. It records the addresses that were
+// selected and the attempts that were made to connect to them.
+type DialError struct {
+	Net      string          // the network being dialed
+	Address  string          // the address as passed to Dial
+	Addrs    []string        // the addresses selected after resolution and filtering
+	NetAddrs []net.Addr      // the selected addresses, such as *net.TCPAddr values
+	Attempts []*AttemptError // the failed attempts in the order they were made
+}
+
+func (e *DialError) Error() string {
+	if len(e.Attempts) == 1 {
+		return e.Attempts[0].Error()
+	}
+	s := "dial " + e.Net + " " + e.Address + ": all " + strconv.Itoa(len(e.Attempts)) + " attempts failed"
+	for i, a := range e.Attempts {
+		if i == 0 {
+			s += ": "
+		} else {
+			s += "; "
+		}
+		s += a.Error()
+	}
+	return s
+}
+
+// Unwrap returns the errors of the attempts.
+func (e *DialError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a
+	}
+	return errs
+}
 
-func (list udpList) Len() int          { return len(list) }
-func (list udpList) Addr(i int) string { return list[i].String() }
+// Timeout reports whether the dial gave up because the final
+// attempt timed out.
+func (e *DialError) Timeout() bool {
+	t, ok := e.last().(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
 
-func (list ipList) Len() int          { return len(list) }
-func (list ipList) Addr(i int) string { return list[i].String() }
+// Temporary reports whether the final attempt failed with a
+// temporary error.
+func (e *DialError) Temporary() bool {
+	t, ok := e.last().(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
 
-func (list unixList) Len() int          { return len(list) }
-func (list unixList) Addr(i int) string { return list[i].String() }
+func (e *DialError) last() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+// newDialError returns the error for a dial of address whose
+// attempts to connect to addrs all failed.
+func newDialError(network, address string, addrs addrList, attempts []*AttemptError) error {
+	if len(attempts) == 0 {
+		return &net.OpError{Op: "dial", Net: network, Err: ErrNoSuitableAddress}
+	}
+	e := &DialError{Net: network, Address: address, Attempts: attempts}
+	e.Addrs = make([]string, addrs.Len())
+	e.NetAddrs = make([]net.Addr, addrs.Len())
+	for i := range e.Addrs {
+		e.Addrs[i] = addrs.Addr(i)
+		e.NetAddrs[i] = addrs.NetAddr(i)
+	}
+	return e
+}
+
+// canceled reports whether a dial with ctx that failed with err was
+// canceled, by its caller or once another attempt connected, rather
+// than failing on its own. The net package reports the cancellation of
+// its dials as errors wrapping context.Canceled.
+func canceled(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, errCanceled) || errors.Is(ctx.Err(), context.Canceled)
+}
+
+// mapErr maps from the context errors to the historical internal net
+// error values.
+func mapErr(err error) error {
+	switch err {
+	case context.Canceled:
+		return errCanceled
+	case context.DeadlineExceeded:
+		return errTimeout
+	default:
+		return err
+	}
+}
 
 type timeoutError struct{}
 
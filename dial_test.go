@@ -6,13 +6,31 @@ package nett
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
+// blockingResolver blocks every lookup until unblock is closed.
+type blockingResolver struct {
+	unblock chan struct{}
+}
+
+func (r *blockingResolver) Resolve(host string) ([]net.IP, error) {
+	<-r.unblock
+	return nil, ErrNoSuitableAddress
+}
+
 func TestDialHTTP(t *testing.T) {
 	b := []byte("OK")
 	h := func(w http.ResponseWriter, r *http.Request) { w.Write(b) }
@@ -35,6 +53,656 @@ func TestDialHTTP(t *testing.T) {
 	}
 }
 
+func TestDialContextHTTP(t *testing.T) {
+	b := []byte("OK")
+	h := func(w http.ResponseWriter, r *http.Request) { w.Write(b) }
+	s := httptest.NewServer(http.HandlerFunc(h))
+	defer s.Close()
+
+	var d Dialer
+	c := http.Client{Transport: &http.Transport{DialContext: d.DialContext}}
+	resp, err := c.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, b) {
+		t.Fatal("response doesn't match")
+	}
+}
+
+func TestDialContextCancelResolve(t *testing.T) {
+	r := &blockingResolver{unblock: make(chan struct{})}
+	defer close(r.unblock)
+	d := &Dialer{Resolver: r}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := d.DialContext(ctx, "tcp", "foo.com:80")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.(*net.OpError).Err != errCanceled {
+		t.Fatalf("expected %v; got %v", errCanceled, err)
+	}
+}
+
+func TestDialCancelResolve(t *testing.T) {
+	r := &blockingResolver{unblock: make(chan struct{})}
+	defer close(r.unblock)
+	cancel := make(chan struct{})
+	d := &Dialer{Resolver: r, Cancel: cancel}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(cancel)
+	}()
+	_, err := d.Dial("tcp", "foo.com:80")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.(*net.OpError).Err != errCanceled {
+		t.Fatalf("expected %v; got %v", errCanceled, err)
+	}
+}
+
+func TestDialContextDeadlineResolve(t *testing.T) {
+	r := &blockingResolver{unblock: make(chan struct{})}
+	defer close(r.unblock)
+	d := &Dialer{Resolver: r, Timeout: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := d.DialContext(ctx, "tcp", "foo.com:80")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("expected timeout error; got %v", err)
+	}
+}
+
+// staticResolver resolves every host to the same addresses.
+type staticResolver []net.IP
+
+func (r staticResolver) Resolve(host string) ([]net.IP, error) {
+	ips := make([]net.IP, len(r))
+	copy(ips, r)
+	return ips, nil
+}
+
+func TestPartition(t *testing.T) {
+	v4a := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1).To4()}
+	v4b := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2).To4()}
+	v6a := &net.TCPAddr{IP: net.IPv6loopback}
+	v6b := &net.TCPAddr{IP: net.ParseIP("fe80::1")}
+	primaries, fallbacks := tcpList{v6a, v4a, v6b, v4b}.partition()
+	if !reflect.DeepEqual(primaries, tcpList{v6a, v6b}) {
+		t.Errorf("primaries: expected %v; got %v", tcpList{v6a, v6b}, primaries)
+	}
+	if !reflect.DeepEqual(fallbacks, tcpList{v4a, v4b}) {
+		t.Errorf("fallbacks: expected %v; got %v", tcpList{v4a, v4b}, fallbacks)
+	}
+}
+
+func TestDialHappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// The IPv6 primary is refused (or unsupported), so the
+	// IPv4 fallback must be dialed without waiting out the delay.
+	d := &Dialer{
+		Resolver:      staticResolver{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		IPFilter:      DualStack,
+		HappyEyeballs: true,
+		FallbackDelay: time.Hour,
+		Timeout:       5 * time.Second,
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	if addr := c.RemoteAddr().(*net.TCPAddr); addr.IP.To4() == nil {
+		t.Fatalf("expected IPv4 connection; got %v", addr)
+	}
+}
+
+func TestDialHappyEyeballsInterleaved(t *testing.T) {
+	if !supportsIPv4 || !supportsIPv6 {
+		t.Skip("both IPv4 and IPv6 are required")
+	}
+	// Every attempt fails, so each address is dialed in turn
+	// without waiting out the delay.
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	d := &Dialer{
+		Resolver:      staticResolver(parseIPs("::1", "2001:db8::1", "127.0.0.1", "127.0.0.2")),
+		IPFilter:      allIPs,
+		HappyEyeballs: true,
+		FallbackDelay: time.Hour,
+		Timeout:       5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			mu.Lock()
+			order = append(order, hostOf(address))
+			mu.Unlock()
+			return errors.New("refused by test")
+		},
+	}
+	if _, err := d.Dial("tcp", "foo.com:80"); err == nil {
+		t.Fatal("expected the dial to fail")
+	}
+	want := []string{"::1", "127.0.0.1", "2001:db8::1", "127.0.0.2"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected the addresses to be dialed in the order %v; got %v", want, order)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	var (
+		a4 = net.IPv4(192, 0, 2, 1).To4()
+		b4 = net.IPv4(192, 0, 2, 2).To4()
+		c4 = net.IPv4(192, 0, 2, 3).To4()
+		a6 = net.ParseIP("2001:db8::1")
+		b6 = net.ParseIP("2001:db8::2")
+	)
+	tests := []struct {
+		ips, want []net.IP
+	}{
+		{[]net.IP{a6, b6, a4, b4, c4}, []net.IP{a6, a4, b6, b4, c4}},
+		{[]net.IP{a4, b4, c4, a6}, []net.IP{a4, a6, b4, c4}},
+		{[]net.IP{a4, b4, c4}, []net.IP{a4, b4, c4}},
+	}
+	for _, tt := range tests {
+		got := Interleave(tt.ips)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Interleave(%v): expected %v; got %v", tt.ips, tt.want, got)
+		}
+	}
+}
+
+func TestPreferFamily(t *testing.T) {
+	var (
+		a4 = net.IPv4(192, 0, 2, 1).To4()
+		b4 = net.IPv4(192, 0, 2, 2).To4()
+		a6 = net.ParseIP("2001:db8::1")
+		b6 = net.ParseIP("2001:db8::2")
+	)
+	ips := []net.IP{a6, a4, b6, b4}
+	if got, want := PreferIPv4(ips), []net.IP{a4, b4, a6, b6}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PreferIPv4(%v): expected %v; got %v", ips, want, got)
+	}
+	if got, want := PreferIPv6(ips), []net.IP{a6, b6, a4, b4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PreferIPv6(%v): expected %v; got %v", ips, want, got)
+	}
+	if got := PreferIPv6([]net.IP{a4, b4}); fmt.Sprint(got) != fmt.Sprint([]net.IP{a4, b4}) {
+		t.Errorf("PreferIPv6: expected IPv4 addresses; got %v", got)
+	}
+
+	// IPv4 addresses in their 16-byte form are IPv4 addresses.
+	m4 := net.IPv4(192, 0, 2, 3)
+	ips = []net.IP{a6, m4}
+	if got, want := PreferIPv4(ips), []net.IP{m4, a6}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PreferIPv4(%v): expected %v; got %v", ips, want, got)
+	}
+	if got, want := DualStack([]net.IP{m4, b4, a6}), []net.IP{m4, a6}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("DualStack: expected %v; got %v", want, got)
+	}
+	if got, want := Interleave([]net.IP{m4, b4, a6}), []net.IP{m4, a6, b4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Interleave: expected %v; got %v", want, got)
+	}
+}
+
+// refusedPort returns a port on which nothing is listening.
+func refusedPort(t *testing.T) string {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	return port
+}
+
+func TestDialSerialFallback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	d := &Dialer{
+		Resolver:      staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)},
+		IPFilter:      allIPs,
+		HappyEyeballs: true, // a single family is dialed serially
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+}
+
+func TestDialerFastFallback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	for _, delay := range []time.Duration{0, -1} {
+		var attempts []string
+		d := &Dialer{
+			Resolver:      staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 3), net.IPv4(127, 0, 0, 1)},
+			FastFallback:  true, // every address is dialed in order
+			FallbackDelay: delay,
+			Trace: &DialTrace{
+				ConnectStart: func(network, address string) { attempts = append(attempts, address) },
+			},
+		}
+		c, err := d.Dial("tcp", "foo.com:"+port)
+		if err != nil {
+			t.Fatalf("FallbackDelay %v: Dial failed: %v", delay, err)
+		}
+		c.Close()
+		want := []string{"127.0.0.2:" + port, "127.0.0.3:" + port, "127.0.0.1:" + port}
+		if !reflect.DeepEqual(attempts, want) {
+			t.Errorf("FallbackDelay %v: expected attempts %v; got %v", delay, want, attempts)
+		}
+	}
+}
+
+func TestDialErrorAttempts(t *testing.T) {
+	port := refusedPort(t)
+	for _, happy := range []bool{false, true} {
+		d := &Dialer{
+			Resolver:      staticResolver{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 2)},
+			IPFilter:      allIPs,
+			HappyEyeballs: happy,
+		}
+		_, err := d.Dial("tcp", "foo.com:"+port)
+		var derr *DialError
+		if !errors.As(err, &derr) {
+			t.Fatalf("HappyEyeballs %v: expected *DialError; got %v", happy, err)
+		}
+		if len(derr.Attempts) != 2 {
+			t.Fatalf("HappyEyeballs %v: expected 2 attempts; got %d", happy, len(derr.Attempts))
+		}
+		addrs := []string{"127.0.0.1:" + port, "127.0.0.2:" + port}
+		if derr.Address != "foo.com:"+port || !reflect.DeepEqual(derr.Addrs, addrs) {
+			t.Errorf("HappyEyeballs %v: expected foo.com:%s %v; got %s %v", happy, port, addrs, derr.Address, derr.Addrs)
+		}
+		for i, addr := range derr.NetAddrs {
+			if tcpAddr, ok := addr.(*net.TCPAddr); !ok || tcpAddr.String() != addrs[i] {
+				t.Errorf("HappyEyeballs %v: expected *net.TCPAddr %s; got %#v", happy, addrs[i], addr)
+			}
+		}
+		if !isConnRefused(err) {
+			t.Errorf("HappyEyeballs %v: expected connection refused; got %v", happy, err)
+		}
+	}
+}
+
+func TestDialerDeadline(t *testing.T) {
+	now := time.Now()
+	soon, later := now.Add(time.Minute), now.Add(time.Hour)
+	for i, tt := range []struct {
+		timeout  time.Duration
+		deadline time.Time
+		ctx      time.Time
+		expect   time.Time // zero for no deadline
+	}{
+		{0, time.Time{}, time.Time{}, time.Time{}},
+		{0, soon, time.Time{}, soon},
+		{0, later, soon, soon},
+		{0, soon, later, soon},
+		{time.Hour, soon, time.Time{}, soon},
+		{time.Minute, later, time.Time{}, soon},
+		{time.Hour, time.Time{}, soon, soon},
+	} {
+		ctx := context.Background()
+		if !tt.ctx.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, tt.ctx)
+			defer cancel()
+		}
+		d := &Dialer{Timeout: tt.timeout, Deadline: tt.deadline}
+		ctx, cancel := d.withDeadline(ctx)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		if ok != !tt.expect.IsZero() {
+			t.Errorf("test %d: expected deadline %v; got %v", i, !tt.expect.IsZero(), ok)
+			continue
+		}
+		// Allow for the time elapsed since now.
+		if diff := deadline.Sub(tt.expect); ok && (diff < 0 || diff > time.Second) {
+			t.Errorf("test %d: deadline: expected %v; got %v", i, tt.expect, deadline)
+		}
+	}
+}
+
+func TestDialPastDeadline(t *testing.T) {
+	d := &Dialer{Deadline: time.Now().Add(-time.Second)}
+	_, err := d.Dial("tcp", "127.0.0.1:80")
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("expected timeout error; got %v", err)
+	}
+}
+
+func TestPartialDeadline(t *testing.T) {
+	var testCases = []struct {
+		now            time.Time
+		deadline       time.Time
+		addrs          int
+		expectDeadline time.Time
+		expectErr      error
+	}{
+		// Regular division.
+		{time.Unix(0, 0), time.Unix(0, 0).Add(10 * time.Second), 1, time.Unix(0, 0).Add(10 * time.Second), nil},
+		{time.Unix(0, 0), time.Unix(0, 0).Add(10 * time.Second), 2, time.Unix(0, 0).Add(5 * time.Second), nil},
+		{time.Unix(0, 0), time.Unix(0, 0).Add(10 * time.Second), 3, time.Unix(0, 0).Add(10 * time.Second / 3), nil},
+		// Bump against the 2-second sane minimum.
+		{time.Unix(0, 0), time.Unix(0, 0).Add(10 * time.Second), 10, time.Unix(0, 0).Add(2 * time.Second), nil},
+		// Total available is now below the sane minimum.
+		{time.Unix(0, 0), time.Unix(0, 0).Add(time.Second), 10, time.Unix(0, 0).Add(time.Second), nil},
+		// No timeout.
+		{time.Unix(0, 0), time.Time{}, 1, time.Time{}, nil},
+		// Step the clock forward and cross the deadline.
+		{time.Unix(0, 0).Add(time.Second), time.Unix(0, 0), 1, time.Time{}, errTimeout},
+		{time.Unix(0, 0).Add(time.Second), time.Unix(0, 0).Add(time.Second), 1, time.Time{}, errTimeout},
+	}
+	for i, tt := range testCases {
+		deadline, err := partialDeadline(tt.now, tt.deadline, tt.addrs)
+		if err != tt.expectErr {
+			t.Errorf("test %d: error: expected %v; got %v", i, tt.expectErr, err)
+		}
+		if !deadline.Equal(tt.expectDeadline) {
+			t.Errorf("test %d: deadline: expected %v; got %v", i, tt.expectDeadline, deadline)
+		}
+	}
+}
+
+func TestDialLocalAddrFamily(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	first := func(ips []net.IP) []net.IP {
+		if len(ips) > 1 {
+			return ips[:1]
+		}
+		return ips
+	}
+	d := &Dialer{
+		Resolver:  staticResolver{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		IPFilter:  first,
+		LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)},
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+
+	d.Resolver = staticResolver{net.IPv6loopback}
+	_, err = d.Dial("tcp", "foo.com:"+port)
+	if err == nil || err.(*net.OpError).Err != ErrNoSuitableAddress {
+		t.Fatalf("expected %v; got %v", ErrNoSuitableAddress, err)
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var calls []string
+	d := &Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			calls = append(calls, network+" "+address)
+			return nil
+		},
+	}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if exp := []string{"tcp4 " + ln.Addr().String()}; !reflect.DeepEqual(calls, exp) {
+		t.Fatalf("Control calls: expected %v; got %v", exp, calls)
+	}
+
+	errControl := errors.New("control failed")
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		return errControl
+	}
+	if _, err := d.Dial("tcp", ln.Addr().String()); !errors.Is(err, errControl) {
+		t.Fatalf("expected %v; got %v", errControl, err)
+	}
+}
+
+func TestDialErrorSingleAttempt(t *testing.T) {
+	port := refusedPort(t)
+	_, err := (&Dialer{}).Dial("tcp", "127.0.0.1:"+port)
+	derr, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("expected *DialError; got %T: %v", err, err)
+	}
+	if len(derr.Attempts) != 1 || derr.Attempts[0].Addr != "127.0.0.1:"+port {
+		t.Fatalf("unexpected attempts: %v", derr.Attempts)
+	}
+	var operr *net.OpError
+	if !errors.As(err, &operr) || derr.Error() != operr.Error() {
+		t.Errorf("expected the message of the lone attempt; got %q", derr.Error())
+	}
+}
+
+func TestDialerClone(t *testing.T) {
+	d := &Dialer{
+		Timeout:     time.Second,
+		Retry:       &RetryPolicy{MaxAttempts: 2},
+		Trace:       &DialTrace{},
+		FailedAddrs: &FailedAddrCache{},
+		Health:      &AddressHealth{},
+		Limiter:     &DialLimiter{MaxConcurrentDials: 1},
+	}
+	c := d.Clone()
+	c.Timeout = 2 * time.Second
+	c.Retry.MaxAttempts = 3
+	c.Trace.DNSStart = func(string) {}
+	if d.Timeout != time.Second || d.Retry.MaxAttempts != 2 || d.Trace.DNSStart != nil {
+		t.Errorf("original was modified: %+v", d)
+	}
+	if c.FailedAddrs != d.FailedAddrs {
+		t.Error("expected FailedAddrs to be shared")
+	}
+	if c.Health != d.Health {
+		t.Error("expected Health to be shared")
+	}
+	if c.Limiter != d.Limiter {
+		t.Error("expected Limiter to be shared")
+	}
+}
+
+func TestDialRaceLoser(t *testing.T) {
+	loser := "192.0.2.2:80"
+	addrs := tcpList{{IP: net.IPv4(192, 0, 2, 1), Port: 80}, {IP: net.IPv4(192, 0, 2, 2), Port: 80}}
+	for _, parallel := range []bool{false, true} {
+		started, release := make(chan struct{}), make(chan struct{})
+		dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+			if address == loser {
+				close(started)
+				<-release // connect despite the cancellation
+			} else {
+				<-started
+			}
+			c, _ := net.Pipe()
+			return c, nil
+		}
+		lost := make(chan net.Conn, 1)
+		var c net.Conn
+		if parallel {
+			inOrder := func(ctx context.Context, addrs addrList) (net.Conn, []*AttemptError) {
+				return dialSerial(ctx, dial, "tcp", addrs, 0)
+			}
+			c, _ = dialParallel(context.Background(), inOrder, addrs[1:], addrs[:1], 0, func(c net.Conn) { lost <- c })
+		} else {
+			c, _ = dialMulti(context.Background(), dial, "tcp", addrs, func(c net.Conn) { lost <- c })
+		}
+		if c == nil {
+			t.Fatalf("parallel %v: expected a connection", parallel)
+		}
+		close(release)
+		select {
+		case l := <-lost:
+			if l == c {
+				t.Errorf("parallel %v: expected the loser; got the winner", parallel)
+			}
+			l.Close()
+		case <-time.After(5 * time.Second):
+			t.Errorf("parallel %v: the loser wasn't handed off", parallel)
+		}
+		c.Close()
+	}
+}
+
+func TestDialerRaceLoser(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	tests := []struct {
+		name  string
+		loser net.IP
+		d     *Dialer
+	}{
+		{"multi", net.IPv4(127, 0, 0, 2), &Dialer{}},
+		{"staggered", net.IPv4(127, 0, 0, 2), &Dialer{FastFallback: true, StaggerAttempts: true, AttemptDelay: 10 * time.Millisecond}},
+		{"parallel", net.IPv6loopback, &Dialer{FastFallback: true, FallbackDelay: 10 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := "tcp4"
+			if tt.loser.To4() == nil {
+				network = "tcp6"
+			}
+			ln, err := net.Listen(network, net.JoinHostPort(tt.loser.String(), port))
+			if err != nil {
+				t.Skipf("can't listen on %v: %v", tt.loser, err)
+			}
+			defer ln.Close()
+
+			// The winner connects once the loser has started, but the
+			// loser connects only after the dial has returned.
+			started, won := make(chan struct{}), make(chan struct{})
+			lost := make(chan net.Conn, 1)
+			d := tt.d
+			d.Resolver = staticResolver{net.IPv4(127, 0, 0, 1), tt.loser}
+			d.IPFilter = allIPs
+			d.Timeout = 5 * time.Second
+			d.Control = func(network, address string, c syscall.RawConn) error {
+				if net.ParseIP(hostOf(address)).Equal(tt.loser) {
+					close(started)
+					<-won
+				} else {
+					<-started
+				}
+				return nil
+			}
+			d.OnRaceLoser = func(c net.Conn) { lost <- c }
+			c, err := d.Dial("tcp", net.JoinHostPort("foo.com", port))
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer c.Close()
+			close(won)
+			select {
+			case l := <-lost:
+				defer l.Close()
+				if ip := l.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(tt.loser) {
+					t.Errorf("expected the loser to be connected to %v; got %v", tt.loser, ip)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("the loser wasn't handed off")
+			}
+		})
+	}
+}
+
+func TestDialAttemptDelay(t *testing.T) {
+	addrs := tcpList{
+		{IP: net.IPv4(192, 0, 2, 1), Port: 80},
+		{IP: net.IPv4(192, 0, 2, 2), Port: 80},
+		{IP: net.IPv4(192, 0, 2, 3), Port: 80},
+	}
+	errRefused := errors.New("refused")
+	refuse := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errRefused
+	}
+	const delay = 20 * time.Millisecond
+	start := time.Now()
+	if _, attempts := dialSerial(context.Background(), refuse, "tcp", addrs, delay); len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts; got %d", len(attempts))
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Errorf("expected the attempts to be at least %v apart; took %v", delay, elapsed)
+	}
+
+	// The first address hangs, so the second wins once it starts.
+	hang := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "192.0.2.1:80" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		c, _ := net.Pipe()
+		return c, nil
+	}
+	c, attempts := dialStaggered(context.Background(), hang, "tcp", addrs, delay, closeConn)
+	if c == nil {
+		t.Fatalf("expected a connection; got attempts %v", attempts)
+	}
+	c.Close()
+
+	// Without a delay, every address is dialed at once.
+	var started sync.WaitGroup
+	started.Add(len(addrs))
+	wait := func(ctx context.Context, network, address string) (net.Conn, error) {
+		started.Done()
+		started.Wait()
+		c, _ := net.Pipe()
+		return c, nil
+	}
+	lost := make(chan net.Conn, len(addrs))
+	c, _ = dialStaggered(context.Background(), wait, "tcp", addrs, 0, func(c net.Conn) { lost <- c })
+	if c == nil {
+		t.Fatal("expected a connection")
+	}
+	c.Close()
+	for i := 0; i < len(addrs)-1; i++ {
+		(<-lost).Close()
+	}
+}
+
 func TestDialMulti(t *testing.T) {
 	ips, err := lookupIPs("localhost")
 	if err != nil {
@@ -79,3 +747,169 @@ func TestDialMulti(t *testing.T) {
 		}
 	}
 }
+
+// contextResolver blocks until its context is done.
+type contextResolver struct{}
+
+func (contextResolver) Resolve(host string) ([]net.IP, error) {
+	return nil, errors.New("unexpected Resolve")
+}
+
+func (contextResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDialContextResolver(t *testing.T) {
+	d := &Dialer{Resolver: contextResolver{}, Timeout: 50 * time.Millisecond}
+	_, err := d.Dial("tcp", "foo.com:80")
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("expected %v; got %v", errTimeout, err)
+	}
+}
+
+func TestDialerNetAddrFilter(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	var (
+		dialed []string
+		seen   []net.Addr
+	)
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)},
+		IPFilter: func(ips []net.IP) []net.IP { return ips },
+		NetAddrFilter: func(addrs []net.Addr) []net.Addr {
+			seen = addrs
+			var a []net.Addr
+			for _, addr := range addrs {
+				if addr.(*net.TCPAddr).IP.Equal(net.IPv4(127, 0, 0, 1)) {
+					a = append(a, addr)
+				}
+			}
+			return a
+		},
+		Trace: &DialTrace{
+			ConnectStart: func(network, address string) { dialed = append(dialed, address) },
+		},
+	}
+	c, err := d.Dial("tcp", fmt.Sprintf("foo.com:%d", port))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if len(seen) != 2 || seen[0].(*net.TCPAddr).Port != port {
+		t.Errorf("expected both addresses with port %d; got %v", port, seen)
+	}
+	if want := []string{ln.Addr().String()}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("expected to dial %v; got %v", want, dialed)
+	}
+
+	d.NetAddrFilter = func(addrs []net.Addr) []net.Addr { return nil }
+	if _, err := d.Dial("tcp", "foo.com:443"); !errors.Is(err, ErrNoSuitableAddress) {
+		t.Errorf("expected error %v; got %v", ErrNoSuitableAddress, err)
+	}
+}
+
+func TestDialerPolicy(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	errDenied := errors.New("denied")
+	type call struct {
+		host string
+		ip   string
+		port int
+	}
+	var calls []call
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(169, 254, 169, 254), net.IPv4(127, 0, 0, 1)},
+		IPFilter: allIPs,
+		// Dial the addresses serially.
+		HappyEyeballs: true,
+		Policy: func(host string, ip net.IP, port int) error {
+			calls = append(calls, call{host, ip.String(), port})
+			if ip.IsLinkLocalUnicast() || len(ip) != net.IPv4len {
+				return errDenied
+			}
+			return nil
+		},
+	}
+	c, err := d.Dial("tcp", net.JoinHostPort("foo.com", port))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	p, _ := strconv.Atoi(port)
+	want := []call{{"foo.com", "169.254.169.254", p}, {"foo.com", "127.0.0.1", p}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected %v; got %v", want, calls)
+	}
+
+	d.Resolver = staticResolver{net.IPv4(169, 254, 169, 254)}
+	_, err = d.Dial("tcp", net.JoinHostPort("foo.com", port))
+	if !errors.Is(err, errDenied) {
+		t.Errorf("expected %v; got %v", errDenied, err)
+	}
+}
+
+func TestDialerMapPort(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	var dialed []string
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		MapPort: func(ip net.IP, p int) int {
+			if p == 443 {
+				return port
+			}
+			return p
+		},
+		Trace: &DialTrace{
+			ConnectStart: func(network, address string) { dialed = append(dialed, address) },
+		},
+	}
+	c, err := d.Dial("tcp", "foo.com:443")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	want := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if c.RemoteAddr().String() != want {
+		t.Errorf("expected to connect to %s; got %v", want, c.RemoteAddr())
+	}
+	if !reflect.DeepEqual(dialed, []string{want}) {
+		t.Errorf("expected to dial %s; got %v", want, dialed)
+	}
+}
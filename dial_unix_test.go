@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package nett
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDialKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	for _, tt := range []struct {
+		keepAlive time.Duration
+		enabled   bool
+	}{
+		{0, true},
+		{time.Minute, true},
+		{-1, false},
+	} {
+		d := &Dialer{KeepAlive: tt.keepAlive}
+		c, err := d.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		raw, err := c.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v int
+		raw.Control(func(fd uintptr) {
+			v, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		})
+		c.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enabled := v != 0; enabled != tt.enabled {
+			t.Errorf("KeepAlive %v: expected enabled %v; got %v", tt.keepAlive, tt.enabled, enabled)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrDialLimit is returned, wrapped in a *net.OpError, by dials that
+// exceed the MaxConcurrentDials of a failing-fast DialLimiter.
+var ErrDialLimit error = &dialLimitError{}
+
+type dialLimitError struct{}
+
+func (e *dialLimitError) Error() string   { return "too many concurrent dials" }
+func (e *dialLimitError) Timeout() bool   { return false }
+func (e *dialLimitError) Temporary() bool { return true }
+
+// A DialLimiter limits the number of dials in progress, so that a
+// burst of outbound requests can't exhaust file descriptors or the
+// SYN backlog. When it is the Limiter of a Dialer, each dial holds a
+// slot from resolving its host until it connects or fails, including
+// its retries. It may be shared by several Dialers to limit their
+// dials together.
+//
+// A DialLimiter is safe for concurrent use by multiple goroutines.
+// Its fields must not be changed once it has been used.
+type DialLimiter struct {
+	// MaxConcurrentDials is the maximum number of dials in
+	// progress. If zero, dials are not limited.
+	MaxConcurrentDials int
+
+	// FailFast makes dials that exceed MaxConcurrentDials fail
+	// with ErrDialLimit. Otherwise, they wait for a dial in progress
+	// to finish, until their context is done.
+	FailFast bool
+
+	once sync.Once
+	sem  chan struct{}
+}
+
+func (l *DialLimiter) init() {
+	l.once.Do(func() {
+		if l.MaxConcurrentDials > 0 {
+			l.sem = make(chan struct{}, l.MaxConcurrentDials)
+		}
+	})
+}
+
+// Active returns the number of dials in progress.
+func (l *DialLimiter) Active() int {
+	l.init()
+	return len(l.sem)
+}
+
+// acquire takes a slot for a dial, waiting for one if required.
+// The returned function releases it.
+func (l *DialLimiter) acquire(ctx context.Context) (release func(), err error) {
+	l.init()
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	release = func() { <-l.sem }
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if l.FailFast {
+		return nil, ErrDialLimit
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, mapErr(ctx.Err())
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialLimiter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// The resolver blocks the first dial until unblocked.
+	started, unblock := make(chan bool, 1), make(chan bool)
+	resolver := resolverFunc(func(host string) ([]net.IP, error) {
+		if host == "slow.test" {
+			started <- true
+			<-unblock
+		}
+		return parseIPs("127.0.0.1"), nil
+	})
+
+	for _, failFast := range []bool{true, false} {
+		limiter := &DialLimiter{MaxConcurrentDials: 1, FailFast: failFast}
+		d := &Dialer{Resolver: resolver, Limiter: limiter}
+		errc := make(chan error, 1)
+		go func() {
+			c, err := d.Dial("tcp", net.JoinHostPort("slow.test", port))
+			if err == nil {
+				c.Close()
+			}
+			errc <- err
+		}()
+		<-started
+		if n := limiter.Active(); n != 1 {
+			t.Errorf("failFast=%v: expected 1 active dial; got %d", failFast, n)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := d.DialContext(ctx, "tcp", net.JoinHostPort("fast.test", port))
+		cancel()
+		if failFast {
+			if !errors.Is(err, ErrDialLimit) {
+				t.Errorf("expected ErrDialLimit; got %v", err)
+			}
+			var nerr net.Error
+			if !errors.As(err, &nerr) || !nerr.Temporary() {
+				t.Errorf("expected a temporary error; got %v", err)
+			}
+		} else if !errors.Is(err, errTimeout) {
+			t.Errorf("expected the dial to wait until its deadline; got %v", err)
+		}
+
+		unblock <- true
+		if err := <-errc; err != nil {
+			t.Errorf("failFast=%v: Dial failed: %v", failFast, err)
+		}
+		if n := limiter.Active(); n != 0 {
+			t.Errorf("failFast=%v: expected 0 active dials; got %d", failFast, n)
+		}
+		c, err := d.Dial("tcp", net.JoinHostPort("fast.test", port))
+		if err != nil {
+			t.Fatalf("failFast=%v: Dial failed: %v", failFast, err)
+		}
+		c.Close()
+	}
+}
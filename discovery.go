@@ -0,0 +1,90 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// A Discoverer looks up the addresses of services in a service
+// discovery system, such as Consul, etcd or Kubernetes Endpoints.
+//
+// A Discoverer must be safe for concurrent use by multiple goroutines.
+type Discoverer interface {
+	// Discover returns the current IP addresses of the service.
+	Discover(ctx context.Context, service string) ([]net.IP, error)
+}
+
+// The DiscovererFunc type is an adapter to allow the use of ordinary
+// functions as Discoverers.
+type DiscovererFunc func(ctx context.Context, service string) ([]net.IP, error)
+
+// Discover returns fn(ctx, service).
+func (fn DiscovererFunc) Discover(ctx context.Context, service string) ([]net.IP, error) {
+	return fn(ctx, service)
+}
+
+// DiscoveryResolver resolves hosts as the names of services in a
+// service discovery system, so that their addresses are filtered and
+// dialed like those of any other host. Systems that push changes may
+// call Update rather than, or in addition to, implementing Discoverer.
+type DiscoveryResolver struct {
+	// Discoverer looks up services that have not been updated.
+	// If nil, only updated services are resolved.
+	Discoverer Discoverer
+
+	// Cache, if non-nil, is a CacheResolver wrapping the
+	// DiscoveryResolver whose entries are removed when the
+	// services they resolve are updated.
+	Cache *CacheResolver
+
+	mu       sync.RWMutex
+	services map[string][]net.IP
+}
+
+// Resolve looks up the given service and returns its IP addresses.
+func (r *DiscoveryResolver) Resolve(service string) ([]net.IP, error) {
+	return r.ResolveContext(context.Background(), service)
+}
+
+// ResolveContext looks up the given service using the provided
+// context and returns its IP addresses.
+func (r *DiscoveryResolver) ResolveContext(ctx context.Context, service string) ([]net.IP, error) {
+	r.mu.RLock()
+	ips, ok := r.services[service]
+	r.mu.RUnlock()
+	if ok {
+		if len(ips) == 0 {
+			return nil, &net.DNSError{Err: "no such service", Name: service, IsNotFound: true}
+		}
+		return copyIPs(ips), nil
+	}
+	if r.Discoverer == nil {
+		return nil, &net.DNSError{Err: "no such service", Name: service, IsNotFound: true}
+	}
+	return r.Discoverer.Discover(ctx, service)
+}
+
+// Update sets the addresses of the service, which are returned by
+// subsequent lookups instead of those of the Discoverer, and removes
+// the service from the Cache. If ips is nil, the service is instead
+// looked up with the Discoverer again.
+func (r *DiscoveryResolver) Update(service string, ips []net.IP) {
+	r.mu.Lock()
+	if ips == nil {
+		delete(r.services, service)
+	} else {
+		if r.services == nil {
+			r.services = make(map[string][]net.IP)
+		}
+		r.services[service] = copyIPs(ips)
+	}
+	r.mu.Unlock()
+	if r.Cache != nil {
+		r.Cache.Remove(service)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoveryResolver(t *testing.T) {
+	discovered := []net.IP{net.IPv4(10, 0, 0, 1).To4()}
+	updated := []net.IP{net.IPv4(10, 0, 0, 2).To4()}
+	r := &DiscoveryResolver{
+		Discoverer: DiscovererFunc(func(ctx context.Context, service string) ([]net.IP, error) {
+			return discovered, nil
+		}),
+	}
+	cache := &CacheResolver{Resolver: r}
+	r.Cache = cache
+
+	validate := func(want []net.IP) {
+		t.Helper()
+		ips, err := cache.Resolve("api.service")
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		if !reflect.DeepEqual(ips, want) {
+			t.Fatalf("expected %v; got %v", want, ips)
+		}
+	}
+	validate(discovered)
+	r.Update("api.service", updated)
+	validate(updated)
+	r.Update("api.service", nil)
+	validate(discovered)
+	r.Update("api.service", []net.IP{})
+	if _, err := cache.Resolve("api.service"); err == nil {
+		t.Fatal("expected error for service without addresses")
+	}
+}
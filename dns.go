@@ -0,0 +1,604 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNS message constants. See RFC 1035 and RFC 3596.
+const (
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypePTR   = 12
+	dnsTypeTXT   = 16
+	dnsTypeAAAA  = 28
+	dnsTypeSRV   = 33
+	dnsTypeOPT   = 41
+
+	dnsClassINET = 1
+
+	dnsRcodeSuccess   = 0
+	dnsRcodeServFail  = 2
+	dnsRcodeNameError = 3
+	dnsRcodeRefused   = 5
+
+	dnsHeaderLen        = 12
+	dnsFlagResponse     = 1 << 15
+	dnsFlagTruncated    = 1 << 9
+	dnsFlagRecursion    = 1 << 8
+	dnsFlagAuthentic    = 1 << 5
+	dnsMaxNameLen       = 255
+	dnsMaxLabelLen      = 63
+	dnsMaxPointerChains = 10
+
+	// EDNS(0) constants. See RFC 6891 and RFC 7871.
+	ednsUDPSize         = 1232
+	ednsOptClientSubnet = 8
+	ednsFlagDNSSECOK    = 1 << 15
+)
+
+// ErrUnauthenticatedResponse is returned by the DNS resolvers when
+// DNSSEC is required and the server doesn't report that its answer is
+// authenticated.
+var ErrUnauthenticatedResponse = errors.New("DNS response not authenticated")
+
+var (
+	errDNSInvalidName = errors.New("invalid domain name")
+	errDNSShortMsg    = errors.New("short DNS message")
+	errDNSMismatch    = errors.New("mismatched DNS response")
+	errDNSPointerLoop = errors.New("too many DNS compression pointers")
+	errDNSSubnet      = errors.New("invalid EDNS client subnet")
+)
+
+// A dnsExchangeFunc sends a DNS query message to a server and returns
+// its response message.
+type dnsExchangeFunc func(ctx context.Context, query []byte) ([]byte, error)
+
+// dnsMsg is a parsed DNS response message.
+type dnsMsg struct {
+	id            uint16
+	rcode         int
+	truncated     bool
+	authenticated bool
+	answers       []dnsRR
+	raw           []byte // for reading the compressed names of records
+}
+
+// dnsRR is a resource record of a DNS response message.
+type dnsRR struct {
+	name  string
+	typ   uint16
+	class uint16
+	ttl   uint32
+	data  []byte
+	off   int // offset of data in the message
+}
+
+// dnsOptions holds the EDNS(0) options of queries.
+type dnsOptions struct {
+	// clientSubnet, if non-nil, is sent as the EDNS Client
+	// Subnet option.
+	clientSubnet *net.IPNet
+
+	// dnssec requests DNSSEC records and requires the answer
+	// to be authenticated by the server.
+	dnssec bool
+}
+
+// newDNSQuery returns a recursive query message for the records of
+// the given type for name, with opts if non-nil.
+func newDNSQuery(id uint16, name string, qtype uint16, opts *dnsOptions) ([]byte, error) {
+	b := make([]byte, dnsHeaderLen, 512)
+	binary.BigEndian.PutUint16(b[0:], id)
+	flags := uint16(dnsFlagRecursion)
+	if opts != nil && opts.dnssec {
+		flags |= dnsFlagAuthentic
+	}
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], 1) // QDCOUNT
+	b, err := appendDNSName(b, name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	b = binary.BigEndian.AppendUint16(b, dnsClassINET)
+	if opts != nil && (opts.clientSubnet != nil || opts.dnssec) {
+		binary.BigEndian.PutUint16(b[10:], 1) // ARCOUNT
+		if b, err = opts.appendOPT(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendOPT appends an OPT pseudo-record holding the options to b.
+func (opts *dnsOptions) appendOPT(b []byte) ([]byte, error) {
+	b = append(b, 0) // root domain
+	b = binary.BigEndian.AppendUint16(b, dnsTypeOPT)
+	b = binary.BigEndian.AppendUint16(b, ednsUDPSize)
+	var flags uint32 // and extended RCODE and version
+	if opts.dnssec {
+		flags |= ednsFlagDNSSECOK
+	}
+	b = binary.BigEndian.AppendUint32(b, flags)
+	var data []byte
+	if subnet := opts.clientSubnet; subnet != nil {
+		ip := subnet.IP
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		// Mask drops the leading 96 bits of a 16-byte mask applied
+		// to an IPv4 address, such as that of a prefix written as
+		// "::ffff:192.0.2.0/120", and fails if they don't match.
+		addr := ip.Mask(subnet.Mask)
+		ones, total := subnet.Mask.Size()
+		if addr == nil || total == 0 {
+			return nil, errDNSSubnet
+		}
+		family := uint16(1)
+		if len(addr) == net.IPv6len {
+			family = 2
+		}
+		bits := ones - (total - 8*len(addr))
+		addr = addr[:(bits+7)/8]
+		data = binary.BigEndian.AppendUint16(data, ednsOptClientSubnet)
+		data = binary.BigEndian.AppendUint16(data, uint16(4+len(addr)))
+		data = binary.BigEndian.AppendUint16(data, family)
+		data = append(data, byte(bits), 0) // source and scope prefix lengths
+		data = append(data, addr...)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...), nil
+}
+
+// appendDNSName appends the wire format of the domain name to b.
+func appendDNSName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > dnsMaxNameLen-2 {
+		return nil, errDNSInvalidName
+	}
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > dnsMaxLabelLen {
+				return nil, errDNSInvalidName
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// parseDNSResponse parses a response to query. Its answers are limited
+// to those about the name of the question, as by chainAnswers.
+func parseDNSResponse(b, query []byte) (*dnsMsg, error) {
+	if len(b) < dnsHeaderLen {
+		return nil, errDNSShortMsg
+	}
+	flags := binary.BigEndian.Uint16(b[2:])
+	msg := &dnsMsg{
+		id:        binary.BigEndian.Uint16(b[0:]),
+		rcode:     int(flags & 0xF),
+		truncated: flags&dnsFlagTruncated != 0,
+
+		authenticated: flags&dnsFlagAuthentic != 0,
+		raw:           b,
+	}
+	if msg.id != binary.BigEndian.Uint16(query) || flags&dnsFlagResponse == 0 {
+		return nil, errDNSMismatch
+	}
+	name, off, ok := matchDNSQuestion(query, b)
+	if !ok {
+		return nil, errDNSMismatch
+	}
+	ancount := int(binary.BigEndian.Uint16(b[6:]))
+	for i := 0; i < ancount; i++ {
+		var (
+			rr  dnsRR
+			err error
+		)
+		if rr.name, off, err = readDNSName(b, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(b) {
+			return nil, errDNSShortMsg
+		}
+		rr.typ = binary.BigEndian.Uint16(b[off:])
+		rr.class = binary.BigEndian.Uint16(b[off+2:])
+		rr.ttl = binary.BigEndian.Uint32(b[off+4:])
+		n := int(binary.BigEndian.Uint16(b[off+8:]))
+		if off += 10; off+n > len(b) {
+			return nil, errDNSShortMsg
+		}
+		rr.data, rr.off = b[off:off+n], off
+		off += n
+		msg.answers = append(msg.answers, rr)
+	}
+	msg.answers = chainAnswers(b, name, msg.answers)
+	return msg, nil
+}
+
+// matchDNSQuestion reports whether the message resp has the single
+// question of query, comparing their names case-insensitively, and
+// returns the name with the offset following the question in resp.
+func matchDNSQuestion(query, resp []byte) (string, int, bool) {
+	if len(resp) < dnsHeaderLen || binary.BigEndian.Uint16(resp[4:]) != 1 {
+		return "", 0, false
+	}
+	qname, qoff, err := readDNSName(query, dnsHeaderLen)
+	if err != nil || qoff+4 > len(query) {
+		return "", 0, false
+	}
+	name, off, err := readDNSName(resp, dnsHeaderLen)
+	if err != nil || off+4 > len(resp) || !strings.EqualFold(name, qname) {
+		return "", 0, false
+	}
+	if !bytes.Equal(resp[off:off+4], query[qoff:qoff+4]) { // type and class
+		return "", 0, false
+	}
+	return qname, off + 4, true
+}
+
+// chainAnswers returns the records of class IN among answers whose
+// owner is name or the target of a chain of CNAME records from it, so
+// that records of other names, such as those forged to poison a cache,
+// are ignored.
+func chainAnswers(msg []byte, name string, answers []dnsRR) []dnsRR {
+	names := []string{name}
+	for i := 0; i < len(names); i++ {
+		for _, rr := range answers {
+			if rr.typ != dnsTypeCNAME || rr.class != dnsClassINET || !strings.EqualFold(rr.name, names[i]) {
+				continue
+			}
+			target, _, err := readDNSName(msg, rr.off)
+			if err == nil && !containsName(names, target) {
+				names = append(names, target)
+			}
+		}
+	}
+	a := answers[:0]
+	for _, rr := range answers {
+		if rr.class == dnsClassINET && containsName(names, rr.name) {
+			a = append(a, rr)
+		}
+	}
+	return a
+}
+
+// containsName reports whether names contains name, ignoring case.
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// readDNSName reads the possibly compressed domain name at off in msg
+// and returns it with the offset following it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var (
+		name     []byte
+		next     = -1
+		pointers = 0
+	)
+	for {
+		if off >= len(msg) {
+			return "", 0, errDNSShortMsg
+		}
+		n := int(msg[off])
+		switch n & 0xC0 {
+		case 0x00:
+			off++
+			if n == 0 {
+				if next < 0 {
+					next = off
+				}
+				if len(name) == 0 {
+					return ".", next, nil
+				}
+				return string(name), next, nil
+			}
+			if off+n > len(msg) {
+				return "", 0, errDNSShortMsg
+			}
+			name = append(name, msg[off:off+n]...)
+			name = append(name, '.')
+			if len(name) > dnsMaxNameLen {
+				return "", 0, errDNSInvalidName
+			}
+			off += n
+		case 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errDNSShortMsg
+			}
+			if pointers++; pointers > dnsMaxPointerChains {
+				return "", 0, errDNSPointerLoop
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		default:
+			return "", 0, errDNSInvalidName
+		}
+	}
+}
+
+// lookupDNS queries the records of the given type for name.
+func lookupDNS(ctx context.Context, exchange dnsExchangeFunc, name string, qtype uint16, opts *dnsOptions) (*dnsMsg, error) {
+	id := uint16(rand.Uint32())
+	query, err := newDNSQuery(id, name, qtype, opts)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name}
+	}
+	resp, err := exchange(ctx, query)
+	if err != nil {
+		return nil, &net.DNSError{
+			Err:         err.Error(),
+			Name:        name,
+			IsTimeout:   isTimeout(err),
+			IsTemporary: true,
+		}
+	}
+	msg, err := parseDNSResponse(resp, query)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, IsTemporary: true}
+	}
+	switch msg.rcode {
+	case dnsRcodeSuccess:
+		if opts != nil && opts.dnssec && !msg.authenticated {
+			return nil, fmt.Errorf("lookup %s: %w", name, ErrUnauthenticatedResponse)
+		}
+		return msg, nil
+	case dnsRcodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	case dnsRcodeServFail:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name}
+	}
+}
+
+// resolveDNS looks up the IPv4 and IPv6 addresses of host and returns
+// them with the lowest TTL of the records from which they were taken.
+// The queries for each family are sent concurrently, and if one fails
+// the addresses of the other are returned.
+func resolveDNS(ctx context.Context, exchange dnsExchangeFunc, host string, opts *dnsOptions) ([]net.IP, time.Duration, error) {
+	res, err := resolveDNSDetailed(ctx, exchange, host, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.IPs, res.TTL, nil
+}
+
+// resolveDNSDetailed is like resolveDNS, but also returns the error of
+// the query for each family.
+func resolveDNSDetailed(ctx context.Context, exchange dnsExchangeFunc, host string, opts *dnsOptions) (*DetailedResult, error) {
+	qtypes := [...]uint16{dnsTypeA, dnsTypeAAAA}
+	type result struct {
+		msg *dnsMsg
+		err error
+	}
+	var results [len(qtypes)]chan result
+	for i, qtype := range qtypes {
+		results[i] = make(chan result, 1)
+		go func(qtype uint16, c chan<- result) {
+			msg, err := lookupDNS(ctx, exchange, host, qtype, opts)
+			c <- result{msg, err}
+		}(qtype, results[i])
+	}
+	var (
+		res     = &DetailedResult{TTL: -1}
+		errs    [len(qtypes)]error
+		lasterr error
+	)
+	for i, c := range results {
+		r := <-c
+		if r.err != nil {
+			errs[i], lasterr = r.err, r.err
+			continue
+		}
+		res.IPs, res.TTL = appendAnswerIPs(res.IPs, res.TTL, r.msg)
+	}
+	if len(res.IPs) == 0 {
+		if lasterr == nil {
+			lasterr = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, lasterr
+	}
+	res.IPv4Err, res.IPv6Err = errs[0], errs[1]
+	return res, nil
+}
+
+// resolveDNSFamily is like resolveDNS, but only looks up the addresses
+// of the family of network, "ip4" or "ip6".
+func resolveDNSFamily(ctx context.Context, exchange dnsExchangeFunc, network, host string, opts *dnsOptions) ([]net.IP, time.Duration, error) {
+	var qtype uint16 = dnsTypeA
+	if network == "ip6" {
+		qtype = dnsTypeAAAA
+	}
+	msg, err := lookupDNS(ctx, exchange, host, qtype, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips, ttl := appendAnswerIPs(nil, -1, msg)
+	if len(ips) == 0 {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, ttl, nil
+}
+
+// appendAnswerIPs appends the addresses answered by msg to ips and
+// lowers ttl, or sets it if negative, to the lowest TTL of the records
+// from which they were taken.
+func appendAnswerIPs(ips []net.IP, ttl time.Duration, msg *dnsMsg) ([]net.IP, time.Duration) {
+	for _, rr := range msg.answers {
+		switch {
+		case rr.typ == dnsTypeA && len(rr.data) == net.IPv4len:
+			ips = append(ips, net.IP(append([]byte(nil), rr.data...)))
+		case rr.typ == dnsTypeAAAA && len(rr.data) == net.IPv6len:
+			ips = append(ips, net.IP(append([]byte(nil), rr.data...)))
+		case rr.typ == dnsTypeCNAME:
+		default:
+			continue
+		}
+		if d := time.Duration(rr.ttl) * time.Second; ttl < 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return ips, ttl
+}
+
+// resolveDNSSRV looks up the SRV records with the given name and
+// returns them sorted by SortSRV.
+func resolveDNSSRV(ctx context.Context, exchange dnsExchangeFunc, name string, opts *dnsOptions) ([]*net.SRV, error) {
+	msg, err := lookupDNS(ctx, exchange, name, dnsTypeSRV, opts)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []*net.SRV
+	for _, rr := range msg.answers {
+		if rr.typ != dnsTypeSRV {
+			continue
+		}
+		if len(rr.data) < 7 {
+			return nil, &net.DNSError{Err: errDNSShortMsg.Error(), Name: name, IsTemporary: true}
+		}
+		target, _, err := readDNSName(msg.raw, rr.off+6)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: name, IsTemporary: true}
+		}
+		addrs = append(addrs, &net.SRV{
+			Target:   target,
+			Port:     binary.BigEndian.Uint16(rr.data[4:]),
+			Priority: binary.BigEndian.Uint16(rr.data[0:]),
+			Weight:   binary.BigEndian.Uint16(rr.data[2:]),
+		})
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	SortSRV(addrs)
+	return addrs, nil
+}
+
+// resolveDNSTXT looks up the TXT records with the given name. As with
+// net.LookupTXT, the strings of each record are concatenated.
+func resolveDNSTXT(ctx context.Context, exchange dnsExchangeFunc, name string, opts *dnsOptions) ([]string, error) {
+	msg, err := lookupDNS(ctx, exchange, name, dnsTypeTXT, opts)
+	if err != nil {
+		return nil, err
+	}
+	var txt []string
+	for _, rr := range msg.answers {
+		if rr.typ != dnsTypeTXT {
+			continue
+		}
+		var s []byte
+		for b := rr.data; len(b) > 0; {
+			n := int(b[0])
+			if 1+n > len(b) {
+				return nil, &net.DNSError{Err: errDNSShortMsg.Error(), Name: name, IsTemporary: true}
+			}
+			s, b = append(s, b[1:1+n]...), b[1+n:]
+		}
+		txt = append(txt, string(s))
+	}
+	if len(txt) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return txt, nil
+}
+
+// resolveDNSPTR performs a reverse lookup of the IP address addr and
+// returns the names that map to it.
+func resolveDNSPTR(ctx context.Context, exchange dnsExchangeFunc, addr string, opts *dnsOptions) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := lookupDNS(ctx, exchange, name, dnsTypePTR, opts)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, rr := range msg.answers {
+		if rr.typ != dnsTypePTR {
+			continue
+		}
+		ptr, _, err := readDNSName(msg.raw, rr.off)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: addr, IsTemporary: true}
+		}
+		names = append(names, ptr)
+	}
+	if len(names) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
+
+// reverseName returns the name of the PTR records of the IP address
+// addr, in the in-addr.arpa or ip6.arpa domain.
+func reverseName(addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+	var b strings.Builder
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := len(ip4) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "%d.", ip4[i])
+		}
+		b.WriteString("in-addr.arpa.")
+		return b.String(), nil
+	}
+	const hex = "0123456789abcdef"
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip[i]&0xF])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String(), nil
+}
+
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// exchangeStream sends a DNS query message over a stream connection,
+// such as TCP or TLS, and returns the response message.
+func exchangeStream(ctx context.Context, c net.Conn, query []byte) ([]byte, error) {
+	var resp []byte
+	err := handshake(ctx, c, func() error {
+		b := make([]byte, 2, 2+len(query))
+		binary.BigEndian.PutUint16(b, uint16(len(query)))
+		if _, err := c.Write(append(b, query...)); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c, b[:2]); err != nil {
+			return err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(b))
+		_, err := io.ReadFull(c, resp)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,439 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dnsZone answers DNS queries with its records.
+type dnsZone struct {
+	records       map[string][]net.IP // by fully qualified name
+	ttl           uint32
+	authenticated bool
+}
+
+// answer returns the response to a query message.
+func (z dnsZone) answer(query []byte) []byte {
+	name, off, err := readDNSName(query, dnsHeaderLen)
+	if err != nil {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[off:])
+	resp := append([]byte(nil), query[:off+4]...)
+	flags := uint16(dnsFlagResponse | dnsFlagRecursion)
+	ips, ok := z.records[name]
+	if !ok {
+		flags |= dnsRcodeNameError
+	}
+	if z.authenticated {
+		flags |= dnsFlagAuthentic
+	}
+	var n uint16
+	for _, ip := range ips {
+		typ, data := uint16(dnsTypeA), []byte(ip.To4())
+		if data == nil {
+			typ, data = dnsTypeAAAA, []byte(ip.To16())
+		}
+		if typ != qtype {
+			continue
+		}
+		resp = binary.BigEndian.AppendUint16(resp, 0xC000|dnsHeaderLen)
+		resp = binary.BigEndian.AppendUint16(resp, typ)
+		resp = binary.BigEndian.AppendUint16(resp, dnsClassINET)
+		resp = binary.BigEndian.AppendUint32(resp, z.ttl)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(data)))
+		resp = append(resp, data...)
+		n++
+	}
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	binary.BigEndian.PutUint16(resp[6:], n)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+	return resp
+}
+
+// serveStream answers the DNS queries sent to ln over stream
+// connections until ln is closed.
+func (z dnsZone) serveStream(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			var b [2]byte
+			for {
+				if _, err := io.ReadFull(c, b[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(b[:]))
+				if _, err := io.ReadFull(c, query); err != nil {
+					return
+				}
+				resp := z.answer(query)
+				binary.BigEndian.PutUint16(b[:], uint16(len(resp)))
+				if _, err := c.Write(append(b[:], resp...)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// servePacket answers the DNS queries sent to pc with answer
+// until pc is closed.
+func servePacket(pc net.PacketConn, answer func(query []byte) []byte) {
+	b := make([]byte, dnsMaxUDPSize)
+	for {
+		n, addr, err := pc.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		pc.WriteTo(answer(b[:n]), addr)
+	}
+}
+
+// withFlags returns answer with the given flags set in its responses
+// and their answers omitted.
+func withFlags(answer func(query []byte) []byte, flags uint16) func([]byte) []byte {
+	return func(query []byte) []byte {
+		resp := answer(query)
+		if len(resp) < dnsHeaderLen {
+			return resp
+		}
+		binary.BigEndian.PutUint16(resp[2:], binary.BigEndian.Uint16(resp[2:])|flags)
+		binary.BigEndian.PutUint16(resp[6:], 0)
+		return resp
+	}
+}
+
+var testZone = dnsZone{
+	records: map[string][]net.IP{
+		"foo.com.": {net.IPv4(192, 0, 2, 1).To4(), net.ParseIP("2001:db8::1")},
+	},
+	ttl: 60,
+}
+
+func TestResolveDNS(t *testing.T) {
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		return testZone.answer(query), nil
+	}
+	ips, ttl, err := resolveDNS(context.Background(), exchange, "foo.com", nil)
+	if err != nil {
+		t.Fatalf("resolveDNS failed: %v", err)
+	}
+	if want := testZone.records["foo.com."]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if ttl != time.Minute {
+		t.Errorf("TTL: expected %v; got %v", time.Minute, ttl)
+	}
+
+	_, _, err = resolveDNS(context.Background(), exchange, "bar.com", nil)
+	if derr, ok := err.(*net.DNSError); !ok || !derr.IsNotFound {
+		t.Errorf("expected not found *net.DNSError; got %T: %v", err, err)
+	}
+}
+
+func TestResolveDNSConcurrent(t *testing.T) {
+	// Each query waits for the other to be sent,
+	// and the failure of the AAAA query is tolerated.
+	var started sync.WaitGroup
+	started.Add(2)
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		started.Done()
+		started.Wait()
+		_, off, _ := readDNSName(query, dnsHeaderLen)
+		if binary.BigEndian.Uint16(query[off:]) == dnsTypeAAAA {
+			return nil, errors.New("AAAA query failed")
+		}
+		return testZone.answer(query), nil
+	}
+	ips, _, err := resolveDNS(context.Background(), exchange, "foo.com", nil)
+	if err != nil {
+		t.Fatalf("resolveDNS failed: %v", err)
+	}
+	if want := testZone.records["foo.com."][:1]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+}
+
+func TestResolveDNSDetailed(t *testing.T) {
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		_, off, _ := readDNSName(query, dnsHeaderLen)
+		if binary.BigEndian.Uint16(query[off:]) == dnsTypeAAAA {
+			return nil, errors.New("AAAA query failed")
+		}
+		return testZone.answer(query), nil
+	}
+	res, err := resolveDNSDetailed(context.Background(), exchange, "foo.com", nil)
+	if err != nil {
+		t.Fatalf("resolveDNSDetailed failed: %v", err)
+	}
+	if want := testZone.records["foo.com."][:1]; !reflect.DeepEqual(res.IPs, want) {
+		t.Errorf("expected %v; got %v", want, res.IPs)
+	}
+	if res.IPv4Err != nil || res.IPv6Err == nil || !res.Partial() {
+		t.Errorf("expected only the IPv6 lookup to fail; got %v and %v", res.IPv4Err, res.IPv6Err)
+	}
+
+	res, err = ResolveDetailed(context.Background(), staticResolver{net.IPv4(192, 0, 2, 1)}, "foo.com")
+	if err != nil {
+		t.Fatalf("ResolveDetailed failed: %v", err)
+	}
+	if len(res.IPs) != 1 || res.TTL >= 0 || res.Partial() {
+		t.Errorf("expected an address of unknown TTL; got %+v", res)
+	}
+}
+
+func TestResolveDNSFamily(t *testing.T) {
+	var qtypes []uint16
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		_, off, _ := readDNSName(query, dnsHeaderLen)
+		qtypes = append(qtypes, binary.BigEndian.Uint16(query[off:]))
+		return testZone.answer(query), nil
+	}
+	for i, network := range []string{"ip4", "ip6"} {
+		qtypes = nil
+		ips, ttl, err := resolveDNSFamily(context.Background(), exchange, network, "foo.com", nil)
+		if err != nil {
+			t.Fatalf("resolveDNSFamily(%q) failed: %v", network, err)
+		}
+		if want := testZone.records["foo.com."][i : i+1]; !reflect.DeepEqual(ips, want) {
+			t.Errorf("resolveDNSFamily(%q): expected %v; got %v", network, want, ips)
+		}
+		if ttl != 60*time.Second {
+			t.Errorf("resolveDNSFamily(%q): expected TTL 1m; got %v", network, ttl)
+		}
+		if want := []uint16{dnsTypeA, dnsTypeAAAA}[i]; len(qtypes) != 1 || qtypes[0] != want {
+			t.Errorf("resolveDNSFamily(%q): expected only a query of type %d; got %v", network, want, qtypes)
+		}
+	}
+}
+
+func TestResolveDNSSRV(t *testing.T) {
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		_, off, _ := readDNSName(query, dnsHeaderLen)
+		resp := append([]byte(nil), query[:off+4]...)
+		for _, port := range []uint16{80, 81} {
+			resp = binary.BigEndian.AppendUint16(resp, 0xC000|dnsHeaderLen)
+			resp = binary.BigEndian.AppendUint16(resp, dnsTypeSRV)
+			resp = binary.BigEndian.AppendUint16(resp, dnsClassINET)
+			resp = binary.BigEndian.AppendUint32(resp, 60)
+			resp = binary.BigEndian.AppendUint16(resp, 10)
+			resp = binary.BigEndian.AppendUint16(resp, port-79) // priority
+			resp = binary.BigEndian.AppendUint16(resp, 0)       // weight
+			resp = binary.BigEndian.AppendUint16(resp, port)
+			// The target is compressed as "a" followed by the
+			// name of the question.
+			resp = append(resp, 1, 'a', 0xC0, dnsHeaderLen)
+		}
+		binary.BigEndian.PutUint16(resp[2:], dnsFlagResponse|dnsFlagRecursion)
+		binary.BigEndian.PutUint16(resp[6:], 2)
+		binary.BigEndian.PutUint16(resp[10:], 0)
+		return resp, nil
+	}
+	addrs, err := resolveDNSSRV(context.Background(), exchange, "_foo._tcp.example.com", nil)
+	if err != nil {
+		t.Fatalf("resolveDNSSRV failed: %v", err)
+	}
+	want := []*net.SRV{
+		{Target: "a._foo._tcp.example.com.", Port: 80, Priority: 1},
+		{Target: "a._foo._tcp.example.com.", Port: 81, Priority: 2},
+	}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Errorf("expected %+v; got %+v", want, addrs)
+	}
+}
+
+func TestResolveDNSTXT(t *testing.T) {
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		_, off, _ := readDNSName(query, dnsHeaderLen)
+		resp := append([]byte(nil), query[:off+4]...)
+		data := []byte("\x07v=spf1 \x04-all")
+		resp = binary.BigEndian.AppendUint16(resp, 0xC000|dnsHeaderLen)
+		resp = binary.BigEndian.AppendUint16(resp, dnsTypeTXT)
+		resp = binary.BigEndian.AppendUint16(resp, dnsClassINET)
+		resp = binary.BigEndian.AppendUint32(resp, 60)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(data)))
+		resp = append(resp, data...)
+		binary.BigEndian.PutUint16(resp[2:], dnsFlagResponse|dnsFlagRecursion)
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		binary.BigEndian.PutUint16(resp[10:], 0)
+		return resp, nil
+	}
+	txt, err := resolveDNSTXT(context.Background(), exchange, "example.com", nil)
+	if err != nil {
+		t.Fatalf("resolveDNSTXT failed: %v", err)
+	}
+	if want := []string{"v=spf1 -all"}; !reflect.DeepEqual(txt, want) {
+		t.Errorf("expected %q; got %q", want, txt)
+	}
+}
+
+func TestResolveDNSPTR(t *testing.T) {
+	var qname string
+	exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+		var off int
+		qname, off, _ = readDNSName(query, dnsHeaderLen)
+		resp := append([]byte(nil), query[:off+4]...)
+		data := []byte("\x04peer\x07example\x03com\x00")
+		resp = binary.BigEndian.AppendUint16(resp, 0xC000|dnsHeaderLen)
+		resp = binary.BigEndian.AppendUint16(resp, dnsTypePTR)
+		resp = binary.BigEndian.AppendUint16(resp, dnsClassINET)
+		resp = binary.BigEndian.AppendUint32(resp, 60)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(data)))
+		resp = append(resp, data...)
+		binary.BigEndian.PutUint16(resp[2:], dnsFlagResponse|dnsFlagRecursion)
+		binary.BigEndian.PutUint16(resp[6:], 1)
+		binary.BigEndian.PutUint16(resp[10:], 0)
+		return resp, nil
+	}
+	names, err := resolveDNSPTR(context.Background(), exchange, "192.0.2.1", nil)
+	if err != nil {
+		t.Fatalf("resolveDNSPTR failed: %v", err)
+	}
+	if want := "1.2.0.192.in-addr.arpa."; qname != want {
+		t.Errorf("expected a query for %q; got %q", want, qname)
+	}
+	if want := []string{"peer.example.com."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %q; got %q", want, names)
+	}
+}
+
+func TestParseDNSResponse(t *testing.T) {
+	query, err := newDNSQuery(1, "foo.com", dnsTypeA, nil)
+	if err != nil {
+		t.Fatalf("newDNSQuery failed: %v", err)
+	}
+	name := func(s string) []byte {
+		b, _ := appendDNSName(nil, s)
+		return b
+	}
+	resp := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(resp[2:], dnsFlagResponse|dnsFlagRecursion)
+	for _, rr := range []struct {
+		name  []byte
+		typ   uint16
+		class uint16
+		data  []byte
+	}{
+		{[]byte{0xC0, dnsHeaderLen}, dnsTypeCNAME, dnsClassINET, name("BAR.com")},
+		{name("bar.com"), dnsTypeA, dnsClassINET, []byte{192, 0, 2, 1}},
+		{name("evil.com"), dnsTypeA, dnsClassINET, []byte{192, 0, 2, 2}},
+		{name("foo.com"), dnsTypeA, 3, []byte{192, 0, 2, 3}}, // CHAOS
+	} {
+		resp = append(resp, rr.name...)
+		resp = binary.BigEndian.AppendUint16(resp, rr.typ)
+		resp = binary.BigEndian.AppendUint16(resp, rr.class)
+		resp = binary.BigEndian.AppendUint32(resp, 60)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(rr.data)))
+		resp = append(resp, rr.data...)
+	}
+	binary.BigEndian.PutUint16(resp[6:], 4)
+	msg, err := parseDNSResponse(resp, query)
+	if err != nil {
+		t.Fatalf("parseDNSResponse failed: %v", err)
+	}
+	// Only the records of the CNAME chain of the question are kept.
+	ips, _ := appendAnswerIPs(nil, -1, msg)
+	if want := []net.IP{net.IPv4(192, 0, 2, 1).To4()}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+
+	// A response to another question is rejected.
+	other, _ := newDNSQuery(1, "bar.com", dnsTypeA, nil)
+	if _, err := parseDNSResponse(resp, other); err != errDNSMismatch {
+		t.Errorf("expected error %v; got %v", errDNSMismatch, err)
+	}
+	other, _ = newDNSQuery(1, "foo.com", dnsTypeAAAA, nil)
+	if _, err := parseDNSResponse(resp, other); err != errDNSMismatch {
+		t.Errorf("expected error %v; got %v", errDNSMismatch, err)
+	}
+}
+
+func TestDNSQueryClientSubnet(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("198.51.100.0/22")
+	query, err := newDNSQuery(1, "foo.com", dnsTypeA, &dnsOptions{clientSubnet: subnet})
+	if err != nil {
+		t.Fatalf("newDNSQuery failed: %v", err)
+	}
+	if n := binary.BigEndian.Uint16(query[10:]); n != 1 {
+		t.Fatalf("ARCOUNT: expected 1; got %d", n)
+	}
+	_, off, _ := readDNSName(query, dnsHeaderLen)
+	opt := query[off+4:]
+	want := []byte{
+		0,             // root domain
+		0, dnsTypeOPT, // type
+		0x04, 0xD0, // UDP payload size
+		0, 0, 0, 0, // extended RCODE and flags
+		0, 11, // length
+		0, 8, 0, 7, // client subnet option and length
+		0, 1, 22, 0, // family, source and scope prefix lengths
+		198, 51, 100, // address
+	}
+	if !bytes.Equal(opt, want) {
+		t.Errorf("OPT record:\nexpected %v\ngot      %v", want, opt)
+	}
+
+	// An IPv4 prefix written in IPv6 notation is sent as IPv4.
+	_, subnet, _ = net.ParseCIDR("::ffff:198.51.100.0/118")
+	query, err = newDNSQuery(1, "foo.com", dnsTypeA, &dnsOptions{clientSubnet: subnet})
+	if err != nil {
+		t.Fatalf("newDNSQuery failed: %v", err)
+	}
+	if opt := query[off+4:]; !bytes.Equal(opt, want) {
+		t.Errorf("OPT record:\nexpected %v\ngot      %v", want, opt)
+	}
+
+	for _, subnet := range []*net.IPNet{
+		{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(24, 32)},
+		{IP: net.IPv4(198, 51, 100, 0), Mask: net.CIDRMask(64, 128)},
+		{IP: net.IPv4(198, 51, 100, 0), Mask: net.IPMask{255, 0, 255, 0}},
+	} {
+		if _, err := newDNSQuery(1, "foo.com", dnsTypeA, &dnsOptions{clientSubnet: subnet}); err == nil {
+			t.Errorf("expected an error for subnet %v", subnet)
+		}
+	}
+}
+
+func TestResolveDNSSEC(t *testing.T) {
+	for _, authenticated := range []bool{false, true} {
+		zone := testZone
+		zone.authenticated = authenticated
+		exchange := func(ctx context.Context, query []byte) ([]byte, error) {
+			if binary.BigEndian.Uint16(query[2:])&dnsFlagAuthentic == 0 {
+				return nil, errors.New("AD flag not set")
+			}
+			return zone.answer(query), nil
+		}
+		_, _, err := resolveDNS(context.Background(), exchange, "foo.com", &dnsOptions{dnssec: true})
+		if authenticated && err != nil {
+			t.Errorf("authenticated: unexpected error: %v", err)
+		}
+		if !authenticated && !errors.Is(err, ErrUnauthenticatedResponse) {
+			t.Errorf("unauthenticated: expected %v; got %v", ErrUnauthenticatedResponse, err)
+		}
+	}
+}
+
+func TestReadDNSNameLoop(t *testing.T) {
+	msg := make([]byte, dnsHeaderLen+2)
+	binary.BigEndian.PutUint16(msg[dnsHeaderLen:], 0xC000|dnsHeaderLen)
+	if _, _, err := readDNSName(msg, dnsHeaderLen); err != errDNSPointerLoop {
+		t.Errorf("expected %v; got %v", errDNSPointerLoop, err)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// dnsMaxUDPSize is the largest DNS message accepted over UDP.
+const dnsMaxUDPSize = 65535
+
+var errNoDNSServers = errors.New("no DNS servers")
+
+// DNSResolver resolves hosts by querying the given DNS servers
+// directly instead of using the system's resolver. Queries are
+// sent over UDP, and repeated over TCP if the response is
+// truncated. It satisfies TTLResolver, DetailedResolver,
+// FamilyResolver, SRVResolver, TXTResolver and PTRResolver.
+type DNSResolver struct {
+	// Servers holds the addresses of the DNS servers, such as
+	// "8.8.8.8:53" or "[2001:4860:4860::8888]:53". If the port
+	// is omitted, port 53 is used.
+	//
+	// The servers are queried in order until one responds,
+	// moving on if a server fails to respond within Timeout
+	// or reports a server failure.
+	//
+	// If Servers is empty, the nameservers listed in the
+	// resolv.conf file at ConfigPath are used. The file is
+	// checked for changes every 5 seconds, so that lookups
+	// follow the system's configuration as it changes, such
+	// as when a laptop moves between networks.
+	//
+	// The file's search domains and ndots option are also used,
+	// so that short names, such as Kubernetes service names,
+	// resolve as they would through the system resolver: a name
+	// with fewer dots than ndots is tried with each search domain
+	// appended before it is tried as given. Names ending in a dot
+	// are never expanded.
+	Servers []string
+
+	// ConfigPath is the path of the resolv.conf file used if
+	// Servers is empty. If empty, "/etc/resolv.conf" is used.
+	ConfigPath string
+
+	// Rotate spreads queries across the servers by starting
+	// each lookup with the server after the one that started
+	// the previous lookup.
+	Rotate bool
+
+	// Dialer connects to the servers. If nil, the zero Dialer
+	// is used.
+	Dialer *Dialer
+
+	// Timeout is the maximum amount of time to wait for each
+	// server to respond. If zero, a default of
+	// DefaultDNSTimeout is used.
+	Timeout time.Duration
+
+	// ClientSubnet, if non-nil, is sent to the servers as the
+	// EDNS Client Subnet option, as with DoHResolver.
+	ClientSubnet *net.IPNet
+
+	// DNSSEC requires the servers to report that their answers
+	// are authenticated, as with DoHResolver. Since queries and
+	// responses are not encrypted, this is only meaningful for
+	// servers reached over a trusted network, such as a local
+	// validating resolver.
+	DNSSEC bool
+
+	next atomic.Uint32
+	conf resolvConfWatcher
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *DNSResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses
+// and the lowest TTL of the records from which they were taken.
+func (r *DNSResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	res, err := r.ResolveDetailed(context.Background(), host)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.IPs, res.TTL, nil
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *DNSResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	res, err := r.ResolveDetailed(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return res.IPs, nil
+}
+
+// ResolveDetailed looks up the given host using the provided context
+// and returns its IP addresses with the errors of the A and AAAA
+// queries.
+func (r *DNSResolver) ResolveDetailed(ctx context.Context, host string) (*DetailedResult, error) {
+	var res *DetailedResult
+	err := r.search(host, func(name string, opts *dnsOptions) (err error) {
+		res, err = resolveDNSDetailed(ctx, r.exchange, name, opts)
+		return err
+	})
+	return res, err
+}
+
+// ResolveFamily looks up the given host's addresses of the family of
+// network, "ip4" or "ip6", with only an A or AAAA query, and returns
+// them with the lowest TTL of the records from which they were taken.
+func (r *DNSResolver) ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	var (
+		ips []net.IP
+		ttl time.Duration
+	)
+	err := r.search(host, func(name string, opts *dnsOptions) (err error) {
+		ips, ttl, err = resolveDNSFamily(ctx, r.exchange, network, name, opts)
+		return err
+	})
+	return ips, ttl, err
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context.
+func (r *DNSResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	var addrs []*net.SRV
+	err := r.search(name, func(name string, opts *dnsOptions) (err error) {
+		addrs, err = resolveDNSSRV(ctx, r.exchange, name, opts)
+		return err
+	})
+	return addrs, err
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context.
+func (r *DNSResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	var txt []string
+	err := r.search(name, func(name string, opts *dnsOptions) (err error) {
+		txt, err = resolveDNSTXT(ctx, r.exchange, name, opts)
+		return err
+	})
+	return txt, err
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context.
+func (r *DNSResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	return resolveDNSPTR(ctx, r.exchange, addr, r.options())
+}
+
+// search calls lookup with the names to look up for host, trying the
+// search list of the configuration if Servers is empty, until one of
+// them is found.
+func (r *DNSResolver) search(host string, lookup func(name string, opts *dnsOptions) error) error {
+	opts := r.options()
+	if len(r.Servers) > 0 {
+		return lookup(host, opts)
+	}
+	for _, name := range r.conf.get(r.ConfigPath).nameList(host) {
+		err := lookup(name, opts)
+		if err == nil {
+			return nil
+		}
+		// Only move on to the next name if this one doesn't exist,
+		// rather than if the servers failed to answer for it.
+		var derr *net.DNSError
+		if !errors.As(err, &derr) || !derr.IsNotFound {
+			return err
+		}
+	}
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r *DNSResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	servers := r.Servers
+	if len(servers) == 0 {
+		servers = r.conf.get(r.ConfigPath).servers
+	}
+	n := len(servers)
+	if n == 0 {
+		return nil, errNoDNSServers
+	}
+	first := 0
+	if r.Rotate {
+		first = int(r.next.Add(1)-1) % n
+	}
+	var (
+		resp []byte
+		err  error
+	)
+	for i := 0; i < n; i++ {
+		server := servers[(first+i)%n]
+		if _, _, serr := net.SplitHostPort(server); serr != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resp, err = r.exchangeServer(ctx, server, query)
+		if err == nil && !dnsServerFailed(resp) {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return resp, err
+}
+
+// exchangeServer sends the query to the server over UDP, and then over
+// TCP if the response is truncated.
+func (r *DNSResolver) exchangeServer(ctx context.Context, server string, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	d := r.Dialer
+	if d == nil {
+		d = &Dialer{}
+	}
+	c, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := exchangeUDP(ctx, c, query)
+	c.Close()
+	if err != nil || binary.BigEndian.Uint16(resp[2:])&dnsFlagTruncated == 0 {
+		return resp, err
+	}
+	if c, err = d.DialContext(ctx, "tcp", server); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return exchangeStream(ctx, c, query)
+}
+
+// exchangeUDP sends a DNS query message over a datagram connection and
+// returns the response message, ignoring datagrams that don't respond
+// to it, such as those spoofed by an off-path attacker, by their ID
+// and question.
+func exchangeUDP(ctx context.Context, c net.Conn, query []byte) ([]byte, error) {
+	var resp []byte
+	err := handshake(ctx, c, func() error {
+		if _, err := c.Write(query); err != nil {
+			return err
+		}
+		b := make([]byte, dnsMaxUDPSize)
+		for {
+			n, err := c.Read(b)
+			if err != nil {
+				return err
+			}
+			if n < dnsHeaderLen || b[0] != query[0] || b[1] != query[1] ||
+				binary.BigEndian.Uint16(b[2:])&dnsFlagResponse == 0 {
+				continue
+			}
+			if _, _, ok := matchDNSQuestion(query, b[:n]); ok {
+				resp = b[:n]
+				return nil
+			}
+		}
+	})
+	return resp, err
+}
+
+// dnsServerFailed reports whether a response message reports that the
+// server failed or refused to answer, so another may be asked.
+func dnsServerFailed(resp []byte) bool {
+	if len(resp) < dnsHeaderLen {
+		return false
+	}
+	switch binary.BigEndian.Uint16(resp[2:]) & 0xF {
+	case dnsRcodeServFail, dnsRcodeRefused:
+		return true
+	}
+	return false
+}
+
+func (r *DNSResolver) options() *dnsOptions {
+	if r.ClientSubnet == nil && !r.DNSSEC {
+		return nil
+	}
+	return &dnsOptions{clientSubnet: r.ClientSubnet, dnssec: r.DNSSEC}
+}
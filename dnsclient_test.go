@@ -0,0 +1,147 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDNSResolver(t *testing.T) {
+	failing, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failing.Close()
+	go servePacket(failing, withFlags(testZone.answer, dnsRcodeServFail))
+
+	ok, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ok.Close()
+	go servePacket(ok, testZone.answer)
+
+	// The failing server is skipped.
+	r := &DNSResolver{Servers: []string{failing.LocalAddr().String(), ok.LocalAddr().String()}}
+	ips, err := r.Resolve("foo.com")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := testZone.records["foo.com."]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if _, err := r.Resolve("bar.com"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDNSResolverTruncated(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go testZone.serveStream(ln)
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		t.Skipf("UDP port unavailable: %v", err)
+	}
+	defer pc.Close()
+	go servePacket(pc, withFlags(testZone.answer, dnsFlagTruncated))
+
+	r := &DNSResolver{Servers: []string{ln.Addr().String()}}
+	ips, err := r.Resolve("foo.com")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := testZone.records["foo.com."]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+}
+
+func TestDNSResolverSearch(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	zone := dnsZone{
+		records: map[string][]net.IP{
+			"foo.svc.cluster.local.": {net.IPv4(192, 0, 2, 1).To4()},
+			"foo.com.":               {net.IPv4(192, 0, 2, 2).To4()},
+		},
+		ttl: 60,
+	}
+	go servePacket(pc, zone.answer)
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	defer func(port string) { dnsPort = port }(dnsPort)
+	dnsPort = port
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "nameserver 127.0.0.1\nsearch default.svc.cluster.local svc.cluster.local\noptions ndots:5\n"
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &DNSResolver{ConfigPath: path}
+	tests := []struct {
+		host string
+		want []net.IP
+	}{
+		{"foo", zone.records["foo.svc.cluster.local."]},
+		{"foo.com", zone.records["foo.com."]},
+		{"foo.com.", zone.records["foo.com."]},
+		{"foo.svc.cluster.local.", zone.records["foo.svc.cluster.local."]},
+		{"bar", nil},
+		{"foo.", nil},
+	}
+	for _, tt := range tests {
+		ips, err := r.Resolve(tt.host)
+		if tt.want == nil {
+			if derr, ok := err.(*net.DNSError); !ok || !derr.IsNotFound || derr.Name != tt.host {
+				t.Errorf("%q: expected not found error; got %v", tt.host, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: Resolve failed: %v", tt.host, err)
+		} else if !reflect.DeepEqual(ips, tt.want) {
+			t.Errorf("%q: expected %v; got %v", tt.host, tt.want, ips)
+		}
+	}
+}
+
+func TestExchangeUDPQuestion(t *testing.T) {
+	c, server := net.Pipe()
+	defer c.Close()
+	defer server.Close()
+	query, _ := newDNSQuery(1, "foo.com", dnsTypeA, nil)
+	spoofed, _ := newDNSQuery(1, "bar.com", dnsTypeA, nil)
+	want := append([]byte(nil), query...)
+	for _, resp := range [][]byte{spoofed, want} {
+		binary.BigEndian.PutUint16(resp[2:], dnsFlagResponse)
+	}
+	go func() {
+		b := make([]byte, dnsMaxUDPSize)
+		server.Read(b)
+		// The response of the same ID to another question is ignored.
+		server.Write(spoofed)
+		server.Write(want)
+	}()
+	resp, err := exchangeUDP(context.Background(), c, query)
+	if err != nil {
+		t.Fatalf("exchangeUDP failed: %v", err)
+	}
+	if !bytes.Equal(resp, want) {
+		t.Errorf("expected %v; got %v", want, resp)
+	}
+}
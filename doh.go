@@ -0,0 +1,215 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const dohMediaType = "application/dns-message"
+
+// DefaultDNSTimeout is the default time limit for lookups by the
+// package's DNS resolvers.
+const DefaultDNSTimeout = 5 * time.Second
+
+// DoHResolver resolves hosts by querying a DNS-over-HTTPS server,
+// as specified by RFC 8484. It satisfies TTLResolver,
+// DetailedResolver, FamilyResolver, SRVResolver, TXTResolver and
+// PTRResolver.
+type DoHResolver struct {
+	// URL is the URL of the server's DNS query endpoint,
+	// such as "https://dns.google/dns-query".
+	URL string
+
+	// Bootstrap holds the IP addresses of the server, so that
+	// connecting to it doesn't depend on another resolver. It
+	// is used only if Client is nil. If Bootstrap is empty,
+	// the server's host is resolved with DefaultResolver.
+	Bootstrap []net.IP
+
+	// Client is the HTTP client used to send queries.
+	// If nil, a client connecting to Bootstrap is used.
+	Client *http.Client
+
+	// Timeout is the maximum amount of time a lookup will wait
+	// for the server to respond. If zero, a default of
+	// DefaultDNSTimeout is used.
+	Timeout time.Duration
+
+	// ClientSubnet, if non-nil, is sent to the server as the
+	// EDNS Client Subnet option, so that services answering
+	// by location can answer for the clients of a proxy. A
+	// prefix length of zero asks the server not to use the
+	// subnet of the address the query came from. If nil, the
+	// option is not sent.
+	ClientSubnet *net.IPNet
+
+	// DNSSEC requests DNSSEC records and requires the server
+	// to report that its answers are authenticated, failing
+	// lookups with ErrUnauthenticatedResponse otherwise. The
+	// server must be a trusted validating resolver, since
+	// its authentication isn't verified. Validating resolvers
+	// typically report a server failure for answers that fail
+	// validation.
+	DNSSEC bool
+
+	once   sync.Once
+	client *http.Client
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *DoHResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses
+// and the lowest TTL of the records from which they were taken.
+func (r *DoHResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	res, err := r.ResolveDetailed(context.Background(), host)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.IPs, res.TTL, nil
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *DoHResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	res, err := r.ResolveDetailed(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return res.IPs, nil
+}
+
+// ResolveDetailed looks up the given host using the provided context
+// and returns its IP addresses with the errors of the A and AAAA
+// queries.
+func (r *DoHResolver) ResolveDetailed(ctx context.Context, host string) (*DetailedResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSDetailed(ctx, r.exchange, host, r.options())
+}
+
+// ResolveFamily looks up the given host's addresses of the family of
+// network, "ip4" or "ip6", with only an A or AAAA query, and returns
+// them with the lowest TTL of the records from which they were taken.
+func (r *DoHResolver) ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSFamily(ctx, r.exchange, network, host, r.options())
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context.
+func (r *DoHResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSSRV(ctx, r.exchange, name, r.options())
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context.
+func (r *DoHResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSTXT(ctx, r.exchange, name, r.options())
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context.
+func (r *DoHResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSPTR(ctx, r.exchange, addr, r.options())
+}
+
+func (r *DoHResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	// The message ID must be zero for HTTP caches to be effective.
+	// Responses are matched to requests by HTTP instead.
+	var id [2]byte
+	copy(id[:], query)
+	query = append([]byte(nil), query...)
+	query[0], query[1] = 0, 0
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != dohMediaType {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned content type %q", ct)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || b[0] != 0 || b[1] != 0 {
+		return nil, errDNSMismatch
+	}
+	b[0], b[1] = id[0], id[1]
+	return b, nil
+}
+
+func (r *DoHResolver) httpClient() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	r.once.Do(func() {
+		d := &Dialer{IPFilter: DualStack}
+		if len(r.Bootstrap) > 0 {
+			d.Resolver = bootstrapResolver{r.URL, r.Bootstrap}
+		}
+		r.client = &http.Client{Transport: NewTransport(d)}
+	})
+	return r.client
+}
+
+// bootstrapResolver resolves the host of a DNS server's URL to its
+// known addresses.
+type bootstrapResolver struct {
+	url string
+	ips []net.IP
+}
+
+func (r bootstrapResolver) Resolve(host string) ([]net.IP, error) {
+	if u, err := url.Parse(r.url); err != nil || u.Hostname() != host {
+		return nil, &net.DNSError{Err: "host is not the DNS server", Name: host}
+	}
+	return copyIPs(r.ips), nil
+}
+
+// dnsTimeout returns timeout, or DefaultDNSTimeout if it is not positive.
+func dnsTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultDNSTimeout
+	}
+	return timeout
+}
+
+func (r *DoHResolver) options() *dnsOptions {
+	if r.ClientSubnet == nil && !r.DNSSEC {
+		return nil
+	}
+	return &dnsOptions{clientSubnet: r.ClientSubnet, dnssec: r.DNSSEC}
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDoHResolver(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dohMediaType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		if query[0] != 0 || query[1] != 0 {
+			http.Error(w, "nonzero ID", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dohMediaType)
+		w.Write(testZone.answer(query))
+	}))
+	defer s.Close()
+
+	r := &DoHResolver{URL: s.URL + "/dns-query", Client: s.Client()}
+	ips, err := r.Resolve("foo.com")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := testZone.records["foo.com."]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if _, err := r.Resolve("bar.com"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestBootstrapResolver(t *testing.T) {
+	r := bootstrapResolver{"https://dns.example/dns-query", []net.IP{net.IPv6loopback}}
+	if ips, err := r.Resolve("dns.example"); err != nil || !reflect.DeepEqual(ips, []net.IP{net.IPv6loopback}) {
+		t.Errorf("unexpected result: %v, %v", ips, err)
+	}
+	if _, err := r.Resolve("foo.com"); err == nil {
+		t.Error("expected error")
+	}
+}
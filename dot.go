@@ -0,0 +1,203 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// DoTResolver resolves hosts by querying a DNS-over-TLS server,
+// as specified by RFC 7858. Connections to the server are reused
+// for subsequent lookups. It satisfies TTLResolver,
+// DetailedResolver, FamilyResolver, SRVResolver, TXTResolver and
+// PTRResolver.
+type DoTResolver struct {
+	// Address is the address of the server, such as
+	// "dns.google:853" or "8.8.8.8:853". If the port is
+	// omitted, port 853 is used.
+	Address string
+
+	// TLSConfig configures the TLS connections to the server.
+	// If nil, the default configuration is used. If its
+	// ServerName is empty, the host of Address is verified.
+	TLSConfig *tls.Config
+
+	// Dialer connects to the server. If the host of Address
+	// is not an IP address, it is resolved using the Dialer's
+	// Resolver. If nil, the zero Dialer is used.
+	Dialer *Dialer
+
+	// Timeout is the maximum amount of time a lookup will wait
+	// for the server to respond, including the time to connect.
+	// If zero, a default of DefaultDNSTimeout is used.
+	Timeout time.Duration
+
+	// ClientSubnet, if non-nil, is sent to the server as the
+	// EDNS Client Subnet option, as with DoHResolver.
+	ClientSubnet *net.IPNet
+
+	// DNSSEC requires the server to report that its answers
+	// are authenticated, as with DoHResolver.
+	DNSSEC bool
+
+	mu   sync.Mutex
+	idle []net.Conn
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *DoTResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses
+// and the lowest TTL of the records from which they were taken.
+func (r *DoTResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	res, err := r.ResolveDetailed(context.Background(), host)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.IPs, res.TTL, nil
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *DoTResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	res, err := r.ResolveDetailed(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return res.IPs, nil
+}
+
+// ResolveDetailed looks up the given host using the provided context
+// and returns its IP addresses with the errors of the A and AAAA
+// queries.
+func (r *DoTResolver) ResolveDetailed(ctx context.Context, host string) (*DetailedResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSDetailed(ctx, r.exchange, host, r.options())
+}
+
+// ResolveFamily looks up the given host's addresses of the family of
+// network, "ip4" or "ip6", with only an A or AAAA query, and returns
+// them with the lowest TTL of the records from which they were taken.
+func (r *DoTResolver) ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSFamily(ctx, r.exchange, network, host, r.options())
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context.
+func (r *DoTResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSSRV(ctx, r.exchange, name, r.options())
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context.
+func (r *DoTResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSTXT(ctx, r.exchange, name, r.options())
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context.
+func (r *DoTResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout(r.Timeout))
+	defer cancel()
+	return resolveDNSPTR(ctx, r.exchange, addr, r.options())
+}
+
+// CloseIdleConnections closes the connections to the server
+// that are not in use.
+func (r *DoTResolver) CloseIdleConnections() {
+	r.mu.Lock()
+	idle := r.idle
+	r.idle = nil
+	r.mu.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+func (r *DoTResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	// A reused connection may have been closed by the server,
+	// in which case the query is retried on a new connection.
+	if c := r.getIdle(); c != nil {
+		if resp, err := exchangeStream(ctx, c, query); err == nil {
+			r.putIdle(c)
+			return resp, nil
+		}
+		c.Close()
+		if ctx.Err() != nil {
+			return nil, mapErr(ctx.Err())
+		}
+	}
+	c, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := exchangeStream(ctx, c, query)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	r.putIdle(c)
+	return resp, nil
+}
+
+func (r *DoTResolver) dial(ctx context.Context) (net.Conn, error) {
+	address := r.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "853")
+	}
+	d := r.Dialer
+	if d == nil {
+		d = &Dialer{}
+	}
+	c, err := d.DialTLSContext(ctx, "tcp", address, r.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *DoTResolver) getIdle() net.Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		return c
+	}
+	return nil
+}
+
+func (r *DoTResolver) putIdle(c net.Conn) {
+	r.mu.Lock()
+	if len(r.idle) < DefaultMaxIdle {
+		r.idle = append(r.idle, c)
+		c = nil
+	}
+	r.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+func (r *DoTResolver) options() *dnsOptions {
+	if r.ClientSubnet == nil && !r.DNSSEC {
+		return nil
+	}
+	return &dnsOptions{clientSubnet: r.ClientSubnet, dnssec: r.DNSSEC}
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDoTResolver(t *testing.T) {
+	// Borrow the test certificate of an HTTPS server.
+	s := httptest.NewTLSServer(http.NotFoundHandler())
+	config := s.TLS.Clone()
+	roots := s.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	s.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go testZone.serveStream(ln)
+
+	r := &DoTResolver{
+		Address:   ln.Addr().String(),
+		TLSConfig: &tls.Config{RootCAs: roots},
+	}
+	defer r.CloseIdleConnections()
+	for i := 0; i < 2; i++ {
+		ips, ttl, err := r.ResolveTTL("foo.com")
+		if err != nil {
+			t.Fatalf("ResolveTTL failed: %v", err)
+		}
+		if want := testZone.records["foo.com."]; !reflect.DeepEqual(ips, want) {
+			t.Errorf("expected %v; got %v", want, ips)
+		}
+		if ttl <= 0 {
+			t.Errorf("expected positive TTL; got %v", ttl)
+		}
+	}
+	r.mu.Lock()
+	idle := len(r.idle)
+	r.mu.Unlock()
+	// The A and AAAA queries may each use a connection.
+	if idle < 1 || idle > 2 {
+		t.Errorf("expected 1 or 2 idle connections; got %d", idle)
+	}
+}
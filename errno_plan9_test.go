@@ -0,0 +1,22 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// errConnRefused is the error of a refused connection. Plan 9 has no
+// errno value for it, so it has the message of its network stack.
+var errConnRefused error = syscall.ErrorString("connection refused")
+
+// isConnRefused reports whether err is caused by the connection being
+// refused.
+func isConnRefused(err error) bool {
+	var serr syscall.ErrorString
+	return errors.As(err, &serr) && strings.Contains(string(serr), "connection refused")
+}
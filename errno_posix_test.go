@@ -0,0 +1,22 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package nett
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errConnRefused is the error of a refused connection.
+var errConnRefused error = syscall.ECONNREFUSED
+
+// isConnRefused reports whether err is caused by the connection being
+// refused.
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,270 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// An Explanation reports how a Dialer chose the addresses to which it
+// dialed an address, as returned by Explain and DialExplain, such as
+// to debug why it connected to an IP address.
+type Explanation struct {
+	// Network and Address are those of the dial explained.
+	Network, Address string
+
+	// Answers holds the addresses returned by the Resolver for the
+	// host of the address, and TTL their time to live, which is
+	// negative if unknown. Answers is empty if the host is a
+	// literal IP address.
+	Answers []net.IP
+	TTL     time.Duration
+
+	// Filters holds the steps of selecting the addresses to be
+	// dialed, in the order they were applied. The first, named
+	// "Network", selects the addresses supported by the network,
+	// without duplicates. The others are named after the fields
+	// of the Dialer that select them, ending with "IPFilter".
+	Filters []FilterStep
+
+	// Addrs holds the addresses selected to be dialed, in the
+	// order they are attempted.
+	Addrs []string
+
+	// Attempts holds the attempts to connect, in the order they
+	// began. It is empty unless the address was dialed.
+	Attempts []AttemptResult
+
+	// Err is the error of the dial, if any.
+	Err error
+}
+
+// A FilterStep is a step of selecting the addresses to be dialed.
+type FilterStep struct {
+	Name    string   // name of the step
+	Kept    []net.IP // addresses selected, in order
+	Dropped []net.IP // addresses given to the step but not selected
+}
+
+// An AttemptResult is the result of an attempt to connect to an
+// address.
+type AttemptResult struct {
+	Address  string
+	Start    time.Time
+	Duration time.Duration
+
+	// Done reports whether the attempt completed before the dial
+	// returned, unlike those abandoned once another connected.
+	Done bool
+
+	// Err is the error of the attempt, if it completed and failed.
+	Err error
+}
+
+// Explain resolves and filters the address on the named network as
+// DialContext does, without connecting, and returns an Explanation of
+// the addresses that would be dialed. Unlike DialContext, it doesn't
+// look up SRV records, and its lookups may be served from a cache.
+// If the address can't be resolved, or no suitable address is found,
+// the Explanation is returned with the error.
+//
+// If the Dialer connects through a proxy, the Explanation is of the
+// destination address, unless the proxy resolves it, in which case no
+// addresses are selected.
+func (d *Dialer) Explain(ctx context.Context, network, address string) (*Explanation, error) {
+	ctx, cancel := d.dialContext(ctx)
+	defer cancel()
+	e := &explainer{ex: Explanation{Network: network, Address: address}}
+	_, err := d.dialDirectOrProxy(context.WithValue(ctx, explainerKey{}, e), network, address)
+	if err == errExplained {
+		err = nil
+	}
+	ex := e.explanation()
+	ex.Err = err
+	return ex, err
+}
+
+// DialExplain connects to the address on the named network using the
+// provided context, as DialContext does, and returns the connection
+// with an Explanation of how it was chosen, including the result of
+// each attempt. If the dial is retried, the last try is explained.
+func (d *Dialer) DialExplain(ctx context.Context, network, address string) (net.Conn, *Explanation, error) {
+	e := &explainer{ex: Explanation{Network: network, Address: address}, connect: true}
+	c, err := d.DialContext(context.WithValue(ctx, explainerKey{}, e), network, address)
+	ex := e.explanation()
+	ex.Err = err
+	return c, ex, err
+}
+
+// errExplained stops a dial made by Explain once its addresses have
+// been selected.
+var errExplained = errors.New("nett: dial explained")
+
+type explainerKey struct{}
+
+// An explainer records the Explanation of a dial.
+type explainer struct {
+	connect bool // whether to dial the selected addresses
+
+	mu  sync.Mutex
+	ex  Explanation
+	try int // incremented by each dial explained
+}
+
+// takeExplainer returns the explainer attached to ctx, if any, and ctx
+// without it, so that the dials made through a proxy are not explained
+// in place of the dial of the destination.
+func takeExplainer(ctx context.Context) (*explainer, context.Context) {
+	e, _ := ctx.Value(explainerKey{}).(*explainer)
+	if e == nil {
+		return nil, ctx
+	}
+	e.mu.Lock()
+	e.ex = Explanation{Network: e.ex.Network, Address: e.ex.Address}
+	e.try++
+	e.mu.Unlock()
+	return e, context.WithValue(ctx, explainerKey{}, (*explainer)(nil))
+}
+
+// explanation returns a copy of the Explanation, which is no longer
+// changed by attempts that complete after it is taken.
+func (e *explainer) explanation() *Explanation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ex := e.ex
+	ex.Attempts = append([]AttemptResult(nil), ex.Attempts...)
+	return &ex
+}
+
+// resolver returns r wrapped to record the addresses it returns with
+// their time to live.
+func (e *explainer) resolver(r Resolver) Resolver {
+	if e == nil {
+		return r
+	}
+	return explainResolver{r, e}
+}
+
+type explainResolver struct {
+	r Resolver
+	e *explainer
+}
+
+func (r explainResolver) Resolve(host string) ([]net.IP, error) {
+	return r.ResolveContext(context.Background(), host)
+}
+
+func (r explainResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	ips, ttl, err := resolveContextTTL(ctx, r.r, host)
+	r.e.mu.Lock()
+	r.e.ex.Answers, r.e.ex.TTL = copyIPs(ips), ttl
+	r.e.mu.Unlock()
+	return ips, err
+}
+
+// resolveContextTTL looks up host with r using ctx, returning the time
+// to live of its addresses if r reports it, or a negative duration.
+func resolveContextTTL(ctx context.Context, r Resolver, host string) ([]net.IP, time.Duration, error) {
+	switch r := r.(type) {
+	case familyResolver:
+		return r.r.ResolveFamily(ctx, r.network, host)
+	case DetailedResolver:
+		res, err := r.ResolveDetailed(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.IPs, res.TTL, nil
+	case TTLResolver:
+		return r.ResolveTTL(host)
+	}
+	ips, err := resolveContext(ctx, r, host)
+	return ips, -1, err
+}
+
+// filter returns filter wrapped to record the addresses it is given as
+// those selected by the step of the given name, which precedes it.
+func (e *explainer) filter(name string, filter ipFilter) ipFilter {
+	if e == nil {
+		return filter
+	}
+	return func(ips []net.IP) []net.IP {
+		e.step(name, ips)
+		return filter(ips)
+	}
+}
+
+// result returns filter wrapped to record the addresses it selects as
+// those of the step of the given name.
+func (e *explainer) result(name string, filter ipFilter) ipFilter {
+	if e == nil {
+		return filter
+	}
+	return func(ips []net.IP) []net.IP {
+		ips = filter(ips)
+		e.step(name, ips)
+		return ips
+	}
+}
+
+// step records the addresses selected by the step of the given name
+// from those selected by the previous step.
+func (e *explainer) step(name string, kept []net.IP) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	given := e.ex.Answers
+	if n := len(e.ex.Filters); n > 0 {
+		given = e.ex.Filters[n-1].Kept
+	}
+	left := make(map[string]int, len(kept))
+	for _, ip := range kept {
+		left[ip.String()]++
+	}
+	var dropped []net.IP
+	for _, ip := range given {
+		if s := ip.String(); left[s] > 0 {
+			left[s]--
+		} else {
+			dropped = append(dropped, ip)
+		}
+	}
+	e.ex.Filters = append(e.ex.Filters, FilterStep{Name: name, Kept: copyIPs(kept), Dropped: dropped})
+}
+
+// selected records the addresses to be dialed.
+func (e *explainer) selected(addrs addrList) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ex.Addrs = make([]string, addrs.Len())
+	for i := range e.ex.Addrs {
+		e.ex.Addrs[i] = addrs.Addr(i)
+	}
+}
+
+// dial returns fn wrapped to record the result of each attempt.
+func (e *explainer) dial(fn dialFunc) dialFunc {
+	if e == nil {
+		return fn
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		start := time.Now()
+		e.mu.Lock()
+		i, try := len(e.ex.Attempts), e.try
+		e.ex.Attempts = append(e.ex.Attempts, AttemptResult{Address: address, Start: start})
+		e.mu.Unlock()
+		c, err := fn(ctx, network, address)
+		e.mu.Lock()
+		// Attempts abandoned by an earlier try are forgotten.
+		if e.try == try {
+			a := &e.ex.Attempts[i]
+			a.Duration, a.Done, a.Err = time.Since(start), true, err
+		}
+		e.mu.Unlock()
+		return c, err
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDialerExplain(t *testing.T) {
+	lookups := 0
+	d := &Dialer{
+		Resolver: ttlResolver{parseIPs("127.0.0.1", "::1", "127.0.0.2", "127.0.0.3"), 30 * time.Second, &lookups},
+		IPFilter: func(ips []net.IP) []net.IP {
+			if len(ips) > 2 {
+				ips = ips[:2]
+			}
+			return ips
+		},
+	}
+	ex, err := d.Explain(context.Background(), "tcp4", "foo.com:80")
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(ex.Answers) != 4 || ex.TTL != 30*time.Second {
+		t.Errorf("expected 4 answers of TTL 30s; got %v of TTL %v", ex.Answers, ex.TTL)
+	}
+	want := []FilterStep{
+		{"Network", parseIPs("127.0.0.1", "127.0.0.2", "127.0.0.3"), parseIPs("::1")},
+		{"IPFilter", parseIPs("127.0.0.1", "127.0.0.2"), parseIPs("127.0.0.3")},
+	}
+	if !reflect.DeepEqual(ex.Filters, want) {
+		t.Errorf("expected filters %v; got %v", want, ex.Filters)
+	}
+	if want := []string{"127.0.0.1:80", "127.0.0.2:80"}; !reflect.DeepEqual(ex.Addrs, want) {
+		t.Errorf("expected addresses %v; got %v", want, ex.Addrs)
+	}
+	if len(ex.Attempts) != 0 {
+		t.Errorf("expected no attempts; got %v", ex.Attempts)
+	}
+
+	d.IPFilter = func([]net.IP) []net.IP { return nil }
+	ex, err = d.Explain(context.Background(), "tcp4", "foo.com:80")
+	if !errors.Is(err, ErrNoSuitableAddress) || ex.Err != err {
+		t.Errorf("expected %v; got %v", ErrNoSuitableAddress, err)
+	}
+	if len(ex.Filters) != 2 || len(ex.Filters[1].Kept) != 0 || len(ex.Filters[1].Dropped) != 3 {
+		t.Errorf("expected the IPFilter to drop every address; got %v", ex.Filters)
+	}
+}
+
+func TestDialExplain(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	failed := &FailedAddrCache{Skip: true}
+	failed.Add(net.IPv4(127, 0, 0, 3))
+	d := &Dialer{
+		Resolver:      staticResolver(parseIPs("127.0.0.2", "127.0.0.3", "127.0.0.1")),
+		IPFilter:      allIPs,
+		FailedAddrs:   failed,
+		HappyEyeballs: true,
+	}
+	c, ex, err := d.DialExplain(context.Background(), "tcp", net.JoinHostPort("foo.com", port))
+	if err != nil {
+		t.Fatalf("DialExplain failed: %v", err)
+	}
+	c.Close()
+	var names []string
+	for _, step := range ex.Filters {
+		names = append(names, step.Name)
+	}
+	if want := []string{"Network", "FailedAddrs", "IPFilter"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected filters %v; got %v", want, names)
+	}
+	if step := ex.Filters[1]; !reflect.DeepEqual(step.Dropped, parseIPs("127.0.0.3")) {
+		t.Errorf("expected FailedAddrs to drop 127.0.0.3; got %v", step.Dropped)
+	}
+	if len(ex.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts; got %v", ex.Attempts)
+	}
+	if a := ex.Attempts[0]; a.Address != net.JoinHostPort("127.0.0.2", port) || !a.Done || a.Err == nil {
+		t.Errorf("expected the first attempt to fail; got %+v", a)
+	}
+	if a := ex.Attempts[1]; a.Address != net.JoinHostPort("127.0.0.1", port) || !a.Done || a.Err != nil {
+		t.Errorf("expected the second attempt to connect; got %+v", a)
+	}
+}
+
+func TestDialerExplainProxy(t *testing.T) {
+	d := &Dialer{
+		Resolver:     staticResolver(parseIPs("127.0.0.1")),
+		Proxy:        &SOCKS5Proxy{Address: "proxy.com:1080"},
+		ProxyResolve: true,
+	}
+	ex, err := d.Explain(context.Background(), "tcp", "foo.com:80")
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(ex.Answers) != 0 || len(ex.Filters) != 0 || len(ex.Addrs) != 0 {
+		t.Errorf("expected no addresses to be selected; got %+v", ex)
+	}
+
+	// The destination is explained rather than the proxy server.
+	d.ProxyResolve = false
+	ex, err = d.Explain(context.Background(), "tcp", "foo.com:80")
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if want := []string{"127.0.0.1:80"}; !reflect.DeepEqual(ex.Addrs, want) {
+		t.Errorf("expected addresses %v; got %v", want, ex.Addrs)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// A FailedAddrCache remembers IP addresses that recently failed to
+// connect so that dials can prefer, or only use, other addresses.
+//
+// A FailedAddrCache is safe for concurrent use by multiple goroutines.
+type FailedAddrCache struct {
+	// TTL is the amount of time a failed address is remembered.
+	// If zero, one minute is used.
+	TTL time.Duration
+
+	// Skip causes Filter to discard failed addresses, unless all
+	// of the addresses have failed. Otherwise failed addresses
+	// are moved after the others.
+	Skip bool
+
+	mu    sync.Mutex
+	addrs map[string]time.Time // expiry by IP address
+}
+
+func (c *FailedAddrCache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return time.Minute
+}
+
+// Failed reports whether ip recently failed to connect.
+func (c *FailedAddrCache) Failed(ip net.IP) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed(ip.String(), timeNow())
+}
+
+func (c *FailedAddrCache) failed(key string, now time.Time) bool {
+	expiry, ok := c.addrs[key]
+	if ok && !now.Before(expiry) {
+		delete(c.addrs, key)
+		return false
+	}
+	return ok
+}
+
+// Addrs returns the addresses that recently failed to connect.
+func (c *FailedAddrCache) Addrs() []net.IP {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := timeNow()
+	var ips []net.IP
+	for key := range c.addrs {
+		if c.failed(key, now) {
+			ips = append(ips, net.ParseIP(key))
+		}
+	}
+	return ips
+}
+
+// Add records that ip failed to connect.
+func (c *FailedAddrCache) Add(ip net.IP) {
+	c.mu.Lock()
+	if c.addrs == nil {
+		c.addrs = make(map[string]time.Time)
+	}
+	c.addrs[ip.String()] = timeNow().Add(c.ttl())
+	c.mu.Unlock()
+}
+
+// Remove forgets that ip failed to connect.
+func (c *FailedAddrCache) Remove(ip net.IP) {
+	c.mu.Lock()
+	delete(c.addrs, ip.String())
+	c.mu.Unlock()
+}
+
+// Clear forgets every failed address.
+func (c *FailedAddrCache) Clear() {
+	c.mu.Lock()
+	c.addrs = nil
+	c.mu.Unlock()
+}
+
+// Filter returns ips with the addresses that recently failed
+// to connect moved after the others, preserving order, or
+// discarded if Skip is set and any addresses have not failed.
+func (c *FailedAddrCache) Filter(ips []net.IP) []net.IP {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.addrs) == 0 {
+		return ips
+	}
+	now := timeNow()
+	var ok, failed []net.IP
+	for _, ip := range ips {
+		if c.failed(ip.String(), now) {
+			failed = append(failed, ip)
+		} else {
+			ok = append(ok, ip)
+		}
+	}
+	switch {
+	case len(ok) == 0:
+		return failed
+	case c.Skip:
+		return ok
+	}
+	return append(ok, failed...)
+}
+
+// filter returns filter applied after ordering by c.
+func (c *FailedAddrCache) filter(filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		return filter(c.Filter(ips))
+	}
+}
+
+// dial returns fn wrapped to record the outcomes of its attempts.
+func (c *FailedAddrCache) dial(fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := fn(ctx, network, address)
+		// Resolved addresses don't have zones, so the zone is
+		// stripped before the address is recorded.
+		host, _ := splitHostZone(hostOf(address))
+		if ip := net.ParseIP(host); ip != nil {
+			if err == nil {
+				c.Remove(ip)
+			} else if !canceled(ctx, err) {
+				c.Add(ip)
+			}
+		}
+		return conn, err
+	}
+}
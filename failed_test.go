@@ -0,0 +1,149 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFailedAddrCache(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	a, b, c := net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2), net.IPv6loopback
+	cache := &FailedAddrCache{TTL: time.Minute}
+	cache.Add(a)
+	now = now.Add(time.Second)
+	cache.Add(c)
+	if !cache.Failed(a) || cache.Failed(b) || !cache.Failed(c) {
+		t.Fatal("unexpected failed addresses")
+	}
+	if ips := cache.Filter([]net.IP{a, b, c}); !reflect.DeepEqual(ips, []net.IP{b, a, c}) {
+		t.Errorf("Filter: expected %v; got %v", []net.IP{b, a, c}, ips)
+	}
+	cache.Skip = true
+	if ips := cache.Filter([]net.IP{a, b, c}); !reflect.DeepEqual(ips, []net.IP{b}) {
+		t.Errorf("Filter: expected %v; got %v", []net.IP{b}, ips)
+	}
+	if ips := cache.Filter([]net.IP{a, c}); !reflect.DeepEqual(ips, []net.IP{a, c}) {
+		t.Errorf("Filter: expected %v; got %v", []net.IP{a, c}, ips)
+	}
+
+	now = now.Add(time.Minute - time.Second) // expire a
+	if ips := cache.Addrs(); !reflect.DeepEqual(ips, []net.IP{c}) {
+		t.Errorf("Addrs: expected %v; got %v", []net.IP{c}, ips)
+	}
+	cache.Clear()
+	if cache.Failed(c) {
+		t.Error("expected cache to be cleared")
+	}
+}
+
+func TestDialFailedAddrs(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	dead := net.IPv4(127, 0, 0, 2).To4()
+	d := &Dialer{
+		Resolver:    staticResolver{dead, net.IPv4(127, 0, 0, 1)},
+		FailedAddrs: &FailedAddrCache{},
+	}
+	if _, err := d.Dial("tcp", "foo.com:"+port); err == nil {
+		t.Fatal("expected the first address to fail")
+	}
+	if !d.FailedAddrs.Failed(dead) {
+		t.Fatalf("expected %v to be recorded as failed", dead)
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+}
+
+// dialRace dials a host resolving to listeners on 127.0.0.1 and
+// 127.0.0.2 with d, holding the attempt to 127.0.0.2 until the race
+// is won by 127.0.0.1, and returns the error of the losing attempt.
+func dialRace(t *testing.T, d *Dialer) error {
+	t.Helper()
+	ln1, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln1.Close()
+	_, port, _ := net.SplitHostPort(ln1.Addr().String())
+	ln2, err := net.Listen("tcp4", "127.0.0.2:"+port)
+	if err != nil {
+		t.Skipf("can't listen on 127.0.0.2: %v", err)
+	}
+	defer ln2.Close()
+
+	won := make(chan struct{})
+	lost := make(chan error, 1)
+	d.Resolver = staticResolver{net.IPv4(127, 0, 0, 1), net.IPv4(127, 0, 0, 2)}
+	d.IPFilter = allIPs
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if strings.HasPrefix(address, "127.0.0.2:") {
+			<-won
+		}
+		return nil
+	}
+	d.Trace = &DialTrace{ConnectDone: func(network, address string, err error) {
+		if strings.HasPrefix(address, "127.0.0.2:") {
+			lost <- err
+		}
+	}}
+	c, err := d.Dial("tcp", net.JoinHostPort("foo.com", port))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	close(won)
+	return <-lost
+}
+
+func TestFailedAddrCacheRaceLoser(t *testing.T) {
+	cache := &FailedAddrCache{Skip: true}
+	if err := dialRace(t, &Dialer{FailedAddrs: cache}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the losing attempt to be canceled; got %v", err)
+	}
+	if ips := cache.Addrs(); len(ips) != 0 {
+		t.Errorf("expected the canceled attempt not to be recorded; got %v", ips)
+	}
+}
+
+func TestFailedAddrCacheZone(t *testing.T) {
+	errDenied := errors.New("denied")
+	cache := &FailedAddrCache{}
+	ip := net.ParseIP("fe80::1")
+	dial := cache.dial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errDenied
+	})
+	if _, err := dial(context.Background(), "tcp", "[fe80::1%eth0]:80"); err != errDenied {
+		t.Fatalf("expected %v; got %v", errDenied, err)
+	}
+	if !cache.Failed(ip) {
+		t.Fatalf("expected %v to be recorded as failed", ip)
+	}
+	dial = cache.dial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, nil
+	})
+	dial(context.Background(), "tcp", "[fe80::1%eth0]:80")
+	if cache.Failed(ip) {
+		t.Errorf("expected %v to be removed after a successful dial", ip)
+	}
+}
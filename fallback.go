@@ -0,0 +1,50 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// An Endpoint is a network and address that may be dialed.
+type Endpoint struct {
+	Network string
+	Address string
+}
+
+var errNoEndpoints = errors.New("no endpoints")
+
+// DialEndpoints connects to the first of the endpoints that can be
+// dialed, trying each in order until one succeeds. It may be used to
+// prefer one transport over another, such as a daemon's Unix domain
+// socket over its TCP loopback address.
+//
+// Timeout, Deadline and Cancel bound the dial as a whole rather than
+// each endpoint. If every endpoint fails, the error of the last one
+// is returned.
+func (d *Dialer) DialEndpoints(ctx context.Context, endpoints ...Endpoint) (net.Conn, error) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	if len(endpoints) == 0 {
+		return nil, &net.OpError{Op: "dial", Err: errNoEndpoints}
+	}
+	ctx, cancel := d.dialContext(ctx)
+	defer cancel()
+	var err error
+	for _, ep := range endpoints {
+		var c net.Conn
+		c, err = d.DialContext(ctx, ep.Network, ep.Address)
+		if err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
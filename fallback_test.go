@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDialEndpoints(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+
+	dir, err := os.MkdirTemp("", "nett")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := (&Dialer{}).DialEndpoints(context.Background(),
+		Endpoint{"unix", filepath.Join(dir, "missing.sock")},
+		Endpoint{"tcp", ln.Addr().String()},
+	)
+	if err != nil {
+		t.Fatalf("DialEndpoints failed: %v", err)
+	}
+	defer c.Close()
+	if c.RemoteAddr().String() != ln.Addr().String() {
+		t.Errorf("expected %v; got %v", ln.Addr(), c.RemoteAddr())
+	}
+
+	port := refusedPort(t)
+	_, err = (&Dialer{}).DialEndpoints(context.Background(),
+		Endpoint{"unix", filepath.Join(dir, "missing.sock")},
+		Endpoint{"tcp", "127.0.0.1:" + port},
+	)
+	if derr, ok := err.(*DialError); !ok || derr.Net != "tcp" {
+		t.Errorf("expected the tcp *DialError; got %T: %v", err, err)
+	}
+}
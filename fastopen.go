@@ -0,0 +1,79 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"strings"
+	"syscall"
+)
+
+type earlyDataKey struct{}
+
+// DialEarlyData connects to the address on the named network using
+// the provided context and writes data to the connection before
+// returning it, as the first bytes sent after any ProxyHeader.
+//
+// For TCP networks, TCP Fast Open is enabled for the dial, so that if
+// the server has issued a cookie to this host, data is sent in the SYN
+// and the server may respond without waiting for another round trip.
+// If there is no cookie, or the operating system or the server doesn't
+// support TCP Fast Open, data is written once the connection is
+// established. TCP Fast Open is only supported on Linux.
+//
+// The data is written by each attempt, so that an address that fails
+// to accept it is skipped like one that fails to connect. Since data
+// sent in a SYN may be delivered more than once, and since the losing
+// connections of a race also receive it, data must be safe to process
+// more than once, such as a TLS ClientHello or an idempotent request.
+func (d *Dialer) DialEarlyData(ctx context.Context, network, address string, data []byte) (net.Conn, error) {
+	return d.DialContext(context.WithValue(ctx, earlyDataKey{}, data), network, address)
+}
+
+// earlyData returns the data to be written by each attempt of a dial
+// with ctx, if any.
+func earlyData(ctx context.Context) ([]byte, bool) {
+	data, ok := ctx.Value(earlyDataKey{}).([]byte)
+	return data, ok
+}
+
+// writeEarlyData returns fn wrapped to write the ProxyHeader attached
+// to the context of each attempt, if any, and data to its connection.
+func writeEarlyData(data []byte, fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := fn(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if err = writeProxyHeader(ctx, c); err == nil {
+			err = handshake(ctx, c, func() error {
+				_, err := c.Write(data)
+				return err
+			})
+		}
+		if err != nil {
+			c.Close()
+			return nil, &net.OpError{Op: "dial", Net: network, Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+		}
+		return c, nil
+	}
+}
+
+// fastOpenControl returns a Control function that enables TCP Fast
+// Open for the sockets of TCP connections before calling control, if
+// it is non-nil. If it can't be enabled, the connection is made
+// without it.
+func fastOpenControl(control func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if strings.HasPrefix(network, "tcp") {
+			c.Control(func(fd uintptr) { setFastOpen(fd) })
+		}
+		if control != nil {
+			return control(network, address, c)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import "syscall"
+
+// tcpFastOpenConnect is the value of TCP_FASTOPEN_CONNECT, which
+// package syscall does not define.
+const tcpFastOpenConnect = 30
+
+func setFastOpen(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpenConnect, 1)
+}
@@ -0,0 +1,14 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package nett
+
+import "errors"
+
+func setFastOpen(fd uintptr) error {
+	return errors.New("TCP Fast Open is not supported on this system")
+}
@@ -0,0 +1,65 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDialEarlyData(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b, _ := io.ReadAll(c)
+			received <- string(b)
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	d := &Dialer{
+		Resolver:      staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)},
+		IPFilter:      allIPs,
+		HappyEyeballs: true, // a single family is dialed serially
+	}
+	c, err := d.DialEarlyData(context.Background(), "tcp", "foo.com:"+port, []byte("hello"))
+	if err != nil {
+		t.Fatalf("DialEarlyData failed: %v", err)
+	}
+	c.Close()
+	if got := <-received; got != "hello" {
+		t.Errorf("expected %q; got %q", "hello", got)
+	}
+
+	// The ProxyHeader comes first.
+	h := &ProxyHeader{Version: 1}
+	ctx := WithProxyHeader(context.Background(), h)
+	d.FastOpen = true
+	if c, err = d.DialEarlyData(ctx, "tcp", "foo.com:"+port, []byte("hello")); err != nil {
+		t.Fatalf("DialEarlyData failed: %v", err)
+	}
+	c.Close()
+	r := bufio.NewReader(strings.NewReader(<-received))
+	if hh, err := readProxyHeader(r); err != nil || hh.Version != 1 {
+		t.Fatalf("expected a PROXY header; got %+v, %v", hh, err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "hello" {
+		t.Errorf("expected %q after the header; got %q", "hello", rest)
+	}
+}
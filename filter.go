@@ -0,0 +1,527 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	srcAddrOf      = udpSrcAddr         // used by tests
+	interfaceAddrs = net.InterfaceAddrs // used by tests
+)
+
+// ParseIPs parses the given literal IP addresses into the form that
+// a Dialer passes to its IPFilter, with IPv4 addresses 4 bytes long,
+// so that filters may be applied to addresses obtained elsewhere,
+// such as from configuration or in tests, as they would be when
+// dialing.
+func ParseIPs(addrs ...string) ([]net.IP, error) {
+	ips := make([]net.IP, len(addrs))
+	for i, s := range addrs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		ips[i] = ip
+	}
+	return ips, nil
+}
+
+// SortRFC6724 orders ips by the destination address selection rules
+// of RFC 6724 section 6, preferring addresses that can be reached
+// with a source address of the same scope and label, addresses of
+// higher precedence, such as native IPv6 over IPv4 and IPv4 over
+// 6to4, addresses of smaller scope, and longer prefix matches with
+// their source addresses. Addresses it can't route to come last.
+//
+// Rules 3, 4 and 7 are not implemented, since they require
+// information that is not available. The relative order of
+// addresses that are otherwise equal is preserved.
+func SortRFC6724(ips []net.IP) []net.IP {
+	if len(ips) <= 1 {
+		return ips
+	}
+	s := &byRFC6724{
+		ips:      append([]net.IP(nil), ips...),
+		attrs:    make([]ipAttr, len(ips)),
+		srcs:     make([]net.IP, len(ips)),
+		srcAttrs: make([]ipAttr, len(ips)),
+	}
+	for i, ip := range s.ips {
+		s.attrs[i] = ipAttrOf(ip)
+		s.srcs[i] = srcAddrOf(ip)
+		s.srcAttrs[i] = ipAttrOf(s.srcs[i])
+	}
+	sort.Stable(s)
+	return s.ips
+}
+
+// SubnetFilter returns an IPFilter that selects the addresses in ips
+// that are in any of the given networks, such as to restrict dials to
+// the address ranges of a VPC, preserving their order.
+func SubnetFilter(nets ...*net.IPNet) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		return selectIPs(ips, func(ip net.IP) bool {
+			return containsIP(nets, ip)
+		})
+	}
+}
+
+// PublicOnly selects the addresses in ips that are publicly routable,
+// discarding loopback, link-local, unspecified, private (RFC 1918 and
+// unique local) and shared (carrier-grade NAT) addresses, so that a
+// service fetching user-supplied URLs can't be made to connect to
+// internal infrastructure. Since it is applied to the addresses
+// actually dialed, including literal IP addresses, it also guards
+// against redirects and DNS rebinding.
+//
+// Dials through a proxy server with ProxyResolve are not filtered,
+// since the proxy server resolves their addresses.
+func PublicOnly(ips []net.IP) []net.IP {
+	return selectIPs(ips, func(ip net.IP) bool { return !isInternalIP(ip) })
+}
+
+// PrivateOnly selects the addresses in ips that PublicOnly discards,
+// such as to restrict dials to internal services.
+func PrivateOnly(ips []net.IP) []net.IP {
+	return selectIPs(ips, isInternalIP)
+}
+
+// internalNets are the networks, other than those with net.IP
+// methods, that are not publicly routable.
+var internalNets = []*net.IPNet{
+	{IP: net.IP{0, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},     // "this" network
+	{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}, // shared address space
+}
+
+// isInternalIP reports whether ip is not publicly routable.
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		containsIP(internalNets, ip)
+}
+
+// WeightedFilter returns an IPFilter that selects one of the addresses
+// in ips at random, in proportion to its weight, such as to split
+// traffic 90/10 between two addresses with weights of 9 and 1. Weights
+// are keyed by the string form of the addresses, and addresses without
+// a positive weight are not selected unless none of ips have one, in
+// which case the first address is selected.
+func WeightedFilter(weights map[string]int) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		if len(ips) <= 1 {
+			return ips
+		}
+		sum := 0
+		for _, ip := range ips {
+			if w := weights[ip.String()]; w > 0 {
+				sum += w
+			}
+		}
+		if sum == 0 {
+			return ips[:1]
+		}
+		n := rand.Intn(sum)
+		for i, ip := range ips {
+			if w := weights[ip.String()]; w > 0 {
+				if n -= w; n < 0 {
+					return ips[i : i+1]
+				}
+			}
+		}
+		return nil // unreachable
+	}
+}
+
+// RoundRobin returns an IPFilter that rotates ips by one more address
+// each time it is called, so that each address comes first in turn,
+// for simple client-side load balancing. It is safe for concurrent
+// use by multiple goroutines.
+//
+// Since a Dialer connects to all of the selected addresses of a TCP
+// dial at once unless HappyEyeballs is set, the filter is typically
+// composed with one that selects the first of the addresses.
+func RoundRobin() func(ips []net.IP) []net.IP {
+	var next atomic.Uint64
+	return func(ips []net.IP) []net.IP {
+		if len(ips) <= 1 {
+			return ips
+		}
+		i := int((next.Add(1) - 1) % uint64(len(ips)))
+		a := make([]net.IP, 0, len(ips))
+		a = append(a, ips[i:]...)
+		return append(a, ips[:i]...)
+	}
+}
+
+// PreferSameSubnet orders ips so that the addresses on the same subnet
+// as one of the local interfaces' addresses come first, preserving the
+// order of the rest, such as to prefer the address of a peer on the
+// same overlay network on a multi-homed host. If the interfaces' addresses
+// can't be read, ips is returned unchanged.
+func PreferSameSubnet(ips []net.IP) []net.IP {
+	if len(ips) <= 1 {
+		return ips
+	}
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	var nets []*net.IPNet
+	for _, addr := range addrs {
+		if n, ok := addr.(*net.IPNet); ok && !n.IP.IsLoopback() {
+			nets = append(nets, n)
+		}
+	}
+	var local, other []net.IP
+	for _, ip := range ips {
+		if containsIP(nets, ip) {
+			local = append(local, ip)
+		} else {
+			other = append(other, ip)
+		}
+	}
+	return append(local, other...)
+}
+
+// MaxPerPrefix returns an IPFilter that selects at most max addresses
+// of each network prefix, preserving their order, so that the selected
+// addresses are spread across subnets rather than all in a rack or
+// subnet that may fail together. Prefixes are v4Bits long for IPv4
+// addresses, such as 24, and v6Bits long for IPv6 addresses, such as
+// 64. Lengths outside of the range of their family are clamped to it.
+func MaxPerPrefix(v4Bits, v6Bits, max int) func(ips []net.IP) []net.IP {
+	v4Mask := net.CIDRMask(clampBits(v4Bits, 8*net.IPv4len), 8*net.IPv4len)
+	v6Mask := net.CIDRMask(clampBits(v6Bits, 8*net.IPv6len), 8*net.IPv6len)
+	return func(ips []net.IP) []net.IP {
+		counts := make(map[string]int)
+		return selectIPs(ips, func(ip net.IP) bool {
+			var prefix net.IP
+			if ip4 := ip.To4(); ip4 != nil {
+				prefix = ip4.Mask(v4Mask)
+			} else {
+				prefix = ip.Mask(v6Mask)
+			}
+			key := string(prefix)
+			if counts[key] >= max {
+				return false
+			}
+			counts[key]++
+			return true
+		})
+	}
+}
+
+// clampBits returns the prefix length bits limited to [0, max].
+func clampBits(bits, max int) int {
+	if bits < 0 {
+		return 0
+	}
+	if bits > max {
+		return max
+	}
+	return bits
+}
+
+// Dedup returns ips without duplicate addresses, such as those that
+// are returned by multiple resolvers, preserving the order in which
+// they are first seen. The addresses resolved by a Dialer are always
+// deduplicated before its IPFilter is applied.
+func Dedup(ips []net.IP) []net.IP {
+	for i := 1; i < len(ips); i++ {
+		if indexIP(ips[:i], ips[i]) < 0 {
+			continue
+		}
+		// Copy the unique addresses so far and those after them.
+		a := append(make([]net.IP, 0, len(ips)-1), ips[:i]...)
+		for _, ip := range ips[i+1:] {
+			if indexIP(a, ip) < 0 {
+				a = append(a, ip)
+			}
+		}
+		return a
+	}
+	return ips
+}
+
+// indexIP returns the index of the first address in ips equal to ip,
+// or -1 if there is none.
+func indexIP(ips []net.IP, ip net.IP) int {
+	for i := range ips {
+		if ips[i].Equal(ip) {
+			return i
+		}
+	}
+	return -1
+}
+
+// WrapFilter returns an IPFilter that applies filter and then calls
+// done with the addresses that it was given, those that it selected
+// and the time it took, such as to log why an address was or wasn't
+// dialed. Nested calls may be used to observe each stage of composed
+// filters. The addresses passed to done must not be modified.
+func WrapFilter(filter func(ips []net.IP) []net.IP, done func(in, out []net.IP, d time.Duration)) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		// Filters may reorder ips in place.
+		in := append([]net.IP(nil), ips...)
+		start := time.Now()
+		out := filter(ips)
+		done(in, out, time.Since(start))
+		return out
+	}
+}
+
+// selectIPs returns the addresses in ips for which keep returns true,
+// preserving their order. The ips slice is not modified.
+func selectIPs(ips []net.IP, keep func(ip net.IP) bool) []net.IP {
+	var a []net.IP
+	for _, ip := range ips {
+		if keep(ip) {
+			a = append(a, ip)
+		}
+	}
+	return a
+}
+
+// containsIP reports whether ip is in any of nets.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// udpSrcAddr returns the source address that the system would use to
+// reach dst, or nil if it has no route to it. No packets are sent.
+func udpSrcAddr(dst net.IP) net.IP {
+	c, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+	if addr, ok := c.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+type byRFC6724 struct {
+	ips      []net.IP
+	attrs    []ipAttr
+	srcs     []net.IP
+	srcAttrs []ipAttr
+}
+
+func (s *byRFC6724) Len() int { return len(s.ips) }
+
+func (s *byRFC6724) Swap(i, j int) {
+	s.ips[i], s.ips[j] = s.ips[j], s.ips[i]
+	s.attrs[i], s.attrs[j] = s.attrs[j], s.attrs[i]
+	s.srcs[i], s.srcs[j] = s.srcs[j], s.srcs[i]
+	s.srcAttrs[i], s.srcAttrs[j] = s.srcAttrs[j], s.srcAttrs[i]
+}
+
+// Less reports whether the address at i is preferred over the one at j.
+func (s *byRFC6724) Less(i, j int) bool {
+	da, db := s.ips[i], s.ips[j]
+	attrA, attrB := s.attrs[i], s.attrs[j]
+	srcA, srcB := s.srcs[i], s.srcs[j]
+	srcAttrA, srcAttrB := s.srcAttrs[i], s.srcAttrs[j]
+
+	// Rule 1: Avoid unusable destinations.
+	if srcA == nil && srcB != nil {
+		return false
+	}
+	if srcB == nil && srcA != nil {
+		return true
+	}
+
+	// Rule 2: Prefer matching scope.
+	if attrA.scope == srcAttrA.scope && attrB.scope != srcAttrB.scope {
+		return true
+	}
+	if attrA.scope != srcAttrA.scope && attrB.scope == srcAttrB.scope {
+		return false
+	}
+
+	// Rule 5: Prefer matching label.
+	if srcAttrA.label == attrA.label && srcAttrB.label != attrB.label {
+		return true
+	}
+	if srcAttrA.label != attrA.label && srcAttrB.label == attrB.label {
+		return false
+	}
+
+	// Rule 6: Prefer higher precedence.
+	if attrA.precedence != attrB.precedence {
+		return attrA.precedence > attrB.precedence
+	}
+
+	// Rule 8: Prefer smaller scope.
+	if attrA.scope != attrB.scope {
+		return attrA.scope < attrB.scope
+	}
+
+	// Rule 9: Use longest matching prefix. Like other
+	// implementations, this is only applied to IPv6 addresses.
+	if srcA != nil && srcB != nil && da.To4() == nil && db.To4() == nil {
+		return commonPrefixLen(srcA, da) > commonPrefixLen(srcB, db)
+	}
+
+	// Rule 10: Otherwise, leave the order unchanged.
+	return false
+}
+
+type ipAttr struct {
+	scope      ipScope
+	precedence uint8
+	label      uint8
+}
+
+type ipScope uint8
+
+const (
+	scopeInterfaceLocal ipScope = 0x1
+	scopeLinkLocal      ipScope = 0x2
+	scopeAdminLocal     ipScope = 0x4
+	scopeSiteLocal      ipScope = 0x5
+	scopeOrgLocal       ipScope = 0x8
+	scopeGlobal         ipScope = 0xe
+)
+
+func ipAttrOf(ip net.IP) ipAttr {
+	if ip == nil {
+		return ipAttr{}
+	}
+	ent := rfc6724Policy(ip)
+	return ipAttr{
+		scope:      classifyScope(ip),
+		precedence: ent.precedence,
+		label:      ent.label,
+	}
+}
+
+// classifyScope returns the scope of ip as defined by RFC 6724
+// section 3.1, treating IPv4 addresses as IPv4-mapped addresses.
+func classifyScope(ip net.IP) ipScope {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return scopeLinkLocal
+	}
+	ipv6 := ip.To4() == nil
+	if ipv6 && ip.IsMulticast() {
+		return ipScope(ip[1] & 0xf)
+	}
+	// Site-local addresses are defined in RFC 3513 section 2.5.6
+	// and deprecated by RFC 3879.
+	if ipv6 && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0 {
+		return scopeSiteLocal
+	}
+	return scopeGlobal
+}
+
+type policyEntry struct {
+	prefix     netip.Prefix
+	precedence uint8
+	label      uint8
+}
+
+// rfc6724PolicyTable is the default policy table of RFC 6724
+// section 2.1, ordered by decreasing prefix length.
+var rfc6724PolicyTable = []policyEntry{
+	{netip.MustParsePrefix("::1/128"), 50, 0},
+	{netip.MustParsePrefix("::ffff:0:0/96"), 35, 4},
+	{netip.MustParsePrefix("::/96"), 1, 3},
+	{netip.MustParsePrefix("2001::/32"), 5, 5},
+	{netip.MustParsePrefix("2002::/16"), 30, 2},
+	{netip.MustParsePrefix("3ffe::/16"), 1, 12},
+	{netip.MustParsePrefix("fec0::/10"), 1, 11},
+	{netip.MustParsePrefix("fc00::/7"), 3, 13},
+	{netip.MustParsePrefix("::/0"), 40, 1},
+}
+
+// rfc6724Policy returns the entry of the policy table for ip.
+func rfc6724Policy(ip net.IP) policyEntry {
+	addr, ok := netip.AddrFromSlice(ip.To16())
+	if !ok {
+		return policyEntry{}
+	}
+	for _, ent := range rfc6724PolicyTable {
+		if ent.prefix.Contains(addr) {
+			return ent
+		}
+	}
+	return policyEntry{}
+}
+
+// commonPrefixLen reports the length of the longest prefix that a
+// and b have in common, up to the length of the prefix of a's
+// default subnet: 64 bits for IPv6, or 32 for IPv4.
+func commonPrefixLen(a, b net.IP) int {
+	if a4 := a.To4(); a4 != nil {
+		a = a4
+	}
+	if b4 := b.To4(); b4 != nil {
+		b = b4
+	}
+	if len(a) != len(b) {
+		return 0
+	}
+	if len(a) > 8 {
+		a, b = a[:8], b[:8]
+	}
+	n := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			n += 8
+			continue
+		}
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	return n
+}
+
+// Shuffle returns ips in random order, such as to spread connections
+// across the addresses of a host.
+func Shuffle(ips []net.IP) []net.IP {
+	a := append([]net.IP(nil), ips...)
+	rand.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+	return a
+}
+
+// ShuffleFrom returns a filter like Shuffle that draws its randomness
+// from src, so that a seeded source produces a reproducible order.
+// The filter is safe for concurrent use even if src is not.
+func ShuffleFrom(src rand.Source) func(ips []net.IP) []net.IP {
+	var mu sync.Mutex
+	r := rand.New(src)
+	return func(ips []net.IP) []net.IP {
+		a := append([]net.IP(nil), ips...)
+		mu.Lock()
+		r.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+		mu.Unlock()
+		return a
+	}
+}
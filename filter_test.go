@@ -0,0 +1,335 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// parseIPs parses the given addresses, returning IPv4 addresses
+// in their 4-byte form.
+func parseIPs(addrs ...string) []net.IP {
+	ips, err := ParseIPs(addrs...)
+	if err != nil {
+		panic(err)
+	}
+	return ips
+}
+
+func TestParseIPs(t *testing.T) {
+	ips, err := ParseIPs("192.0.2.1", "2001:db8::1", "::ffff:192.0.2.2")
+	if err != nil {
+		t.Fatalf("ParseIPs failed: %v", err)
+	}
+	want := []net.IP{{192, 0, 2, 1}, net.ParseIP("2001:db8::1"), {192, 0, 2, 2}}
+	if !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if got, want := DualStack(ips), ips[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("DualStack: expected %v; got %v", want, got)
+	}
+	for _, s := range []string{"foo.com", "192.0.2.256", "fe80::1%eth0"} {
+		if _, err := ParseIPs("192.0.2.1", s); err == nil {
+			t.Errorf("ParseIPs(%q): expected error", s)
+		}
+	}
+}
+
+func TestSortRFC6724(t *testing.T) {
+	defer func(fn func(net.IP) net.IP) { srcAddrOf = fn }(srcAddrOf)
+
+	// Examples from RFC 6724 section 10.2.
+	tests := []struct {
+		desc string
+		in   []net.IP
+		srcs map[string]string // destination to source
+		want []net.IP
+	}{
+		{
+			desc: "prefer usable",
+			in:   parseIPs("2001:db8:1::1", "198.51.100.121"),
+			srcs: map[string]string{"198.51.100.121": "198.51.100.117"},
+			want: parseIPs("198.51.100.121", "2001:db8:1::1"),
+		},
+		{
+			desc: "prefer matching scope",
+			in:   parseIPs("2001:db8:1::1", "198.51.100.121"),
+			srcs: map[string]string{"2001:db8:1::1": "fe80::1", "198.51.100.121": "198.51.100.117"},
+			want: parseIPs("198.51.100.121", "2001:db8:1::1"),
+		},
+		{
+			desc: "prefer higher precedence",
+			in:   parseIPs("10.1.2.3", "2001:db8:1::1"),
+			srcs: map[string]string{"2001:db8:1::1": "2001:db8:1::2", "10.1.2.3": "10.1.2.4"},
+			want: parseIPs("2001:db8:1::1", "10.1.2.3"),
+		},
+		{
+			desc: "prefer smaller scope",
+			in:   parseIPs("2001:db8:1::1", "fe80::1"),
+			srcs: map[string]string{"2001:db8:1::1": "2001:db8:1::2", "fe80::1": "fe80::2"},
+			want: parseIPs("fe80::1", "2001:db8:1::1"),
+		},
+		{
+			desc: "prefer longest matching prefix",
+			in:   parseIPs("2001:db8:3ffe::1", "2001:db8:1::1"),
+			srcs: map[string]string{"2001:db8:3ffe::1": "2001:db8:3f44::2", "2001:db8:1::1": "2001:db8:1::2"},
+			want: parseIPs("2001:db8:1::1", "2001:db8:3ffe::1"),
+		},
+		{
+			desc: "prefer matching label",
+			in:   parseIPs("2002:c633:6401::1", "2001:db8:1::1"),
+			srcs: map[string]string{"2002:c633:6401::1": "2002:c633:6401::2", "2001:db8:1::1": "2002:c633:6401::2"},
+			want: parseIPs("2002:c633:6401::1", "2001:db8:1::1"),
+		},
+		{
+			desc: "stable",
+			in:   parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+			srcs: map[string]string{"192.0.2.1": "192.0.2.9", "192.0.2.2": "192.0.2.9", "192.0.2.3": "192.0.2.9"},
+			want: parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+		},
+	}
+	for _, tt := range tests {
+		srcAddrOf = func(dst net.IP) net.IP {
+			if src, ok := tt.srcs[dst.String()]; ok {
+				return parseIPs(src)[0]
+			}
+			return nil
+		}
+		in := append([]net.IP(nil), tt.in...)
+		if got := SortRFC6724(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v; got %v", tt.desc, tt.want, got)
+		}
+		if !reflect.DeepEqual(tt.in, in) {
+			t.Errorf("%s: input modified: %v", tt.desc, tt.in)
+		}
+	}
+}
+
+// parseCIDRs parses the given networks.
+func parseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, s := range cidrs {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+func TestSubnetFilter(t *testing.T) {
+	filter := SubnetFilter(parseCIDRs(t, "10.0.0.0/8", "2001:db8:1::/48")...)
+	in := parseIPs("192.0.2.1", "10.1.2.3", "2001:db8:2::1", "2001:db8:1::1", "10.255.0.1")
+	want := parseIPs("10.1.2.3", "2001:db8:1::1", "10.255.0.1")
+	if got := filter(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	if got := filter(parseIPs("192.0.2.1")); len(got) != 0 {
+		t.Errorf("expected no addresses; got %v", got)
+	}
+}
+
+func TestPublicOnly(t *testing.T) {
+	public := parseIPs("192.0.2.1", "8.8.8.8", "100.128.0.1", "2001:db8::1", "2606:4700::1111")
+	internal := parseIPs(
+		"10.1.2.3", "172.16.0.1", "192.168.1.1", // RFC 1918
+		"127.0.0.1", "::1", // loopback
+		"169.254.169.254", "fe80::1", // link-local
+		"100.64.0.1", "100.127.255.255", // carrier-grade NAT
+		"fd00::1",       // unique local
+		"0.0.0.0", "::", // unspecified
+		"0.1.2.3",         // "this" network
+		"::ffff:10.0.0.1", // IPv4-mapped
+	)
+	in := append(append([]net.IP(nil), internal...), public...)
+	if got := PublicOnly(in); !reflect.DeepEqual(got, public) {
+		t.Errorf("PublicOnly: expected %v; got %v", public, got)
+	}
+	if got := PrivateOnly(in); !reflect.DeepEqual(got, internal) {
+		t.Errorf("PrivateOnly: expected %v; got %v", internal, got)
+	}
+}
+
+func TestWeightedFilter(t *testing.T) {
+	filter := WeightedFilter(map[string]int{"192.0.2.1": 9, "192.0.2.2": 1, "192.0.2.3": 0})
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4")
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ips := filter(in)
+		if len(ips) != 1 {
+			t.Fatalf("expected 1 address; got %v", ips)
+		}
+		counts[ips[0].String()]++
+	}
+	if c := counts["192.0.2.1"]; c < n*85/100 || c > n*95/100 {
+		t.Errorf("expected about 90%% of selections for 192.0.2.1; got %d of %d", c, n)
+	}
+	if c := counts["192.0.2.3"] + counts["192.0.2.4"]; c != 0 {
+		t.Errorf("expected no selections of unweighted addresses; got %d", c)
+	}
+	if got, want := filter(parseIPs("192.0.2.3", "192.0.2.4")), parseIPs("192.0.2.3"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	filter := RoundRobin()
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3")
+	for _, want := range [][]net.IP{
+		parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+		parseIPs("192.0.2.2", "192.0.2.3", "192.0.2.1"),
+		parseIPs("192.0.2.3", "192.0.2.1", "192.0.2.2"),
+		parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+	} {
+		if got := filter(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v; got %v", want, got)
+		}
+	}
+	if !reflect.DeepEqual(in, parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3")) {
+		t.Errorf("input modified: %v", in)
+	}
+}
+
+func TestPreferSameSubnet(t *testing.T) {
+	defer func(fn func() ([]net.Addr, error)) { interfaceAddrs = fn }(interfaceAddrs)
+	interfaceAddrs = func() ([]net.Addr, error) {
+		var addrs []net.Addr
+		for _, n := range parseCIDRs(t, "127.0.0.1/8", "10.1.0.5/16", "2001:db8:1::5/64") {
+			addrs = append(addrs, n)
+		}
+		return addrs, nil
+	}
+	in := parseIPs("192.0.2.1", "10.2.0.1", "2001:db8:1::1", "10.1.200.1", "127.0.0.1")
+	want := parseIPs("2001:db8:1::1", "10.1.200.1", "192.0.2.1", "10.2.0.1", "127.0.0.1")
+	if got := PreferSameSubnet(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4")
+	orders := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		out := Shuffle(in)
+		if len(out) != len(in) {
+			t.Fatalf("expected %d addresses; got %v", len(in), out)
+		}
+		orders[fmt.Sprint(out)] = true
+	}
+	if len(orders) < 2 {
+		t.Errorf("expected addresses in different orders; got %v", orders)
+	}
+	if want := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4"); !reflect.DeepEqual(in, want) {
+		t.Errorf("input modified: %v", in)
+	}
+}
+
+func TestShuffleFrom(t *testing.T) {
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5")
+	a, b := ShuffleFrom(rand.NewSource(42)), ShuffleFrom(rand.NewSource(42))
+	for i := 0; i < 10; i++ {
+		if x, y := a(in), b(in); !reflect.DeepEqual(x, y) {
+			t.Fatalf("expected equally seeded shuffles to match: %v, %v", x, y)
+		}
+	}
+	if want := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5"); !reflect.DeepEqual(in, want) {
+		t.Errorf("input modified: %v", in)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	tests := []struct {
+		in, want []net.IP
+	}{
+		{nil, nil},
+		{parseIPs("192.0.2.1", "192.0.2.2"), parseIPs("192.0.2.1", "192.0.2.2")},
+		{
+			[]net.IP{net.IPv4(192, 0, 2, 1).To4(), net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 2), net.IPv4(192, 0, 2, 1), net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 2)},
+			[]net.IP{net.IPv4(192, 0, 2, 1).To4(), net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 2)},
+		},
+	}
+	for _, tt := range tests {
+		if got := Dedup(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Dedup(%v): expected %v; got %v", tt.in, tt.want, got)
+		}
+	}
+
+	// Resolved addresses are deduplicated before they're filtered.
+	resolver := staticResolver(parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.1"))
+	var filtered []net.IP
+	filter := func(ips []net.IP) []net.IP {
+		filtered = ips
+		return ips
+	}
+	if _, err := resolveAddrList(resolver, filter, "tcp4", "foo.com:80"); err != nil {
+		t.Fatalf("resolveAddrList failed: %v", err)
+	}
+	if want := parseIPs("192.0.2.1", "192.0.2.2"); !reflect.DeepEqual(filtered, want) {
+		t.Errorf("expected filtered addresses %v; got %v", want, filtered)
+	}
+}
+
+func TestMaxPerPrefix(t *testing.T) {
+	filter := MaxPerPrefix(24, 64, 2)
+	in := parseIPs(
+		"192.0.2.1", "192.0.2.2", "192.0.2.3",
+		"198.51.100.1",
+		"2001:db8::1", "2001:db8::2", "2001:db8::3",
+		"2001:db8:0:1::1",
+		"192.0.2.4",
+	)
+	want := parseIPs(
+		"192.0.2.1", "192.0.2.2",
+		"198.51.100.1",
+		"2001:db8::1", "2001:db8::2",
+		"2001:db8:0:1::1",
+	)
+	if got := filter(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+
+	// Out of range prefix lengths are clamped.
+	filter = MaxPerPrefix(-8, 200, 1)
+	want = parseIPs("192.0.2.1", "2001:db8::1", "2001:db8::2", "2001:db8::3", "2001:db8:0:1::1")
+	if got := filter(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+func TestWrapFilter(t *testing.T) {
+	var stages []string
+	record := func(name string) func(in, out []net.IP, d time.Duration) {
+		return func(in, out []net.IP, d time.Duration) {
+			if d < 0 {
+				t.Errorf("%s: unexpected negative duration %v", name, d)
+			}
+			stages = append(stages, fmt.Sprintf("%s: %v -> %v", name, in, out))
+		}
+	}
+	reverse := func(ips []net.IP) []net.IP {
+		for i, j := 0, len(ips)-1; i < j; i, j = i+1, j-1 {
+			ips[i], ips[j] = ips[j], ips[i]
+		}
+		return ips
+	}
+	filter := WrapFilter(func(ips []net.IP) []net.IP {
+		return WrapFilter(DualStack, record("dualstack"))(reverse(ips))
+	}, record("all"))
+	filter(parseIPs("192.0.2.1", "192.0.2.2", "2001:db8::1"))
+	want := []string{
+		"dualstack: [2001:db8::1 192.0.2.2 192.0.2.1] -> [2001:db8::1 192.0.2.2]",
+		"all: [192.0.2.1 192.0.2.2 2001:db8::1] -> [2001:db8::1 192.0.2.2]",
+	}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("expected %q; got %q", want, stages)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import "net"
+
+// A FilterListener is a net.Listener that closes the connections it
+// accepts from disallowed remote IP addresses immediately, without
+// returning them, such as to restrict a server to clients in its
+// private networks.
+//
+// Connections whose remote addresses are not IP addresses, such as
+// those of Unix sockets, are always accepted.
+type FilterListener struct {
+	net.Listener
+
+	// Allow, if non-empty, lists the networks from which
+	// connections are allowed.
+	Allow []*net.IPNet
+
+	// Deny lists the networks from which connections are not
+	// allowed, taking precedence over Allow.
+	Deny []*net.IPNet
+
+	// IPFilter, if non-nil, disallows connections from the
+	// addresses it discards, so that the filters of a Dialer,
+	// such as PrivateOnly or the Filter of a DenyList, may also
+	// be applied to clients. It is called with a single address.
+	IPFilter func(ips []net.IP) []net.IP
+
+	// Rejected, if non-nil, is called with each connection that is
+	// disallowed before it is closed.
+	Rejected func(c net.Conn)
+}
+
+// Accept waits for and returns the next allowed connection to the
+// listener.
+func (l *FilterListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.allowed(c.RemoteAddr()) {
+			return c, nil
+		}
+		if l.Rejected != nil {
+			l.Rejected(c)
+		}
+		c.Close()
+	}
+}
+
+// allowed reports whether connections from addr are allowed.
+func (l *FilterListener) allowed(addr net.Addr) bool {
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.IPAddr:
+		ip = addr.IP
+	default:
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if containsIP(l.Deny, ip) || len(l.Allow) > 0 && !containsIP(l.Allow, ip) {
+		return false
+	}
+	return l.IPFilter == nil || len(l.IPFilter([]net.IP{ip})) > 0
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFilterListener(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl := NewDenyList([]net.IP{net.IPv4(127, 0, 0, 3)}, nil)
+	var rejected []string
+	l := &FilterListener{
+		Listener: ln,
+		Allow:    parseCIDRs(t, "127.0.0.0/24"),
+		Deny:     parseCIDRs(t, "127.0.0.2/32"),
+		IPFilter: dl.Filter,
+		Rejected: func(c net.Conn) { rejected = append(rejected, c.RemoteAddr().(*net.TCPAddr).IP.String()) },
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	for _, src := range []string{"127.0.1.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+		d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(src)}}
+		c, err := d.Dial("tcp4", "127.0.0.1:"+port)
+		if err != nil {
+			t.Fatalf("Dial from %s failed: %v", src, err)
+		}
+		defer c.Close()
+	}
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	c.Close()
+	if ip := c.RemoteAddr().(*net.TCPAddr).IP.String(); ip != "127.0.0.4" {
+		t.Errorf("expected a connection from 127.0.0.4; got %s", ip)
+	}
+	if len(rejected) != 3 {
+		t.Errorf("expected 3 rejected connections; got %v", rejected)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// A GracefulListener is a net.Listener that tracks the connections
+// it accepts, so that a server may stop accepting connections and
+// wait for its connections to finish before exiting, such as during
+// a rolling restart.
+//
+// A GracefulListener is safe for concurrent use by multiple goroutines.
+type GracefulListener struct {
+	net.Listener
+
+	mu      sync.Mutex
+	conns   map[*gracefulConn]struct{}
+	closed  bool
+	drained chan struct{} // closed once conns is empty, after Shutdown
+}
+
+// NewGracefulListener returns a GracefulListener that accepts
+// connections from ln.
+func NewGracefulListener(ln net.Listener) *GracefulListener {
+	return &GracefulListener{
+		Listener: ln,
+		conns:    make(map[*gracefulConn]struct{}),
+	}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *GracefulListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		c.Close()
+		return nil, &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: net.ErrClosed}
+	}
+	gc := &gracefulConn{Conn: c, l: l}
+	l.conns[gc] = struct{}{}
+	return gc, nil
+}
+
+// Close stops accepting connections. Connections that have already
+// been accepted are unaffected.
+func (l *GracefulListener) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	return l.Listener.Close()
+}
+
+// Shutdown stops accepting connections and waits for the accepted
+// connections to be closed. If ctx is done first, the remaining
+// connections are closed and the context's error is returned.
+// Otherwise, the error of closing the listener is returned.
+func (l *GracefulListener) Shutdown(ctx context.Context) error {
+	err := l.Close()
+	l.mu.Lock()
+	if len(l.conns) == 0 {
+		l.mu.Unlock()
+		return err
+	}
+	if l.drained == nil {
+		l.drained = make(chan struct{})
+	}
+	drained := l.drained
+	l.mu.Unlock()
+	select {
+	case <-drained:
+		return err
+	case <-ctx.Done():
+		l.mu.Lock()
+		conns := make([]*gracefulConn, 0, len(l.conns))
+		for c := range l.conns {
+			conns = append(conns, c)
+		}
+		l.mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+// ActiveConns returns the number of accepted connections that have
+// not been closed.
+func (l *GracefulListener) ActiveConns() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// remove forgets c, once it is closed.
+func (l *GracefulListener) remove(c *gracefulConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, c)
+	if len(l.conns) == 0 && l.drained != nil {
+		close(l.drained)
+		l.drained = nil
+	}
+}
+
+type gracefulConn struct {
+	net.Conn
+	l    *GracefulListener
+	once sync.Once
+}
+
+func (c *gracefulConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.l.remove(c) })
+	return err
+}
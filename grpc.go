@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// GRPCContextDialer returns a dial function suitable for use with
+// grpc.WithContextDialer that connects using d.
+//
+// Addresses may use the target syntaxes of gRPC's name resolvers:
+// "dns:///host:port" and "dns://authority/host:port" dial host:port
+// over TCP, with the authority ignored in favor of d's Resolver, and
+// "unix:path", "unix:///path" and "unix-abstract:name" dial a Unix
+// domain socket. Any other address is dialed over TCP.
+func GRPCContextDialer(d *Dialer) func(ctx context.Context, address string) (net.Conn, error) {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		network, address := parseGRPCTarget(address)
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// parseGRPCTarget returns the network and address to dial for a gRPC target.
+func parseGRPCTarget(target string) (network, address string) {
+	switch {
+	case strings.HasPrefix(target, "dns:///"):
+		return "tcp", target[len("dns:///"):]
+	case strings.HasPrefix(target, "dns://"):
+		target = target[len("dns://"):]
+		if i := strings.IndexByte(target, '/'); i >= 0 {
+			return "tcp", target[i+1:]
+		}
+		return "tcp", target
+	case strings.HasPrefix(target, "unix-abstract:"):
+		return "unix", "@" + target[len("unix-abstract:"):]
+	case strings.HasPrefix(target, "unix://"):
+		return "unix", target[len("unix://"):]
+	case strings.HasPrefix(target, "unix:"):
+		return "unix", target[len("unix:"):]
+	}
+	return "tcp", target
+}
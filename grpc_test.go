@@ -0,0 +1,62 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var grpcTargetTests = []struct {
+	target  string
+	network string
+	address string
+}{
+	{"example.com:443", "tcp", "example.com:443"},
+	{"dns:///example.com:443", "tcp", "example.com:443"},
+	{"dns://8.8.8.8/example.com:443", "tcp", "example.com:443"},
+	{"unix:sock", "unix", "sock"},
+	{"unix:/tmp/sock", "unix", "/tmp/sock"},
+	{"unix:///tmp/sock", "unix", "/tmp/sock"},
+	{"unix-abstract:sock", "unix", "@sock"},
+}
+
+func TestParseGRPCTarget(t *testing.T) {
+	for _, tt := range grpcTargetTests {
+		network, address := parseGRPCTarget(tt.target)
+		if network != tt.network || address != tt.address {
+			t.Errorf("parseGRPCTarget(%q): expected %s %q; got %s %q", tt.target, tt.network, tt.address, network, address)
+		}
+	}
+}
+
+func TestGRPCContextDialerUnix(t *testing.T) {
+	dir, err := os.MkdirTemp("", "nett")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+
+	dial := GRPCContextDialer(&Dialer{})
+	c, err := dial(context.Background(), "unix://"+path)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	c.Close()
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// An AddressHealth records the connect latency and failure rate of
+// IP addresses, so that dials can prefer the addresses that have
+// historically been fast and reliable. Its Filter method may be used
+// as, or composed into, an IPFilter and, when it is the Health of a
+// Dialer, each of the Dialer's connection attempts is recorded.
+//
+// Latencies and failure rates are exponentially weighted moving
+// averages, so that recent attempts count for more than old ones.
+//
+// An AddressHealth is safe for concurrent use by multiple goroutines.
+type AddressHealth struct {
+	// Penalty is the latency added to the score of an address by
+	// a failure rate of 1, which is scaled by the address's actual
+	// failure rate. If zero, one second is used.
+	Penalty time.Duration
+
+	// TTL is the amount of time an address is remembered after
+	// its last attempt. If zero, ten minutes is used.
+	TTL time.Duration
+
+	mu     sync.Mutex
+	addrs  map[string]*health // by IP address
+	pruned time.Time
+}
+
+// health is the record of attempts to connect to an address.
+type health struct {
+	latency  time.Duration // smoothed latency of successful attempts
+	measured bool          // whether latency has been measured
+	failure  float64       // smoothed failure rate
+	updated  time.Time     // when it was last attempted
+}
+
+// healthWeight is the weight of a new attempt in the smoothed
+// latency and failure rate.
+const healthWeight = 0.25
+
+func (h *AddressHealth) penalty() time.Duration {
+	if h.Penalty > 0 {
+		return h.Penalty
+	}
+	return time.Second
+}
+
+func (h *AddressHealth) ttl() time.Duration {
+	if h.TTL > 0 {
+		return h.TTL
+	}
+	return 10 * time.Minute
+}
+
+// Observe records an attempt to connect to ip, which took d and
+// failed if err is non-nil.
+func (h *AddressHealth) Observe(ip net.IP, d time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.addrs == nil {
+		h.addrs = make(map[string]*health)
+	}
+	now := timeNow()
+	h.prune(now)
+	key := ip.String()
+	a, ok := h.addrs[key]
+	if !ok || now.Sub(a.updated) >= h.ttl() {
+		a = &health{}
+		h.addrs[key] = a
+		if err != nil {
+			a.failure = 1
+		}
+	} else if err != nil {
+		a.failure += healthWeight * (1 - a.failure)
+	} else {
+		a.failure -= healthWeight * a.failure
+	}
+	a.updated = now
+	if err != nil {
+		return
+	}
+	if !a.measured {
+		a.latency, a.measured = d, true
+	} else {
+		a.latency += time.Duration(healthWeight * float64(d-a.latency))
+	}
+}
+
+// Stats returns the smoothed latency of the successful attempts to
+// connect to ip, the smoothed rate at which its attempts failed, and
+// whether any attempts have been recorded. The latency is zero if
+// every attempt failed.
+func (h *AddressHealth) Stats(ip net.IP) (latency time.Duration, failureRate float64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.addrs[ip.String()]
+	if !ok || timeNow().Sub(a.updated) >= h.ttl() {
+		return 0, 0, false
+	}
+	return a.latency, a.failure, true
+}
+
+// Filter returns ips ordered by score, from lowest to highest,
+// preserving the order of addresses with equal scores. The score
+// of an address is its latency plus its failure rate times Penalty.
+// Addresses without any recorded attempts come first, so that they
+// are measured.
+func (h *AddressHealth) Filter(ips []net.IP) []net.IP {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.addrs) == 0 {
+		return ips
+	}
+	now := timeNow()
+	type entry struct {
+		ip    net.IP
+		score time.Duration
+	}
+	entries := make([]entry, len(ips))
+	for i, ip := range ips {
+		e := entry{ip: ip}
+		if a, ok := h.addrs[ip.String()]; ok && now.Sub(a.updated) < h.ttl() {
+			e.score = a.latency + time.Duration(a.failure*float64(h.penalty()))
+		}
+		entries[i] = e
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score < entries[j].score
+	})
+	a := make([]net.IP, len(entries))
+	for i, e := range entries {
+		a[i] = e.ip
+	}
+	return a
+}
+
+// prune forgets the addresses that have not been attempted within
+// the TTL. The caller must hold h.mu.
+func (h *AddressHealth) prune(now time.Time) {
+	ttl := h.ttl()
+	if now.Sub(h.pruned) < ttl {
+		return
+	}
+	h.pruned = now
+	for key, a := range h.addrs {
+		if now.Sub(a.updated) >= ttl {
+			delete(h.addrs, key)
+		}
+	}
+}
+
+// filter returns filter applied after ordering by h.
+func (h *AddressHealth) filter(filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		return filter(h.Filter(ips))
+	}
+}
+
+// dial returns fn wrapped to record the outcomes of its attempts.
+// Attempts that are canceled, such as those that lose a race to
+// another address, are not recorded.
+func (h *AddressHealth) dial(fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := fn(ctx, network, address)
+		if ip := net.ParseIP(hostOf(address)); ip != nil && (err == nil || !canceled(ctx, err)) {
+			h.Observe(ip, time.Since(start), err)
+		}
+		return conn, err
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddressHealth(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	a, b, c, d := parseIPs("10.0.0.1")[0], parseIPs("10.0.0.2")[0], parseIPs("10.0.0.3")[0], parseIPs("10.0.0.4")[0]
+	h := &AddressHealth{Penalty: time.Second, TTL: time.Minute}
+	if ips := h.Filter([]net.IP{a, b, c, d}); !reflect.DeepEqual(ips, []net.IP{a, b, c, d}) {
+		t.Errorf("Filter: expected unchanged order; got %v", ips)
+	}
+	h.Observe(a, 100*time.Millisecond, nil)
+	h.Observe(b, 20*time.Millisecond, nil)
+	h.Observe(c, 10*time.Millisecond, nil)
+	h.Observe(c, 50*time.Millisecond, errors.New("refused"))
+	if lat, rate, ok := h.Stats(c); !ok || lat != 10*time.Millisecond || rate != healthWeight {
+		t.Errorf("Stats: unexpected latency %v and failure rate %v", lat, rate)
+	}
+	h.Observe(b, 60*time.Millisecond, nil)
+	if lat, rate, ok := h.Stats(b); !ok || lat != 30*time.Millisecond || rate != 0 {
+		t.Errorf("Stats: unexpected latency %v and failure rate %v", lat, rate)
+	}
+	// d has no history, b scores 30ms, a 100ms and c 10ms+250ms.
+	want := []net.IP{d, b, a, c}
+	if ips := h.Filter([]net.IP{a, b, c, d}); !reflect.DeepEqual(ips, want) {
+		t.Errorf("Filter: expected %v; got %v", want, ips)
+	}
+
+	now = now.Add(time.Minute) // forget every address
+	if _, _, ok := h.Stats(a); ok {
+		t.Error("expected expired history to be forgotten")
+	}
+	h.Observe(c, 5*time.Millisecond, nil)
+	want = []net.IP{a, b, d, c}
+	if ips := h.Filter([]net.IP{a, b, c, d}); !reflect.DeepEqual(ips, want) {
+		t.Errorf("Filter: expected %v; got %v", want, ips)
+	}
+}
+
+func TestDialHealth(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	dead, live := parseIPs("127.0.0.2")[0], parseIPs("127.0.0.1")[0]
+	d := &Dialer{
+		Resolver: staticResolver{dead, live},
+		Health:   &AddressHealth{},
+	}
+	if _, err := d.Dial("tcp", "foo.com:"+port); err == nil {
+		t.Fatal("expected the first address to fail")
+	}
+	if _, rate, ok := d.Health.Stats(dead); !ok || rate != 1 {
+		t.Fatalf("expected %v to be recorded as failed", dead)
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if _, rate, ok := d.Health.Stats(live); !ok || rate != 0 {
+		t.Errorf("expected %v to be recorded as healthy", live)
+	}
+}
+
+func TestAddressHealthRaceLoser(t *testing.T) {
+	h := &AddressHealth{}
+	if err := dialRace(t, &Dialer{Health: h}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the losing attempt to be canceled; got %v", err)
+	}
+	if _, _, ok := h.Stats(net.IPv4(127, 0, 0, 2)); ok {
+		t.Error("expected the canceled attempt not to be recorded")
+	}
+	if _, rate, ok := h.Stats(net.IPv4(127, 0, 0, 1)); !ok || rate != 0 {
+		t.Errorf("expected the winning attempt to be recorded as a success; got rate %v, %v", rate, ok)
+	}
+}
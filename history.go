@@ -0,0 +1,152 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// A SuccessHistory remembers the address of each host name that most
+// recently connected, so that dialing the host again tries it first,
+// cutting the latency of reconnecting to services with many addresses
+// of which some are unreachable. When it is the History of a Dialer,
+// the Dialer's successful connections are recorded and the last
+// successful address is moved to the front before IPFilter is applied.
+//
+// So that the other addresses of a host are not shunned forever, its
+// addresses are left in their resolved order for one dial every
+// ProbeInterval.
+//
+// A SuccessHistory is safe for concurrent use by multiple goroutines.
+type SuccessHistory struct {
+	// ProbeInterval is how often the addresses of a host are dialed
+	// in their resolved order. If zero, five minutes is used. If
+	// negative, they are always reordered.
+	ProbeInterval time.Duration
+
+	// MaxEntries is the maximum number of hosts remembered, after
+	// which the least recently connected host is forgotten. If
+	// zero, 1024 is used.
+	MaxEntries int
+
+	mu    sync.Mutex
+	hosts map[string]*successEntry // by host name
+	lru   *list.List               // of keys, most recently connected first
+}
+
+type successEntry struct {
+	ip     net.IP
+	probed time.Time     // when the addresses were last left in order
+	elem   *list.Element // position in SuccessHistory.lru
+}
+
+func (h *SuccessHistory) probeInterval() time.Duration {
+	if h.ProbeInterval != 0 {
+		return h.ProbeInterval
+	}
+	return 5 * time.Minute
+}
+
+func (h *SuccessHistory) maxEntries() int {
+	if h.MaxEntries > 0 {
+		return h.MaxEntries
+	}
+	return 1024
+}
+
+// Last returns the address of host that most recently connected,
+// or nil if there is none.
+func (h *SuccessHistory) Last(host string) net.IP {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.hosts[host]; ok {
+		return e.ip
+	}
+	return nil
+}
+
+// Observe records that connecting to ip, an address of host, succeeded.
+func (h *SuccessHistory) Observe(host string, ip net.IP) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.hosts[host]; ok {
+		e.ip = ip
+		h.lru.MoveToFront(e.elem)
+		return
+	}
+	if h.hosts == nil {
+		h.hosts = make(map[string]*successEntry)
+		h.lru = list.New()
+	}
+	for len(h.hosts) >= h.maxEntries() {
+		elem := h.lru.Back()
+		h.lru.Remove(elem)
+		delete(h.hosts, elem.Value.(string))
+	}
+	h.hosts[host] = &successEntry{ip: ip, probed: timeNow(), elem: h.lru.PushFront(host)}
+}
+
+// Forget forgets the last successful address of host.
+func (h *SuccessHistory) Forget(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.hosts[host]; ok {
+		h.lru.Remove(e.elem)
+		delete(h.hosts, host)
+	}
+}
+
+// order returns ips with the last successful address of host moved
+// to the front, unless it is time to probe the other addresses.
+func (h *SuccessHistory) order(host string, ips []net.IP) []net.IP {
+	h.mu.Lock()
+	e, ok := h.hosts[host]
+	if !ok {
+		h.mu.Unlock()
+		return ips
+	}
+	if interval := h.probeInterval(); interval > 0 {
+		if now := timeNow(); now.Sub(e.probed) >= interval {
+			e.probed = now
+			h.mu.Unlock()
+			return ips
+		}
+	}
+	last := e.ip
+	h.mu.Unlock()
+	i := indexIP(ips, last)
+	if i <= 0 {
+		return ips
+	}
+	a := make([]net.IP, 0, len(ips))
+	a = append(a, ips[i])
+	a = append(a, ips[:i]...)
+	return append(a, ips[i+1:]...)
+}
+
+// filter returns filter applied after ordering the addresses of host.
+func (h *SuccessHistory) filter(host string, filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		return filter(h.order(host, ips))
+	}
+}
+
+// dial returns fn wrapped to record the successful attempts of a dial
+// of host.
+func (h *SuccessHistory) dial(host string, fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := fn(ctx, network, address)
+		if err == nil {
+			if ip := net.ParseIP(hostOf(address)); ip != nil {
+				h.Observe(host, ip)
+			}
+		}
+		return c, err
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSuccessHistory(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	a, b, c := parseIPs("10.0.0.1")[0], parseIPs("10.0.0.2")[0], parseIPs("10.0.0.3")[0]
+	h := &SuccessHistory{ProbeInterval: time.Minute, MaxEntries: 2}
+	if ips := h.order("foo.com", []net.IP{a, b, c}); !reflect.DeepEqual(ips, []net.IP{a, b, c}) {
+		t.Errorf("expected unchanged order; got %v", ips)
+	}
+	h.Observe("foo.com", c)
+	want := []net.IP{c, a, b}
+	if ips := h.order("foo.com", []net.IP{a, b, c}); !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if ips := h.order("bar.com", []net.IP{a, b, c}); !reflect.DeepEqual(ips, []net.IP{a, b, c}) {
+		t.Errorf("expected other hosts to be unchanged; got %v", ips)
+	}
+
+	now = now.Add(time.Minute) // probe the alternatives once
+	if ips := h.order("foo.com", []net.IP{a, b, c}); !reflect.DeepEqual(ips, []net.IP{a, b, c}) {
+		t.Errorf("expected resolved order when probing; got %v", ips)
+	}
+	if ips := h.order("foo.com", []net.IP{a, b, c}); !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v after probing; got %v", want, ips)
+	}
+
+	h.Observe("bar.com", a)
+	h.Observe("baz.com", b) // forget foo.com
+	if ip := h.Last("foo.com"); ip != nil {
+		t.Errorf("expected the least recent host to be forgotten; got %v", ip)
+	}
+	h.Forget("bar.com")
+	if ip := h.Last("bar.com"); ip != nil {
+		t.Errorf("expected forgotten host; got %v", ip)
+	}
+	if ip := h.Last("baz.com"); !ip.Equal(b) {
+		t.Errorf("expected %v; got %v", b, ip)
+	}
+}
+
+func TestDialHistory(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var attempts []string
+	d := &Dialer{
+		Resolver:      staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)},
+		IPFilter:      allIPs,
+		HappyEyeballs: true,
+		History:       &SuccessHistory{},
+		Trace: &DialTrace{
+			ConnectStart: func(network, address string) { attempts = append(attempts, address) },
+		},
+	}
+	for i := 0; i < 2; i++ {
+		c, err := d.Dial("tcp", "foo.com:"+port)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		c.Close()
+	}
+	live := net.JoinHostPort("127.0.0.1", port)
+	want := []string{net.JoinHostPort("127.0.0.2", port), live, live}
+	if !reflect.DeepEqual(attempts, want) {
+		t.Errorf("expected attempts %v; got %v", want, attempts)
+	}
+	if ip := d.History.Last("foo.com"); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("expected 127.0.0.1 to be recorded; got %v", ip)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrHostRateLimit is returned, wrapped in a *net.OpError, by dials
+// that exceed the rate of their host with a failing-fast
+// HostRateLimiter.
+var ErrHostRateLimit error = &hostRateLimitError{}
+
+type hostRateLimitError struct{}
+
+func (e *hostRateLimitError) Error() string   { return "host dial rate exceeded" }
+func (e *hostRateLimitError) Timeout() bool   { return false }
+func (e *hostRateLimitError) Temporary() bool { return true }
+
+// A HostRate is a rate of dials.
+type HostRate struct {
+	// Rate is the number of dials per second allowed. If zero,
+	// dials are not limited.
+	Rate float64
+
+	// Burst is the number of dials allowed at once, on top of the
+	// Rate. If less than one, one is used.
+	Burst int
+}
+
+// A HostRateLimiter limits the rate at which the hosts of dial
+// addresses are dialed, so that crawlers and scanners can be polite
+// without an external throttling layer. When it is the RateLimit of
+// a Dialer, each dial and retry of a host takes a token from a token
+// bucket of the host.
+//
+// Hosts are keyed as they appear in dial addresses, ignoring case,
+// so a host name and its addresses are limited separately.
+//
+// A HostRateLimiter is safe for concurrent use by multiple
+// goroutines. Its fields must not be changed once it has been used.
+type HostRateLimiter struct {
+	// HostRate is the rate of each host not in Hosts.
+	HostRate
+
+	// Hosts holds the rates of particular hosts, keyed by their
+	// lower-case names or addresses.
+	Hosts map[string]HostRate
+
+	// FailFast makes dials that exceed the rate of their host fail
+	// with ErrHostRateLimit. Otherwise, they wait until the host may
+	// be dialed, unless their context is done first.
+	FailFast bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// maxIdleHostBuckets is the number of buckets above which the buckets
+// of hosts that are not being limited are discarded.
+const maxIdleHostBuckets = 1024
+
+// bucket returns the bucket of host, or nil if it is not limited.
+func (l *HostRateLimiter) bucket(host string) *tokenBucket {
+	host = strings.ToLower(host)
+	rate, ok := l.Hosts[host]
+	if !ok {
+		rate = l.HostRate
+	}
+	if rate.Rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[host]; ok {
+		return b
+	}
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	if len(l.buckets) >= maxIdleHostBuckets {
+		for h, b := range l.buckets {
+			if b.full() {
+				delete(l.buckets, h)
+			}
+		}
+	}
+	b := newTokenBucket(rate.Rate, rate.Burst)
+	l.buckets[host] = b
+	return b
+}
+
+// wait takes a token for a dial of host, waiting for one if required.
+func (l *HostRateLimiter) wait(ctx context.Context, host string) error {
+	b := l.bucket(host)
+	if b == nil {
+		return nil
+	}
+	if l.FailFast {
+		if !b.allow() {
+			return ErrHostRateLimit
+		}
+		return nil
+	}
+	d := b.reserve(1)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		b.unreserve(1)
+		return mapErr(ctx.Err())
+	}
+}
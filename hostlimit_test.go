@@ -0,0 +1,87 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHostRateLimiter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	limiter := &HostRateLimiter{
+		HostRate: HostRate{Rate: 0.001, Burst: 2},
+		Hosts:    map[string]HostRate{"polite.test": {Rate: 0.001, Burst: 1}},
+		FailFast: true,
+	}
+	d := &Dialer{
+		Resolver:  staticResolver{net.IPv4(127, 0, 0, 1)},
+		RateLimit: limiter,
+	}
+	dial := func(host string) error {
+		c, err := d.Dial("tcp", net.JoinHostPort(host, port))
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"a.test", true},
+		{"A.test", true},
+		{"a.test", false},
+		{"b.test", true},
+		{"polite.test", true},
+		{"polite.test", false},
+	}
+	for i, tt := range tests {
+		err := dial(tt.host)
+		if tt.ok && err != nil {
+			t.Errorf("%d: dial of %s failed: %v", i, tt.host, err)
+		} else if !tt.ok && !errors.Is(err, ErrHostRateLimit) {
+			t.Errorf("%d: expected dial of %s to fail with ErrHostRateLimit; got %v", i, tt.host, err)
+		}
+	}
+
+	// Dials wait for their host's rate.
+	d.RateLimit = &HostRateLimiter{HostRate: HostRate{Rate: 20}}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := dial("a.test"); err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("expected 3 dials at 20 per second to take 100ms; took %v", elapsed)
+	}
+
+	d.RateLimit = &HostRateLimiter{HostRate: HostRate{Rate: 0.001}}
+	dial("a.test")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.DialContext(ctx, "tcp", net.JoinHostPort("a.test", port)); !errors.Is(err, errTimeout) {
+		t.Errorf("expected the dial to wait until its deadline; got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// HostsResolver resolves hosts to the addresses of a static table,
+// like an in-process hosts file, and other hosts with an underlying
+// Resolver. It satisfies TTLResolver.
+type HostsResolver struct {
+	// Hosts maps host names to their IP addresses. Names are
+	// matched case-insensitively and without a trailing dot.
+	// It must not be modified while the HostsResolver is in use.
+	Hosts map[string][]net.IP
+
+	// Resolver resolves hosts that are not in Hosts.
+	// If Resolver is nil, DefaultResolver will be used.
+	Resolver Resolver
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *HostsResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses and
+// their time to live, if the underlying Resolver reports it. Hosts in
+// the table have an unknown time to live.
+func (r *HostsResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	if ips, ok := r.lookup(host); ok {
+		return copyIPs(ips), -1, nil
+	}
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	return resolveTTL(resolver, host)
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *HostsResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	if ips, ok := r.lookup(host); ok {
+		return copyIPs(ips), nil
+	}
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	return resolveContext(ctx, resolver, host)
+}
+
+func (r *HostsResolver) lookup(host string) ([]net.IP, bool) {
+	if ips, ok := r.Hosts[host]; ok {
+		return ips, true
+	}
+	host = strings.TrimSuffix(host, ".")
+	for name, ips := range r.Hosts {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), host) {
+			return ips, true
+		}
+	}
+	return nil, false
+}
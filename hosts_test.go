@@ -0,0 +1,37 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestHostsResolver(t *testing.T) {
+	canary := []net.IP{net.IPv4(192, 0, 2, 1)}
+	fallback := []net.IP{net.IPv6loopback}
+	r := &HostsResolver{
+		Hosts:    map[string][]net.IP{"api.example.com": canary},
+		Resolver: staticResolver(fallback),
+	}
+	tests := []struct {
+		host string
+		ips  []net.IP
+	}{
+		{"api.example.com", canary},
+		{"API.Example.com.", canary},
+		{"www.example.com", fallback},
+	}
+	for _, tt := range tests {
+		ips, err := r.Resolve(tt.host)
+		if err != nil {
+			t.Fatalf("Resolve(%q) failed: %v", tt.host, err)
+		}
+		if !reflect.DeepEqual(ips, tt.ips) {
+			t.Errorf("Resolve(%q): expected %v; got %v", tt.host, tt.ips, ips)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// HTTPProxy is an HTTP proxy server that tunnels connections
+// using the CONNECT method.
+type HTTPProxy struct {
+	// Network is the network of the proxy server.
+	// If empty, "tcp" is used.
+	Network string
+
+	// Address is the address of the proxy server.
+	Address string
+
+	// TLSConfig, if non-nil, is used to connect to the proxy
+	// server over TLS. If its ServerName is empty, the host of
+	// Address is used.
+	TLSConfig *tls.Config
+
+	// Username and Password authenticate with the proxy server
+	// using Basic authentication. If Username is empty, no
+	// authentication is used.
+	Username string
+	Password string
+
+	// Header, if non-nil, holds additional header fields sent
+	// with each CONNECT request.
+	Header http.Header
+}
+
+// ProxyAddr returns the network and address of the proxy server.
+func (p *HTTPProxy) ProxyAddr() (network, address string) {
+	if p.Network == "" {
+		return "tcp", p.Address
+	}
+	return p.Network, p.Address
+}
+
+// Tunnel asks the proxy server connected to c to connect to address.
+func (p *HTTPProxy) Tunnel(ctx context.Context, c net.Conn, network, address string) (net.Conn, error) {
+	if p.TLSConfig != nil {
+		tc := tls.Client(c, tlsConfig(p.TLSConfig, p.Address))
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		c = tc
+	}
+	header := make(http.Header)
+	for k, v := range p.Header {
+		header[k] = v
+	}
+	if p.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: header,
+	}
+	if err := req.Write(c); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("proxy: unexpected status: " + resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{c, br}, nil
+	}
+	return c, nil
+}
+
+// bufferedConn is a net.Conn whose reads are served from r
+// until its buffered data is exhausted.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(b)
+	}
+	return c.Conn.Read(b)
+}
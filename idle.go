@@ -0,0 +1,107 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrIdleTimeout is returned by the reads and writes of a connection
+// wrapped by WrapIdleTimeout once it has been closed for inactivity.
+var ErrIdleTimeout error = &idleTimeoutError{}
+
+type idleTimeoutError struct{}
+
+func (e *idleTimeoutError) Error() string   { return "connection idle timeout" }
+func (e *idleTimeoutError) Timeout() bool   { return true }
+func (e *idleTimeoutError) Temporary() bool { return false }
+
+// WrapIdleTimeout returns c wrapped to be closed once no Read or
+// Write has started or completed for d, so that servers and pools
+// can enforce idle policies uniformly. A Read or Write in progress
+// when the connection is closed fails with ErrIdleTimeout, as do
+// those that follow.
+func WrapIdleTimeout(c net.Conn, d time.Duration) net.Conn {
+	ic := &idleTimeoutConn{Conn: c, d: d}
+	ic.timer = time.AfterFunc(d, ic.expire)
+	return ic
+}
+
+type idleTimeoutConn struct {
+	net.Conn
+	d     time.Duration
+	timer *time.Timer
+
+	mu     sync.Mutex
+	idle   bool // closed for inactivity
+	closed bool
+}
+
+func (c *idleTimeoutConn) expire() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.idle, c.closed = true, true
+	c.mu.Unlock()
+	c.Conn.Close()
+}
+
+// active resets the idle timer and reports whether c is still open.
+func (c *idleTimeoutConn) active() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return !c.idle
+	}
+	c.timer.Reset(c.d)
+	return true
+}
+
+// err returns err, or ErrIdleTimeout if c was closed for inactivity.
+func (c *idleTimeoutConn) err(op string, err error) error {
+	c.mu.Lock()
+	idle := c.idle
+	c.mu.Unlock()
+	if !idle {
+		return err
+	}
+	return &net.OpError{Op: op, Net: c.LocalAddr().Network(), Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: ErrIdleTimeout}
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if !c.active() {
+		return 0, c.err("read", nil)
+	}
+	n, err := c.Conn.Read(b)
+	c.active()
+	if err != nil {
+		err = c.err("read", err)
+	}
+	return n, err
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	if !c.active() {
+		return 0, c.err("write", nil)
+	}
+	n, err := c.Conn.Write(b)
+	c.active()
+	if err != nil {
+		err = c.err("write", err)
+	}
+	return n, err
+}
+
+func (c *idleTimeoutConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.timer.Stop()
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
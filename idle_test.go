@@ -0,0 +1,51 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWrapIdleTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapIdleTimeout(a, 50*time.Millisecond)
+	defer c.Close()
+
+	// Activity keeps the connection open.
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := b.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := c.Write([]byte("x")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	start := time.Now()
+	_, err := c.Read(make([]byte, 1))
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected ErrIdleTimeout; got %v", err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("expected the connection to be idle for 50ms; closed after %v", d)
+	}
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Errorf("expected a timeout error; got %v", err)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, ErrIdleTimeout) {
+		t.Errorf("expected ErrIdleTimeout; got %v", err)
+	}
+}
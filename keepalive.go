@@ -0,0 +1,83 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"time"
+)
+
+// A KeepAliveListener is a net.Listener that sets TCP options on
+// every connection it accepts, giving servers the control over their
+// connections that the KeepAlive of a Dialer gives clients, even for
+// listeners that were not created by a ListenConfig.
+//
+// Options are only set on connections that support them, such as a
+// *net.TCPConn. Errors setting them are ignored, as with the
+// keep-alives of net.ListenConfig.
+type KeepAliveListener struct {
+	net.Listener
+
+	// KeepAlive specifies the keep-alive period of accepted
+	// connections.
+	//
+	// If zero, keep-alives are enabled with a default period of
+	// 15 seconds. If negative, keep-alives are disabled.
+	KeepAlive time.Duration
+
+	// Nagle enables Nagle's algorithm, which delays small writes
+	// to coalesce them, by disabling TCP_NODELAY.
+	Nagle bool
+
+	// ReadBuffer and WriteBuffer, if positive, set the sizes of
+	// the operating system's receive and send buffers of accepted
+	// connections.
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// defaultKeepAlive is the keep-alive period used by net.ListenConfig
+// and net.Dialer when theirs is zero.
+const defaultKeepAlive = 15 * time.Second
+
+// Accept waits for the next connection to the listener and sets its
+// options.
+func (l *KeepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if kc, ok := c.(interface {
+		SetKeepAlive(keepalive bool) error
+		SetKeepAlivePeriod(d time.Duration) error
+	}); ok {
+		if l.KeepAlive < 0 {
+			kc.SetKeepAlive(false)
+		} else {
+			period := l.KeepAlive
+			if period == 0 {
+				period = defaultKeepAlive
+			}
+			kc.SetKeepAlive(true)
+			kc.SetKeepAlivePeriod(period)
+		}
+	}
+	if l.Nagle {
+		if nc, ok := c.(interface{ SetNoDelay(noDelay bool) error }); ok {
+			nc.SetNoDelay(false)
+		}
+	}
+	if l.ReadBuffer > 0 {
+		if bc, ok := c.(interface{ SetReadBuffer(bytes int) error }); ok {
+			bc.SetReadBuffer(l.ReadBuffer)
+		}
+	}
+	if l.WriteBuffer > 0 {
+		if bc, ok := c.(interface{ SetWriteBuffer(bytes int) error }); ok {
+			bc.SetWriteBuffer(l.WriteBuffer)
+		}
+	}
+	return c, nil
+}
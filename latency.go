@@ -0,0 +1,211 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A LatencySorter orders IP addresses by their measured round-trip
+// times, so that dials prefer the fastest of them. Its Filter method
+// may be used as, or composed into, an IPFilter.
+//
+// Addresses are probed in the background the first time they are
+// filtered and again once their measurement is older than Interval.
+// Measurements are smoothed, so that those of a single slow probe
+// decay rather than reordering the addresses.
+//
+// Since a Dialer connects to all of the selected addresses of a TCP
+// dial at once unless HappyEyeballs is set, Filter is typically
+// composed with a filter that selects the first of the addresses.
+//
+// A LatencySorter is safe for concurrent use by multiple goroutines.
+type LatencySorter struct {
+	// Port is the TCP port to which connections are made to
+	// probe an address. If zero, port 443 is used.
+	Port int
+
+	// Interval is how often an address is probed again.
+	// If zero, one minute is used.
+	Interval time.Duration
+
+	// Timeout is the maximum amount of time a probe may take.
+	// If zero, one second is used.
+	Timeout time.Duration
+
+	// Probe, if non-nil, measures the round-trip time of ip,
+	// instead of timing a TCP connection to Port.
+	Probe func(ctx context.Context, ip net.IP) (time.Duration, error)
+
+	mu     sync.Mutex
+	addrs  map[string]*latency // by IP address
+	pruned time.Time
+}
+
+// latency is the measured round-trip time of an address.
+type latency struct {
+	rtt     time.Duration // smoothed round-trip time
+	failed  bool          // whether the last probe failed
+	probed  time.Time     // when it was last probed
+	used    time.Time     // when it was last filtered
+	probing bool
+}
+
+// latencyWeight is the weight of a new measurement in the smoothed
+// round-trip time.
+const latencyWeight = 0.25
+
+func (s *LatencySorter) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return time.Minute
+}
+
+func (s *LatencySorter) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return time.Second
+}
+
+// RTT returns the smoothed round-trip time of ip and whether it is
+// known. It is not known if ip has not been probed or its last probe
+// failed.
+func (s *LatencySorter) RTT(ip net.IP) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.addrs[ip.String()]
+	if !ok || l.probed.IsZero() || l.failed {
+		return 0, false
+	}
+	return l.rtt, true
+}
+
+// Filter returns ips ordered by round-trip time, preserving the order
+// of addresses with equal times. Addresses that have not been probed
+// follow those that have, and addresses whose last probe failed come
+// last. If no addresses have been probed, ips is returned unchanged.
+func (s *LatencySorter) Filter(ips []net.IP) []net.IP {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.addrs == nil {
+		s.addrs = make(map[string]*latency)
+	}
+	now := timeNow()
+	s.prune(now)
+	type entry struct {
+		ip   net.IP
+		rank int // 0 if probed, 1 if not, or 2 if failed
+		rtt  time.Duration
+	}
+	var (
+		entries  = make([]entry, len(ips))
+		measured bool
+	)
+	for i, ip := range ips {
+		key := ip.String()
+		l, ok := s.addrs[key]
+		if !ok {
+			l = &latency{}
+			s.addrs[key] = l
+		}
+		l.used = now
+		if !l.probing && (l.probed.IsZero() || now.Sub(l.probed) >= s.interval()) {
+			l.probing = true
+			go s.probe(key, ip, l)
+		}
+		e := entry{ip: ip, rank: 1}
+		switch {
+		case l.probed.IsZero():
+		case l.failed:
+			e.rank = 2
+			measured = true
+		default:
+			e.rank, e.rtt = 0, l.rtt
+			measured = true
+		}
+		entries[i] = e
+	}
+	if !measured {
+		return ips
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].rank != entries[j].rank {
+			return entries[i].rank < entries[j].rank
+		}
+		return entries[i].rtt < entries[j].rtt
+	})
+	a := make([]net.IP, len(entries))
+	for i, e := range entries {
+		a[i] = e.ip
+	}
+	return a
+}
+
+// prune forgets the addresses that have not been filtered recently.
+// The caller must hold s.mu.
+func (s *LatencySorter) prune(now time.Time) {
+	ttl := 2 * s.interval()
+	if now.Sub(s.pruned) < ttl {
+		return
+	}
+	s.pruned = now
+	for key, l := range s.addrs {
+		if !l.probing && now.Sub(l.used) >= ttl {
+			delete(s.addrs, key)
+		}
+	}
+}
+
+// probe measures the round-trip time of ip and records it in l.
+func (s *LatencySorter) probe(key string, ip net.IP, l *latency) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+	var (
+		rtt time.Duration
+		err error
+	)
+	if s.Probe != nil {
+		rtt, err = s.Probe(ctx, ip)
+	} else {
+		port := s.Port
+		if port == 0 {
+			port = 443
+		}
+		rtt, err = probeTCP(ctx, ip, port)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.probing = false
+	first := l.probed.IsZero() || l.failed
+	l.probed = timeNow()
+	if l.failed = err != nil; l.failed {
+		return
+	}
+	if first {
+		l.rtt = rtt
+	} else {
+		l.rtt += time.Duration(latencyWeight * float64(rtt-l.rtt))
+	}
+}
+
+// probeTCP measures the time it takes to connect to port of ip.
+func probeTCP(ctx context.Context, ip net.IP, port int) (time.Duration, error) {
+	var d net.Dialer
+	start := time.Now()
+	c, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	c.Close()
+	return rtt, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencySorter(t *testing.T) {
+	var mu sync.Mutex
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	rtts := map[string]time.Duration{
+		"192.0.2.1": 30 * time.Millisecond,
+		"192.0.2.2": 10 * time.Millisecond,
+		"192.0.2.4": 20 * time.Millisecond,
+	}
+	probes := 0
+	s := &LatencySorter{
+		Interval: time.Minute,
+		Probe: func(ctx context.Context, ip net.IP) (time.Duration, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			probes++
+			if rtt, ok := rtts[ip.String()]; ok {
+				return rtt, nil
+			}
+			return 0, errors.New("unreachable")
+		},
+	}
+	// probed waits for n probes to finish.
+	probed := func(n int) {
+		for {
+			mu.Lock()
+			done := probes >= n
+			mu.Unlock()
+			if done {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		for {
+			s.mu.Lock()
+			probing := false
+			for _, l := range s.addrs {
+				probing = probing || l.probing
+			}
+			s.mu.Unlock()
+			if !probing {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4")
+	if got := s.Filter(in); !reflect.DeepEqual(got, in) {
+		t.Errorf("expected unprobed addresses unchanged %v; got %v", in, got)
+	}
+	probed(4)
+	want := parseIPs("192.0.2.2", "192.0.2.4", "192.0.2.1", "192.0.2.3")
+	if got := s.Filter(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	if rtt, ok := s.RTT(in[1]); !ok || rtt != 10*time.Millisecond {
+		t.Errorf("expected RTT of 10ms; got %v, %v", rtt, ok)
+	}
+	if _, ok := s.RTT(in[2]); ok {
+		t.Error("expected unknown RTT of unreachable address")
+	}
+
+	// A slower measurement is smoothed: 10ms + (130ms-10ms)/4 = 40ms.
+	mu.Lock()
+	rtts["192.0.2.2"] = 130 * time.Millisecond
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	s.Filter(in)
+	probed(8)
+	want = parseIPs("192.0.2.4", "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	if got := s.Filter(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	if rtt, _ := s.RTT(in[1]); rtt != 40*time.Millisecond {
+		t.Errorf("expected smoothed RTT of 40ms; got %v", rtt)
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	rtt, err := probeTCP(context.Background(), net.IPv4(127, 0, 0, 1), ln.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		t.Fatalf("probeTCP failed: %v", err)
+	}
+	if rtt <= 0 {
+		t.Errorf("expected positive RTT; got %v", rtt)
+	}
+	port, err := strconv.Atoi(refusedPort(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := probeTCP(context.Background(), net.IPv4(127, 0, 0, 1), port); err == nil {
+		t.Error("expected error probing refused port")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// A LimitListener is a net.Listener that limits the rate at which it
+// accepts connections and the number of its accepted connections that
+// may be open at once, so that a server sheds load before it runs
+// out of file descriptors or other resources.
+//
+// Once a limit is reached, Accept waits until the limit permits
+// another connection, unless Reject is set.
+//
+// A LimitListener is safe for concurrent use by multiple goroutines,
+// but its fields must not be modified after it first accepts a
+// connection.
+type LimitListener struct {
+	net.Listener
+
+	// Rate is the number of connections per second that may be
+	// accepted on average. If zero, the rate is unlimited.
+	Rate float64
+
+	// Burst is the number of connections that may be accepted at
+	// once, in excess of Rate. If zero, 1 is used.
+	Burst int
+
+	// MaxConns is the maximum number of accepted connections that
+	// may be open at once. If zero, the number is unlimited.
+	MaxConns int
+
+	// Reject causes Accept to close connections in excess of the
+	// limits immediately after accepting them and to return an
+	// *AcceptLimitError, instead of waiting.
+	Reject bool
+
+	once   sync.Once
+	bucket *tokenBucket
+	sem    chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// An AcceptLimitError is returned by the Accept method of a
+// LimitListener when it rejects a connection in excess of its limits.
+type AcceptLimitError struct {
+	MaxConns bool // whether MaxConns was reached, rather than Rate
+}
+
+func (e *AcceptLimitError) Error() string {
+	if e.MaxConns {
+		return "too many open connections"
+	}
+	return "connection rate limit exceeded"
+}
+
+func (e *AcceptLimitError) Timeout() bool   { return false }
+func (e *AcceptLimitError) Temporary() bool { return true }
+
+func (l *LimitListener) init() {
+	if l.Rate > 0 {
+		l.bucket = newTokenBucket(l.Rate, l.Burst)
+	}
+	if l.MaxConns > 0 {
+		l.sem = make(chan struct{}, l.MaxConns)
+	}
+	l.done = make(chan struct{})
+}
+
+// Accept waits for and returns the next connection to the listener
+// that is permitted by its limits.
+func (l *LimitListener) Accept() (net.Conn, error) {
+	l.once.Do(l.init)
+	if l.Reject {
+		return l.acceptOrReject()
+	}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-l.done:
+			return nil, l.closedError()
+		}
+	}
+	if l.bucket != nil {
+		if d := l.bucket.reserve(1); d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-l.done:
+				t.Stop()
+				l.release()
+				return nil, l.closedError()
+			}
+		}
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	return l.wrap(c), nil
+}
+
+func (l *LimitListener) acceptOrReject() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			c.Close()
+			return nil, &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: &AcceptLimitError{MaxConns: true}}
+		}
+	}
+	if l.bucket != nil && !l.bucket.allow() {
+		l.release()
+		c.Close()
+		return nil, &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: &AcceptLimitError{}}
+	}
+	return l.wrap(c), nil
+}
+
+// wrap returns c wrapped to release its slot when it is closed.
+func (l *LimitListener) wrap(c net.Conn) net.Conn {
+	if l.sem == nil {
+		return c
+	}
+	return &limitedConn{Conn: c, release: l.release}
+}
+
+// release frees the slot of a connection.
+func (l *LimitListener) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+func (l *LimitListener) closedError() error {
+	return &net.OpError{Op: "accept", Net: l.Addr().Network(), Addr: l.Addr(), Err: net.ErrClosed}
+}
+
+// Close closes the listener, interrupting any Accept waiting for
+// the limits to permit a connection.
+func (l *LimitListener) Close() error {
+	l.once.Do(l.init)
+	l.closed.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+type limitedConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
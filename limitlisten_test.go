@@ -0,0 +1,83 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListenerMaxConns(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &LimitListener{Listener: ln, MaxConns: 1}
+	defer l.Close()
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer c.Close()
+	}
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("expected Accept to wait for the open connection to be closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Close()
+	if c := <-accepted; c == nil {
+		t.Fatal("Accept failed")
+	} else {
+		c.Close()
+	}
+
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	if c := <-accepted; c != nil {
+		t.Error("expected Accept to fail once the listener is closed")
+	}
+}
+
+func TestLimitListenerReject(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &LimitListener{Listener: ln, Rate: 0.001, Burst: 1, Reject: true}
+	defer l.Close()
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer c.Close()
+	}
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	s.Close()
+	var lerr *AcceptLimitError
+	if _, err := l.Accept(); !errors.As(err, &lerr) || lerr.MaxConns {
+		t.Errorf("expected a rate *AcceptLimitError; got %v", err)
+	}
+}
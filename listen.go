@@ -0,0 +1,222 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// A ListenConfig contains options for listening to an address. The
+// host of the address being listened on is resolved and filtered as
+// a Dialer does with the hosts it dials, such as to bind to the IPv4
+// address of a host name.
+type ListenConfig struct {
+	// Resolver is used to resolve IP addresses from domain names.
+	// If it is a ContextResolver, the context of each listen is
+	// passed to it. If it is a FamilyResolver, only the addresses
+	// of the family of a family-specific network are looked up.
+	//
+	// If nil, DefaultResolver will be used.
+	Resolver Resolver
+
+	// IPFilter selects addresses from those available after
+	// resolving a host to a set of supported IPs, without
+	// duplicates. If the network is family-specific, such as
+	// "tcp4", only addresses of that family are available. The
+	// addresses are bound in order until one succeeds.
+	//
+	// If nil, a single address is selected.
+	IPFilter func(ips []net.IP) []net.IP
+
+	// UnixFilter, if non-nil, reports whether the address of a
+	// Unix domain socket may be listened on, such as
+	// UnixPathPrefix. If it returns false, listening fails with
+	// ErrNoSuitableAddress.
+	UnixFilter func(name string) bool
+
+	// KeepAlive specifies the keep-alive period for network
+	// connections accepted by listeners, as with net.ListenConfig.
+	//
+	// If zero, keep-alives are enabled if supported by the protocol
+	// and operating system. If negative, keep-alives are disabled.
+	KeepAlive time.Duration
+
+	// Control is called after creating the network connection but
+	// before binding it to the operating system, as with
+	// net.ListenConfig. It is called once per address attempted.
+	Control func(network, address string, c syscall.RawConn) error
+
+	// ReusePort enables SO_REUSEPORT on the sockets of listeners,
+	// so that multiple processes or listeners may bind the same
+	// address and the operating system balances connections among
+	// them, such as to hand off a port without downtime.
+	//
+	// If the operating system does not support it, listening fails.
+	ReusePort bool
+
+	// UseMultipathTCP listens for TCP connections with Multipath
+	// TCP, as with net.ListenConfig's SetMultipathTCP. Clients that
+	// don't support it connect with plain TCP, and if the operating
+	// system doesn't support it, plain TCP is used. It requires
+	// Go 1.21 or later; otherwise it is ignored.
+	UseMultipathTCP bool
+
+	// ReadBuffer and WriteBuffer, if positive, set the sizes of the
+	// operating system's receive and send buffers of the packet
+	// connections created by ListenPacket.
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// Listen announces on the local network address.
+//
+// The network must be "tcp", "tcp4", "tcp6", "unix" or "unixpacket".
+//
+// For TCP networks, if the host in the address parameter is empty,
+// Listen listens on all available unicast and anycast IP addresses
+// of the local system. Otherwise the host may be a literal IP
+// address or a host name, which is resolved by DefaultResolver and
+// the first of its addresses is bound.
+//
+// See ListenConfig.Listen for details.
+func Listen(network, address string) (net.Listener, error) {
+	var lc ListenConfig
+	return lc.Listen(context.Background(), network, address)
+}
+
+// Listen announces on the local network address, using the provided
+// context to resolve and bind it. Once the listener is created, any
+// expiration of the context will not affect it.
+//
+// If every address selected by the IPFilter fails to be bound, the
+// error of the last attempt is returned.
+func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	addrs, err := lc.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	nlc := lc.netListenConfig()
+	var ln net.Listener
+	for i := 0; i < addrs.Len(); i++ {
+		if ln, err = nlc.Listen(ctx, network, addrs.Addr(i)); err == nil {
+			return ln, nil
+		}
+	}
+	return nil, err
+}
+
+// ListenPacket announces on the local network address using a zero
+// ListenConfig.
+//
+// The network must be "udp", "udp4", "udp6", "unixgram", or an IP
+// transport. For UDP and IP networks, if the host in the address
+// parameter is empty, ListenPacket listens on all available IP
+// addresses of the local system except multicast IP addresses.
+// Otherwise the host may be a literal IP address or a host name,
+// which is resolved by DefaultResolver and the first of its
+// addresses is bound.
+//
+// See ListenConfig.ListenPacket for details.
+func ListenPacket(network, address string) (net.PacketConn, error) {
+	var lc ListenConfig
+	return lc.ListenPacket(context.Background(), network, address)
+}
+
+// ListenPacket announces on the local network address, using the
+// provided context to resolve and bind it, as Listen does for stream
+// networks.
+func (lc *ListenConfig) ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+	addrs, err := lc.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	nlc := lc.netListenConfig()
+	var c net.PacketConn
+	for i := 0; i < addrs.Len(); i++ {
+		if c, err = nlc.ListenPacket(ctx, network, addrs.Addr(i)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := lc.setBuffers(c); err != nil {
+		c.Close()
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: c.LocalAddr(), Err: err}
+	}
+	return c, nil
+}
+
+// setBuffers sets the buffer sizes of c.
+func (lc *ListenConfig) setBuffers(c net.PacketConn) error {
+	if lc.ReadBuffer > 0 {
+		if bc, ok := c.(interface{ SetReadBuffer(bytes int) error }); ok {
+			if err := bc.SetReadBuffer(lc.ReadBuffer); err != nil {
+				return err
+			}
+		}
+	}
+	if lc.WriteBuffer > 0 {
+		if bc, ok := c.(interface{ SetWriteBuffer(bytes int) error }); ok {
+			if err := bc.SetWriteBuffer(lc.WriteBuffer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve resolves the local address list of a listen.
+func (lc *ListenConfig) resolve(ctx context.Context, network, address string) (addrList, error) {
+	var filter ipFilter = lc.IPFilter
+	if filter == nil {
+		filter = defaultIP
+	}
+	addrs, err := resolveAddrsContext(ctx, withContext(ctx, forNetwork(lc.Resolver, network)), filter, network, address)
+	if err == nil {
+		err = filterUnix(lc.UnixFilter, addrs)
+	}
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: err}
+	}
+	return addrs, nil
+}
+
+func (lc *ListenConfig) netListenConfig() net.ListenConfig {
+	control := lc.Control
+	if lc.ReusePort {
+		control = reusePortControl(control)
+	}
+	nlc := net.ListenConfig{
+		KeepAlive: lc.KeepAlive,
+		Control:   control,
+	}
+	if lc.UseMultipathTCP {
+		setMultipathTCPListenConfig(&nlc, true)
+	}
+	return nlc
+}
+
+// reusePortControl returns a Control function that enables
+// SO_REUSEPORT before calling control, if it is non-nil.
+func reusePortControl(control func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) { err = setReusePort(fd) }); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+		if control != nil {
+			return control(network, address, c)
+		}
+		return nil
+	}
+}
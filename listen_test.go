@@ -0,0 +1,136 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	ln, err := Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	if ip := ln.Addr().(*net.TCPAddr).IP; !ip.IsUnspecified() {
+		t.Errorf("expected an unspecified address; got %v", ip)
+	}
+}
+
+func TestListenConfig(t *testing.T) {
+	lc := &ListenConfig{
+		Resolver: staticResolver{
+			net.ParseIP("2001:db8::1"),
+			net.IPv4(192, 0, 2, 1).To4(), // not local
+			net.IPv4(127, 0, 0, 1).To4(),
+		},
+		IPFilter: func(ips []net.IP) []net.IP {
+			return selectIPs(ips, func(ip net.IP) bool { return ip.To4() != nil })
+		},
+	}
+	ln, err := lc.Listen(context.Background(), "tcp", "foo.com:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	if ip := ln.Addr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("expected to listen on 127.0.0.1; got %v", ip)
+	}
+
+	lc.IPFilter = func([]net.IP) []net.IP { return nil }
+	if _, err := lc.Listen(context.Background(), "tcp", "foo.com:0"); !errors.Is(err, ErrNoSuitableAddress) {
+		t.Errorf("expected ErrNoSuitableAddress; got %v", err)
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		t.Skipf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+	}
+	lc := &ListenConfig{ReusePort: true}
+	a, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer a.Close()
+	b, err := lc.Listen(context.Background(), "tcp4", a.Addr().String())
+	if err != nil {
+		t.Fatalf("expected a second listener to share %v: %v", a.Addr(), err)
+	}
+	b.Close()
+
+	lc.ReusePort = false
+	if c, err := lc.Listen(context.Background(), "tcp4", a.Addr().String()); err == nil {
+		c.Close()
+		t.Errorf("expected listening on %v without SO_REUSEPORT to fail", a.Addr())
+	}
+}
+
+func TestMultipathTCP(t *testing.T) {
+	lc := &ListenConfig{UseMultipathTCP: true}
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c.Write([]byte("x"))
+		c.Close()
+	}()
+
+	// Either end falls back to TCP if MPTCP isn't available.
+	d := &Dialer{UseMultipathTCP: true}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	var b [1]byte
+	if _, err := c.Read(b[:]); err != nil {
+		t.Errorf("Read failed: %v", err)
+	}
+}
+
+func TestListenPacket(t *testing.T) {
+	lc := &ListenConfig{
+		Resolver:    staticResolver{net.ParseIP("2001:db8::1"), net.IPv4(127, 0, 0, 1).To4()},
+		IPFilter:    func(ips []net.IP) []net.IP { return ips[len(ips)-1:] },
+		ReadBuffer:  1 << 16,
+		WriteBuffer: 1 << 16,
+	}
+	c, err := lc.ListenPacket(context.Background(), "udp", "foo.com:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer c.Close()
+	addr := c.LocalAddr().(*net.UDPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("expected to listen on 127.0.0.1; got %v", addr.IP)
+	}
+
+	s, err := ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.WriteTo([]byte("hello"), addr); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	b := make([]byte, 16)
+	n, from, err := c.ReadFrom(b)
+	if err != nil || string(b[:n]) != "hello" || from.String() != s.LocalAddr().String() {
+		t.Errorf("ReadFrom: expected %q from %v; got %q from %v, %v", "hello", s.LocalAddr(), b[:n], from, err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package nett
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestKeepAliveListener(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	for _, tt := range []struct {
+		ln               *KeepAliveListener
+		keepAlive, delay bool
+	}{
+		{&KeepAliveListener{Listener: ln}, true, false},
+		{&KeepAliveListener{Listener: ln, KeepAlive: -1, Nagle: true}, false, true},
+	} {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		s, err := tt.ln.Accept()
+		c.Close()
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		raw, err := s.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keepAlive, noDelay int
+		raw.Control(func(fd uintptr) {
+			if keepAlive, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); err == nil {
+				noDelay, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+			}
+		})
+		s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enabled := keepAlive != 0; enabled != tt.keepAlive {
+			t.Errorf("KeepAlive %v: expected keep-alives enabled %v; got %v", tt.ln.KeepAlive, tt.keepAlive, enabled)
+		}
+		if delay := noDelay == 0; delay != tt.delay {
+			t.Errorf("Nagle %v: expected delay %v; got %v", tt.ln.Nagle, tt.delay, delay)
+		}
+	}
+}
+
+func TestListenActivated(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The activated file descriptor is closed once it is loaded.
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(start int) {
+		listenFdsStart = start
+		activated = activatedListeners{}
+	}(listenFdsStart)
+	listenFdsStart = fd
+	activated = activatedListeners{}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	a, err := ListenActivated("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("ListenActivated failed: %v", err)
+	}
+	defer a.Close()
+	if a.Addr().String() != ln.Addr().String() {
+		t.Errorf("expected the activated listener on %v; got %v", ln.Addr(), a.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected LISTEN_FDS to be unset")
+	}
+
+	// The activated listener is only returned once, and other
+	// addresses are listened on.
+	b, err := ListenActivated("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenActivated failed: %v", err)
+	}
+	defer b.Close()
+	if b.Addr().String() == ln.Addr().String() {
+		t.Error("expected a new listener")
+	}
+	if lns := ActivationListeners(); len(lns) != 0 {
+		t.Errorf("expected no remaining listeners; got %v", lns)
+	}
+}
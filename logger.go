@@ -0,0 +1,46 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+)
+
+// A Logger logs events of a Dialer or CacheResolver, such as cache
+// misses, fallback dials and failures, that are otherwise silent.
+// The arguments following the message are alternating keys and
+// values, so that a *slog.Logger is a Logger.
+//
+// A Logger must be safe for concurrent use by multiple goroutines.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// logDial returns fn wrapped to log its failed attempts.
+func logDial(log Logger, fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := fn(ctx, network, address)
+		if err != nil && !canceled(ctx, err) {
+			log.Debug("nett: dial attempt failed", "network", network, "address", address, "err", err)
+		}
+		return c, err
+	}
+}
+
+// logFallback returns fn wrapped to log its attempts to dial the
+// fallback addresses of a Happy Eyeballs dial.
+func logFallback(log Logger, fn dialFunc, fallbacks addrList) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		for i := 0; i < fallbacks.Len(); i++ {
+			if fallbacks.Addr(i) == address {
+				log.Debug("nett: dialing fallback address", "network", network, "address", address)
+				break
+			}
+		}
+		return fn(ctx, network, address)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger records the messages logged to it.
+type testLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) log(level, msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(args)%2 != 0 {
+		panic(fmt.Sprintf("odd number of arguments: %v", args))
+	}
+	l.msgs = append(l.msgs, level+" "+msg)
+}
+
+func (l *testLogger) Debug(msg string, args ...any) { l.log("DEBUG", msg, args...) }
+func (l *testLogger) Warn(msg string, args ...any)  { l.log("WARN", msg, args...) }
+
+func TestCacheResolverLogger(t *testing.T) {
+	var log testLogger
+	fail := false
+	r := &CacheResolver{
+		Resolver: resolverFunc(func(host string) ([]net.IP, error) {
+			if fail {
+				return nil, errors.New("fail")
+			}
+			return parseIPs("192.0.2.1"), nil
+		}),
+		TTL:    time.Hour,
+		Logger: &log,
+	}
+	r.Resolve("foo.com")
+	r.Resolve("foo.com")
+	fail = true
+	r.Resolve("bar.com")
+	want := []string{
+		"DEBUG nett: cache miss",
+		"DEBUG nett: cache miss",
+		"WARN nett: lookup failed",
+	}
+	if !reflect.DeepEqual(log.msgs, want) {
+		t.Errorf("expected %q; got %q", want, log.msgs)
+	}
+}
+
+func TestDialerLogger(t *testing.T) {
+	var log testLogger
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		Logger:   &log,
+	}
+	if _, err := d.Dial("tcp", net.JoinHostPort("foo.com", refusedPort(t))); err == nil {
+		t.Fatal("expected the dial to fail")
+	}
+	want := []string{
+		"DEBUG nett: dial attempt failed",
+		"WARN nett: dial failed",
+	}
+	if !reflect.DeepEqual(log.msgs, want) {
+		t.Errorf("expected %q; got %q", want, log.msgs)
+	}
+}
+
+func TestDialerLoggerRaceLoser(t *testing.T) {
+	var log testLogger
+	if err := dialRace(t, &Dialer{Logger: &log}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the losing attempt to be canceled; got %v", err)
+	}
+	if len(log.msgs) != 0 {
+		t.Errorf("expected the canceled attempt not to be logged; got %q", log.msgs)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	mdnsAddress = "224.0.0.251:5353"
+	mdnsTimeout = time.Second
+)
+
+// MDNSResolver resolves hosts in the ".local" domain using multicast
+// DNS, as specified by RFC 6762, so that devices advertised on the
+// local link by responders such as Bonjour or Avahi can be dialed.
+// Other hosts are resolved with an underlying Resolver. It satisfies
+// TTLResolver and DetailedResolver.
+//
+// Queries are sent as one-shot queries from an ephemeral port, to
+// which responders reply directly.
+type MDNSResolver struct {
+	// Resolver resolves hosts outside of the ".local" domain.
+	// If Resolver is nil, DefaultResolver will be used.
+	Resolver Resolver
+
+	// Address is the multicast address to which queries are
+	// sent. If empty, "224.0.0.251:5353" is used.
+	Address string
+
+	// Timeout is the maximum amount of time to wait for a
+	// response. Since nonexistent hosts aren't reported by
+	// responders, it also bounds failed lookups. If zero,
+	// a default of 1 second is used.
+	Timeout time.Duration
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *MDNSResolver) Resolve(host string) ([]net.IP, error) {
+	ips, _, err := r.ResolveTTL(host)
+	return ips, err
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses and
+// their time to live.
+func (r *MDNSResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	if !isLocalName(host) {
+		return resolveTTL(r.resolver(), host)
+	}
+	res, err := r.resolve(context.Background(), host)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.IPs, res.TTL, nil
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *MDNSResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	if !isLocalName(host) {
+		return resolveContext(ctx, r.resolver(), host)
+	}
+	res, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return res.IPs, nil
+}
+
+// ResolveDetailed looks up the given host using the provided context
+// and returns its IP addresses with the errors of the lookup of each
+// family.
+func (r *MDNSResolver) ResolveDetailed(ctx context.Context, host string) (*DetailedResult, error) {
+	if !isLocalName(host) {
+		return ResolveDetailed(ctx, r.resolver(), host)
+	}
+	return r.resolve(ctx, host)
+}
+
+func (r *MDNSResolver) resolver() Resolver {
+	if r.Resolver == nil {
+		return DefaultResolver
+	}
+	return r.Resolver
+}
+
+func (r *MDNSResolver) resolve(ctx context.Context, host string) (*DetailedResult, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = mdnsTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return resolveDNSDetailed(ctx, r.exchange, host, nil)
+}
+
+func (r *MDNSResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	address := r.Address
+	if address == "" {
+		address = mdnsAddress
+	}
+	group, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+	var resp []byte
+	err = handshake(ctx, pc, func() error {
+		if _, err := pc.WriteTo(query, group); err != nil {
+			return err
+		}
+		b := make([]byte, dnsMaxUDPSize)
+		for {
+			n, _, err := pc.ReadFrom(b)
+			if err != nil {
+				return err
+			}
+			if n >= dnsHeaderLen && b[0] == query[0] && b[1] == query[1] &&
+				binary.BigEndian.Uint16(b[2:])&dnsFlagResponse != 0 {
+				resp = b[:n]
+				return nil
+			}
+		}
+	})
+	return resp, err
+}
+
+// isLocalName reports whether host is in the ".local" domain.
+func isLocalName(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	return len(host) > len(".local") && strings.EqualFold(host[len(host)-len(".local"):], ".local")
+}
@@ -0,0 +1,54 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMDNSResolver(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	zone := dnsZone{records: map[string][]net.IP{"printer.local.": {net.IPv4(192, 168, 1, 9).To4()}}, ttl: 120}
+	go servePacket(pc, zone.answer)
+
+	fallback := []net.IP{net.IPv6loopback}
+	r := &MDNSResolver{
+		Resolver: staticResolver(fallback),
+		Address:  pc.LocalAddr().String(),
+		Timeout:  100 * time.Millisecond,
+	}
+	ips, err := r.Resolve("printer.local")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := zone.records["printer.local."]; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+	if ips, err := r.Resolve("example.com"); err != nil || !reflect.DeepEqual(ips, fallback) {
+		t.Errorf("unexpected fallback result: %v, %v", ips, err)
+	}
+}
+
+func TestIsLocalName(t *testing.T) {
+	for host, want := range map[string]bool{
+		"printer.local":  true,
+		"Printer.LOCAL.": true,
+		"local":          false,
+		".local":         false,
+		"example.com":    false,
+		"notlocal":       false,
+	} {
+		if got := isLocalName(host); got != want {
+			t.Errorf("isLocalName(%q): expected %v; got %v", host, want, got)
+		}
+	}
+}
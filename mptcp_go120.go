@@ -0,0 +1,16 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.21
+// +build !go1.21
+
+package nett
+
+import "net"
+
+// MPTCP requires Go 1.21, so plain TCP is used.
+
+func setMultipathTCPDialer(nd *net.Dialer, use bool) {}
+
+func setMultipathTCPListenConfig(nlc *net.ListenConfig, use bool) {}
@@ -0,0 +1,18 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+// +build go1.21
+
+package nett
+
+import "net"
+
+func setMultipathTCPDialer(nd *net.Dialer, use bool) {
+	nd.SetMultipathTCP(use)
+}
+
+func setMultipathTCPListenConfig(nlc *net.ListenConfig, use bool) {
+	nlc.SetMultipathTCP(use)
+}
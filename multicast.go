@@ -0,0 +1,93 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+var errNoMulticastInterface = errors.New("no suitable multicast interface")
+
+// MulticastInterfaces selects the interfaces in ifis that are up and
+// support multicast, other than loopback interfaces. It is the
+// default interface filter of ListenMulticast.
+func MulticastInterfaces(ifis []net.Interface) []net.Interface {
+	var a []net.Interface
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 && ifi.Flags&net.FlagLoopback == 0 {
+			a = append(a, ifi)
+		}
+	}
+	return a
+}
+
+// ListenMulticast listens for packets sent to the multicast group
+// address on each of the system's network interfaces selected by
+// filter, such as for a discovery protocol. The network must be
+// "udp", "udp4" or "udp6".
+//
+// If filter is nil, MulticastInterfaces is used. The filter is
+// called with the interfaces of the system and must select at least
+// one of them. The group is joined on each selected interface and
+// the port of the group is bound with SO_REUSEADDR, as with
+// net.ListenMulticastUDP, so that other processes may also listen
+// for the group.
+func ListenMulticast(network string, group *net.UDPAddr, filter func(ifis []net.Interface) []net.Interface) (*net.UDPConn, error) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: group, Err: err}
+	}
+	if filter == nil {
+		filter = MulticastInterfaces
+	}
+	if ifis = filter(ifis); len(ifis) == 0 {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: group, Err: errNoMulticastInterface}
+	}
+	c, err := net.ListenMulticastUDP(network, &ifis[0], group)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifis[1:] {
+		if err := joinGroup(c, &ifis[i+1], group.IP); err != nil {
+			c.Close()
+			return nil, &net.OpError{Op: "listen", Net: network, Addr: group, Err: err}
+		}
+	}
+	return c, nil
+}
+
+// joinGroup joins the multicast group on ifi with the socket of c.
+func joinGroup(c *net.UDPConn, ifi *net.Interface, group net.IP) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ifaddr net.IP
+	if group.To4() != nil {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ifaddr = ipnet.IP.To4()
+				break
+			}
+		}
+		if ifaddr == nil {
+			return errNoMulticastInterface
+		}
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) { serr = setJoinGroup(fd, ifi, ifaddr, group) }); err != nil {
+		return err
+	}
+	if serr != nil {
+		return os.NewSyscallError("setsockopt", serr)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package nett
+
+import (
+	"errors"
+	"net"
+)
+
+func setJoinGroup(fd uintptr, ifi *net.Interface, ifaddr, group net.IP) error {
+	return errors.New("joining multicast groups on multiple interfaces is not supported on this system")
+}
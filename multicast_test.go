@@ -0,0 +1,51 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMulticastInterfaces(t *testing.T) {
+	ifis := []net.Interface{
+		{Index: 1, Name: "lo", Flags: net.FlagUp | net.FlagLoopback | net.FlagMulticast},
+		{Index: 2, Name: "eth0", Flags: net.FlagUp | net.FlagBroadcast | net.FlagMulticast},
+		{Index: 3, Name: "eth1", Flags: net.FlagBroadcast | net.FlagMulticast},
+		{Index: 4, Name: "tun0", Flags: net.FlagUp | net.FlagPointToPoint},
+	}
+	if got, want := MulticastInterfaces(ifis), ifis[1:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+}
+
+func TestListenMulticast(t *testing.T) {
+	group := &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 0}
+	none := func([]net.Interface) []net.Interface { return nil }
+	if _, err := ListenMulticast("udp4", group, none); !errors.Is(err, errNoMulticastInterface) {
+		t.Errorf("expected errNoMulticastInterface; got %v", err)
+	}
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("Interfaces failed: %v", err)
+	}
+	var multicast []net.Interface
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 {
+			multicast = append(multicast, ifi)
+		}
+	}
+	if len(multicast) == 0 {
+		t.Skip("no multicast interfaces")
+	}
+	c, err := ListenMulticast("udp4", group, func([]net.Interface) []net.Interface { return multicast })
+	if err != nil {
+		t.Skipf("ListenMulticast failed: %v", err)
+	}
+	c.Close()
+}
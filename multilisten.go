@@ -0,0 +1,134 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// MultiListen announces on every local address selected for the
+// network address, such as each IPv4 and IPv6 address of a host name,
+// by a zero ListenConfig.
+//
+// See ListenConfig.MultiListen for details.
+func MultiListen(network, address string) (*MultiListener, error) {
+	var lc ListenConfig
+	return lc.MultiListen(context.Background(), network, address)
+}
+
+// MultiListen announces on every local address selected by the
+// IPFilter for the network address, returning a listener that
+// accepts connections from all of them. If any of the addresses
+// fails to be bound, the others are closed and the error is returned.
+func (lc *ListenConfig) MultiListen(ctx context.Context, network, address string) (*MultiListener, error) {
+	addrs, err := lc.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	nlc := lc.netListenConfig()
+	lns := make([]net.Listener, 0, addrs.Len())
+	for i := 0; i < addrs.Len(); i++ {
+		ln, err := nlc.Listen(ctx, network, addrs.Addr(i))
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+	return newMultiListener(lns), nil
+}
+
+// A MultiListener is a net.Listener that accepts connections from
+// several listeners.
+type MultiListener struct {
+	lns   []net.Listener
+	conns chan accepted
+	done  chan struct{}
+	once  sync.Once
+	err   error // of closing the listeners
+}
+
+type accepted struct {
+	net.Conn
+	err error
+}
+
+func newMultiListener(lns []net.Listener) *MultiListener {
+	l := &MultiListener{
+		lns:   lns,
+		conns: make(chan accepted),
+		done:  make(chan struct{}),
+	}
+	for _, ln := range lns {
+		go l.accept(ln)
+	}
+	return l
+}
+
+// accept passes the connections accepted by ln and its errors to
+// l.Accept until ln or l is closed. Other errors, such as temporary
+// ones caused by running out of file descriptors, don't stop it, so
+// that the caller may retry as it would with ln.
+func (l *MultiListener) accept(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		select {
+		case l.conns <- accepted{c, err}:
+		case <-l.done:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// Accept waits for and returns the next connection accepted by any
+// of the listeners, or the next error of one of them. A listener that
+// fails with a temporary error continues to accept connections, as do
+// the others if one is closed.
+func (l *MultiListener) Accept() (net.Conn, error) {
+	select {
+	case a := <-l.conns:
+		return a.Conn, a.err
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: l.lns[0].Addr().Network(), Addr: l.Addr(), Err: net.ErrClosed}
+	}
+}
+
+// Close closes all of the listeners.
+func (l *MultiListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+		for _, ln := range l.lns {
+			if err := ln.Close(); err != nil && l.err == nil {
+				l.err = err
+			}
+		}
+	})
+	return l.err
+}
+
+// Addr returns the address of the first listener.
+func (l *MultiListener) Addr() net.Addr {
+	return l.lns[0].Addr()
+}
+
+// Addrs returns the addresses of all of the listeners.
+func (l *MultiListener) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(l.lns))
+	for i, ln := range l.lns {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
@@ -0,0 +1,100 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestMultiListen(t *testing.T) {
+	lc := &ListenConfig{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1).To4(), net.IPv4(127, 0, 0, 2).To4()},
+		IPFilter: func(ips []net.IP) []net.IP { return ips },
+	}
+	ln, err := lc.MultiListen(context.Background(), "tcp", "foo.com:0")
+	if err != nil {
+		t.Fatalf("MultiListen failed: %v", err)
+	}
+	defer ln.Close()
+	addrs := ln.Addrs()
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 addresses; got %v", addrs)
+	}
+	for _, addr := range addrs {
+		c, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer c.Close()
+		s, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		if s.LocalAddr().String() != addr.String() {
+			t.Errorf("expected a connection to %v; got %v", addr, s.LocalAddr())
+		}
+		s.Close()
+	}
+
+	if err := ln.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed; got %v", err)
+	}
+}
+
+func TestMultiListenBindError(t *testing.T) {
+	lc := &ListenConfig{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1).To4(), net.IPv4(192, 0, 2, 1).To4()},
+		IPFilter: func(ips []net.IP) []net.IP { return ips },
+	}
+	if ln, err := lc.MultiListen(context.Background(), "tcp", "foo.com:0"); err == nil {
+		ln.Close()
+		t.Fatal("expected binding a non-local address to fail")
+	}
+}
+
+// flakyListener fails to accept with a temporary error every other
+// time.
+type flakyListener struct {
+	net.Listener
+	n int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.n++; l.n%2 == 1 {
+		return nil, &net.OpError{Op: "accept", Err: errTimeout}
+	}
+	return l.Listener.Accept()
+}
+
+func TestMultiListenTemporaryError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newMultiListener([]net.Listener{&flakyListener{Listener: ln}})
+	defer l.Close()
+	for i := 0; i < 2; i++ {
+		var nerr net.Error
+		if _, err := l.Accept(); !errors.As(err, &nerr) || !nerr.Timeout() {
+			t.Fatalf("expected a temporary error; got %v", err)
+		}
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer c.Close()
+		s, err := l.Accept()
+		if err != nil {
+			t.Fatalf("Accept failed after a temporary error: %v", err)
+		}
+		s.Close()
+	}
+}
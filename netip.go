@@ -0,0 +1,186 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+var lookupNetIP = net.DefaultResolver.LookupNetIP // used by tests
+
+// A NetIPResolver is a Resolver that can also return the addresses of
+// a host as netip.Addr values, which are comparable and don't need
+// to be allocated, unlike net.IP.
+type NetIPResolver interface {
+	Resolver
+
+	// ResolveNetIP looks up the given host using the provided
+	// context and returns its IP addresses.
+	ResolveNetIP(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// ResolveNetIP looks up the given host using the local resolver.
+// It returns an array of that host's IPv4 and IPv6 addresses.
+func (defaultResolver) ResolveNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	addrs, err := lookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+	}
+	return addrs, nil
+}
+
+// ResolveNetIP looks up the given host with r, or DefaultResolver if r
+// is nil, and returns its IP addresses as netip.Addr values. IPv4
+// addresses are never returned in their IPv4-mapped IPv6 form.
+//
+// If r is a NetIPResolver, its ResolveNetIP method is used. Otherwise,
+// the addresses it returns are converted, and the context is passed
+// to it if it is a ContextResolver.
+func ResolveNetIP(ctx context.Context, r Resolver, host string) ([]netip.Addr, error) {
+	if r == nil {
+		r = DefaultResolver
+	}
+	if nr, ok := r.(NetIPResolver); ok {
+		return nr.ResolveNetIP(ctx, host)
+	}
+	ips, err := resolveContext(ctx, r, host)
+	if err != nil {
+		return nil, err
+	}
+	return netIPAddrs(ips), nil
+}
+
+// netIPAddrs converts ips to netip.Addr values, skipping any that
+// are invalid.
+func netIPAddrs(ips []net.IP) []netip.Addr {
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs
+}
+
+// netIPs converts addrs to net.IP values, with IPv4 addresses in
+// their 4-byte form.
+func netIPs(addrs []netip.Addr) []net.IP {
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.IsValid() {
+			ips = append(ips, net.IP(addr.Unmap().AsSlice()))
+		}
+	}
+	return ips
+}
+
+// AddrFilter returns an IPFilter that applies filter to the addresses
+// as netip.Addr values. IPv4-mapped IPv6 addresses are converted to
+// IPv4 addresses, so that filter may classify addresses by Is4 and
+// Is6 without ambiguity.
+func AddrFilter(filter func(addrs []netip.Addr) []netip.Addr) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		return netIPs(filter(netIPAddrs(ips)))
+	}
+}
+
+// NetIPFilter returns a filter of netip.Addr values that applies
+// the IPFilter filter, such as to compose the filters of this
+// package with filters written for AddrFilter.
+func NetIPFilter(filter func(ips []net.IP) []net.IP) func(addrs []netip.Addr) []netip.Addr {
+	return func(addrs []netip.Addr) []netip.Addr {
+		return netIPAddrs(filter(netIPs(addrs)))
+	}
+}
+
+// DualStackAddrs selects the first IPv4 address and IPv6 address
+// in addrs, like DualStack.
+func DualStackAddrs(addrs []netip.Addr) []netip.Addr {
+	var (
+		ipv4, ipv6 bool
+		a          []netip.Addr
+	)
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if !ipv4 && addr.Is4() {
+			a = append(a, addr)
+			ipv4 = true
+		} else if !ipv6 && addr.Is6() {
+			a = append(a, addr)
+			ipv6 = true
+		}
+		if ipv4 && ipv6 {
+			break
+		}
+	}
+	return a
+}
+
+// PreferIPv4Addrs orders addrs so that IPv4 addresses come before
+// IPv6 addresses, like PreferIPv4.
+func PreferIPv4Addrs(addrs []netip.Addr) []netip.Addr {
+	return preferAddrFamily(addrs, true)
+}
+
+// PreferIPv6Addrs orders addrs so that IPv6 addresses come before
+// IPv4 addresses, like PreferIPv6.
+func PreferIPv6Addrs(addrs []netip.Addr) []netip.Addr {
+	return preferAddrFamily(addrs, false)
+}
+
+// preferAddrFamily orders addrs so that IPv4 addresses come first
+// if ipv4, or IPv6 addresses otherwise.
+func preferAddrFamily(addrs []netip.Addr, ipv4 bool) []netip.Addr {
+	a := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() == ipv4 {
+			a = append(a, addr)
+		}
+	}
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() != ipv4 {
+			a = append(a, addr)
+		}
+	}
+	return a
+}
+
+// ResolveAddrPorts resolves the host and port of the address on the
+// named network, which must be a TCP or UDP network, with r, or
+// DefaultResolver if r is nil. The addresses are restricted to the
+// family of the network and selected by filter, or the first one is
+// selected if filter is nil.
+func ResolveAddrPorts(ctx context.Context, r Resolver, filter func(addrs []netip.Addr) []netip.Addr, network, address string) ([]netip.AddrPort, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		return nil, &net.OpError{Op: "resolve", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+	var ipf ipFilter = defaultIP
+	if filter != nil {
+		ipf = AddrFilter(filter)
+	}
+	addrs, err := resolveAddrsContext(ctx, withContext(ctx, r), ipf, network, address)
+	if err != nil {
+		return nil, &net.OpError{Op: "resolve", Net: network, Err: err}
+	}
+	a := make([]netip.AddrPort, 0, addrs.Len())
+	for i := 0; i < addrs.Len(); i++ {
+		var ap netip.AddrPort
+		switch addr := addrs.NetAddr(i).(type) {
+		case *net.TCPAddr:
+			ap = addr.AddrPort()
+		case *net.UDPAddr:
+			ap = addr.AddrPort()
+		}
+		a = append(a, netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()))
+	}
+	return a, nil
+}
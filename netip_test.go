@@ -0,0 +1,110 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestResolveNetIP(t *testing.T) {
+	defer func(fn func(context.Context, string, string) ([]netip.Addr, error)) { lookupNetIP = fn }(lookupNetIP)
+	lookupNetIP = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1"), netip.MustParseAddr("2001:db8::1")}, nil
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")}
+
+	tests := []struct {
+		desc     string
+		resolver Resolver
+	}{
+		{"default", nil},
+		{"converted", staticResolver{net.IPv4(192, 0, 2, 1), net.ParseIP("2001:db8::1"), net.IP{1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		addrs, err := ResolveNetIP(context.Background(), tt.resolver, "foo.com")
+		if err != nil {
+			t.Errorf("%s: ResolveNetIP failed: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(addrs, want) {
+			t.Errorf("%s: expected %v; got %v", tt.desc, want, addrs)
+		}
+	}
+}
+
+func TestAddrFilter(t *testing.T) {
+	var got []netip.Addr
+	filter := AddrFilter(func(addrs []netip.Addr) []netip.Addr {
+		got = addrs
+		return addrs[1:]
+	})
+	ips := filter([]net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1"), net.ParseIP("::ffff:192.0.2.2")})
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("192.0.2.2")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected filter of %v; got %v", want, got)
+	}
+	wantIPs := []net.IP{net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 2).To4()}
+	if !reflect.DeepEqual(ips, wantIPs) {
+		t.Errorf("expected %v; got %v", wantIPs, ips)
+	}
+
+	addrs := NetIPFilter(DualStack)(want)
+	if !reflect.DeepEqual(addrs, want[:2]) {
+		t.Errorf("expected %v; got %v", want[:2], addrs)
+	}
+}
+
+func TestNetIPFamilyFilters(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::ffff:192.0.2.1"),
+		netip.MustParseAddr("2001:db8::2"),
+		netip.MustParseAddr("192.0.2.2"),
+	}
+	tests := []struct {
+		desc   string
+		filter func([]netip.Addr) []netip.Addr
+		want   []netip.Addr
+	}{
+		{"DualStackAddrs", DualStackAddrs, []netip.Addr{addrs[0], netip.MustParseAddr("192.0.2.1")}},
+		{"PreferIPv4Addrs", PreferIPv4Addrs, []netip.Addr{addrs[1], addrs[3], addrs[0], addrs[2]}},
+		{"PreferIPv6Addrs", PreferIPv6Addrs, []netip.Addr{addrs[0], addrs[2], addrs[1], addrs[3]}},
+	}
+	for _, tt := range tests {
+		if got := tt.filter(addrs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v; got %v", tt.desc, tt.want, got)
+		}
+	}
+}
+
+func TestResolveAddrPorts(t *testing.T) {
+	r := staticResolver{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}
+	tests := []struct {
+		network string
+		filter  func([]netip.Addr) []netip.Addr
+		want    []netip.AddrPort
+	}{
+		{"tcp", nil, []netip.AddrPort{netip.MustParseAddrPort("192.0.2.1:80")}},
+		{"tcp", DualStackAddrs, []netip.AddrPort{netip.MustParseAddrPort("192.0.2.1:80"), netip.MustParseAddrPort("[2001:db8::1]:80")}},
+		{"udp6", DualStackAddrs, []netip.AddrPort{netip.MustParseAddrPort("[2001:db8::1]:80")}},
+	}
+	for _, tt := range tests {
+		got, err := ResolveAddrPorts(context.Background(), r, tt.filter, tt.network, "foo.com:80")
+		if err != nil {
+			t.Errorf("%s: ResolveAddrPorts failed: %v", tt.network, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v; got %v", tt.network, tt.want, got)
+		}
+	}
+	if _, err := ResolveAddrPorts(context.Background(), r, nil, "unix", "/tmp/sock"); err == nil {
+		t.Error("expected unix network to fail")
+	}
+}
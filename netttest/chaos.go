@@ -0,0 +1,191 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/abursavich/nett"
+)
+
+// A ContextDialer dials addresses using a context, such as a
+// *nett.Dialer or a *MemoryNetwork.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// A ChaosDialer wraps a dialer to inject faults into its dials and
+// connections, so that the resilience of applications to slow or
+// unreliable networks may be tested.
+//
+// A ChaosDialer is safe for concurrent use by multiple goroutines
+// once its fields are set.
+type ChaosDialer struct {
+	// Dialer is the dialer wrapped. If nil, a zero *nett.Dialer
+	// is used.
+	Dialer ContextDialer
+
+	// Latency and LatencyJitter delay each dial by Latency plus
+	// a random duration of up to LatencyJitter. The delay ends
+	// early if the context of the dial is done.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	// FailureRate is the probability, between zero and one, that
+	// a dial fails with an ECONNREFUSED error without dialing.
+	FailureRate float64
+
+	// ResetAfter, if positive, is the number of bytes a connection
+	// reads and writes in total before it is reset. Further reads
+	// and writes fail with an ECONNRESET error.
+	ResetAfter int64
+
+	// Bandwidth, if positive, limits each direction of a connection
+	// to about that many bytes per second. Each read and write is
+	// delayed for its size, which is varied by a random fraction of
+	// up to BandwidthJitter, between zero and one.
+	Bandwidth       int
+	BandwidthJitter float64
+
+	// Source is the source of randomness. If nil, a source seeded
+	// with the current time is used.
+	Source rand.Source
+
+	once sync.Once
+	mu   sync.Mutex // guards rnd
+	rnd  *rand.Rand
+}
+
+func (d *ChaosDialer) float64() float64 {
+	d.once.Do(func() {
+		src := d.Source
+		if src == nil {
+			src = rand.NewSource(time.Now().UnixNano())
+		}
+		d.rnd = rand.New(src)
+	})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rnd.Float64()
+}
+
+// Dial connects to the address on the named network.
+func (d *ChaosDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using the
+// provided context, after injecting its latency and failures.
+func (d *ChaosDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if delay := d.Latency + time.Duration(d.float64()*float64(d.LatencyJitter)); delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+		}
+	}
+	if d.FailureRate > 0 && d.float64() < d.FailureRate {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errConnRefused}
+	}
+	var dialer ContextDialer = d.Dialer
+	if dialer == nil {
+		dialer = &nett.Dialer{}
+	}
+	c, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if d.ResetAfter <= 0 && d.Bandwidth <= 0 {
+		return c, nil
+	}
+	return &chaosConn{Conn: c, d: d, remaining: d.ResetAfter}, nil
+}
+
+// chaosConn is a connection of a ChaosDialer.
+type chaosConn struct {
+	net.Conn
+	d *ChaosDialer
+
+	mu        sync.Mutex // guards the following
+	remaining int64      // bytes until reset, if ResetAfter is positive
+	reset     bool
+}
+
+// limit returns b truncated to the bytes that may be transferred
+// before the connection is reset.
+func (c *chaosConn) limit(b []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reset {
+		return nil, errConnReset
+	}
+	if c.d.ResetAfter > 0 && int64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	return b, nil
+}
+
+// consume records a transfer of n bytes and resets the connection
+// once it reaches ResetAfter.
+func (c *chaosConn) consume(n int) {
+	if c.d.ResetAfter <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remaining -= int64(n)
+	if c.remaining > 0 || c.reset {
+		return
+	}
+	c.reset = true
+	if tc, ok := c.Conn.(*net.TCPConn); ok {
+		tc.SetLinger(0) // send a RST
+	}
+	c.Conn.Close()
+}
+
+// throttle delays a transfer of n bytes for the Bandwidth.
+func (c *chaosConn) throttle(n int) {
+	if c.d.Bandwidth <= 0 || n <= 0 {
+		return
+	}
+	f := 1 + c.d.BandwidthJitter*(2*c.d.float64()-1)
+	time.Sleep(time.Duration(f * float64(n) / float64(c.d.Bandwidth) * float64(time.Second)))
+}
+
+func (c *chaosConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: c.LocalAddr().Network(), Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+}
+
+func (c *chaosConn) Read(b []byte) (int, error) {
+	p, err := c.limit(b)
+	if err != nil {
+		return 0, c.opError("read", err)
+	}
+	n, err := c.Conn.Read(p)
+	c.throttle(n)
+	c.consume(n)
+	return n, err
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+	p, err := c.limit(b)
+	if err != nil {
+		return 0, c.opError("write", err)
+	}
+	n, err := c.Conn.Write(p)
+	c.throttle(n)
+	c.consume(n)
+	if err == nil && n < len(b) {
+		err = c.opError("write", errConnReset)
+	}
+	return n, err
+}
@@ -0,0 +1,131 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// echoNetwork returns a MemoryNetwork with an echo server at address.
+func echoNetwork(t *testing.T, address string) *MemoryNetwork {
+	n := &MemoryNetwork{}
+	ln, err := n.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+	return n
+}
+
+func TestChaosDialerLatency(t *testing.T) {
+	d := &ChaosDialer{
+		Dialer:        echoNetwork(t, "echo:7"),
+		Latency:       20 * time.Millisecond,
+		LatencyJitter: 10 * time.Millisecond,
+	}
+	start := time.Now()
+	c, err := d.Dial("tcp", "echo:7")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected a delay of at least 20ms; got %v", elapsed)
+	}
+
+	d.Latency = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := d.DialContext(ctx, "tcp", "echo:7"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestChaosDialerFailureRate(t *testing.T) {
+	d := &ChaosDialer{
+		Dialer:      echoNetwork(t, "echo:7"),
+		FailureRate: 0.5,
+		Source:      rand.NewSource(1),
+	}
+	failures := 0
+	for i := 0; i < 100; i++ {
+		c, err := d.Dial("tcp", "echo:7")
+		if err != nil {
+			if !errors.Is(err, errConnRefused) {
+				t.Fatalf("expected ECONNREFUSED; got %v", err)
+			}
+			failures++
+			continue
+		}
+		c.Close()
+	}
+	if failures < 25 || failures > 75 {
+		t.Errorf("expected about 50 failures; got %d", failures)
+	}
+}
+
+func TestChaosDialerResetAfter(t *testing.T) {
+	d := &ChaosDialer{
+		Dialer:     echoNetwork(t, "echo:7"),
+		ResetAfter: 10,
+	}
+	c, err := d.Dial("tcp", "echo:7")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("expected to read %q; got %q, %v", "hello", buf, err)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, errConnReset) {
+		t.Errorf("expected ECONNRESET; got %v", err)
+	}
+	if _, err := c.Read(buf); !errors.Is(err, errConnReset) {
+		t.Errorf("expected ECONNRESET; got %v", err)
+	}
+}
+
+func TestChaosDialerBandwidth(t *testing.T) {
+	d := &ChaosDialer{
+		Dialer:          echoNetwork(t, "echo:7"),
+		Bandwidth:       1000,
+		BandwidthJitter: 0.1,
+	}
+	c, err := d.Dial("tcp", "echo:7")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	start := time.Now()
+	go c.Write(make([]byte, 50))
+	if _, err := io.ReadFull(c, make([]byte, 50)); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	// 50 bytes at 1000 bytes per second take 50ms, less the jitter.
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected a transfer of at least 40ms; got %v", elapsed)
+	}
+}
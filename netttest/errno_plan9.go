@@ -0,0 +1,15 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import "syscall"
+
+// The errors of simulated failures. Plan 9 has no errno values for
+// them, so they are errors with the messages of its network stack.
+var (
+	errAddrInUse   error = syscall.ErrorString("address in use")
+	errConnRefused error = syscall.ErrorString("connection refused")
+	errConnReset   error = syscall.ErrorString("connection reset")
+)
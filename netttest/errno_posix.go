@@ -0,0 +1,17 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package netttest
+
+import "syscall"
+
+// The errors of simulated failures, which match those of real sockets.
+var (
+	errAddrInUse   error = syscall.EADDRINUSE
+	errConnRefused error = syscall.ECONNREFUSED
+	errConnReset   error = syscall.ECONNRESET
+)
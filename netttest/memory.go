@@ -0,0 +1,152 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// A MemoryNetwork is an in-memory network of listeners keyed by
+// address, whose connections are synchronous, in-memory pipes like
+// those of net.Pipe. It lets integration tests of clients and servers
+// run without real sockets.
+//
+// Its Dial and DialContext methods have the signatures of those of
+// nett.Dialer, so it may replace a Dialer in code that accepts an
+// interface, and its listeners may be served like any net.Listener.
+//
+// A MemoryNetwork is safe for concurrent use by multiple goroutines.
+// Its zero value is an empty network.
+type MemoryNetwork struct {
+	mu        sync.Mutex
+	listeners map[MemoryAddr]*memoryListener
+	port      int // last port allocated
+}
+
+// A MemoryAddr is the address of an endpoint of a MemoryNetwork.
+type MemoryAddr struct {
+	Net     string
+	Address string
+}
+
+// Network returns the network name of the address.
+func (a MemoryAddr) Network() string { return a.Net }
+
+func (a MemoryAddr) String() string { return a.Address }
+
+// nextPort returns an unallocated port. n.mu must be held.
+func (n *MemoryNetwork) nextPort() string {
+	n.port++
+	return strconv.Itoa(n.port)
+}
+
+// Listen announces on the address of the named network, which may be
+// any name. The address is matched literally by dials, except that
+// a port of zero is replaced by an unused port, as reported by the
+// Addr of the listener. Closing the listener frees its address and
+// refuses the dials waiting for it to accept their connections.
+func (n *MemoryNetwork) Listen(network, address string) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addr := MemoryAddr{network, address}
+	if host, port, err := net.SplitHostPort(address); err == nil && port == "0" {
+		// Skip the ports that were listened on explicitly.
+		for {
+			addr.Address = net.JoinHostPort(host, n.nextPort())
+			if _, ok := n.listeners[addr]; !ok {
+				break
+			}
+		}
+	}
+	if _, ok := n.listeners[addr]; ok {
+		return nil, &net.OpError{Op: "listen", Net: network, Addr: addr, Err: errAddrInUse}
+	}
+	if n.listeners == nil {
+		n.listeners = make(map[MemoryAddr]*memoryListener)
+	}
+	l := &memoryListener{
+		n:     n,
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	n.listeners[addr] = l
+	return l, nil
+}
+
+// Dial connects to the address on the named network.
+func (n *MemoryNetwork) Dial(network, address string) (net.Conn, error) {
+	return n.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using the
+// provided context. It blocks until the connection is accepted by the
+// listener on the address, and is refused if there is none.
+func (n *MemoryNetwork) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	raddr := MemoryAddr{network, address}
+	n.mu.Lock()
+	l := n.listeners[raddr]
+	laddr := MemoryAddr{network, net.JoinHostPort("memory", n.nextPort())}
+	n.mu.Unlock()
+	if l == nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: raddr, Err: errConnRefused}
+	}
+	c, s := net.Pipe()
+	select {
+	case l.conns <- &memoryConn{Conn: s, local: raddr, remote: laddr}:
+		return &memoryConn{Conn: c, local: laddr, remote: raddr}, nil
+	case <-l.done:
+		c.Close()
+		s.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: raddr, Err: errConnRefused}
+	case <-ctx.Done():
+		c.Close()
+		s.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: raddr, Err: ctx.Err()}
+	}
+}
+
+type memoryListener struct {
+	n     *MemoryNetwork
+	addr  MemoryAddr
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: l.addr.Net, Addr: l.addr, Err: net.ErrClosed}
+	}
+}
+
+func (l *memoryListener) Close() error {
+	err := error(&net.OpError{Op: "close", Net: l.addr.Net, Addr: l.addr, Err: net.ErrClosed})
+	l.once.Do(func() {
+		close(l.done)
+		l.n.mu.Lock()
+		delete(l.n.listeners, l.addr)
+		l.n.mu.Unlock()
+		err = nil
+	})
+	return err
+}
+
+func (l *memoryListener) Addr() net.Addr { return l.addr }
+
+// memoryConn is a pipe with the addresses of its endpoints.
+type memoryConn struct {
+	net.Conn
+	local, remote MemoryAddr
+}
+
+func (c *memoryConn) LocalAddr() net.Addr  { return c.local }
+func (c *memoryConn) RemoteAddr() net.Addr { return c.remote }
@@ -0,0 +1,119 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMemoryNetwork(t *testing.T) {
+	var n MemoryNetwork
+	ln, err := n.Listen("tcp", "server:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+	if addr == "server:0" {
+		t.Fatalf("expected a port to be allocated; got %v", addr)
+	}
+	if _, err := n.Listen("tcp", addr); !errors.Is(err, errAddrInUse) {
+		t.Errorf("expected EADDRINUSE; got %v", err)
+	}
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}()
+	c, err := n.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	if c.RemoteAddr().String() != addr || c.RemoteAddr().Network() != "tcp" {
+		t.Errorf("unexpected remote address: %v", c.RemoteAddr())
+	}
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("expected to read %q; got %q, %v", "ping", buf, err)
+	}
+
+	if _, err := n.Dial("tcp", "other:1"); !errors.Is(err, errConnRefused) {
+		t.Errorf("expected ECONNREFUSED; got %v", err)
+	}
+	if _, err := n.Dial("udp", addr); !errors.Is(err, errConnRefused) {
+		t.Errorf("expected ECONNREFUSED for another network; got %v", err)
+	}
+}
+
+func TestMemoryNetworkUnusedPort(t *testing.T) {
+	var n MemoryNetwork
+	ln1, err := n.Listen("tcp", "server:1")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln1.Close()
+	ln2, err := n.Listen("tcp", "server:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln2.Close()
+	if addr := ln2.Addr().String(); addr == "server:0" || addr == "server:1" {
+		t.Errorf("expected an unused port to be allocated; got %v", addr)
+	}
+}
+
+func TestMemoryNetworkClose(t *testing.T) {
+	var n MemoryNetwork
+	ln, err := n.Listen("tcp", "server:80")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	// Dials wait for the listener to accept them.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := n.DialContext(ctx, "tcp", "server:80"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := n.Dial("tcp", "server:80")
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := ln.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, errConnRefused) {
+		t.Errorf("expected ECONNREFUSED; got %v", err)
+	}
+	if _, err := ln.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed; got %v", err)
+	}
+	if err := ln.Close(); err == nil {
+		t.Error("expected closing twice to fail")
+	}
+
+	// The address is freed.
+	ln, err = n.Listen("tcp", "server:80")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	ln.Close()
+}
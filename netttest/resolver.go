@@ -0,0 +1,171 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package netttest provides utilities for testing applications that
+// use nett without touching real networks or DNS.
+package netttest
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abursavich/nett"
+)
+
+// A FakeResolver is a nett.Resolver that resolves hosts from an
+// in-memory table, so that the dial logic of an application may be
+// tested hermetically. It counts the lookups of each host, so that
+// tests may check whether the application caches its results.
+//
+// Host names are matched case-insensitively, ignoring a trailing dot.
+// Hosts not in the table fail with a "no such host" *net.DNSError.
+//
+// A FakeResolver is safe for concurrent use by multiple goroutines.
+// Its zero value resolves no hosts.
+type FakeResolver struct {
+	// Latency, if positive, delays each lookup, such as to test
+	// timeouts. Lookups with a context stop waiting when it is done.
+	Latency time.Duration
+
+	mu    sync.Mutex
+	ips   map[string][]net.IP
+	errs  map[string]error
+	calls map[string]int
+}
+
+var _ nett.ContextResolver = (*FakeResolver)(nil)
+
+// NewFakeResolver returns a FakeResolver that resolves each host in
+// the map to its addresses, which are parsed like those of Set.
+func NewFakeResolver(hosts map[string][]string) *FakeResolver {
+	r := &FakeResolver{}
+	for host, addrs := range hosts {
+		r.Set(host, addrs...)
+	}
+	return r
+}
+
+func canonicalHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Set sets the addresses of host, which must be literal IP addresses,
+// and clears any error set for it. It panics if an address is invalid.
+func (r *FakeResolver) Set(host string, addrs ...string) {
+	ips := make([]net.IP, len(addrs))
+	for i, s := range addrs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			panic("netttest: invalid IP address " + s)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		ips[i] = ip
+	}
+	r.SetIPs(host, ips...)
+}
+
+// SetIPs sets the addresses of host and clears any error set for it.
+func (r *FakeResolver) SetIPs(host string, ips ...net.IP) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = canonicalHost(host)
+	if r.ips == nil {
+		r.ips = make(map[string][]net.IP)
+	}
+	r.ips[host] = append([]net.IP(nil), ips...)
+	delete(r.errs, host)
+}
+
+// SetError makes lookups of host fail with err, or stop failing
+// if err is nil.
+func (r *FakeResolver) SetError(host string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = canonicalHost(host)
+	if err == nil {
+		delete(r.errs, host)
+		return
+	}
+	if r.errs == nil {
+		r.errs = make(map[string]error)
+	}
+	r.errs[host] = err
+}
+
+// Remove removes host and its error, if any, from the table.
+func (r *FakeResolver) Remove(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = canonicalHost(host)
+	delete(r.ips, host)
+	delete(r.errs, host)
+}
+
+// Calls returns the number of lookups of host.
+func (r *FakeResolver) Calls(host string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[canonicalHost(host)]
+}
+
+// TotalCalls returns the number of lookups of all hosts.
+func (r *FakeResolver) TotalCalls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, c := range r.calls {
+		n += c
+	}
+	return n
+}
+
+// ResetCalls resets the lookup counters.
+func (r *FakeResolver) ResetCalls() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// Resolve looks up the given host in the table.
+func (r *FakeResolver) Resolve(host string) ([]net.IP, error) {
+	return r.ResolveContext(context.Background(), host)
+}
+
+// ResolveContext looks up the given host in the table, waiting for
+// the Latency unless the context is done first.
+func (r *FakeResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	key := canonicalHost(host)
+	r.mu.Lock()
+	if r.calls == nil {
+		r.calls = make(map[string]int)
+	}
+	r.calls[key]++
+	r.mu.Unlock()
+
+	if r.Latency > 0 {
+		t := time.NewTimer(r.Latency)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err, ok := r.errs[key]; ok {
+		return nil, err
+	}
+	ips, ok := r.ips[key]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return append([]net.IP(nil), ips...), nil
+}
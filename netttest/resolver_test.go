@@ -0,0 +1,98 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package netttest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/abursavich/nett"
+)
+
+func TestFakeResolver(t *testing.T) {
+	r := NewFakeResolver(map[string][]string{
+		"example.com": {"192.0.2.1", "2001:db8::1"},
+	})
+	ips, err := r.Resolve("Example.COM.")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(ips) != 2 || !ips[0].Equal(net.ParseIP("192.0.2.1")) || len(ips[0]) != net.IPv4len || !ips[1].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("unexpected addresses: %v", ips)
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := r.Resolve("missing.example"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected a not found error; got %v", err)
+	}
+
+	errFail := errors.New("fail")
+	r.SetError("example.com", errFail)
+	if _, err := r.Resolve("example.com"); err != errFail {
+		t.Errorf("expected %v; got %v", errFail, err)
+	}
+	r.SetError("example.com", nil)
+	if _, err := r.Resolve("example.com"); err != nil {
+		t.Errorf("Resolve failed: %v", err)
+	}
+
+	if n := r.Calls("example.com"); n != 3 {
+		t.Errorf("expected 3 calls; got %d", n)
+	}
+	if n := r.TotalCalls(); n != 4 {
+		t.Errorf("expected 4 total calls; got %d", n)
+	}
+	r.ResetCalls()
+	if n := r.TotalCalls(); n != 0 {
+		t.Errorf("expected 0 total calls; got %d", n)
+	}
+
+	r.Remove("example.com")
+	if _, err := r.Resolve("example.com"); err == nil {
+		t.Error("expected removed host to fail")
+	}
+}
+
+func TestFakeResolverLatency(t *testing.T) {
+	r := &FakeResolver{Latency: time.Hour}
+	r.Set("example.com", "192.0.2.1")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.ResolveContext(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("expected %v; got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestFakeResolverDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	r := NewFakeResolver(map[string][]string{"service.test": {"127.0.0.1"}})
+	d := &nett.Dialer{Resolver: r}
+	c, err := d.Dial("tcp", net.JoinHostPort("service.test", port))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if n := r.Calls("service.test"); n != 1 {
+		t.Errorf("expected 1 call; got %d", n)
+	}
+}
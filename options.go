@@ -0,0 +1,44 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"time"
+)
+
+// An Option configures a Dialer created by NewDialer.
+type Option func(*Dialer)
+
+// NewDialer returns a Dialer configured by the given options.
+// Options are applied in order. A Dialer created without options
+// is equivalent to the zero Dialer.
+func NewDialer(opts ...Option) *Dialer {
+	d := &Dialer{}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// WithTimeout sets the Dialer's Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dialer) { d.Timeout = timeout }
+}
+
+// WithFilter sets the Dialer's IPFilter.
+func WithFilter(filter func(ips []net.IP) []net.IP) Option {
+	return func(d *Dialer) { d.IPFilter = filter }
+}
+
+// WithResolver sets the Dialer's Resolver.
+func WithResolver(resolver Resolver) Option {
+	return func(d *Dialer) { d.Resolver = resolver }
+}
+
+// WithKeepAlive sets the Dialer's KeepAlive period.
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(d *Dialer) { d.KeepAlive = keepAlive }
+}
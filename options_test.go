@@ -0,0 +1,32 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDialer(t *testing.T) {
+	r := staticResolver{}
+	d := NewDialer(
+		WithTimeout(time.Second),
+		WithFilter(DualStack),
+		WithResolver(r),
+		WithKeepAlive(-1),
+	)
+	if d.Timeout != time.Second {
+		t.Errorf("Timeout: expected %v; got %v", time.Second, d.Timeout)
+	}
+	if d.IPFilter == nil {
+		t.Error("IPFilter: expected non-nil")
+	}
+	if d.Resolver == nil {
+		t.Error("Resolver: expected non-nil")
+	}
+	if d.KeepAlive != -1 {
+		t.Errorf("KeepAlive: expected -1; got %v", d.KeepAlive)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A PinnedDialer connects to each address at a single IP address for
+// a period of time, so that the connections of a session reach the
+// same backend, as some protocols require, even if the Resolver
+// rotates the addresses of the host or they change.
+//
+// The first dial of an address resolves and filters its host as the
+// Dialer would, and dials the selected addresses in order until one
+// connects, which is pinned. Later dials of the address connect to
+// the pinned address directly, until the pin expires. Concurrent
+// dials of an address that is not pinned wait for the first of them
+// to choose the address.
+//
+// A dial of a pinned address that fails leaves it pinned, since
+// connecting to another backend would break the session. Unpin
+// allows a new address to be chosen by the next dial.
+//
+// Addresses of the form "srv+name" are dialed by the Dialer without
+// being pinned.
+//
+// A PinnedDialer is safe for concurrent use by multiple goroutines.
+type PinnedDialer struct {
+	// Dialer connects to the addresses.
+	// If nil, a zero Dialer is used.
+	Dialer *Dialer
+
+	// PinDuration is how long an address remains pinned after it
+	// is chosen. If zero, it remains pinned until Unpin is called.
+	PinDuration time.Duration
+
+	mu   sync.Mutex
+	pins map[pinKey]*pin
+}
+
+type pinKey struct {
+	network, address string
+}
+
+type pin struct {
+	mu      sync.Mutex // held while choosing the address
+	address string     // empty until chosen
+	expires time.Time
+}
+
+func (p *PinnedDialer) dialer() *Dialer {
+	if p.Dialer != nil {
+		return p.Dialer
+	}
+	return &Dialer{}
+}
+
+// Dial connects to the address on the named network, at its pinned
+// address if it has one.
+func (p *PinnedDialer) Dial(network, address string) (net.Conn, error) {
+	return p.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using
+// the provided context, at its pinned address if it has one.
+func (p *PinnedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d := p.dialer()
+	if strings.HasPrefix(address, srvPrefix) {
+		return d.DialContext(ctx, network, address)
+	}
+	key := pinKey{network, address}
+	p.mu.Lock()
+	if p.pins == nil {
+		p.pins = make(map[pinKey]*pin)
+	}
+	pn, ok := p.pins[key]
+	if !ok {
+		pn = &pin{}
+		p.pins[key] = pn
+	}
+	p.mu.Unlock()
+
+	pn.mu.Lock()
+	if pn.address != "" && (pn.expires.IsZero() || timeNow().Before(pn.expires)) {
+		pinned := pn.address
+		pn.mu.Unlock()
+		return d.DialContext(ctx, network, pinned)
+	}
+	defer pn.mu.Unlock()
+	c, pinned, err := p.choose(ctx, d, network, address)
+	if err != nil {
+		return nil, err
+	}
+	pn.address = pinned
+	if p.PinDuration > 0 {
+		pn.expires = timeNow().Add(p.PinDuration)
+	}
+	return c, nil
+}
+
+// choose dials the addresses selected for address in order, returning
+// the first connection and its address.
+func (p *PinnedDialer) choose(ctx context.Context, d *Dialer, network, address string) (net.Conn, string, error) {
+	addrs, err := resolveAddrsContext(ctx, withContext(ctx, forNetwork(d.Resolver, network)), d.ipFilter(), network, address)
+	if err != nil {
+		return nil, "", &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	for i := 0; i < addrs.Len(); i++ {
+		var c net.Conn
+		if c, err = d.DialContext(ctx, network, addrs.Addr(i)); err == nil {
+			return c, addrs.Addr(i), nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", err
+}
+
+// Pinned returns the address to which the address on the named
+// network is pinned, or the empty string if it is not pinned.
+func (p *PinnedDialer) Pinned(network, address string) string {
+	p.mu.Lock()
+	pn, ok := p.pins[pinKey{network, address}]
+	p.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	pn.mu.Lock()
+	defer pn.mu.Unlock()
+	if pn.expires.IsZero() || timeNow().Before(pn.expires) {
+		return pn.address
+	}
+	return ""
+}
+
+// Unpin forgets the pinned address of the address on the named
+// network, so that the next dial chooses it again.
+func (p *PinnedDialer) Unpin(network, address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pins, pinKey{network, address})
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPinnedDialer(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// The first lookup selects a refused and a live address,
+	// and the later ones only a refused address.
+	lookups := 0
+	resolver := resolverFunc(func(host string) ([]net.IP, error) {
+		lookups++
+		if lookups == 1 {
+			return []net.IP{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)}, nil
+		}
+		return []net.IP{net.IPv4(127, 0, 0, 3)}, nil
+	})
+	p := &PinnedDialer{
+		Dialer:      &Dialer{Resolver: resolver, IPFilter: allIPs},
+		PinDuration: time.Minute,
+	}
+	address := "foo.com:" + port
+	for i := 0; i < 3; i++ {
+		c, err := p.Dial("tcp", address)
+		if err != nil {
+			t.Fatalf("Dial %d failed: %v", i, err)
+		}
+		c.Close()
+	}
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup; got %d", lookups)
+	}
+	if got, want := p.Pinned("tcp", address), "127.0.0.1:"+port; got != want {
+		t.Errorf("expected %s to be pinned; got %q", want, got)
+	}
+
+	now = now.Add(time.Minute) // expire the pin
+	if got := p.Pinned("tcp", address); got != "" {
+		t.Errorf("expected the pin to expire; got %q", got)
+	}
+	if _, err := p.Dial("tcp", address); err == nil {
+		t.Error("expected the refused address to be chosen once the pin expired")
+	}
+	if lookups != 2 {
+		t.Errorf("expected 2 lookups; got %d", lookups)
+	}
+
+	p.Unpin("tcp", address)
+	if got := p.Pinned("tcp", address); got != "" {
+		t.Errorf("expected no pin after Unpin; got %q", got)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParseAddrsFilter parses an address selection policy into an IPFilter, so
+// that the policy may be changed with a configuration file or flag.
+// A policy is a list of filters separated by vertical bars, which are
+// applied from left to right, such as "dedup|ipv4|shuffle|max(3)".
+//
+// The filters are:
+//
+//	ipv4        select IPv4 addresses
+//	ipv6        select IPv6 addresses
+//	max(n)      select the first n addresses
+//	first       select the first address, like max(1)
+//	dualstack   DualStack
+//	interleave  Interleave
+//	preferipv4  PreferIPv4
+//	preferipv6  PreferIPv6
+//	dedup       Dedup
+//	shuffle     Shuffle
+//	rfc6724     SortRFC6724
+//	public      PublicOnly
+//	private     PrivateOnly
+//	samesubnet  PreferSameSubnet
+//	roundrobin  RoundRobin()
+//	subnet(cidr, ...)
+//	            SubnetFilter with the given networks
+//	maxperprefix(v4bits, v6bits, n)
+//	            MaxPerPrefix with the given prefix lengths and count
+//
+// Filters other than shuffle, rfc6724, samesubnet, roundrobin and
+// maxperprefix select indices of the addresses rather than copying
+// them, so that a run of them allocates at most once, for the
+// addresses selected. If they are a prefix of those given, no
+// allocation is needed.
+func ParseAddrsFilter(policy string) (func(ips []net.IP) []net.IP, error) {
+	var stages []filterStage
+	for _, s := range strings.Split(policy, "|") {
+		stage, err := parseFilterStage(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter policy %q: %v", policy, err)
+		}
+		stages = append(stages, stage)
+	}
+	if len(stages) == 1 && stages[0].filter != nil {
+		return stages[0].filter, nil
+	}
+	return func(ips []net.IP) []net.IP {
+		for i := 0; i < len(stages); {
+			if stages[i].filter != nil {
+				ips = stages[i].filter(ips)
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(stages) && stages[j].filter == nil {
+				j++
+			}
+			ips = selectIndices(ips, stages[i:j])
+			i = j
+		}
+		return ips
+	}, nil
+}
+
+// A filterStage is a filter of a policy. Either filter or index is set.
+type filterStage struct {
+	filter func(ips []net.IP) []net.IP
+	index  indexFilter
+}
+
+// An indexFilter selects addresses by their indices in ips. It
+// appends the indices it selects from src, which it must not modify,
+// to dst, whose capacity is at least len(src), and returns the result.
+type indexFilter func(ips []net.IP, src, dst []int) []int
+
+// indexBufs holds pairs of index buffers for selectIndices.
+var indexBufs = sync.Pool{New: func() any { return new([2][]int) }}
+
+// selectIndices applies the index filters of stages to ips and
+// returns the selected addresses.
+func selectIndices(ips []net.IP, stages []filterStage) []net.IP {
+	bufs := indexBufs.Get().(*[2][]int)
+	defer indexBufs.Put(bufs)
+	if cap(bufs[0]) < len(ips) {
+		bufs[0], bufs[1] = make([]int, len(ips)), make([]int, len(ips))
+	}
+	src, dst := bufs[0][:len(ips)], bufs[1]
+	for i := range src {
+		src[i] = i
+	}
+	for _, stage := range stages {
+		res := stage.index(ips, src, dst[:0])
+		src, dst = res, src[:cap(src)]
+	}
+	if len(src) == 0 {
+		return nil
+	}
+	prefix := true
+	for i, j := range src {
+		if i != j {
+			prefix = false
+			break
+		}
+	}
+	if prefix {
+		return ips[:len(src)]
+	}
+	a := make([]net.IP, len(src))
+	for i, j := range src {
+		a[i] = ips[j]
+	}
+	return a
+}
+
+// selectIndex returns an indexFilter that selects the addresses for
+// which keep returns true, preserving their order.
+func selectIndex(keep func(ip net.IP) bool) indexFilter {
+	return func(ips []net.IP, src, dst []int) []int {
+		for _, i := range src {
+			if keep(ips[i]) {
+				dst = append(dst, i)
+			}
+		}
+		return dst
+	}
+}
+
+// maxIndex returns an indexFilter that selects the first n addresses.
+func maxIndex(n int) indexFilter {
+	return func(ips []net.IP, src, dst []int) []int {
+		if len(src) > n {
+			src = src[:n]
+		}
+		return append(dst, src...)
+	}
+}
+
+// dualStackIndex is the indexFilter of DualStack.
+func dualStackIndex(ips []net.IP, src, dst []int) []int {
+	var ipv4, ipv6 bool
+	for _, i := range src {
+		if is4 := ips[i].To4() != nil; !ipv4 && is4 {
+			dst = append(dst, i)
+			ipv4 = true
+		} else if !ipv6 && !is4 {
+			dst = append(dst, i)
+			ipv6 = true
+		}
+		if ipv4 && ipv6 {
+			break
+		}
+	}
+	return dst
+}
+
+// preferIndex returns the indexFilter of PreferIPv4 if ipv4,
+// or of PreferIPv6 otherwise.
+func preferIndex(ipv4 bool) indexFilter {
+	return func(ips []net.IP, src, dst []int) []int {
+		for _, i := range src {
+			if (ips[i].To4() != nil) == ipv4 {
+				dst = append(dst, i)
+			}
+		}
+		for _, i := range src {
+			if (ips[i].To4() != nil) != ipv4 {
+				dst = append(dst, i)
+			}
+		}
+		return dst
+	}
+}
+
+// interleaveIndex is the indexFilter of Interleave.
+func interleaveIndex(ips []net.IP, src, dst []int) []int {
+	if len(src) == 0 {
+		return dst
+	}
+	firstV4 := ips[src[0]].To4() != nil
+	for i, j := 0, 0; i < len(src) || j < len(src); {
+		for i < len(src) && (ips[src[i]].To4() != nil) != firstV4 {
+			i++
+		}
+		if i < len(src) {
+			dst = append(dst, src[i])
+			i++
+		}
+		for j < len(src) && (ips[src[j]].To4() != nil) == firstV4 {
+			j++
+		}
+		if j < len(src) {
+			dst = append(dst, src[j])
+			j++
+		}
+	}
+	return dst
+}
+
+// dedupIndex is the indexFilter of Dedup.
+func dedupIndex(ips []net.IP, src, dst []int) []int {
+outer:
+	for _, i := range src {
+		for _, j := range dst {
+			if ips[i].Equal(ips[j]) {
+				continue outer
+			}
+		}
+		dst = append(dst, i)
+	}
+	return dst
+}
+
+// parseFilterStage parses a single filter of a policy.
+func parseFilterStage(s string) (filterStage, error) {
+	name, args := s, []string(nil)
+	if i := strings.IndexByte(s, '('); i >= 0 {
+		if s[len(s)-1] != ')' {
+			return filterStage{}, fmt.Errorf("missing ) in %q", s)
+		}
+		name = strings.TrimSpace(s[:i])
+		for _, arg := range strings.Split(s[i+1:len(s)-1], ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	}
+	var stage filterStage
+	switch name {
+	case "ipv4":
+		stage.index = selectIndex(func(ip net.IP) bool { return ip.To4() != nil })
+	case "ipv6":
+		stage.index = selectIndex(func(ip net.IP) bool { return ip.To4() == nil })
+	case "first":
+		stage.index = maxIndex(1)
+	case "max":
+		if len(args) != 1 {
+			return filterStage{}, fmt.Errorf("%s takes 1 argument", name)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return filterStage{}, fmt.Errorf("invalid count %q", args[0])
+		}
+		return filterStage{index: maxIndex(n)}, nil
+	case "subnet":
+		if len(args) == 0 {
+			return filterStage{}, fmt.Errorf("%s takes at least 1 argument", name)
+		}
+		nets := make([]*net.IPNet, len(args))
+		for i, arg := range args {
+			_, n, err := net.ParseCIDR(arg)
+			if err != nil {
+				return filterStage{}, err
+			}
+			nets[i] = n
+		}
+		return filterStage{index: selectIndex(func(ip net.IP) bool { return containsIP(nets, ip) })}, nil
+	case "maxperprefix":
+		if len(args) != 3 {
+			return filterStage{}, fmt.Errorf("%s takes 3 arguments", name)
+		}
+		v4Bits, err := strconv.Atoi(args[0])
+		if err != nil || v4Bits < 0 || v4Bits > 8*net.IPv4len {
+			return filterStage{}, fmt.Errorf("invalid IPv4 prefix length %q", args[0])
+		}
+		v6Bits, err := strconv.Atoi(args[1])
+		if err != nil || v6Bits < 0 || v6Bits > 8*net.IPv6len {
+			return filterStage{}, fmt.Errorf("invalid IPv6 prefix length %q", args[1])
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 {
+			return filterStage{}, fmt.Errorf("invalid count %q", args[2])
+		}
+		return filterStage{filter: MaxPerPrefix(v4Bits, v6Bits, n)}, nil
+	case "dualstack":
+		stage.index = dualStackIndex
+	case "interleave":
+		stage.index = interleaveIndex
+	case "preferipv4":
+		stage.index = preferIndex(true)
+	case "preferipv6":
+		stage.index = preferIndex(false)
+	case "dedup":
+		stage.index = dedupIndex
+	case "public":
+		stage.index = selectIndex(func(ip net.IP) bool { return !isInternalIP(ip) })
+	case "private":
+		stage.index = selectIndex(isInternalIP)
+	case "shuffle":
+		stage.filter = Shuffle
+	case "rfc6724":
+		stage.filter = SortRFC6724
+	case "samesubnet":
+		stage.filter = PreferSameSubnet
+	case "roundrobin":
+		stage.filter = RoundRobin()
+	case "":
+		return filterStage{}, fmt.Errorf("missing filter")
+	default:
+		return filterStage{}, fmt.Errorf("unknown filter %q", name)
+	}
+	if args != nil {
+		return filterStage{}, fmt.Errorf("%s takes no arguments", name)
+	}
+	return stage, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	in := parseIPs("10.0.0.1", "192.0.2.1", "2001:db8::1", "192.0.2.1", "192.0.2.2", "2001:db8::2", "192.0.2.3")
+	tests := []struct {
+		policy string
+		want   []net.IP
+	}{
+		{"ipv4", parseIPs("10.0.0.1", "192.0.2.1", "192.0.2.1", "192.0.2.2", "192.0.2.3")},
+		{"ipv6", parseIPs("2001:db8::1", "2001:db8::2")},
+		{"first", parseIPs("10.0.0.1")},
+		{"dedup | public | max(3)", parseIPs("192.0.2.1", "2001:db8::1", "192.0.2.2")},
+		{"private|dualstack", parseIPs("10.0.0.1")},
+		{"subnet(192.0.2.0/31, 2001:db8::2/128)|dedup", parseIPs("192.0.2.1", "2001:db8::2")},
+		{"dedup|preferipv6|max(3)", parseIPs("2001:db8::1", "2001:db8::2", "10.0.0.1")},
+		{"dedup|ipv4|interleave|max(10)", parseIPs("10.0.0.1", "192.0.2.1", "192.0.2.2", "192.0.2.3")},
+		{"maxperprefix(24, 64, 1)", parseIPs("10.0.0.1", "192.0.2.1", "2001:db8::1")},
+	}
+	for _, tt := range tests {
+		filter, err := ParseAddrsFilter(tt.policy)
+		if err != nil {
+			t.Errorf("ParseAddrsFilter(%q) failed: %v", tt.policy, err)
+			continue
+		}
+		ips := append([]net.IP(nil), in...)
+		if got := filter(ips); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v; got %v", tt.policy, tt.want, got)
+		}
+	}
+
+	for _, policy := range []string{
+		"",
+		"ipv4|",
+		"bogus",
+		"max",
+		"max(0)",
+		"max(3",
+		"max(1, 2)",
+		"ipv4(1)",
+		"subnet()",
+		"subnet(192.0.2.1)",
+		"maxperprefix(24, 64)",
+		"maxperprefix(-1, 64, 1)",
+		"maxperprefix(24, 129, 1)",
+		"maxperprefix(24, 64, 0)",
+	} {
+		if _, err := ParseAddrsFilter(policy); err == nil {
+			t.Errorf("ParseAddrsFilter(%q): expected error", policy)
+		}
+	}
+}
+
+func TestParseFilterAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector drops pooled buffers")
+	}
+	in := parseIPs("192.0.2.1", "192.0.2.2", "2001:db8::1", "192.0.2.1")
+	tests := []struct {
+		policy string
+		allocs float64
+	}{
+		{"dedup|ipv4|max(2)", 0}, // a prefix of in
+		{"dedup|preferipv6|max(2)", 1},
+	}
+	for _, tt := range tests {
+		filter, err := ParseAddrsFilter(tt.policy)
+		if err != nil {
+			t.Fatalf("ParseAddrsFilter(%q) failed: %v", tt.policy, err)
+		}
+		filter(in) // fill the pool
+		if n := testing.AllocsPerRun(100, func() { filter(in) }); n > tt.allocs {
+			t.Errorf("%q: expected at most %v allocations; got %v", tt.policy, tt.allocs, n)
+		}
+	}
+}
+
+var benchPolicyIPs = parseIPs(
+	"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2",
+	"10.0.0.1", "192.0.2.1", "2001:db8::3", "192.0.2.3",
+)
+
+func BenchmarkParseAddrsFilter(b *testing.B) {
+	filter, err := ParseAddrsFilter("dedup|public|preferipv4|interleave|max(4)")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		filter(benchPolicyIPs)
+	}
+}
+
+// BenchmarkComposedFilters composes the exported filters equivalent
+// to those of BenchmarkParseAddrsFilter, each of which allocates.
+func BenchmarkComposedFilters(b *testing.B) {
+	filter := func(ips []net.IP) []net.IP {
+		ips = Dedup(ips)
+		ips = PublicOnly(ips)
+		ips = PreferIPv4(ips)
+		ips = Interleave(ips)
+		if len(ips) > 4 {
+			ips = ips[:4]
+		}
+		return ips
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		filter(benchPolicyIPs)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by Pool.Get after the Pool is closed.
+var ErrPoolClosed = errors.New("pool closed")
+
+// DefaultMaxIdle is the default maximum number of idle connections
+// a Pool keeps for each network and address.
+const DefaultMaxIdle = 2
+
+// A Pool reuses idle connections established by a Dialer. Connections
+// are keyed by the network and address they were requested with, so
+// a Pool may be shared by dials to many destinations.
+//
+// A Pool is safe for concurrent use by multiple goroutines.
+type Pool struct {
+	// Dialer establishes new connections.
+	// If nil, a zero Dialer is used.
+	Dialer *Dialer
+
+	// MaxIdle is the maximum number of idle connections kept
+	// for each network and address.
+	// If zero, DefaultMaxIdle is used.
+	MaxIdle int
+
+	// MaxLifetime is the maximum amount of time a connection may
+	// be reused, measured from when it was established.
+	// If zero, connections are reused regardless of age.
+	MaxLifetime time.Duration
+
+	// HealthCheck, if non-nil, is called with an idle connection
+	// before it is returned by Get. If it returns an error, the
+	// connection is closed and discarded.
+	HealthCheck func(c net.Conn) error
+
+	mu     sync.Mutex
+	idle   map[poolKey][]*idleConn
+	closed bool
+}
+
+type poolKey struct {
+	network, address string
+}
+
+type idleConn struct {
+	net.Conn
+	created time.Time
+}
+
+// A PoolConn is a connection obtained from a Pool.
+// Closing it returns it to the Pool to be reused.
+type PoolConn struct {
+	net.Conn
+	pool    *Pool
+	key     poolKey
+	created time.Time
+
+	mu       sync.Mutex
+	unusable bool
+	closed   bool
+}
+
+// Close returns the connection to the Pool, unless it was marked
+// unusable, is too old, or the Pool already has enough idle
+// connections, in which case the connection is closed.
+func (c *PoolConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.unusable {
+		return c.Conn.Close()
+	}
+	return c.pool.put(c.key, &idleConn{c.Conn, c.created})
+}
+
+// MarkUnusable marks the connection as not reusable, so that
+// Close closes it rather than returning it to the Pool.
+func (c *PoolConn) MarkUnusable() {
+	c.mu.Lock()
+	c.unusable = true
+	c.mu.Unlock()
+}
+
+// Get returns an idle connection to the address on the named network
+// if one is available and healthy, or else dials a new connection.
+func (p *Pool) Get(ctx context.Context, network, address string) (*PoolConn, error) {
+	key := poolKey{network, address}
+	for {
+		ic, err := p.take(key)
+		if err != nil {
+			return nil, err
+		}
+		if ic == nil {
+			break
+		}
+		if p.expired(ic, timeNow()) || (p.HealthCheck != nil && p.HealthCheck(ic.Conn) != nil) {
+			ic.Close()
+			continue
+		}
+		return &PoolConn{Conn: ic.Conn, pool: p, key: key, created: ic.created}, nil
+	}
+	d := p.Dialer
+	if d == nil {
+		d = &Dialer{}
+	}
+	c, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &PoolConn{Conn: c, pool: p, key: key, created: timeNow()}, nil
+}
+
+// Close closes the Pool's idle connections. Connections that are in
+// use are closed instead of being returned to the Pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, conns := range idle {
+		for _, ic := range conns {
+			ic.Close()
+		}
+	}
+	return nil
+}
+
+// take removes and returns the most recently used idle connection
+// for key, or nil if there are none.
+func (p *Pool) take(key poolKey) (*idleConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil, nil
+	}
+	ic := conns[len(conns)-1]
+	conns[len(conns)-1] = nil
+	if conns = conns[:len(conns)-1]; len(conns) == 0 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = conns
+	}
+	return ic, nil
+}
+
+// put adds ic to the idle connections for key, or closes it.
+func (p *Pool) put(key poolKey, ic *idleConn) error {
+	p.mu.Lock()
+	if p.closed || p.expired(ic, timeNow()) || len(p.idle[key]) >= p.maxIdle() {
+		p.mu.Unlock()
+		return ic.Close()
+	}
+	if p.idle == nil {
+		p.idle = make(map[poolKey][]*idleConn)
+	}
+	p.idle[key] = append(p.idle[key], ic)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Pool) maxIdle() int {
+	if p.MaxIdle > 0 {
+		return p.MaxIdle
+	}
+	return DefaultMaxIdle
+}
+
+func (p *Pool) expired(ic *idleConn, now time.Time) bool {
+	return p.MaxLifetime > 0 && !now.Before(ic.created.Add(p.MaxLifetime))
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newIdleServer returns a listener that accepts connections
+// and leaves them open until it is closed.
+func newIdleServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+	return ln
+}
+
+func TestPoolReuse(t *testing.T) {
+	ln := newIdleServer(t)
+	defer ln.Close()
+	ctx := context.Background()
+	addr := ln.Addr().String()
+
+	p := &Pool{MaxIdle: 1}
+	defer p.Close()
+	c1, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Close()
+	c2.Close() // exceeds MaxIdle
+	c3, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c3.Conn != c1.Conn {
+		t.Error("expected idle connection to be reused")
+	}
+	c3.MarkUnusable()
+	c3.Close()
+	c4, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c4.Close()
+	if c4.Conn == c1.Conn || c4.Conn == c2.Conn {
+		t.Error("expected a new connection")
+	}
+}
+
+func TestPoolMaxLifetime(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	ln := newIdleServer(t)
+	defer ln.Close()
+	ctx := context.Background()
+	addr := ln.Addr().String()
+
+	p := &Pool{MaxLifetime: time.Minute}
+	defer p.Close()
+	c1, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Close()
+	now = now.Add(time.Minute)
+	c2, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if c2.Conn == c1.Conn {
+		t.Error("expected expired connection to be discarded")
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	ln := newIdleServer(t)
+	defer ln.Close()
+	ctx := context.Background()
+	addr := ln.Addr().String()
+
+	p := &Pool{HealthCheck: func(net.Conn) error { return errors.New("unhealthy") }}
+	c1, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Close()
+	c2, err := p.Get(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.Conn == c1.Conn {
+		t.Error("expected unhealthy connection to be discarded")
+	}
+	c2.Close()
+
+	p.Close()
+	if _, err := p.Get(ctx, "tcp", addr); err != ErrPoolClosed {
+		t.Errorf("expected %v; got %v", ErrPoolClosed, err)
+	}
+}
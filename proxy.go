@@ -0,0 +1,179 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var errProxyNetwork = errors.New("network not supported by proxy")
+
+// aLongTimeAgo is a non-zero time, far in the past, used for
+// immediate cancellation of I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// A Proxy tunnels connections through a proxy server.
+//
+// A Proxy must be safe for concurrent use by multiple goroutines.
+type Proxy interface {
+	// ProxyAddr returns the network and address of the proxy server.
+	ProxyAddr() (network, address string)
+
+	// Tunnel performs the handshake on c, an established connection
+	// to the proxy server, asking the server to connect to address
+	// on the named network. It returns the tunneled connection,
+	// which may wrap c.
+	Tunnel(ctx context.Context, c net.Conn, network, address string) (net.Conn, error)
+}
+
+// ProxyFromURL returns the Proxy described by u. The supported
+// schemes are "http", "https", "socks5" and "socks5h". If u has
+// a user, it is used to authenticate with the proxy server.
+//
+// The "socks5" and "socks5h" schemes return the same Proxy, since
+// whether the host being dialed is resolved locally, as for "socks5",
+// or by the proxy server, as for "socks5h", is chosen by the Dialer's
+// ProxyResolve field.
+func ProxyFromURL(u *url.URL) (Proxy, error) {
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	addr := func(port string) string {
+		if u.Port() != "" {
+			return u.Host
+		}
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	switch u.Scheme {
+	case "http":
+		return &HTTPProxy{Address: addr("80"), Username: username, Password: password}, nil
+	case "https":
+		return &HTTPProxy{Address: addr("443"), TLSConfig: &tls.Config{}, Username: username, Password: password}, nil
+	case "socks5", "socks5h":
+		return &SOCKS5Proxy{Address: addr("1080"), Username: username, Password: password}, nil
+	}
+	return nil, errors.New("proxy: unsupported scheme " + u.Scheme)
+}
+
+// ProxyFromEnvironment returns the Proxy to use for the address as
+// indicated by the environment variables HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY (or the lowercase versions thereof), with the same
+// semantics and caching as http.ProxyFromEnvironment. HTTPS_PROXY
+// is used for addresses with port 443, and HTTP_PROXY otherwise.
+//
+// A nil Proxy is returned if no proxy is defined in the environment
+// or the address should not be proxied, and for networks other than
+// TCP, which the proxies don't support.
+//
+// It may be used as a Dialer's ProxyFunc.
+func ProxyFromEnvironment(network, address string) (Proxy, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, nil
+	}
+	scheme := "http"
+	if _, port, err := net.SplitHostPort(address); err == nil && (port == "443" || port == "https") {
+		scheme = "https"
+	}
+	u, err := http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: scheme, Host: address}})
+	if err != nil || u == nil {
+		return nil, err
+	}
+	return ProxyFromURL(u)
+}
+
+// proxy returns the Proxy to use for the address, if any.
+func (d *Dialer) proxy(network, address string) (Proxy, error) {
+	if d.ProxyFunc != nil {
+		return d.ProxyFunc(network, address)
+	}
+	return d.Proxy, nil
+}
+
+// dialProxy connects to address through p.
+func (d *Dialer) dialProxy(ctx context.Context, p Proxy, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errProxyNetwork}
+	}
+	var tunnel dialFunc = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return d.tunnel(ctx, p, network, address)
+	}
+	if data, ok := earlyData(ctx); ok {
+		tunnel = writeEarlyData(data, tunnel)
+	}
+	tunnel = d.policyDial(address, tunnel)
+	if d.ProxyResolve {
+		// No addresses are selected for the destination, and the
+		// dial of the proxy server is not explained in its place.
+		ex, ctx := takeExplainer(ctx)
+		if ex != nil && !ex.connect {
+			return nil, errExplained
+		}
+		return tunnel(ctx, network, address)
+	}
+	return d.dial(ctx, tunnel, network, address, true)
+}
+
+// tunnel connects to the proxy server and asks it to connect
+// to address.
+func (d *Dialer) tunnel(ctx context.Context, p Proxy, network, address string) (net.Conn, error) {
+	pnet, paddr := p.ProxyAddr()
+	nd := d.netDialer()
+	c, err := d.dial(ctx, nd.DialContext, pnet, paddr, false)
+	if err != nil {
+		return nil, err
+	}
+	var tc net.Conn
+	err = handshake(ctx, c, func() (err error) {
+		tc, err = p.Tunnel(ctx, c, network, address)
+		return err
+	})
+	if err != nil {
+		c.Close()
+		return nil, &net.OpError{Op: "proxy", Net: pnet, Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+	}
+	return tc, nil
+}
+
+// handshake runs fn, which performs I/O on c, interrupting the I/O
+// if ctx is done before fn returns.
+func handshake(ctx context.Context, c net.Conn, fn func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if ctx.Done() != nil {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				c.SetDeadline(aLongTimeAgo)
+			case <-done:
+			}
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+	}
+	err := fn()
+	if err != nil && ctx.Err() != nil {
+		err = mapErr(ctx.Err())
+	}
+	return err
+}
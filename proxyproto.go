@@ -0,0 +1,363 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ProxyHeader is the header of the PROXY protocol used by HAProxy
+// and load balancers such as AWS NLB, which is sent at the start of
+// a relayed connection to convey the addresses of the connection
+// being relayed.
+type ProxyHeader struct {
+	// Version is the version of the protocol, 1 for the text
+	// format or 2 for the binary format.
+	Version int
+
+	// Source and Destination are the addresses of the client and
+	// server of the connection being relayed, which must both be
+	// *net.TCPAddr or *net.UDPAddr of the same family. If Source is
+	// nil, the header conveys no addresses and the receiver uses
+	// the addresses of the relayed connection itself.
+	Source, Destination net.Addr
+}
+
+var (
+	proxyV1Prefix  = []byte("PROXY ")
+	proxyV2Sig     = []byte("\r\n\r\n\x00\r\nQUIT\n")
+	errProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+// proxyV1MaxLen is the maximum length of a version 1 header.
+const proxyV1MaxLen = 107
+
+// Format returns the encoding of the header.
+func (h *ProxyHeader) Format() ([]byte, error) {
+	src, dst, proto, err := h.addrs()
+	if err != nil {
+		return nil, err
+	}
+	switch h.Version {
+	case 1:
+		if src == nil {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		if proto != "TCP" {
+			return nil, errors.New("PROXY protocol version 1 only supports TCP")
+		}
+		family := "TCP6"
+		if src.IP.To4() != nil {
+			family = "TCP4"
+		}
+		return []byte("PROXY " + family + " " + src.IP.String() + " " + dst.IP.String() + " " +
+			strconv.Itoa(src.Port) + " " + strconv.Itoa(dst.Port) + "\r\n"), nil
+	case 2:
+		b := append([]byte(nil), proxyV2Sig...)
+		if src == nil {
+			return append(b, 0x20, 0x00, 0, 0), nil // LOCAL, UNSPEC
+		}
+		fam, ip1, ip2 := byte(0x20), src.IP.To16(), dst.IP.To16()
+		if s4, d4 := src.IP.To4(), dst.IP.To4(); s4 != nil && d4 != nil {
+			fam, ip1, ip2 = 0x10, s4, d4
+		}
+		if proto == "TCP" {
+			fam |= 0x01
+		} else {
+			fam |= 0x02
+		}
+		b = append(b, 0x21, fam) // PROXY
+		b = binary.BigEndian.AppendUint16(b, uint16(2*len(ip1)+4))
+		b = append(b, ip1...)
+		b = append(b, ip2...)
+		b = binary.BigEndian.AppendUint16(b, uint16(src.Port))
+		return binary.BigEndian.AppendUint16(b, uint16(dst.Port)), nil
+	}
+	return nil, errors.New("unsupported PROXY protocol version " + strconv.Itoa(h.Version))
+}
+
+// addrs returns the addresses of the header as *net.TCPAddr,
+// with the protocol of the originals.
+func (h *ProxyHeader) addrs() (src, dst *net.TCPAddr, proto string, err error) {
+	switch s := h.Source.(type) {
+	case nil:
+		return nil, nil, "", nil
+	case *net.TCPAddr:
+		if d, ok := h.Destination.(*net.TCPAddr); ok && (s.IP.To4() == nil) == (d.IP.To4() == nil) {
+			return s, d, "TCP", nil
+		}
+	case *net.UDPAddr:
+		if d, ok := h.Destination.(*net.UDPAddr); ok && (s.IP.To4() == nil) == (d.IP.To4() == nil) {
+			return &net.TCPAddr{IP: s.IP, Port: s.Port}, &net.TCPAddr{IP: d.IP, Port: d.Port}, "UDP", nil
+		}
+	}
+	return nil, nil, "", errors.New("unsupported PROXY protocol addresses")
+}
+
+// WriteTo writes the encoding of the header to w.
+func (h *ProxyHeader) WriteTo(w io.Writer) (int64, error) {
+	b, err := h.Format()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// readProxyHeader reads a version 1 or 2 header from r.
+func readProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	b, err := r.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, proxyV1Prefix) {
+		return readProxyV1Header(r)
+	}
+	if b, err = r.Peek(len(proxyV2Sig)); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, proxyV2Sig) {
+		return readProxyV2Header(r)
+	}
+	return nil, errProxyHeader
+}
+
+func readProxyV1Header(r *bufio.Reader) (*ProxyHeader, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLen {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+	}
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errProxyHeader
+	}
+	f := strings.Split(s, " ")
+	h := &ProxyHeader{Version: 1}
+	if len(f) >= 2 && f[1] == "UNKNOWN" {
+		return h, nil
+	}
+	if len(f) != 6 || (f[1] != "TCP4" && f[1] != "TCP6") {
+		return nil, errProxyHeader
+	}
+	src, dst := net.ParseIP(f[2]), net.ParseIP(f[3])
+	sport, err1 := strconv.ParseUint(f[4], 10, 16)
+	dport, err2 := strconv.ParseUint(f[5], 10, 16)
+	if src == nil || dst == nil || err1 != nil || err2 != nil || (src.To4() != nil) != (f[1] == "TCP4") || (dst.To4() != nil) != (f[1] == "TCP4") {
+		return nil, errProxyHeader
+	}
+	if f[1] == "TCP4" {
+		src, dst = src.To4(), dst.To4()
+	}
+	h.Source = &net.TCPAddr{IP: src, Port: int(sport)}
+	h.Destination = &net.TCPAddr{IP: dst, Port: int(dport)}
+	return h, nil
+}
+
+func readProxyV2Header(r *bufio.Reader) (*ProxyHeader, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, errProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	h := &ProxyHeader{Version: 2}
+	switch hdr[12] & 0x0f {
+	case 0x0: // LOCAL
+		return h, nil
+	case 0x1: // PROXY
+	default:
+		return nil, errProxyHeader
+	}
+	var ipLen int
+	switch hdr[13] >> 4 {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default: // UNSPEC or UNIX
+		return h, nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, errProxyHeader
+	}
+	src := net.IP(append([]byte(nil), body[:ipLen]...))
+	dst := net.IP(append([]byte(nil), body[ipLen:2*ipLen]...))
+	sport := int(binary.BigEndian.Uint16(body[2*ipLen:]))
+	dport := int(binary.BigEndian.Uint16(body[2*ipLen+2:]))
+	switch hdr[13] & 0x0f {
+	case 0x1:
+		h.Source, h.Destination = &net.TCPAddr{IP: src, Port: sport}, &net.TCPAddr{IP: dst, Port: dport}
+	case 0x2:
+		h.Source, h.Destination = &net.UDPAddr{IP: src, Port: sport}, &net.UDPAddr{IP: dst, Port: dport}
+	default:
+		return nil, errProxyHeader
+	}
+	return h, nil
+}
+
+type proxyHeaderKey struct{}
+
+// WithProxyHeader returns a new context based on the provided parent
+// ctx. Connections established by a Dialer with the returned context
+// begin with h, which the Dialer writes before returning them. If
+// the Destination of h is nil, the remote address of the connection
+// is used.
+func WithProxyHeader(ctx context.Context, h *ProxyHeader) context.Context {
+	return context.WithValue(ctx, proxyHeaderKey{}, h)
+}
+
+// writeProxyHeader writes the header attached to ctx, if any, to c.
+func writeProxyHeader(ctx context.Context, c net.Conn) error {
+	h, _ := ctx.Value(proxyHeaderKey{}).(*ProxyHeader)
+	if h == nil {
+		return nil
+	}
+	if h.Destination == nil && h.Source != nil {
+		hh := *h
+		hh.Destination = c.RemoteAddr()
+		h = &hh
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetWriteDeadline(deadline)
+		defer c.SetWriteDeadline(time.Time{})
+	}
+	_, err := h.WriteTo(c)
+	return err
+}
+
+// A ProxyProtocolListener is a net.Listener whose connections begin
+// with a PROXY protocol header, such as those relayed by HAProxy or
+// a network load balancer. The RemoteAddr and LocalAddr of its
+// connections return the addresses conveyed by the header.
+//
+// The header is read by the first call to Read, RemoteAddr or
+// LocalAddr of a connection, so that a slow client cannot block
+// Accept. If the header is invalid, Read returns an error.
+//
+// Since clients may send arbitrary headers, a ProxyProtocolListener
+// must only accept connections from trusted proxies.
+type ProxyProtocolListener struct {
+	net.Listener
+
+	// HeaderTimeout is the maximum amount of time to wait for the
+	// header. If zero, ten seconds is used.
+	HeaderTimeout time.Duration
+}
+
+func (l *ProxyProtocolListener) headerTimeout() time.Duration {
+	if l.HeaderTimeout > 0 {
+		return l.HeaderTimeout
+	}
+	return 10 * time.Second
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{Conn: c, r: bufio.NewReader(c), timeout: l.headerTimeout()}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	header  *ProxyHeader
+	err     error
+
+	mu             sync.Mutex
+	deadline       time.Time // read deadline set by the user
+	headerDeadline time.Time // while reading the header
+}
+
+// readHeader reads the header of the connection, once. The header
+// must be read before the earlier of the user's read deadline and the
+// header timeout, after which the user's read deadline is restored.
+func (c *proxyProtocolConn) readHeader() error {
+	c.once.Do(func() {
+		c.mu.Lock()
+		c.headerDeadline = time.Now().Add(c.timeout)
+		c.Conn.SetReadDeadline(earliest(c.deadline, c.headerDeadline))
+		c.mu.Unlock()
+		c.header, c.err = readProxyHeader(c.r)
+		c.mu.Lock()
+		c.headerDeadline = time.Time{}
+		c.Conn.SetReadDeadline(c.deadline)
+		c.mu.Unlock()
+		if c.err != nil {
+			c.err = &net.OpError{Op: "read", Net: c.Conn.LocalAddr().Network(), Source: c.Conn.LocalAddr(), Addr: c.Conn.RemoteAddr(), Err: c.err}
+		}
+	})
+	return c.err
+}
+
+func (c *proxyProtocolConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.SetReadDeadline(t)
+}
+
+func (c *proxyProtocolConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(earliest(t, c.headerDeadline))
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	if c.readHeader() == nil && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	if c.readHeader() == nil && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// earliest returns the earlier of the deadlines a and b, where the
+// zero time means no deadline.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || !b.IsZero() && b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHeader(t *testing.T) {
+	v4src := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 56324}
+	v4dst := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 443}
+	v6src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	v6dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+	udpsrc := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 53}
+	udpdst := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2).To4(), Port: 53}
+	tests := []struct {
+		h   ProxyHeader
+		enc string
+	}{
+		{ProxyHeader{1, v4src, v4dst}, "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"},
+		{ProxyHeader{1, v6src, v6dst}, "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"},
+		{ProxyHeader{1, nil, nil}, "PROXY UNKNOWN\r\n"},
+		{ProxyHeader{2, v4src, v4dst}, "\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c\xc0\x00\x02\x01\xc0\x00\x02\x02\xdc\x04\x01\xbb"},
+		{ProxyHeader{2, v6src, v6dst}, ""},
+		{ProxyHeader{2, udpsrc, udpdst}, ""},
+		{ProxyHeader{2, nil, nil}, "\r\n\r\n\x00\r\nQUIT\n\x20\x00\x00\x00"},
+	}
+	for _, tt := range tests {
+		b, err := tt.h.Format()
+		if err != nil {
+			t.Errorf("%+v: Format failed: %v", tt.h, err)
+			continue
+		}
+		if tt.enc != "" && string(b) != tt.enc {
+			t.Errorf("%+v: expected %q; got %q", tt.h, tt.enc, b)
+		}
+		h, err := readProxyHeader(bufio.NewReader(io.MultiReader(bytes.NewReader(b), strings.NewReader("data"))))
+		if err != nil {
+			t.Errorf("%+v: readProxyHeader failed: %v", tt.h, err)
+			continue
+		}
+		if !reflect.DeepEqual(*h, tt.h) {
+			t.Errorf("expected %+v; got %+v", tt.h, *h)
+		}
+	}
+
+	for _, h := range []ProxyHeader{
+		{3, v4src, v4dst},
+		{1, udpsrc, udpdst},
+		{2, v4src, v6dst},
+		{2, v4src, nil},
+	} {
+		if _, err := h.Format(); err == nil {
+			t.Errorf("%+v: expected Format to fail", h)
+		}
+	}
+	for _, s := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n",
+		"PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\n",
+		"PROXY " + strings.Repeat("X", 120) + "\r\n",
+	} {
+		if _, err := readProxyHeader(bufio.NewReader(strings.NewReader(s))); err == nil {
+			t.Errorf("%q: expected readProxyHeader to fail", s)
+		}
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &ProxyProtocolListener{Listener: ln}
+	defer l.Close()
+
+	src := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 56324}
+	for _, version := range []int{1, 2} {
+		ctx := WithProxyHeader(context.Background(), &ProxyHeader{Version: version, Source: src})
+		c, err := new(Dialer).DialContext(ctx, "tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		io.WriteString(c, "hello")
+		c.Close()
+
+		s, err := l.Accept()
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		if addr := s.RemoteAddr(); addr.String() != src.String() {
+			t.Errorf("version %d: expected remote address %v; got %v", version, src, addr)
+		}
+		if addr := s.LocalAddr(); addr.String() != l.Addr().String() {
+			t.Errorf("version %d: expected local address %v; got %v", version, l.Addr(), addr)
+		}
+		b, err := io.ReadAll(s)
+		s.Close()
+		if err != nil || string(b) != "hello" {
+			t.Errorf("version %d: expected %q; got %q, %v", version, "hello", b, err)
+		}
+	}
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	io.WriteString(c, "GET / HTTP/1.1\r\n")
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Read(make([]byte, 1)); err == nil {
+		t.Error("expected reading a connection without a header to fail")
+	}
+}
+
+func TestProxyProtocolDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &ProxyProtocolListener{Listener: ln, HeaderTimeout: time.Minute}
+	defer l.Close()
+
+	// The user's read deadline applies while the header is read if
+	// it is earlier than the header timeout.
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer s.Close()
+	s.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := s.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected error %v; got %v", os.ErrDeadlineExceeded, err)
+	}
+
+	// It is restored once the header is read.
+	ctx := WithProxyHeader(context.Background(), &ProxyHeader{
+		Version: 1,
+		Source:  &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 56324},
+	})
+	c, err = new(Dialer).DialContext(ctx, "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer c.Close()
+	io.WriteString(c, "hello")
+	s, err = l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(s, b); err != nil || string(b) != "hello" {
+		t.Fatalf("expected %q; got %q, %v", "hello", b, err)
+	}
+	if _, err := s.Read(b); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("expected error %v; got %v", os.ErrDeadlineExceeded, err)
+	}
+}
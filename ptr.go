@@ -0,0 +1,89 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+var lookupAddr = net.DefaultResolver.LookupAddr // used by tests
+
+// A PTRResolver is a Resolver that can also perform reverse lookups
+// of the names of addresses.
+type PTRResolver interface {
+	Resolver
+
+	// ResolveAddr performs a reverse lookup of the given IP
+	// address using the provided context and returns the names
+	// that map to it.
+	ResolveAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the local resolver.
+func (defaultResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	return lookupAddr(ctx, addr)
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the underlying Resolver, which must be a PTRResolver. The names are
+// cached and their lookups coalesced like the addresses of hosts, for
+// TTL or NegativeTTL, so that servers logging the names of their
+// peers don't repeatedly look up the same addresses. Like Resolve,
+// lookups are not canceled by ctx, since they may be shared by
+// concurrent callers.
+func (r *CacheResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	item, err := r.get("PTR "+addr, addr, func() (*cacheItem, time.Duration, error) {
+		names, err := resolveAddr(context.Background(), r.Resolver, addr)
+		return &cacheItem{records: names}, r.clampTTL(r.defaultTTL()), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), item.records...), nil
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context with the first of the resolvers that succeeds,
+// in turn even if Concurrent is set. Resolvers that are not
+// PTRResolvers are skipped.
+func (r *ChainResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	var names []string
+	err := r.firstLookup(addr, func(resolver Resolver) (err error) {
+		names, err = resolveAddr(ctx, resolver, addr)
+		return err
+	})
+	return names, err
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context with the Resolver, since addresses are not
+// routed by name.
+func (r *RouteResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	return resolveAddr(ctx, r.Resolver, addr)
+}
+
+// ResolveAddr performs a reverse lookup of the given IP address using
+// the provided context with the underlying Resolver.
+func (r *HostsResolver) ResolveAddr(ctx context.Context, addr string) ([]string, error) {
+	return resolveAddr(ctx, r.Resolver, addr)
+}
+
+// resolveAddr performs a reverse lookup of addr with r, or
+// DefaultResolver if r is nil, failing with ErrUnsupportedLookup if it
+// is not a PTRResolver.
+func resolveAddr(ctx context.Context, r Resolver, addr string) ([]string, error) {
+	if r == nil {
+		r = DefaultResolver
+	}
+	pr, ok := r.(PTRResolver)
+	if !ok {
+		return nil, fmt.Errorf("lookup %s: %w", addr, ErrUnsupportedLookup)
+	}
+	return pr.ResolveAddr(ctx, addr)
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCacheResolverAddr(t *testing.T) {
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupAddr = fn }(lookupAddr)
+	lookups := 0
+	names := []string{"peer.example.com."}
+	lookupAddr = func(context.Context, string) ([]string, error) {
+		lookups++
+		return names, nil
+	}
+	r := &CacheResolver{}
+	for i := 0; i < 2; i++ {
+		got, err := r.ResolveAddr(context.Background(), "192.0.2.1")
+		if err != nil {
+			t.Fatalf("ResolveAddr failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, names) {
+			t.Fatalf("expected %q; got %q", names, got)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookups: expected 1; got %d", lookups)
+	}
+}
+
+func TestResolveAddrUnsupported(t *testing.T) {
+	r := &CacheResolver{Resolver: staticResolver{}}
+	if _, err := r.ResolveAddr(context.Background(), "192.0.2.1"); !errors.Is(err, ErrUnsupportedLookup) {
+		t.Errorf("expected error %v; got %v", ErrUnsupportedLookup, err)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+	for _, tt := range tests {
+		got, err := reverseName(tt.addr)
+		if err != nil {
+			t.Fatalf("reverseName(%q) failed: %v", tt.addr, err)
+		}
+		if got != tt.want {
+			t.Errorf("reverseName(%q): expected %q; got %q", tt.addr, tt.want, got)
+		}
+	}
+	if _, err := reverseName("example.com"); err == nil {
+		t.Error("expected an error for a name")
+	}
+}
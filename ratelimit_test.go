@@ -0,0 +1,35 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	b := newTokenBucket(10, 2)
+	if !b.allow() || !b.allow() {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if b.allow() {
+		t.Fatal("expected the empty bucket to disallow")
+	}
+	now = now.Add(100 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a token to have accrued")
+	}
+	now = now.Add(time.Hour)
+	if d := b.reserve(2); d != 0 {
+		t.Errorf("expected no wait for the burst; got %v", d)
+	}
+	if d := b.reserve(5); d != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms; got %v", d)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// ReachableFilter returns an IPFilter that connects to port of each
+// of the addresses at once and selects those that accept within
+// timeout, preserving their order, such as to skip stale addresses
+// in DNS. If none of the addresses are reachable, they are returned
+// unchanged, so that dialing them reports why.
+//
+// Since this delays every dial by up to timeout and makes additional
+// connections, it is typically used with a CacheResolver whose
+// addresses rarely change.
+func ReachableFilter(port int, timeout time.Duration) func(ips []net.IP) []net.IP {
+	return ProbeFilter(timeout, func(ctx context.Context, ip net.IP) error {
+		_, err := probeTCP(ctx, ip, port)
+		return err
+	})
+}
+
+// ProbeFilter is like ReachableFilter, but selects the addresses for
+// which probe returns a nil error within timeout.
+func ProbeFilter(timeout time.Duration, probe func(ctx context.Context, ip net.IP) error) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		if len(ips) == 0 {
+			return ips
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		ok := make([]chan bool, len(ips))
+		for i, ip := range ips {
+			ok[i] = make(chan bool, 1)
+			go func(ip net.IP, c chan<- bool) {
+				c <- probe(ctx, ip) == nil
+			}(ip, ok[i])
+		}
+		var a []net.IP
+		for i, c := range ok {
+			var reachable bool
+			select {
+			case reachable = <-c:
+			case <-ctx.Done():
+				// Keep the results of probes that finished in time.
+				select {
+				case reachable = <-c:
+				default:
+				}
+			}
+			if reachable {
+				a = append(a, ips[i])
+			}
+		}
+		if len(a) == 0 {
+			return ips
+		}
+		return a
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProbeFilter(t *testing.T) {
+	filter := ProbeFilter(50*time.Millisecond, func(ctx context.Context, ip net.IP) error {
+		switch ip.String() {
+		case "192.0.2.2":
+			return errors.New("refused")
+		case "192.0.2.3":
+			<-ctx.Done() // hangs
+			return ctx.Err()
+		}
+		return nil
+	})
+	in := parseIPs("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4")
+	if got, want := filter(in), parseIPs("192.0.2.1", "192.0.2.4"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	// If none are reachable, all are returned.
+	in = parseIPs("192.0.2.2", "192.0.2.3")
+	if got := filter(in); !reflect.DeepEqual(got, in) {
+		t.Errorf("expected %v; got %v", in, got)
+	}
+}
+
+func TestReachableFilter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	filter := ReachableFilter(ln.Addr().(*net.TCPAddr).Port, time.Second)
+	in := []net.IP{net.IPv6loopback, net.IPv4(127, 0, 0, 1).To4()} // only listening on IPv4
+	if got := filter(in); len(got) != 1 || !got[0].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("expected 127.0.0.1; got %v", got)
+	}
+}
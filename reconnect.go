@@ -0,0 +1,255 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// A ReconnectingConn is a net.Conn for long-lived clients that dials
+// its address again once its connection is lost.
+//
+// When a Read or Write fails, other than with a timeout, the
+// connection is closed and the error is returned, since the data in
+// flight may have been lost. The next Read or Write dials a new
+// connection, retrying with backoff until the dial succeeds, the
+// attempts are exhausted or the ReconnectingConn is closed. If the
+// Dialer's Resolver is a *CacheResolver, the address's host is
+// removed from it after each failed attempt, so that the host is
+// resolved again in case its addresses have changed.
+//
+// The deadlines of a ReconnectingConn apply to the connections it
+// dials, but not to the dials themselves.
+type ReconnectingConn struct {
+	// Backoff returns the delay before the given attempt to dial
+	// a new connection, numbered from one. The first attempt
+	// is not delayed.
+	//
+	// If nil, ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	// is used.
+	Backoff func(attempt int) time.Duration
+
+	// MaxAttempts is the maximum number of consecutive attempts
+	// to dial a new connection, after which the Read or Write
+	// fails with the error of the last attempt. If zero, the
+	// attempts are unlimited.
+	MaxAttempts int
+
+	// OnReconnect, if non-nil, is called after each attempt to
+	// dial a new connection, with its number and error, if any.
+	OnReconnect func(attempt int, err error)
+
+	d                *Dialer
+	network, address string
+	ctx              context.Context // canceled by Close
+	cancel           context.CancelFunc
+
+	dialMu sync.Mutex // serializes dials
+	mu     sync.Mutex // guards the following
+	conn   net.Conn   // nil once lost
+	last   net.Conn   // the most recent connection
+	closed bool
+	rd, wd time.Time // deadlines
+}
+
+// DialReconnecting connects to the address on the named network with
+// d, which may be nil for a zero Dialer, and returns a connection
+// that reconnects to the address once its connection is lost.
+func DialReconnecting(d *Dialer, network, address string) (*ReconnectingConn, error) {
+	if d == nil {
+		d = &Dialer{}
+	}
+	c, err := d.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReconnectingConn{
+		d:       d,
+		network: network,
+		address: address,
+		ctx:     ctx,
+		cancel:  cancel,
+		conn:    c,
+		last:    c,
+	}, nil
+}
+
+func (r *ReconnectingConn) backoff(attempt int) time.Duration {
+	if r.Backoff != nil {
+		return r.Backoff(attempt)
+	}
+	return defaultBackoff(attempt)
+}
+
+// get returns the current connection, dialing a new one if it was lost.
+func (r *ReconnectingConn) get() (net.Conn, error) {
+	if c, err := r.current(); c != nil || err != nil {
+		return c, err
+	}
+	r.dialMu.Lock()
+	defer r.dialMu.Unlock()
+	if c, err := r.current(); c != nil || err != nil {
+		return c, err
+	}
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			t := time.NewTimer(r.backoff(attempt - 1))
+			select {
+			case <-t.C:
+			case <-r.ctx.Done():
+				t.Stop()
+				return nil, net.ErrClosed
+			}
+		}
+		c, err := r.d.DialContext(r.ctx, r.network, r.address)
+		if r.OnReconnect != nil {
+			r.OnReconnect(attempt, err)
+		}
+		if err == nil {
+			return r.set(c)
+		}
+		if cache, ok := r.d.Resolver.(*CacheResolver); ok {
+			cache.Remove(hostOf(r.address))
+		}
+		if r.ctx.Err() != nil {
+			return nil, net.ErrClosed
+		}
+		if r.MaxAttempts > 0 && attempt >= r.MaxAttempts {
+			return nil, err
+		}
+	}
+}
+
+func (r *ReconnectingConn) current() (net.Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, net.ErrClosed
+	}
+	return r.conn, nil
+}
+
+// set makes c the current connection, applying the deadlines.
+func (r *ReconnectingConn) set(c net.Conn) (net.Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		c.Close()
+		return nil, net.ErrClosed
+	}
+	if !r.rd.IsZero() {
+		c.SetReadDeadline(r.rd)
+	}
+	if !r.wd.IsZero() {
+		c.SetWriteDeadline(r.wd)
+	}
+	r.conn, r.last = c, c
+	return c, nil
+}
+
+// fail closes c if err indicates that it was lost.
+func (r *ReconnectingConn) fail(c net.Conn, err error) {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return
+	}
+	r.mu.Lock()
+	if r.conn == c {
+		r.conn = nil
+	}
+	r.mu.Unlock()
+	c.Close()
+}
+
+func (r *ReconnectingConn) Read(b []byte) (int, error) {
+	c, err := r.get()
+	if err != nil {
+		return 0, &net.OpError{Op: "read", Net: r.network, Err: err}
+	}
+	n, err := c.Read(b)
+	if err != nil {
+		r.fail(c, err)
+	}
+	return n, err
+}
+
+func (r *ReconnectingConn) Write(b []byte) (int, error) {
+	c, err := r.get()
+	if err != nil {
+		return 0, &net.OpError{Op: "write", Net: r.network, Err: err}
+	}
+	n, err := c.Write(b)
+	if err != nil {
+		r.fail(c, err)
+	}
+	return n, err
+}
+
+// Close closes the current connection and stops reconnecting,
+// interrupting any dial in progress.
+func (r *ReconnectingConn) Close() error {
+	r.cancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return &net.OpError{Op: "close", Net: r.network, Err: net.ErrClosed}
+	}
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	c := r.conn
+	r.conn = nil
+	return c.Close()
+}
+
+// LocalAddr returns the local address of the most recent connection.
+func (r *ReconnectingConn) LocalAddr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the most recent connection.
+func (r *ReconnectingConn) RemoteAddr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last.RemoteAddr()
+}
+
+func (r *ReconnectingConn) SetDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rd, r.wd = t, t
+	if r.conn != nil {
+		return r.conn.SetDeadline(t)
+	}
+	return nil
+}
+
+func (r *ReconnectingConn) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rd = t
+	if r.conn != nil {
+		return r.conn.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (r *ReconnectingConn) SetWriteDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wd = t
+	if r.conn != nil {
+		return r.conn.SetWriteDeadline(t)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReconnectingConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	c, err := DialReconnecting(nil, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialReconnecting failed: %v", err)
+	}
+	defer c.Close()
+	var attempts []int
+	c.OnReconnect = func(attempt int, err error) {
+		if err != nil {
+			t.Errorf("unexpected reconnect error: %v", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	// Losing the connection returns its error.
+	(<-conns).Close()
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected io.EOF; got %v", err)
+	}
+	if len(attempts) != 0 {
+		t.Errorf("unexpected reconnect attempts: %v", attempts)
+	}
+
+	// The next operation reconnects.
+	if _, err := c.Write([]byte("x")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	s := <-conns
+	defer s.Close()
+	buf := make([]byte, 1)
+	if _, err := s.Read(buf); err != nil || buf[0] != 'x' {
+		t.Fatalf("expected to read 'x'; got %q, %v", buf, err)
+	}
+	if len(attempts) != 1 || attempts[0] != 1 {
+		t.Errorf("expected one reconnect attempt; got %v", attempts)
+	}
+
+	// Timeouts don't lose the connection.
+	c.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	var nerr net.Error
+	if _, err := c.Read(buf); !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("expected a timeout error; got %v", err)
+	}
+	c.SetReadDeadline(time.Time{})
+	s.Write([]byte("y"))
+	if _, err := c.Read(buf); err != nil || buf[0] != 'y' {
+		t.Fatalf("expected to read 'y'; got %q, %v", buf, err)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("unexpected reconnect attempts: %v", attempts)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed; got %v", err)
+	}
+}
+
+func TestReconnectingConnBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+
+	var lookups int
+	resolver := &CacheResolver{
+		Resolver: resolverFunc(func(host string) ([]net.IP, error) {
+			lookups++
+			return parseIPs("127.0.0.1"), nil
+		}),
+		TTL: time.Hour,
+	}
+	r, err := DialReconnecting(&Dialer{Resolver: resolver}, "tcp", "localhost"+addr[len("127.0.0.1"):])
+	if err != nil {
+		t.Fatalf("DialReconnecting failed: %v", err)
+	}
+	defer r.Close()
+	ln.Close()
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected io.EOF; got %v", err)
+	}
+
+	var errs []error
+	var delays []time.Duration
+	r.MaxAttempts = 3
+	r.Backoff = func(attempt int) time.Duration {
+		delays = append(delays, time.Duration(attempt)*time.Millisecond)
+		return time.Duration(attempt) * time.Millisecond
+	}
+	r.OnReconnect = func(attempt int, err error) { errs = append(errs, err) }
+	if _, err := r.Write([]byte("x")); err == nil {
+		t.Fatal("expected Write to fail")
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 reconnect attempts; got %d", len(errs))
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("attempt %d: expected an error", i+1)
+		}
+	}
+	// The first attempt uses the cached addresses and each of the
+	// others resolves the host again.
+	if lookups != 3 {
+		t.Errorf("expected 3 lookups; got %d", lookups)
+	}
+	if len(delays) != 2 || delays[0] != time.Millisecond || delays[1] != 2*time.Millisecond {
+		t.Errorf("unexpected backoff: %v", delays)
+	}
+}
+
+func TestReconnectingConnCloseInterruptsBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	r, err := DialReconnecting(nil, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("DialReconnecting failed: %v", err)
+	}
+	ln.Close()
+	r.Read(make([]byte, 1))
+	r.Backoff = func(int) time.Duration { return time.Hour }
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.Write([]byte("x"))
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.Close()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed; got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't interrupt the backoff")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResolvConf = "/etc/resolv.conf"
+
+	// resolvConfCheckInterval is how often a resolv.conf
+	// file is checked for changes.
+	resolvConfCheckInterval = 5 * time.Second
+)
+
+var (
+	// defaultNameservers are used if a resolv.conf file lists none.
+	defaultNameservers = []string{"127.0.0.1:53", "[::1]:53"}
+
+	// dnsPort is the port of the nameservers in a resolv.conf file.
+	dnsPort = "53"
+)
+
+// resolvConf is the configuration of a resolv.conf file.
+type resolvConf struct {
+	servers []string // server addresses (host:port) to use
+	search  []string // rooted suffixes to append to local names
+	ndots   int      // number of dots in a name to try it as rooted first
+	err     error    // any error reading the file
+}
+
+// readResolvConf reads the resolv.conf file at path. If it can't be
+// read, the default configuration is returned with the error.
+func readResolvConf(path string) *resolvConf {
+	conf := &resolvConf{ndots: 1}
+	f, err := os.Open(path)
+	if err != nil {
+		conf.servers = defaultNameservers
+		conf.err = err
+		return conf
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) < 1 {
+			continue
+		}
+		switch f[0] {
+		case "nameserver":
+			if len(f) > 1 && len(conf.servers) < 3 { // small, but the standard limit
+				// One more check: make sure server name is
+				// just an IP address. Otherwise we need DNS
+				// to look it up.
+				if net.ParseIP(f[1]) != nil {
+					conf.servers = append(conf.servers, net.JoinHostPort(f[1], dnsPort))
+				}
+			}
+		case "domain":
+			if len(f) > 1 {
+				conf.search = []string{ensureRooted(f[1])}
+			}
+		case "search":
+			conf.search = make([]string, 0, len(f)-1)
+			for _, name := range f[1:] {
+				if name != "." {
+					conf.search = append(conf.search, ensureRooted(name))
+				}
+			}
+		case "options":
+			for _, opt := range f[1:] {
+				if v, ok := strings.CutPrefix(opt, "ndots:"); ok {
+					if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+						if n > 15 {
+							n = 15
+						}
+						conf.ndots = n
+					}
+				}
+			}
+		}
+	}
+	if len(conf.servers) == 0 {
+		conf.servers = defaultNameservers
+	}
+	conf.err = s.Err()
+	return conf
+}
+
+// nameList returns the rooted names to try, in order, when looking up
+// the given name. As with the system resolver, a name with fewer than
+// ndots dots is tried with each of the search suffixes before being
+// tried as it is, and a rooted name is only tried as it is.
+func (conf *resolvConf) nameList(name string) []string {
+	if name == "" || name[len(name)-1] == '.' {
+		return []string{name}
+	}
+	hasNdots := strings.Count(name, ".") >= conf.ndots
+	names := make([]string, 0, 1+len(conf.search))
+	if hasNdots {
+		names = append(names, name+".")
+	}
+	for _, suffix := range conf.search {
+		names = append(names, name+"."+suffix)
+	}
+	if !hasNdots {
+		names = append(names, name+".")
+	}
+	return names
+}
+
+func ensureRooted(s string) string {
+	if len(s) > 0 && s[len(s)-1] == '.' {
+		return s
+	}
+	return s + "."
+}
+
+// resolvConfWatcher holds the configuration of a resolv.conf file,
+// reading it again when it changes.
+type resolvConfWatcher struct {
+	mu      sync.Mutex
+	path    string
+	conf    *resolvConf
+	checked time.Time // when the file was last checked for changes
+	mtime   time.Time // modification time of the file when read
+}
+
+// get returns the configuration of the resolv.conf file at path,
+// reading it if it has changed since it was last checked.
+func (w *resolvConfWatcher) get(path string) *resolvConf {
+	if path == "" {
+		path = defaultResolvConf
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := timeNow()
+	if w.conf != nil && w.path == path && now.Sub(w.checked) < resolvConfCheckInterval {
+		return w.conf
+	}
+	w.checked = now
+	var mtime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		mtime = fi.ModTime()
+	}
+	if w.conf == nil || w.path != path || !mtime.Equal(w.mtime) {
+		w.path = path
+		w.conf = readResolvConf(path)
+		w.mtime = mtime
+	}
+	return w.conf
+}
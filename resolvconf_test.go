@@ -0,0 +1,114 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadResolvConf(t *testing.T) {
+	tests := []struct {
+		data string
+		want *resolvConf
+	}{
+		{
+			data: "# comment\nnameserver 192.0.2.1\nnameserver 2001:db8::1 ; comment\nnameserver ns.example.com\ndomain example.com\n",
+			want: &resolvConf{
+				servers: []string{"192.0.2.1:53", "[2001:db8::1]:53"},
+				search:  []string{"example.com."},
+				ndots:   1,
+			},
+		},
+		{
+			data: "search a.example.com b.example.com.\noptions rotate ndots:3\n",
+			want: &resolvConf{
+				servers: defaultNameservers,
+				search:  []string{"a.example.com.", "b.example.com."},
+				ndots:   3,
+			},
+		},
+		{
+			data: "options ndots:20\n",
+			want: &resolvConf{servers: defaultNameservers, ndots: 15},
+		},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := filepath.Join(dir, "resolv.conf")
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := readResolvConf(path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: expected %+v; got %+v", i, tt.want, got)
+		}
+	}
+	if conf := readResolvConf(filepath.Join(dir, "missing")); conf.err == nil || !reflect.DeepEqual(conf.servers, defaultNameservers) {
+		t.Errorf("expected default servers and error; got %+v", conf)
+	}
+}
+
+func TestResolvConfWatcher(t *testing.T) {
+	defer func(fn func() time.Time) { timeNow = fn }(timeNow)
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	write := func(data string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("nameserver 192.0.2.1\n", now)
+
+	var w resolvConfWatcher
+	servers := func() []string { return w.get(path).servers }
+	if got, want := servers(), []string{"192.0.2.1:53"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v; got %v", want, got)
+	}
+
+	// Changes aren't noticed until the file is checked again.
+	write("nameserver 192.0.2.2\n", now.Add(time.Second))
+	if got, want := servers(), []string{"192.0.2.1:53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+	now = now.Add(resolvConfCheckInterval)
+	if got, want := servers(), []string{"192.0.2.2:53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v; got %v", want, got)
+	}
+
+	// A removed file falls back to the default servers.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(resolvConfCheckInterval)
+	if got := servers(); !reflect.DeepEqual(got, defaultNameservers) {
+		t.Errorf("expected %v; got %v", defaultNameservers, got)
+	}
+}
+
+func TestResolvConfNameList(t *testing.T) {
+	conf := &resolvConf{search: []string{"ns.svc.cluster.local.", "svc.cluster.local."}, ndots: 2}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"foo", []string{"foo.ns.svc.cluster.local.", "foo.svc.cluster.local.", "foo."}},
+		{"foo.ns", []string{"foo.ns.ns.svc.cluster.local.", "foo.ns.svc.cluster.local.", "foo.ns."}},
+		{"www.foo.com", []string{"www.foo.com.", "www.foo.com.ns.svc.cluster.local.", "www.foo.com.svc.cluster.local."}},
+		{"foo.com.", []string{"foo.com."}},
+	}
+	for _, tt := range tests {
+		if got := conf.nameList(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v; got %v", tt.name, tt.want, got)
+		}
+	}
+}
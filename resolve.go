@@ -5,9 +5,9 @@
 package nett
 
 import (
+	"context"
 	"errors"
 	"net"
-	"sync"
 	"time"
 )
 
@@ -15,8 +15,9 @@ var (
 	ErrMissingAddress    = errors.New("missing address")
 	ErrNoSuitableAddress = errors.New("no suitable address found")
 
-	lookupIPs = net.LookupIP // used by tests
-	timeNow   = time.Now     // used by tests
+	lookupIPs       = net.LookupIP        // used by tests
+	lookupIPContext = lookupIPWithContext // used by tests
+	timeNow         = time.Now            // used by tests
 )
 
 // Resolver is an interface representing the ability to lookup the
@@ -41,61 +42,203 @@ func (defaultResolver) Resolve(host string) ([]net.IP, error) {
 	return lookupIPs(host)
 }
 
-// CacheResolver looks up the IP addresses of a host
-// and caches successful results.
-type CacheResolver struct {
-	// Resolver resolves hosts that are not cached.
-	// If Resolver is nil, DefaultResolver will be used.
-	Resolver Resolver
-	// TTL is the time to live for resolved hosts.
-	// If TTL is zero, cached hosts do not expire.
-	TTL time.Duration
+// ResolveContext looks up the given host using the local resolver.
+// It returns an array of that host's IPv4 and IPv6 addresses.
+func (defaultResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	return lookupIPContext(ctx, host)
+}
+
+func lookupIPWithContext(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// A ContextResolver is a Resolver that can use a context to cancel
+// lookups or bound them with a deadline. A Dialer passes the context
+// of each dial to its Resolver if it is a ContextResolver.
+type ContextResolver interface {
+	Resolver
 
-	mu    sync.RWMutex
-	cache map[string]*cacheItem
+	// ResolveContext looks up the given host using the provided
+	// context and returns its IP addresses.
+	ResolveContext(ctx context.Context, host string) ([]net.IP, error)
 }
 
-type cacheItem struct {
-	ips []net.IP
-	ttl time.Time
+// resolveContext looks up host with r, passing ctx to it if it is a
+// ContextResolver.
+func resolveContext(ctx context.Context, r Resolver, host string) ([]net.IP, error) {
+	if cr, ok := r.(ContextResolver); ok {
+		return cr.ResolveContext(ctx, host)
+	}
+	return r.Resolve(host)
 }
 
-// Resolve returns a host's IP addresses.
-func (r *CacheResolver) Resolve(host string) ([]net.IP, error) {
-	r.mu.RLock()
-	if item, ok := r.cache[host]; ok {
-		if item.ttl.IsZero() || timeNow().Before(item.ttl) {
-			r.mu.RUnlock()
-			ips := make([]net.IP, len(item.ips))
-			copy(ips, item.ips)
-			return ips, nil
-		}
+// withContext returns a Resolver that looks up hosts with r, or
+// DefaultResolver if r is nil, passing ctx to it if it is a
+// ContextResolver.
+func withContext(ctx context.Context, r Resolver) Resolver {
+	if r == nil {
+		r = DefaultResolver
 	}
-	r.mu.RUnlock()
+	if cr, ok := r.(ContextResolver); ok {
+		return boundResolver{ctx, cr}
+	}
+	return r
+}
+
+// boundResolver is a ContextResolver bound to a context.
+type boundResolver struct {
+	ctx context.Context
+	r   ContextResolver
+}
+
+func (r boundResolver) Resolve(host string) ([]net.IP, error) {
+	return r.r.ResolveContext(r.ctx, host)
+}
+
+// A TTLResolver is a Resolver that also reports how long the
+// addresses it returns remain valid, such as the TTL of the DNS
+// records from which they were taken.
+type TTLResolver interface {
+	Resolver
+
+	// ResolveTTL looks up the given host and returns its IP
+	// addresses and their time to live. A negative TTL means
+	// that the time to live is unknown.
+	ResolveTTL(host string) ([]net.IP, time.Duration, error)
+}
+
+// ResolveWithTTL looks up the given host with r, or DefaultResolver if
+// r is nil, and returns its IP addresses and how long they remain
+// valid, so that callers may schedule their own lookups. The time to
+// live is negative if r is not a TTLResolver or doesn't know it.
+func ResolveWithTTL(r Resolver, host string) ([]net.IP, time.Duration, error) {
+	if r == nil {
+		r = DefaultResolver
+	}
+	return resolveTTL(r, host)
+}
+
+// resolveTTL looks up host with r, returning the time to live of its
+// addresses if r is a TTLResolver, or a negative duration otherwise.
+func resolveTTL(r Resolver, host string) ([]net.IP, time.Duration, error) {
+	if t, ok := r.(TTLResolver); ok {
+		return t.ResolveTTL(host)
+	}
+	ips, err := r.Resolve(host)
+	return ips, -1, err
+}
+
+// A DetailedResult is the result of looking up a host's IPv4 and
+// IPv6 addresses separately, with the outcome of each lookup, so that
+// the failure of one family, such as broken AAAA records of a host
+// whose A records resolve, can be detected instead of hidden by the
+// addresses of the other.
+type DetailedResult struct {
+	// IPs holds the addresses of both families.
+	IPs []net.IP
+
+	// TTL is the time to live of IPs. It is negative if unknown.
+	TTL time.Duration
+
+	// IPv4Err and IPv6Err hold the errors of the lookups of each
+	// family. They are nil if the lookup succeeded, even if it
+	// found no addresses, or if the families weren't looked up
+	// separately.
+	IPv4Err, IPv6Err error
+}
+
+// Partial reports whether the lookup of one family failed while
+// addresses were found for the other.
+func (r *DetailedResult) Partial() bool {
+	return len(r.IPs) > 0 && (r.IPv4Err != nil || r.IPv6Err != nil)
+}
+
+// A DetailedResolver is a Resolver that looks up the addresses of
+// each family separately and can report the outcome of each.
+type DetailedResolver interface {
+	Resolver
+
+	// ResolveDetailed looks up the given host using the provided
+	// context. If addresses of either family are found, the error
+	// is nil and the DetailedResult records any failure of the
+	// other. Otherwise, it fails as Resolve does.
+	ResolveDetailed(ctx context.Context, host string) (*DetailedResult, error)
+}
 
-	resolver := r.Resolver
-	if resolver == nil {
-		resolver = DefaultResolver
+// ResolveDetailed looks up the given host with r, or DefaultResolver if
+// r is nil, and returns its addresses with the outcome of the lookup
+// of each family. If r is not a DetailedResolver, the errors of the
+// families are nil and the time to live is unknown.
+func ResolveDetailed(ctx context.Context, r Resolver, host string) (*DetailedResult, error) {
+	if r == nil {
+		r = DefaultResolver
 	}
-	ips, err := resolver.Resolve(host)
+	if d, ok := r.(DetailedResolver); ok {
+		return d.ResolveDetailed(ctx, host)
+	}
+	ips, err := resolveContext(ctx, r, host)
 	if err != nil {
 		return nil, err
 	}
+	return &DetailedResult{IPs: ips, TTL: -1}, nil
+}
+
+// A FamilyResolver is a Resolver that can look up the addresses of a
+// single family, such as with only an A or AAAA query. A Dialer uses
+// it to resolve hosts for family-specific networks, such as "tcp4".
+type FamilyResolver interface {
+	Resolver
+
+	// ResolveFamily looks up the given host's addresses of the
+	// family of network, which is "ip4" or "ip6", using the
+	// provided context. It returns them with their time to live,
+	// which is negative if unknown.
+	ResolveFamily(ctx context.Context, network, host string) ([]net.IP, time.Duration, error)
+}
 
-	var ttl time.Time
-	if r.TTL > 0 {
-		ttl = timeNow().Add(r.TTL)
+// familyOf returns the lookup network of the family of network, "ip4"
+// or "ip6", or "" if network isn't family-specific.
+func familyOf(network string) string {
+	nett, err := parseNetwork(network)
+	if err != nil {
+		return ""
 	}
-	item := &cacheItem{ips, ttl}
-	r.mu.Lock()
-	if r.cache == nil {
-		r.cache = make(map[string]*cacheItem)
+	switch nett[len(nett)-1] {
+	case '4':
+		return "ip4"
+	case '6':
+		return "ip6"
 	}
-	r.cache[host] = item
-	r.mu.Unlock()
+	return ""
+}
 
-	ips = make([]net.IP, len(item.ips))
-	copy(ips, item.ips)
+// forNetwork returns r, or DefaultResolver if r is nil, so that it
+// looks up only the addresses of the family of network if it is
+// family-specific and r is a FamilyResolver.
+func forNetwork(r Resolver, network string) Resolver {
+	if r == nil {
+		r = DefaultResolver
+	}
+	if fr, ok := r.(FamilyResolver); ok {
+		if family := familyOf(network); family != "" {
+			return familyResolver{fr, family}
+		}
+	}
+	return r
+}
+
+// familyResolver is a FamilyResolver bound to a family.
+type familyResolver struct {
+	r       FamilyResolver
+	network string
+}
+
+func (r familyResolver) Resolve(host string) ([]net.IP, error) {
+	return r.ResolveContext(context.Background(), host)
+}
+
+func (r familyResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	ips, _, err := r.r.ResolveFamily(ctx, r.network, host)
 	return ips, err
 }
 
@@ -112,7 +255,11 @@ func resolveAddrList(resolver Resolver, filter ipFilter, network, address string
 	}
 	switch nett {
 	case "unix", "unixgram", "unixpacket":
-		return unixList{&net.UnixAddr{Name: address, Net: nett}}, nil
+		addr, err := resolveUnixAddr(nett, address)
+		if err != nil {
+			return nil, err
+		}
+		return unixList{addr}, nil
 	}
 	return resolveInternetAddrList(resolver, filter, nett, address)
 }
@@ -169,6 +316,11 @@ func resolveInternetAddrList(resolver Resolver, filter ipFilter, network, addres
 		if err != nil {
 			return nil, err
 		}
+		if zone == "" {
+			// Link-local IPv6 addresses can't be dialed
+			// without the zone of their link.
+			ips = filterIPs(zonelessIP, ips)
+		}
 	}
 	supported := supportedIP
 	if network[len(network)-1] == '4' {
@@ -176,7 +328,7 @@ func resolveInternetAddrList(resolver Resolver, filter ipFilter, network, addres
 	} else if network[len(network)-1] == '6' || zone != "" {
 		supported = ipv6only
 	}
-	ips = filterIPs(supported, ips)
+	ips = Dedup(filterIPs(supported, ips))
 	if filter != nil {
 		ips = filter(ips)
 	}
@@ -244,7 +396,7 @@ func parsePort(network, port string) (int, error) {
 		}
 	}
 	if p < 0 || p > 0xFFFF {
-		return 0, &net.AddrError{"invalid port", port}
+		return 0, &net.AddrError{Err: "invalid port", Addr: port}
 	}
 	return p, nil
 }
@@ -461,6 +613,15 @@ func isDomainName(s string) bool {
 	return ok
 }
 
+// zonelessIP returns ip if it can be dialed without a zone.
+// Otherwise it returns nil.
+func zonelessIP(ip net.IP) net.IP {
+	if ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+		return nil
+	}
+	return ip
+}
+
 // ipv4only returns IPv4 addresses that we can use with the kernel's
 // IPv4 addressing modes. If ip is an IPv4 address, ipv4only returns ip.
 // Otherwise it returns nil.
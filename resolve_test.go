@@ -9,7 +9,6 @@ import (
 	"reflect"
 	"strings"
 	"testing"
-	"time"
 )
 
 type testAddr struct {
@@ -123,46 +122,68 @@ func TestResolveIP(t *testing.T) {
 	}
 }
 
-func TestCacheResolver(t *testing.T) {
-	defer func(lookupFn func(string) ([]net.IP, error), timeFn func() time.Time) {
-		lookupIPs = lookupFn
-		timeNow = timeFn
-	}(lookupIPs, timeNow)
-	lookups := 0
-	ips := []net.IP{net.IPv6loopback}
-	lookupIPs = func(string) ([]net.IP, error) {
-		lookups++
-		return ips, nil
+func TestResolveZone(t *testing.T) {
+	defer func(ipv4, ipv6 bool) {
+		supportsIPv4 = ipv4
+		supportsIPv6 = ipv6
+	}(supportsIPv4, supportsIPv6)
+	supportsIPv4, supportsIPv6 = true, true
+	resolver := staticResolver{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 1)}
+	tests := []struct {
+		addr string
+		want []string
+	}{
+		{"foo.com:80", []string{"[2001:db8::1]:80", "192.0.2.1:80"}},
+		{"foo.local%eth0:80", []string{"[fe80::1%eth0]:80", "[2001:db8::1%eth0]:80"}},
+		{"[fe80::2%eth0]:80", []string{"[fe80::2%eth0]:80"}},
 	}
-	start := time.Now()
-	now := start
-	ttl := time.Second
-	timeNow = func() time.Time { return now }
-	resolver := &CacheResolver{TTL: ttl}
-	validate := func(host string, expLookups int) {
-		ips0, err := resolver.Resolve(host)
+	for _, tt := range tests {
+		addrs, err := resolveAddrList(resolver, allIPs, "tcp", tt.addr)
 		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
+			t.Errorf("%s: resolveAddrList failed: %v", tt.addr, err)
+			continue
+		}
+		var got []string
+		for i := 0; i < addrs.Len(); i++ {
+			got = append(got, addrs.Addr(i))
 		}
-		if lookups != expLookups {
-			t.Fatalf("lookups: expected %d; got %d", expLookups, lookups)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v; got %v", tt.addr, tt.want, got)
+		}
+	}
+}
+
+func TestResolveFamilyBeforeFilter(t *testing.T) {
+	defer func(ipv4, ipv6 bool) {
+		supportsIPv4 = ipv4
+		supportsIPv6 = ipv6
+	}(supportsIPv4, supportsIPv6)
+	supportsIPv4, supportsIPv6 = true, true
+
+	resolver := staticResolver{net.IPv4(192, 0, 2, 1), net.ParseIP("2001:db8::1"), net.ParseIP("::ffff:192.0.2.2")}
+	tests := []struct {
+		network, address string
+		want             []net.IP
+	}{
+		{"tcp", "foo.com:80", []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 2).To4()}},
+		{"tcp4", "foo.com:80", []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.IPv4(192, 0, 2, 2).To4()}},
+		{"tcp6", "foo.com:80", []net.IP{net.ParseIP("2001:db8::1")}},
+		{"udp4", "foo.com:53", []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.IPv4(192, 0, 2, 2).To4()}},
+		{"udp6", "foo.com:53", []net.IP{net.ParseIP("2001:db8::1")}},
+		{"ip4:icmp", "foo.com", []net.IP{net.IPv4(192, 0, 2, 1).To4(), net.IPv4(192, 0, 2, 2).To4()}},
+	}
+	for _, tt := range tests {
+		var got []net.IP
+		filter := func(ips []net.IP) []net.IP {
+			got = append([]net.IP(nil), ips...)
+			return ips
 		}
-		if !reflect.DeepEqual(ips, ips0) {
-			t.Fatalf("ips: expected %v; got %v", ips, ips0)
+		if _, err := resolveAddrList(resolver, filter, tt.network, tt.address); err != nil {
+			t.Errorf("%s: resolveAddrList failed: %v", tt.network, err)
+			continue
 		}
-		ips0[0] = nil
-		if reflect.DeepEqual(ips, ips0) {
-			t.Fatal("ips: expected copy; got same")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected the filter to be given %v; got %v", tt.network, tt.want, got)
 		}
 	}
-	validate("foo.com", 1)       // lookup foo.com
-	now = start.Add(ttl / 2)     //
-	validate("bar.net", 2)       // lookup bar.net
-	validate("foo.com", 2)       // cached
-	now = start.Add(ttl)         // expire foo.com
-	validate("foo.com", 3)       // lookup foo.com
-	validate("bar.net", 3)       // cached
-	now = start.Add(ttl + ttl/2) // expire bar.net
-	validate("foo.com", 3)       // cached
-	validate("bar.net", 4)       // lookup bar.net
 }
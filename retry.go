@@ -0,0 +1,103 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// A RetryPolicy controls how a Dialer retries failed dials.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a dial is
+	// attempted, including the first attempt. If it is less
+	// than two, failed dials are not retried.
+	MaxAttempts int
+
+	// Backoff returns the delay before the given retry,
+	// numbered from one.
+	//
+	// If nil, ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	// is used.
+	Backoff func(retry int) time.Duration
+
+	// Retryable reports whether a dial that failed with err
+	// should be retried.
+	//
+	// If nil, IsTransient is used.
+	Retryable func(err error) bool
+}
+
+var defaultBackoff = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(retry)
+	}
+	return defaultBackoff(retry)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// dial calls dial until it succeeds, fails with an error that
+// is not retryable, the attempts are exhausted, or ctx is done.
+// It returns the result of the last call to dial.
+func (p *RetryPolicy) dial(ctx context.Context, dial func() (net.Conn, error)) (net.Conn, error) {
+	for retry := 1; ; retry++ {
+		c, err := dial()
+		if err == nil || retry >= p.MaxAttempts || !p.retryable(err) {
+			return c, err
+		}
+		t := time.NewTimer(p.backoff(retry))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+	}
+}
+
+// ExponentialBackoff returns a backoff function whose delay before
+// each retry is chosen at random between zero and base doubled for
+// each preceding retry, not exceeding max.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		if base <= 0 || max <= 0 {
+			return 0
+		}
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max || d <= 0 {
+			d = max
+		}
+		return time.Duration(rand.Int63n(int64(d)))
+	}
+}
+
+// IsTransient reports whether err is a dial error that may succeed
+// if retried: the connection was refused, reset or timed out.
+// Cancellation and expiry of the dial's own context are not
+// transient.
+func IsTransient(err error) bool {
+	if errors.Is(err, errCanceled) || errors.Is(err, errTimeout) {
+		return false
+	}
+	if transientErrno(err) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
@@ -0,0 +1,26 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// transientErrno reports whether err is caused by the connection being
+// refused, reset or timing out. Plan 9 has no errno values for refused
+// or reset connections, so their messages are matched instead.
+func transientErrno(err error) bool {
+	if errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+	var serr syscall.ErrorString
+	if !errors.As(err, &serr) {
+		return false
+	}
+	msg := string(serr)
+	return strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset")
+}
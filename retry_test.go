@@ -0,0 +1,101 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDialRetry(t *testing.T) {
+	port := refusedPort(t)
+	var attempts int
+	d := &Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			attempts++
+			return nil
+		},
+		Retry: &RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     func(int) time.Duration { return 0 },
+		},
+	}
+	if _, err := d.Dial("tcp", "127.0.0.1:"+port); !isConnRefused(err) {
+		t.Fatalf("expected connection refused; got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts: expected 3; got %d", attempts)
+	}
+
+	attempts = 0
+	d.Retry.Retryable = func(error) bool { return false }
+	d.Dial("tcp", "127.0.0.1:"+port)
+	if attempts != 1 {
+		t.Errorf("attempts: expected 1; got %d", attempts)
+	}
+}
+
+func TestDialRetryCanceled(t *testing.T) {
+	port := refusedPort(t)
+	d := &Dialer{
+		Retry: &RetryPolicy{
+			MaxAttempts: 100,
+			Backoff:     func(int) time.Duration { return time.Hour },
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.DialContext(ctx, "tcp", "127.0.0.1:"+port)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !isConnRefused(err) {
+			t.Fatalf("expected connection refused; got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retries were not abandoned when the context expired")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+	for retry, max := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if retry == 0 {
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if d := backoff(retry); d < 0 || d >= max {
+				t.Fatalf("retry %d: expected delay in [0, %v); got %v", retry, max, d)
+			}
+		}
+	}
+	if d := backoff(1000); d < 0 || d >= 10*time.Second {
+		t.Fatalf("retry 1000: expected delay in [0, 10s); got %v", d)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		transient bool
+	}{
+		{&net.OpError{Op: "dial", Err: errConnRefused}, true},
+		{&net.OpError{Op: "dial", Err: syscall.ETIMEDOUT}, true},
+		{&net.OpError{Op: "dial", Err: errTimeout}, false},
+		{&net.OpError{Op: "dial", Err: errCanceled}, false},
+		{&net.OpError{Op: "dial", Err: ErrNoSuitableAddress}, false},
+		{&DialError{Net: "tcp", Attempts: []*AttemptError{{"a", &net.OpError{Op: "dial", Err: errConnRefused}}}}, true},
+	} {
+		if transient := IsTransient(tt.err); transient != tt.transient {
+			t.Errorf("%v: expected %v; got %v", tt.err, tt.transient, transient)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// RouteResolver resolves hosts with a resolver chosen by their name,
+// for split DNS, such as resolving the hosts of an internal domain
+// with its own DoTResolver and others with DefaultResolver.
+// It satisfies TTLResolver.
+type RouteResolver struct {
+	// Routes maps name patterns to the resolvers for hosts
+	// that match them. A pattern is either a name, matching
+	// only that host, or "*." followed by a name, matching
+	// any of its subdomains but not the name itself. The
+	// pattern "*" matches any host. Patterns are matched in
+	// lower case, without a trailing dot, and the most
+	// specific pattern that matches is used.
+	//
+	// Routes must not be modified while the RouteResolver
+	// is in use.
+	Routes map[string]Resolver
+
+	// Resolver resolves hosts that match none of the routes.
+	// If Resolver is nil, DefaultResolver will be used.
+	Resolver Resolver
+}
+
+// Resolve looks up the given host and returns its IP addresses.
+func (r *RouteResolver) Resolve(host string) ([]net.IP, error) {
+	return r.route(host).Resolve(host)
+}
+
+// ResolveTTL looks up the given host and returns its IP addresses and
+// their time to live, if the resolver it is routed to reports it.
+func (r *RouteResolver) ResolveTTL(host string) ([]net.IP, time.Duration, error) {
+	return resolveTTL(r.route(host), host)
+}
+
+// ResolveContext looks up the given host using the provided context
+// and returns its IP addresses.
+func (r *RouteResolver) ResolveContext(ctx context.Context, host string) ([]net.IP, error) {
+	return resolveContext(ctx, r.route(host), host)
+}
+
+// route returns the resolver for host.
+func (r *RouteResolver) route(host string) Resolver {
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if resolver, ok := r.Routes[name]; ok {
+		return resolver
+	}
+	for {
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+		if resolver, ok := r.Routes["*."+name]; ok {
+			return resolver
+		}
+	}
+	if resolver, ok := r.Routes["*"]; ok {
+		return resolver
+	}
+	if r.Resolver != nil {
+		return r.Resolver
+	}
+	return DefaultResolver
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestRouteResolver(t *testing.T) {
+	var (
+		exact    = staticResolver{net.IPv4(192, 0, 2, 1)}
+		corp     = staticResolver{net.IPv4(192, 0, 2, 2)}
+		dev      = staticResolver{net.IPv4(192, 0, 2, 3)}
+		fallback = staticResolver{net.IPv4(192, 0, 2, 4)}
+	)
+	r := &RouteResolver{
+		Routes: map[string]Resolver{
+			"corp.example":       exact,
+			"*.corp.example":     corp,
+			"*.dev.corp.example": dev,
+		},
+		Resolver: fallback,
+	}
+	tests := []struct {
+		host string
+		want staticResolver
+	}{
+		{"corp.example", exact},
+		{"CORP.example.", exact},
+		{"www.corp.example", corp},
+		{"a.b.corp.example", corp},
+		{"dev.corp.example", corp},
+		{"api.dev.corp.example", dev},
+		{"corp.example.com", fallback},
+		{"example", fallback},
+	}
+	for _, tt := range tests {
+		ips, err := r.Resolve(tt.host)
+		if err != nil {
+			t.Errorf("%q: Resolve failed: %v", tt.host, err)
+			continue
+		}
+		if want := []net.IP(tt.want); !reflect.DeepEqual(ips, want) {
+			t.Errorf("%q: expected %v; got %v", tt.host, want, ips)
+		}
+	}
+
+	r.Routes["*"] = dev
+	if ips, _ := r.Resolve("example.com"); !reflect.DeepEqual(ips, []net.IP(dev)) {
+		t.Errorf("expected wildcard route %v; got %v", dev, ips)
+	}
+}
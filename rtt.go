@@ -0,0 +1,140 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// An RTTTable records the time it took to connect to IP addresses,
+// so that repeated dials converge on the fastest addresses of a host
+// without actively probing them, as a LatencySorter does. When it is
+// the RTTs of a Dialer, each of the Dialer's connections is recorded.
+//
+// Times are smoothed, so that a single slow connection decays rather
+// than reordering the addresses.
+//
+// An RTTTable is safe for concurrent use by multiple goroutines.
+type RTTTable struct {
+	// MaxEntries is the maximum number of addresses recorded,
+	// after which the least recently recorded address is
+	// forgotten. If zero, 256 is used.
+	MaxEntries int
+
+	mu    sync.Mutex
+	addrs map[string]*rttEntry // by IP address
+	lru   *list.List           // of keys, most recently recorded first
+}
+
+type rttEntry struct {
+	rtt  time.Duration // smoothed connect time
+	elem *list.Element // position in RTTTable.lru
+}
+
+func (t *RTTTable) maxEntries() int {
+	if t.MaxEntries > 0 {
+		return t.MaxEntries
+	}
+	return 256
+}
+
+// Observe records that connecting to ip took d.
+func (t *RTTTable) Observe(ip net.IP, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := ip.String()
+	if e, ok := t.addrs[key]; ok {
+		e.rtt += time.Duration(latencyWeight * float64(d-e.rtt))
+		t.lru.MoveToFront(e.elem)
+		return
+	}
+	if t.addrs == nil {
+		t.addrs = make(map[string]*rttEntry)
+		t.lru = list.New()
+	}
+	for len(t.addrs) >= t.maxEntries() {
+		elem := t.lru.Back()
+		t.lru.Remove(elem)
+		delete(t.addrs, elem.Value.(string))
+	}
+	t.addrs[key] = &rttEntry{rtt: d, elem: t.lru.PushFront(key)}
+}
+
+// RTT returns the smoothed connect time of ip and whether it is known.
+func (t *RTTTable) RTT(ip net.IP) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.addrs[ip.String()]
+	if !ok {
+		return 0, false
+	}
+	return e.rtt, true
+}
+
+// Len returns the number of addresses recorded.
+func (t *RTTTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.addrs)
+}
+
+// dial returns fn wrapped to record the connect times of its
+// successful attempts.
+func (t *RTTTable) dial(fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		start := time.Now()
+		c, err := fn(ctx, network, address)
+		if err == nil {
+			if ip := net.ParseIP(hostOf(address)); ip != nil {
+				t.Observe(ip, time.Since(start))
+			}
+		}
+		return c, err
+	}
+}
+
+// SortByObservedLatency returns a filter that orders addresses by
+// their connect times recorded in t, preserving the order of
+// addresses with equal times. Addresses that have not been recorded
+// come last, in their original order.
+//
+// Only successful connections are recorded, so the table learns the
+// fastest address of a host when a Dialer races several of them, as
+// it does for TCP unless HappyEyeballs is set.
+func SortByObservedLatency(t *RTTTable) func(ips []net.IP) []net.IP {
+	return func(ips []net.IP) []net.IP {
+		type entry struct {
+			ip  net.IP
+			rtt time.Duration
+			ok  bool
+		}
+		entries := make([]entry, len(ips))
+		t.mu.Lock()
+		for i, ip := range ips {
+			e := entry{ip: ip}
+			if r, ok := t.addrs[ip.String()]; ok {
+				e.rtt, e.ok = r.rtt, true
+			}
+			entries[i] = e
+		}
+		t.mu.Unlock()
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].ok != entries[j].ok {
+				return entries[i].ok
+			}
+			return entries[i].rtt < entries[j].rtt
+		})
+		a := make([]net.IP, len(entries))
+		for i, e := range entries {
+			a[i] = e.ip
+		}
+		return a
+	}
+}
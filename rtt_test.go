@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRTTTable(t *testing.T) {
+	a, b, c, d := parseIPs("10.0.0.1")[0], parseIPs("10.0.0.2")[0], parseIPs("10.0.0.3")[0], parseIPs("10.0.0.4")[0]
+	table := &RTTTable{MaxEntries: 3}
+	table.Observe(a, 40*time.Millisecond)
+	table.Observe(b, 10*time.Millisecond)
+	table.Observe(c, 20*time.Millisecond)
+	table.Observe(b, 50*time.Millisecond)
+	if rtt, ok := table.RTT(b); !ok || rtt != 20*time.Millisecond {
+		t.Errorf("RTT: expected 20ms; got %v", rtt)
+	}
+	filter := SortByObservedLatency(table)
+	if ips, want := filter([]net.IP{d, a, b, c}), []net.IP{b, c, a, d}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+
+	table.Observe(d, 30*time.Millisecond) // evict a
+	if _, ok := table.RTT(a); ok || table.Len() != 3 {
+		t.Errorf("expected the least recently recorded address to be evicted")
+	}
+	if ips, want := filter([]net.IP{a, b, c, d}), []net.IP{b, c, d, a}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("expected %v; got %v", want, ips)
+	}
+}
+
+func TestDialRTTs(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	dead, live := parseIPs("127.0.0.2")[0], parseIPs("127.0.0.1")[0]
+	d := &Dialer{
+		Resolver: staticResolver{dead, live},
+		IPFilter: func(ips []net.IP) []net.IP { return ips },
+		RTTs:     &RTTTable{},
+	}
+	c, err := d.Dial("tcp", "foo.com:"+port)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+	if _, ok := d.RTTs.RTT(live); !ok {
+		t.Errorf("expected the connect time of %v to be recorded", live)
+	}
+	if _, ok := d.RTTs.RTT(dead); ok {
+		t.Errorf("expected the connect time of %v not to be recorded", dead)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+
+	socks5PasswordVersion = 0x01
+
+	socks5Connect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+var socks5Replies = [...]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5Proxy is a SOCKS version 5 proxy server, as described
+// in RFC 1928.
+type SOCKS5Proxy struct {
+	// Network is the network of the proxy server.
+	// If empty, "tcp" is used.
+	Network string
+
+	// Address is the address of the proxy server.
+	Address string
+
+	// Username and Password authenticate with the proxy server
+	// as described in RFC 1929. If Username is empty, no
+	// authentication is used.
+	Username string
+	Password string
+}
+
+// ProxyAddr returns the network and address of the proxy server.
+func (p *SOCKS5Proxy) ProxyAddr() (network, address string) {
+	if p.Network == "" {
+		return "tcp", p.Address
+	}
+	return p.Network, p.Address
+}
+
+// Tunnel asks the proxy server connected to c to connect to address.
+// If the host of address is not a literal IP address it is sent to
+// the proxy server to be resolved.
+func (p *SOCKS5Proxy) Tunnel(ctx context.Context, c net.Conn, network, address string) (net.Conn, error) {
+	if err := p.connect(c, network, address); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *SOCKS5Proxy) connect(c net.Conn, network, address string) error {
+	host, portstr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := parsePort(network, portstr)
+	if err != nil {
+		return err
+	}
+	// A zone only identifies an interface of this host, so it can't
+	// be sent to the proxy server, and it isn't a domain name.
+	if ip, zone := splitHostZone(host); zone != "" && net.ParseIP(ip) != nil {
+		return errors.New("socks5: zoned IPv6 address " + host + " can't be proxied")
+	}
+	if err := p.authenticate(c); err != nil {
+		return err
+	}
+
+	b := []byte{socks5Version, socks5Connect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("socks5: host name too long")
+		}
+		b = append(b, socks5AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		b = append(b, socks5AddrIPv4)
+		b = append(b, ip4...)
+	} else {
+		b = append(b, socks5AddrIPv6)
+		b = append(b, ip...)
+	}
+	b = append(b, byte(port>>8), byte(port))
+	if _, err := c.Write(b); err != nil {
+		return err
+	}
+
+	// Read the reply and discard the bound address.
+	if _, err := io.ReadFull(c, b[:4]); err != nil {
+		return err
+	}
+	if b[0] != socks5Version {
+		return errors.New("socks5: unexpected protocol version " + strconv.Itoa(int(b[0])))
+	}
+	if rep := int(b[1]); rep != 0 {
+		if rep < len(socks5Replies) && socks5Replies[rep] != "" {
+			return errors.New("socks5: " + socks5Replies[rep])
+		}
+		return errors.New("socks5: unknown reply code " + strconv.Itoa(rep))
+	}
+	var n int
+	switch b[3] {
+	case socks5AddrIPv4:
+		n = net.IPv4len
+	case socks5AddrIPv6:
+		n = net.IPv6len
+	case socks5AddrDomain:
+		if _, err := io.ReadFull(c, b[:1]); err != nil {
+			return err
+		}
+		n = int(b[0])
+	default:
+		return errors.New("socks5: unknown address type " + strconv.Itoa(int(b[3])))
+	}
+	_, err = io.CopyN(io.Discard, c, int64(n+2))
+	return err
+}
+
+// authenticate negotiates an authentication method with the proxy
+// server connected to c and authenticates if required.
+func (p *SOCKS5Proxy) authenticate(c net.Conn) error {
+	b := []byte{socks5Version, 1, socks5AuthNone}
+	if p.Username != "" {
+		b = []byte{socks5Version, 2, socks5AuthNone, socks5AuthPassword}
+	}
+	if _, err := c.Write(b); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(c, b[:2]); err != nil {
+		return err
+	}
+	if b[0] != socks5Version {
+		return errors.New("socks5: unexpected protocol version " + strconv.Itoa(int(b[0])))
+	}
+	switch b[1] {
+	case socks5AuthNone:
+		return nil
+	case socks5AuthPassword:
+		if p.Username == "" {
+			break
+		}
+		if len(p.Username) > 255 || len(p.Password) > 255 {
+			return errors.New("socks5: username or password too long")
+		}
+		b = []byte{socks5PasswordVersion, byte(len(p.Username))}
+		b = append(b, p.Username...)
+		b = append(b, byte(len(p.Password)))
+		b = append(b, p.Password...)
+		if _, err := c.Write(b); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c, b[:2]); err != nil {
+			return err
+		}
+		if b[0] != socks5PasswordVersion {
+			return errors.New("socks5: unexpected username/password authentication version " + strconv.Itoa(int(b[0])))
+		}
+		if b[1] != 0 {
+			return errors.New("socks5: username/password authentication failed")
+		}
+		return nil
+	}
+	return errors.New("socks5: no acceptable authentication methods")
+}
@@ -0,0 +1,237 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// socks5Server is a minimal SOCKS5 proxy server that resolves
+// every domain name to 127.0.0.1.
+type socks5Server struct {
+	ln                 net.Listener
+	username, password string
+
+	mu    sync.Mutex
+	hosts []string // requested hosts
+}
+
+func newSOCKS5Server(t *testing.T, username, password string) *socks5Server {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{ln: ln, username: username, password: password}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) close() { s.ln.Close() }
+
+func (s *socks5Server) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hosts
+}
+
+func (s *socks5Server) serve(c net.Conn) {
+	defer c.Close()
+	b := make([]byte, 512)
+	if _, err := io.ReadFull(c, b[:2]); err != nil {
+		return
+	}
+	methods := b[2 : 2+int(b[1])]
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return
+	}
+	want := byte(socks5AuthNone)
+	if s.username != "" {
+		want = socks5AuthPassword
+	}
+	ok := false
+	for _, m := range methods {
+		ok = ok || m == want
+	}
+	if !ok {
+		c.Write([]byte{socks5Version, 0xff})
+		return
+	}
+	c.Write([]byte{socks5Version, want})
+	if want == socks5AuthPassword {
+		if _, err := io.ReadFull(c, b[:2]); err != nil {
+			return
+		}
+		user := make([]byte, b[1])
+		io.ReadFull(c, user)
+		io.ReadFull(c, b[:1])
+		pass := make([]byte, b[0])
+		io.ReadFull(c, pass)
+		if string(user) != s.username || string(pass) != s.password {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+	}
+
+	if _, err := io.ReadFull(c, b[:4]); err != nil {
+		return
+	}
+	var host string
+	switch b[3] {
+	case socks5AddrIPv4:
+		io.ReadFull(c, b[:net.IPv4len])
+		host = net.IP(b[:net.IPv4len]).String()
+	case socks5AddrIPv6:
+		io.ReadFull(c, b[:net.IPv6len])
+		host = net.IP(b[:net.IPv6len]).String()
+	case socks5AddrDomain:
+		io.ReadFull(c, b[:1])
+		name := make([]byte, b[0])
+		io.ReadFull(c, name)
+		host = string(name)
+	}
+	io.ReadFull(c, b[:2])
+	port := int(b[0])<<8 | int(b[1])
+	s.mu.Lock()
+	s.hosts = append(s.hosts, host)
+	s.mu.Unlock()
+
+	if ip := net.ParseIP(host); ip == nil {
+		host = "127.0.0.1"
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		c.Write([]byte{socks5Version, 0x05, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	c.Write([]byte{socks5Version, 0, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	go io.Copy(target, c)
+	io.Copy(c, target)
+}
+
+// newHelloServer returns a listener that writes "hello" to every
+// accepted connection and closes it.
+func newHelloServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Write([]byte("hello"))
+			c.Close()
+		}
+	}()
+	return ln
+}
+
+func readHello(t *testing.T, c net.Conn) {
+	defer c.Close()
+	b, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("expected %q; got %q", "hello", b)
+	}
+}
+
+func TestDialSOCKS5(t *testing.T) {
+	target := newHelloServer(t)
+	defer target.Close()
+	_, port, _ := net.SplitHostPort(target.Addr().String())
+
+	for _, tt := range []struct {
+		resolve            bool
+		username, password string
+		host               string
+	}{
+		{false, "", "", "127.0.0.1"},
+		{true, "", "", "example.com"},
+		{true, "user", "pass", "example.com"},
+	} {
+		s := newSOCKS5Server(t, tt.username, tt.password)
+		d := &Dialer{
+			Resolver:     staticResolver{net.IPv4(127, 0, 0, 1)},
+			Proxy:        &SOCKS5Proxy{Address: s.ln.Addr().String(), Username: tt.username, Password: tt.password},
+			ProxyResolve: tt.resolve,
+		}
+		c, err := d.Dial("tcp", "example.com:"+port)
+		if err != nil {
+			s.close()
+			t.Fatalf("Dial failed: %v", err)
+		}
+		readHello(t, c)
+		if hosts := s.requested(); len(hosts) != 1 || hosts[0] != tt.host {
+			t.Errorf("requested hosts: expected [%s]; got %v", tt.host, hosts)
+		}
+		s.close()
+	}
+}
+
+func TestDialSOCKS5Errors(t *testing.T) {
+	s := newSOCKS5Server(t, "user", "pass")
+	defer s.close()
+	d := &Dialer{
+		Proxy:        &SOCKS5Proxy{Address: s.ln.Addr().String(), Username: "user", Password: "wrong"},
+		ProxyResolve: true,
+	}
+	if _, err := d.Dial("tcp", "example.com:80"); err == nil {
+		t.Error("expected authentication error")
+	}
+	d.Proxy = &SOCKS5Proxy{Address: s.ln.Addr().String()}
+	if _, err := d.Dial("tcp", "example.com:80"); err == nil {
+		t.Error("expected authentication method error")
+	}
+	if _, err := d.Dial("udp", "example.com:80"); err == nil || err.(*net.OpError).Err != errProxyNetwork {
+		t.Errorf("expected %v; got %v", errProxyNetwork, err)
+	}
+}
+
+func TestSOCKS5PasswordVersion(t *testing.T) {
+	c, s := net.Pipe()
+	defer c.Close()
+	go func() {
+		defer s.Close()
+		b := make([]byte, 512)
+		s.Read(b) // methods
+		s.Write([]byte{socks5Version, socks5AuthPassword})
+		s.Read(b) // username and password
+		s.Write([]byte{socks5Version, 0})
+	}()
+	p := &SOCKS5Proxy{Username: "user", Password: "pass"}
+	if err := p.connect(c, "tcp", "example.com:80"); err == nil {
+		t.Error("expected authentication version error")
+	}
+}
+
+func TestSOCKS5Zone(t *testing.T) {
+	c, s := net.Pipe()
+	defer c.Close()
+	s.Close()
+	p := &SOCKS5Proxy{}
+	err := p.connect(c, "tcp", "[fe80::1%eth0]:80")
+	if err == nil || !strings.Contains(err.Error(), "zoned") {
+		t.Errorf("expected zoned address error; got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// A Tracer starts spans for the stages of a dial, such as those of
+// OpenTelemetry. It is an interface, rather than an OpenTelemetry
+// type, so that nett doesn't depend on a tracing library; an adapter
+// may start spans with an OpenTelemetry trace.Tracer and convert the
+// attributes with attribute.String and attribute.Int.
+//
+// A Tracer must be safe for concurrent use by multiple goroutines.
+type Tracer interface {
+	// Start starts a span with the given name as a child of the
+	// span in ctx, if any, and returns a context containing it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span, whose value is
+	// a string or an int.
+	SetAttribute(key string, value any)
+
+	// RecordError records that the stage of the span failed.
+	RecordError(err error)
+
+	// End ends the span.
+	End()
+}
+
+// The names of the spans started by a Dialer with a Tracer.
+//
+// A "nett.Dial" span covers each dial, with "server.address" and
+// "network.transport" attributes holding the address and network
+// being dialed. Within it, a "nett.Resolve" span covers resolving its
+// host name, with "server.address" and "nett.addresses" attributes
+// holding the host name and the number of addresses it resolved to;
+// a "nett.Filter" span covers selecting the addresses to be dialed,
+// with "nett.addresses.in" and "nett.addresses.out" attributes; and a
+// "nett.Connect" span covers each attempt to connect to an address,
+// with "network.peer.address", "network.type" ("ipv4" or "ipv6") and
+// "nett.attempt" (numbered from one) attributes.
+const (
+	SpanDial    = "nett.Dial"
+	SpanResolve = "nett.Resolve"
+	SpanFilter  = "nett.Filter"
+	SpanConnect = "nett.Connect"
+)
+
+// endSpan records err, if any, and ends span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// spanResolver is a Resolver that starts a span for each lookup,
+// passing its context to r if it is a ContextResolver.
+type spanResolver struct {
+	ctx    context.Context
+	tracer Tracer
+	r      Resolver
+}
+
+// newSpanResolver returns r, or DefaultResolver if it is nil, wrapped to
+// start a span for each lookup as a child of the span in ctx.
+func newSpanResolver(ctx context.Context, tracer Tracer, r Resolver) Resolver {
+	if r == nil {
+		r = DefaultResolver
+	}
+	return spanResolver{ctx, tracer, r}
+}
+
+func (r spanResolver) Resolve(host string) ([]net.IP, error) {
+	ctx, span := r.tracer.Start(r.ctx, SpanResolve)
+	span.SetAttribute("server.address", host)
+	ips, err := resolveContext(ctx, r.r, host)
+	span.SetAttribute("nett.addresses", len(ips))
+	endSpan(span, err)
+	return ips, err
+}
+
+// spanFilter returns filter wrapped to start a span for selecting
+// addresses as a child of the span in ctx.
+func spanFilter(ctx context.Context, tracer Tracer, filter ipFilter) ipFilter {
+	return func(ips []net.IP) []net.IP {
+		_, span := tracer.Start(ctx, SpanFilter)
+		span.SetAttribute("nett.addresses.in", len(ips))
+		ips = filter(ips)
+		span.SetAttribute("nett.addresses.out", len(ips))
+		span.End()
+		return ips
+	}
+}
+
+// spanDial returns fn wrapped to start a span for each attempt.
+func spanDial(tracer Tracer, fn dialFunc) dialFunc {
+	var attempts atomic.Int64
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		ctx, span := tracer.Start(ctx, SpanConnect)
+		span.SetAttribute("network.peer.address", hostOf(address))
+		switch familyNetwork(network, address) {
+		case "tcp4", "udp4", "ip4":
+			span.SetAttribute("network.type", "ipv4")
+		case "tcp6", "udp6", "ip6":
+			span.SetAttribute("network.type", "ipv6")
+		}
+		span.SetAttribute("nett.attempt", int(attempts.Add(1)))
+		c, err := fn(ctx, network, address)
+		endSpan(span, err)
+		return c, err
+	}
+}
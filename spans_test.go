@@ -0,0 +1,109 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// testSpan is a span recorded by a spanRecorder.
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+// spanRecorder is a Tracer that records the spans it starts.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (r *spanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*testSpan)
+	s := &testSpan{name: name, parent: parent, attrs: make(map[string]any)}
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func TestDialerTracer(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	var rec spanRecorder
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)},
+		IPFilter: allIPs,
+		Tracer:   &rec,
+		// Dial the addresses serially.
+		HappyEyeballs: true,
+	}
+	ctx, root := rec.Start(context.Background(), "root")
+	c, err := d.DialContext(ctx, "tcp", net.JoinHostPort("foo.com", port))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+
+	var names []string
+	for _, s := range rec.spans[1:] {
+		names = append(names, s.name)
+		if !s.ended {
+			t.Errorf("%s: span not ended", s.name)
+		}
+	}
+	if want := []string{SpanDial, SpanResolve, SpanFilter, SpanConnect, SpanConnect}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected spans %v; got %v", want, names)
+	}
+	dial := rec.spans[1]
+	if dial.parent != root.(*testSpan) || dial.attrs["server.address"] != net.JoinHostPort("foo.com", port) || dial.attrs["network.transport"] != "tcp" || dial.err != nil {
+		t.Errorf("unexpected dial span: %+v", dial)
+	}
+	resolve, filter := rec.spans[2], rec.spans[3]
+	if resolve.parent != dial || resolve.attrs["server.address"] != "foo.com" || resolve.attrs["nett.addresses"] != 2 {
+		t.Errorf("unexpected resolve span: %+v", resolve)
+	}
+	if filter.parent != dial || filter.attrs["nett.addresses.in"] != 2 || filter.attrs["nett.addresses.out"] != 2 {
+		t.Errorf("unexpected filter span: %+v", filter)
+	}
+	for i, s := range rec.spans[4:] {
+		if s.parent != dial || s.attrs["network.type"] != "ipv4" || s.attrs["nett.attempt"] != i+1 {
+			t.Errorf("unexpected connect span: %+v", s)
+		}
+	}
+	if s := rec.spans[4]; s.attrs["network.peer.address"] != "127.0.0.2" || s.err == nil {
+		t.Errorf("expected a failed attempt to 127.0.0.2; got %+v", s)
+	}
+	if s := rec.spans[5]; s.attrs["network.peer.address"] != "127.0.0.1" || s.err != nil {
+		t.Errorf("expected a successful attempt to 127.0.0.1; got %+v", s)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var lookupSRV = net.DefaultResolver.LookupSRV // used by tests
+
+// srvPrefix marks an address as the name of SRV records to look up.
+const srvPrefix = "srv+"
+
+var errNoSRVTargets = errors.New("service not available")
+
+// ErrUnsupportedLookup is returned when records other than addresses
+// are looked up with a Resolver that can't look them up, such as SRV
+// records with a Resolver that is not an SRVResolver.
+var ErrUnsupportedLookup = errors.New("lookup not supported by resolver")
+
+// An SRVResolver is a Resolver that can also look up SRV records.
+type SRVResolver interface {
+	Resolver
+
+	// ResolveSRV looks up the SRV records with the given name,
+	// such as "_grpc._tcp.example.com", using the provided
+	// context. The records are sorted by priority and randomized
+	// by weight within a priority.
+	ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error)
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// local resolver.
+func (defaultResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, addrs, err := lookupSRV(ctx, "", "", name)
+	return addrs, err
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// underlying Resolver, which must be an SRVResolver. The records are
+// cached and their lookups coalesced like those of hosts, for TTL or
+// NegativeTTL, and each lookup returns them randomized by weight
+// anew. Like Resolve, lookups are not canceled by ctx, since they may
+// be shared by concurrent callers.
+func (r *CacheResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	item, err := r.get("SRV "+name, name, func() (*cacheItem, time.Duration, error) {
+		addrs, err := resolveSRV(context.Background(), r.Resolver, name)
+		return &cacheItem{srvs: addrs}, r.clampTTL(r.defaultTTL()), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]*net.SRV, len(item.srvs))
+	for i, addr := range item.srvs {
+		a := *addr
+		addrs[i] = &a
+	}
+	SortSRV(addrs)
+	return addrs, nil
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context with the first of the resolvers that succeeds, in
+// turn even if Concurrent is set. Resolvers that are not SRVResolvers
+// are skipped.
+func (r *ChainResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	var addrs []*net.SRV
+	err := r.firstLookup(name, func(resolver Resolver) (err error) {
+		addrs, err = resolveSRV(ctx, resolver, name)
+		return err
+	})
+	return addrs, err
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context with the resolver the name is routed to.
+func (r *RouteResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	return resolveSRV(ctx, r.route(name), name)
+}
+
+// ResolveSRV looks up the SRV records with the given name using the
+// provided context with the underlying Resolver, since the table only
+// holds addresses.
+func (r *HostsResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	return resolveSRV(ctx, r.Resolver, name)
+}
+
+// resolveSRV looks up the SRV records with the given name with r, or
+// DefaultResolver if r is nil, failing with ErrUnsupportedLookup if it
+// is not an SRVResolver.
+func resolveSRV(ctx context.Context, r Resolver, name string) ([]*net.SRV, error) {
+	if r == nil {
+		r = DefaultResolver
+	}
+	sr, ok := r.(SRVResolver)
+	if !ok {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrUnsupportedLookup)
+	}
+	return sr.ResolveSRV(ctx, name)
+}
+
+// dialTarget connects to the address, or if it has the form
+// "srv+name", to the targets of the SRV records with the name,
+// in order, until one succeeds.
+func (d *Dialer) dialTarget(ctx context.Context, network, address string) (net.Conn, error) {
+	name, ok := strings.CutPrefix(address, srvPrefix)
+	if !ok {
+		return d.dialOnce(ctx, network, address)
+	}
+	addrs, err := resolveSRV(ctx, d.Resolver, name)
+	if err == nil && (len(addrs) == 0 || (len(addrs) == 1 && addrs[0].Target == ".")) {
+		err = errNoSRVTargets
+	}
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	for _, addr := range addrs {
+		target := net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port)))
+		var c net.Conn
+		if c, err = d.dialOnce(ctx, network, target); err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// SortSRV sorts addrs by priority and randomizes them by weight within
+// a priority, as specified by RFC 2782, for SRVResolvers whose records
+// aren't already sorted.
+func SortSRV(addrs []*net.SRV) {
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return addrs[i].Priority < addrs[j].Priority
+	})
+	for i := 0; i < len(addrs); {
+		j := i + 1
+		for j < len(addrs) && addrs[j].Priority == addrs[i].Priority {
+			j++
+		}
+		shuffleByWeight(addrs[i:j])
+		i = j
+	}
+}
+
+func shuffleByWeight(addrs []*net.SRV) {
+	sum := 0
+	for _, addr := range addrs {
+		sum += int(addr.Weight)
+	}
+	for sum > 0 && len(addrs) > 1 {
+		s := 0
+		n := rand.Intn(sum)
+		for i := range addrs {
+			s += int(addrs[i].Weight)
+			if s > n {
+				if i > 0 {
+					addrs[0], addrs[i] = addrs[i], addrs[0]
+				}
+				break
+			}
+		}
+		sum -= int(addrs[0].Weight)
+		addrs = addrs[1:]
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// srvResolver resolves every host to the loopback address
+// and every SRV name to its records.
+type srvResolver []*net.SRV
+
+func (srvResolver) Resolve(host string) ([]net.IP, error) {
+	return []net.IP{net.IPv4(127, 0, 0, 1)}, nil
+}
+
+func (r srvResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	return r, nil
+}
+
+func TestDialSRV(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	refused, _ := strconv.Atoi(refusedPort(t))
+
+	d := &Dialer{Resolver: srvResolver{
+		{Target: "a.example.com.", Port: uint16(refused), Priority: 1},
+		{Target: "b.example.com.", Port: uint16(p), Priority: 2},
+	}}
+	c, err := d.Dial("tcp", "srv+_foo._tcp.example.com")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+
+	d.Resolver = srvResolver{{Target: "."}}
+	if _, err := d.Dial("tcp", "srv+_foo._tcp.example.com"); err == nil {
+		t.Fatal("expected error for unavailable service")
+	}
+}
+
+func TestResolveSRVUnsupported(t *testing.T) {
+	d := &Dialer{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}}
+	if _, err := d.Dial("tcp", "srv+_foo._tcp.example.com"); !errors.Is(err, ErrUnsupportedLookup) {
+		t.Fatalf("expected error %v; got %v", ErrUnsupportedLookup, err)
+	}
+	r := ChainResolvers(staticResolver{net.IPv4(127, 0, 0, 1)})
+	if _, err := r.ResolveSRV(context.Background(), "_foo._tcp.example.com"); !errors.Is(err, ErrUnsupportedLookup) {
+		t.Fatalf("expected error %v; got %v", ErrUnsupportedLookup, err)
+	}
+	r.Resolvers = append(r.Resolvers, srvResolver{{Target: "a.example.com.", Port: 80}})
+	addrs, err := r.ResolveSRV(context.Background(), "_foo._tcp.example.com")
+	if err != nil {
+		t.Fatalf("ResolveSRV failed: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0].Target != "a.example.com." {
+		t.Errorf("expected the records of the SRVResolver; got %+v", addrs)
+	}
+}
+
+func TestCacheResolverSRV(t *testing.T) {
+	lookups := 0
+	srvs := srvResolver{{Target: "a.example.com.", Port: 80, Priority: 1}, {Target: "b.example.com.", Port: 81, Priority: 2}}
+	r := &CacheResolver{Resolver: countingSRVResolver{srvs, &lookups}}
+	for i := 0; i < 2; i++ {
+		addrs, err := r.ResolveSRV(context.Background(), "_foo._tcp.example.com")
+		if err != nil {
+			t.Fatalf("ResolveSRV failed: %v", err)
+		}
+		if !reflect.DeepEqual(addrs, []*net.SRV(srvs)) {
+			t.Fatalf("expected %+v; got %+v", srvs, addrs)
+		}
+		addrs[0].Port = 0 // must not change what is cached
+	}
+	if lookups != 1 {
+		t.Errorf("lookups: expected 1; got %d", lookups)
+	}
+}
+
+// countingSRVResolver is an srvResolver that counts its SRV lookups.
+type countingSRVResolver struct {
+	srvResolver
+	lookups *int
+}
+
+func (r countingSRVResolver) ResolveSRV(ctx context.Context, name string) ([]*net.SRV, error) {
+	*r.lookups++
+	return r.srvResolver.ResolveSRV(ctx, name)
+}
+
+func TestSortSRV(t *testing.T) {
+	addrs := []*net.SRV{
+		{Target: "c", Priority: 3},
+		{Target: "a1", Priority: 1, Weight: 0},
+		{Target: "b", Priority: 2},
+		{Target: "a2", Priority: 1, Weight: 10},
+	}
+	SortSRV(addrs)
+	for i, prio := range []uint16{1, 1, 2, 3} {
+		if addrs[i].Priority != prio {
+			t.Fatalf("addrs[%d]: expected priority %d; got %d", i, prio, addrs[i].Priority)
+		}
+	}
+	// A zero weight record is only selected once the others have been.
+	if addrs[0].Target != "a2" {
+		t.Errorf("expected a2 first; got %s", addrs[0].Target)
+	}
+}
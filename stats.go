@@ -0,0 +1,83 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// A Recorder records the outcomes and latencies of lookups and
+// dials, for example to export them as metrics.
+//
+// A Recorder must be safe for concurrent use by multiple goroutines.
+type Recorder interface {
+	// Lookup is called after a Dialer resolves a host name,
+	// with the time the lookup took and its error, if any.
+	Lookup(host string, d time.Duration, err error)
+
+	// CacheLookup is called when a CacheResolver resolves a host,
+	// reporting whether the host was found in the cache.
+	CacheLookup(host string, hit bool)
+
+	// DialAttempt is called after a Dialer dials a single
+	// address, with the time the attempt took and its error, if
+	// any. The network is specific to the address family of the
+	// address, such as "tcp4" or "tcp6", so that attempts and
+	// failures may be counted per family.
+	DialAttempt(network, address string, d time.Duration, err error)
+}
+
+type recordedResolver struct {
+	Resolver
+	rec Recorder
+}
+
+func (r recordedResolver) Resolve(host string) ([]net.IP, error) {
+	start := time.Now()
+	ips, err := r.Resolver.Resolve(host)
+	r.rec.Lookup(host, time.Since(start), err)
+	return ips, err
+}
+
+// recordResolver returns r wrapped to record its lookups.
+func recordResolver(rec Recorder, r Resolver) Resolver {
+	if r == nil {
+		r = DefaultResolver
+	}
+	return recordedResolver{r, rec}
+}
+
+// recordDial returns fn wrapped to record its attempts.
+func recordDial(rec Recorder, fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		start := time.Now()
+		c, err := fn(ctx, network, address)
+		rec.DialAttempt(familyNetwork(network, address), address, time.Since(start), err)
+		return c, err
+	}
+}
+
+// familyNetwork returns the network specific to the address family
+// of address, such as "tcp4" for "tcp" and an IPv4 address.
+func familyNetwork(network, address string) string {
+	switch network {
+	case "tcp", "udp", "ip":
+	default:
+		return network
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host, _ = splitHostZone(host)
+	if ip := net.ParseIP(host); ip == nil {
+		return network
+	} else if ip.To4() != nil {
+		return network + "4"
+	}
+	return network + "6"
+}
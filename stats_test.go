@@ -0,0 +1,90 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder is a Recorder that records its events.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) add(format string, args ...interface{}) {
+	r.mu.Lock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+	r.mu.Unlock()
+}
+
+func (r *eventRecorder) Lookup(host string, d time.Duration, err error) {
+	r.add("Lookup %s %v", host, err)
+}
+
+func (r *eventRecorder) CacheLookup(host string, hit bool) {
+	r.add("CacheLookup %s %v", host, hit)
+}
+
+func (r *eventRecorder) DialAttempt(network, address string, d time.Duration, err error) {
+	r.add("DialAttempt %s %s %v", network, address, err == nil)
+}
+
+func TestRecorder(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	rec := &eventRecorder{}
+	d := &Dialer{
+		Resolver: &CacheResolver{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}, Recorder: rec},
+		Recorder: rec,
+	}
+	for i := 0; i < 2; i++ {
+		c, err := d.Dial("tcp", "foo.com:"+port)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		c.Close()
+	}
+	addr := "127.0.0.1:" + port
+	exp := []string{
+		"CacheLookup foo.com false",
+		"Lookup foo.com <nil>",
+		"DialAttempt tcp4 " + addr + " true",
+		"CacheLookup foo.com true",
+		"Lookup foo.com <nil>",
+		"DialAttempt tcp4 " + addr + " true",
+	}
+	if !reflect.DeepEqual(rec.events, exp) {
+		t.Errorf("events:\nexpected %q\ngot      %q", exp, rec.events)
+	}
+}
+
+func TestFamilyNetwork(t *testing.T) {
+	for _, tt := range []struct {
+		network, address, family string
+	}{
+		{"tcp", "127.0.0.1:80", "tcp4"},
+		{"tcp", "[::1]:80", "tcp6"},
+		{"tcp", "[fe80::1%eth0]:80", "tcp6"},
+		{"tcp4", "127.0.0.1:80", "tcp4"},
+		{"udp", "[::1]:53", "udp6"},
+		{"ip", "127.0.0.1", "ip4"},
+		{"unix", "/tmp/sock", "unix"},
+	} {
+		if family := familyNetwork(tt.network, tt.address); family != tt.family {
+			t.Errorf("familyNetwork(%q, %q): expected %q; got %q", tt.network, tt.address, tt.family, family)
+		}
+	}
+}
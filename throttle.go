@@ -0,0 +1,92 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"time"
+)
+
+// A Throttle limits the rate at which bytes are transferred by the
+// connections it is applied to with WrapThrottle. A Throttle may be
+// shared by several connections to limit their combined rate, such
+// as that of every connection of a backup or crawl.
+//
+// A Throttle is safe for concurrent use by multiple goroutines.
+type Throttle struct {
+	bucket *tokenBucket
+	burst  int
+}
+
+// NewThrottle returns a Throttle that allows rate bytes per second
+// on average and bursts of up to burst bytes. If burst is less than
+// 1, it is one tenth of rate, or 1 if that is less.
+func NewThrottle(rate, burst int) *Throttle {
+	if burst < 1 {
+		if burst = rate / 10; burst < 1 {
+			burst = 1
+		}
+	}
+	return &Throttle{bucket: newTokenBucket(float64(rate), burst), burst: burst}
+}
+
+// wait waits for n bytes to be allowed.
+func (t *Throttle) wait(n int) {
+	if d := t.bucket.reserve(float64(n)); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// WrapThrottle returns c wrapped to limit the rate of its reads by
+// read and the rate of its writes by write. A nil Throttle leaves
+// the rate of that direction unlimited.
+//
+// Reads are limited to the burst size of read and are delayed after
+// they complete, so that the data they return is not delayed by the
+// time it would take to arrive. Writes are split into bursts, each
+// of which is delayed until it is allowed.
+func WrapThrottle(c net.Conn, read, write *Throttle) net.Conn {
+	return &throttledConn{Conn: c, read: read, write: write}
+}
+
+type throttledConn struct {
+	net.Conn
+	read, write *Throttle
+}
+
+func (c *throttledConn) Read(b []byte) (int, error) {
+	if c.read == nil {
+		return c.Conn.Read(b)
+	}
+	if len(b) > c.read.burst {
+		b = b[:c.read.burst]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.read.wait(n)
+	}
+	return n, err
+}
+
+func (c *throttledConn) Write(b []byte) (int, error) {
+	if c.write == nil {
+		return c.Conn.Write(b)
+	}
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > c.write.burst {
+			chunk = chunk[:c.write.burst]
+		}
+		c.write.wait(len(chunk))
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWrapThrottle(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	// 10KB/s in bursts of 1KB, so 3KB takes at least 200ms after
+	// the initial burst.
+	throttle := NewThrottle(10000, 1000)
+	c := WrapThrottle(a, nil, throttle)
+	defer c.Close()
+
+	done := make(chan int64)
+	go func() {
+		n, _ := io.Copy(io.Discard, b)
+		done <- n
+	}()
+	start := time.Now()
+	if n, err := c.Write(make([]byte, 3000)); n != 3000 || err != nil {
+		t.Fatalf("Write: expected 3000 bytes; got %d, %v", n, err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("expected writes to be throttled; took %v", d)
+	}
+	c.Close()
+	if n := <-done; n != 3000 {
+		t.Errorf("expected 3000 bytes to be read; got %d", n)
+	}
+}
+
+func TestWrapThrottleRead(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapThrottle(a, NewThrottle(10000, 1000), nil)
+	defer c.Close()
+	go b.Write(make([]byte, 3000))
+	start := time.Now()
+	buf := make([]byte, 4000)
+	var total int
+	for total < 3000 {
+		n, err := c.Read(buf)
+		if n > 1000 {
+			t.Fatalf("expected reads of at most 1000 bytes; got %d", n)
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		total += n
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("expected reads to be throttled; took %v", d)
+	}
+}
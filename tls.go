@@ -0,0 +1,128 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DialTLS connects to the address on the named network and then
+// initiates a TLS handshake, returning the resulting TLS connection.
+// The Dialer's Timeout and Deadline include the handshake.
+//
+// A nil config is equivalent to the zero configuration. If
+// config.ServerName is empty, it is set to the host of address,
+// rather than the IP address it resolves to, so that the name is
+// sent for SNI and used to verify the server's certificate. If the
+// address has the form "srv+name", it is set to the domain of the
+// service, such as "example.com" for "srv+_grpc._tcp.example.com".
+func (d *Dialer) DialTLS(network, address string, config *tls.Config) (*tls.Conn, error) {
+	return d.DialTLSContext(context.Background(), network, address, config)
+}
+
+// DialTLSContext is like DialTLS but takes a context. The context
+// applies to resolving the host, establishing the connection and
+// the TLS handshake. Once successfully connected, any expiration of
+// the context will not affect the connection.
+func (d *Dialer) DialTLSContext(ctx context.Context, network, address string, config *tls.Config) (*tls.Conn, error) {
+	if ctx == nil {
+		panic("nil context")
+	}
+	ctx, cancel := d.dialContext(ctx)
+	defer cancel()
+
+	rawConn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(rawConn, tlsConfig(config, address))
+	trace := d.trace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	err = conn.HandshakeContext(ctx)
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(conn.ConnectionState(), err)
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// tlsConfig returns config with ServerName set to the host of
+// address, or the domain of the service if it has the form
+// "srv+name", copying config if it must be changed.
+func tlsConfig(config *tls.Config, address string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName != "" {
+		return config
+	}
+	var host string
+	if name, ok := strings.CutPrefix(address, srvPrefix); ok {
+		host = srvDomain(name)
+	} else {
+		var err error
+		if host, _, err = net.SplitHostPort(address); err != nil {
+			host = address
+		}
+		host, _ = splitHostZone(host)
+	}
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
+
+// srvDomain returns the domain of the service with the SRV name,
+// such as "example.com" for "_grpc._tcp.example.com", as described
+// in RFC 6125.
+func srvDomain(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	for strings.HasPrefix(name, "_") {
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return name
+}
+
+// ListenTLS announces on the local network address using a zero
+// ListenConfig and returns a listener whose connections perform TLS
+// handshakes as servers with config.
+//
+// See ListenConfig.ListenTLS for details.
+func ListenTLS(network, address string, config *tls.Config) (net.Listener, error) {
+	var lc ListenConfig
+	return lc.ListenTLS(context.Background(), network, address, config)
+}
+
+// ListenTLS announces on the local network address, like Listen, and
+// returns a listener whose connections perform TLS handshakes as
+// servers with config, like tls.NewListener.
+//
+// The config must be non-nil and include at least one certificate
+// or set GetCertificate or GetConfigForClient. Application protocols
+// are negotiated with ALPN if config.NextProtos is non-empty, and
+// client certificates are requested according to config.ClientAuth
+// and verified with config.ClientCAs.
+func (lc *ListenConfig) ListenTLS(ctx context.Context, network, address string, config *tls.Config) (net.Listener, error) {
+	if config == nil || len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: errors.New("tls: neither Certificates, GetCertificate, nor GetConfigForClient set in Config")}
+	}
+	ln, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, config), nil
+}
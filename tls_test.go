@@ -0,0 +1,100 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDialTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	roots := s.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	// The test certificate is valid for example.com,
+	// which must be verified rather than the resolved IP.
+	d := &Dialer{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}}
+	config := &tls.Config{RootCAs: roots}
+	c, err := d.DialTLS("tcp", "example.com:"+port, config)
+	if err != nil {
+		t.Fatalf("DialTLS failed: %v", err)
+	}
+	defer c.Close()
+	if name := c.ConnectionState().ServerName; name != "example.com" {
+		t.Errorf("ServerName: expected %q; got %q", "example.com", name)
+	}
+	if config.ServerName != "" {
+		t.Errorf("config was modified: ServerName: %q", config.ServerName)
+	}
+
+	// An explicit ServerName is honored.
+	config = &tls.Config{RootCAs: roots, ServerName: "foo.com"}
+	if c, err := d.DialTLS("tcp", "example.com:"+port, config); err == nil {
+		c.Close()
+		t.Fatal("expected certificate verification error")
+	}
+}
+
+func TestTLSConfigServerName(t *testing.T) {
+	for _, tt := range []struct {
+		address, name string
+	}{
+		{"example.com:443", "example.com"},
+		{"[fe80::1%eth0]:443", "fe80::1"},
+		{"srv+_grpc._tcp.example.com", "example.com"},
+		{"srv+_grpc._tcp.example.com.", "example.com"},
+	} {
+		if name := tlsConfig(nil, tt.address).ServerName; name != tt.name {
+			t.Errorf("%s: expected %q; got %q", tt.address, tt.name, name)
+		}
+	}
+}
+
+func TestListenTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+	roots := s.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	if _, err := ListenTLS("tcp", "127.0.0.1:0", &tls.Config{}); err == nil {
+		t.Fatal("expected ListenTLS without certificates to fail")
+	}
+	lc := &ListenConfig{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}}
+	config := &tls.Config{
+		Certificates: s.TLS.Certificates,
+		NextProtos:   []string{"foo", "bar"},
+	}
+	ln, err := lc.ListenTLS(context.Background(), "tcp", "example.com:0", config)
+	if err != nil {
+		t.Fatalf("ListenTLS failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	d := &Dialer{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}}
+	c, err := d.DialTLS("tcp", "example.com:"+port, &tls.Config{RootCAs: roots, NextProtos: []string{"bar"}})
+	if err != nil {
+		t.Fatalf("DialTLS failed: %v", err)
+	}
+	defer c.Close()
+	if proto := c.ConnectionState().NegotiatedProtocol; proto != "bar" {
+		t.Errorf("NegotiatedProtocol: expected %q; got %q", "bar", proto)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// DialTrace is a set of hooks to run at various stages of a dial.
+// Any particular hook may be nil. Functions may be called
+// concurrently from different goroutines and some may be called
+// after the dial has completed or failed.
+type DialTrace struct {
+	// DNSStart is called when resolving a host name begins.
+	// It is not called for literal IP addresses.
+	DNSStart func(host string)
+
+	// DNSDone is called when resolving a host name ends, with
+	// the addresses returned by the Resolver.
+	DNSDone func(host string, ips []net.IP, err error)
+
+	// FilterDone is called after the IPFilter selects the
+	// addresses to be dialed.
+	FilterDone func(ips []net.IP)
+
+	// ConnectStart is called when dialing an address begins.
+	ConnectStart func(network, address string)
+
+	// ConnectDone is called when dialing an address completes,
+	// with a nil err if a connection was established.
+	ConnectDone func(network, address string, err error)
+
+	// TLSHandshakeStart is called when the TLS handshake of
+	// DialTLS begins.
+	TLSHandshakeStart func()
+
+	// TLSHandshakeDone is called after the TLS handshake of
+	// DialTLS with either the successful handshake's connection
+	// state, or a non-nil error on handshake failure.
+	TLSHandshakeDone func(tls.ConnectionState, error)
+}
+
+type dialTraceKey struct{}
+
+// WithDialTrace returns a new context based on the provided parent
+// ctx. Dials made with the returned context use the provided trace
+// hooks in preference to the Dialer's Trace.
+func WithDialTrace(ctx context.Context, trace *DialTrace) context.Context {
+	return context.WithValue(ctx, dialTraceKey{}, trace)
+}
+
+// ContextDialTrace returns the DialTrace associated with the
+// provided context. If none, it returns nil.
+func ContextDialTrace(ctx context.Context) *DialTrace {
+	trace, _ := ctx.Value(dialTraceKey{}).(*DialTrace)
+	return trace
+}
+
+// trace returns the DialTrace to use for a dial with ctx, if any.
+func (d *Dialer) trace(ctx context.Context) *DialTrace {
+	if trace := ContextDialTrace(ctx); trace != nil {
+		return trace
+	}
+	return d.Trace
+}
+
+type tracedResolver struct {
+	Resolver
+	trace *DialTrace
+}
+
+func (r tracedResolver) Resolve(host string) ([]net.IP, error) {
+	if r.trace.DNSStart != nil {
+		r.trace.DNSStart(host)
+	}
+	ips, err := r.Resolver.Resolve(host)
+	if r.trace.DNSDone != nil {
+		r.trace.DNSDone(host, ips, err)
+	}
+	return ips, err
+}
+
+// resolver returns r wrapped to call the DNS hooks.
+func (trace *DialTrace) resolver(r Resolver) Resolver {
+	if trace.DNSStart == nil && trace.DNSDone == nil {
+		return r
+	}
+	if r == nil {
+		r = DefaultResolver
+	}
+	return tracedResolver{r, trace}
+}
+
+// filter returns filter wrapped to call the FilterDone hook.
+func (trace *DialTrace) filter(filter ipFilter) ipFilter {
+	if trace.FilterDone == nil {
+		return filter
+	}
+	return func(ips []net.IP) []net.IP {
+		ips = filter(ips)
+		trace.FilterDone(ips)
+		return ips
+	}
+}
+
+// dial returns fn wrapped to call the connect hooks.
+func (trace *DialTrace) dial(fn dialFunc) dialFunc {
+	if trace.ConnectStart == nil && trace.ConnectDone == nil {
+		return fn
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if trace.ConnectStart != nil {
+			trace.ConnectStart(network, address)
+		}
+		c, err := fn(ctx, network, address)
+		if trace.ConnectDone != nil {
+			trace.ConnectDone(network, address, err)
+		}
+		return c, err
+	}
+}
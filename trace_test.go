@@ -0,0 +1,94 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// traceRecorder records the events of a DialTrace.
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *traceRecorder) add(format string, args ...interface{}) {
+	r.mu.Lock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+	r.mu.Unlock()
+}
+
+func (r *traceRecorder) trace() *DialTrace {
+	return &DialTrace{
+		DNSStart:     func(host string) { r.add("DNSStart %s", host) },
+		DNSDone:      func(host string, ips []net.IP, err error) { r.add("DNSDone %s %v %v", host, ips, err) },
+		FilterDone:   func(ips []net.IP) { r.add("FilterDone %v", ips) },
+		ConnectStart: func(network, address string) { r.add("ConnectStart %s %s", network, address) },
+		ConnectDone: func(network, address string, err error) {
+			r.add("ConnectDone %s %s %v", network, address, err)
+		},
+		TLSHandshakeStart: func() { r.add("TLSHandshakeStart") },
+		TLSHandshakeDone:  func(_ tls.ConnectionState, err error) { r.add("TLSHandshakeDone %v", err) },
+	}
+}
+
+func TestDialTrace(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	roots := s.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	var dialerTrace, ctxTrace traceRecorder
+	d := &Dialer{
+		Resolver: staticResolver{net.IPv4(127, 0, 0, 1)},
+		Trace:    dialerTrace.trace(),
+	}
+	ctx := WithDialTrace(context.Background(), ctxTrace.trace())
+	c, err := d.DialTLSContext(ctx, "tcp", "example.com:"+port, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("DialTLS failed: %v", err)
+	}
+	c.Close()
+
+	addr := "127.0.0.1:" + port
+	exp := []string{
+		"DNSStart example.com",
+		"DNSDone example.com [127.0.0.1] <nil>",
+		"FilterDone [127.0.0.1]",
+		"ConnectStart tcp " + addr,
+		"ConnectDone tcp " + addr + " <nil>",
+		"TLSHandshakeStart",
+		"TLSHandshakeDone <nil>",
+	}
+	if !reflect.DeepEqual(ctxTrace.events, exp) {
+		t.Errorf("events:\nexpected %q\ngot      %q", exp, ctxTrace.events)
+	}
+	if len(dialerTrace.events) != 0 {
+		t.Errorf("Dialer trace used in preference to context trace: %q", dialerTrace.events)
+	}
+
+	// Literal IPs are not resolved.
+	rawConn, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	rawConn.Close()
+	exp = []string{
+		"FilterDone [127.0.0.1]",
+		"ConnectStart tcp " + addr,
+		"ConnectDone tcp " + addr + " <nil>",
+	}
+	if !reflect.DeepEqual(dialerTrace.events, exp) {
+		t.Errorf("events:\nexpected %q\ngot      %q", exp, dialerTrace.events)
+	}
+}
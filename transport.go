@@ -0,0 +1,62 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewTransport returns an http.Transport with the settings of
+// http.DefaultTransport that connects using a copy of d, as
+// configured by WrapTransport.
+func NewTransport(d *Dialer) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	WrapTransport(t, d)
+	return t
+}
+
+// WrapTransport configures t to connect using a copy of d.
+//
+// Plain connections are dialed with DialContext, and TLS connections
+// with DialTLSContext using t's TLSClientConfig, so that the Dialer's
+// Timeout, Deadline and trace hooks apply to the TLS handshake; t's
+// TLSHandshakeTimeout is not used. The server name sent for SNI and
+// verified against the certificate is the host of the request, never
+// the IP address it resolves to, and HTTP/2 is negotiated if
+// t.ForceAttemptHTTP2 is set.
+//
+// If d.Resolver is nil, a CacheResolver with a one minute TTL is used
+// so that repeated requests to a host share lookups. If d uses a
+// proxy, t's Proxy is cleared so that connections aren't proxied
+// twice.
+func WrapTransport(t *http.Transport, d *Dialer) {
+	dd := *d
+	if dd.Resolver == nil {
+		dd.Resolver = &CacheResolver{TTL: time.Minute}
+	}
+	if dd.Proxy != nil || dd.ProxyFunc != nil {
+		t.Proxy = nil
+	}
+	t.DialContext = dd.DialContext
+	t.DialTLSContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		config := t.TLSClientConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if t.ForceAttemptHTTP2 && len(config.NextProtos) == 0 {
+			config = config.Clone()
+			config.NextProtos = []string{"h2", "http/1.1"}
+		}
+		c, err := dd.DialTLSContext(ctx, network, address, config)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	roots := s.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	// The test certificate is valid for example.com,
+	// which must be verified rather than the resolved IP.
+	d := &Dialer{Resolver: staticResolver{net.IPv4(127, 0, 0, 1)}}
+	tr := NewTransport(d)
+	tr.TLSClientConfig = &tls.Config{RootCAs: roots}
+	defer tr.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: tr}).Get("https://example.com:" + port)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2; got %s", resp.Proto)
+	}
+}
+
+func TestWrapTransportProxy(t *testing.T) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	WrapTransport(tr, &Dialer{Proxy: &HTTPProxy{Address: "127.0.0.1:0"}})
+	if tr.Proxy != nil {
+		t.Error("expected Transport Proxy to be cleared")
+	}
+}
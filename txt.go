@@ -0,0 +1,85 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+var lookupTXT = net.DefaultResolver.LookupTXT // used by tests
+
+// A TXTResolver is a Resolver that can also look up TXT records.
+type TXTResolver interface {
+	Resolver
+
+	// ResolveTXT looks up the TXT records with the given name
+	// using the provided context.
+	ResolveTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// local resolver.
+func (defaultResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	return lookupTXT(ctx, name)
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// underlying Resolver, which must be a TXTResolver. The records are
+// cached and their lookups coalesced like those of hosts, for TTL or
+// NegativeTTL. Like Resolve, lookups are not canceled by ctx, since
+// they may be shared by concurrent callers.
+func (r *CacheResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	item, err := r.get("TXT "+name, name, func() (*cacheItem, time.Duration, error) {
+		txt, err := resolveTXT(context.Background(), r.Resolver, name)
+		return &cacheItem{records: txt}, r.clampTTL(r.defaultTTL()), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), item.records...), nil
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context with the first of the resolvers that succeeds, in
+// turn even if Concurrent is set. Resolvers that are not TXTResolvers
+// are skipped.
+func (r *ChainResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	var txt []string
+	err := r.firstLookup(name, func(resolver Resolver) (err error) {
+		txt, err = resolveTXT(ctx, resolver, name)
+		return err
+	})
+	return txt, err
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context with the resolver the name is routed to.
+func (r *RouteResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	return resolveTXT(ctx, r.route(name), name)
+}
+
+// ResolveTXT looks up the TXT records with the given name using the
+// provided context with the underlying Resolver, since the table only
+// holds addresses.
+func (r *HostsResolver) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	return resolveTXT(ctx, r.Resolver, name)
+}
+
+// resolveTXT looks up the TXT records with the given name with r, or
+// DefaultResolver if r is nil, failing with ErrUnsupportedLookup if it
+// is not a TXTResolver.
+func resolveTXT(ctx context.Context, r Resolver, name string) ([]string, error) {
+	if r == nil {
+		r = DefaultResolver
+	}
+	tr, ok := r.(TXTResolver)
+	if !ok {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrUnsupportedLookup)
+	}
+	return tr.ResolveTXT(ctx, name)
+}
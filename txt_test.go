@@ -0,0 +1,53 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// withTXT is a Resolver that also resolves every name to the TXT
+// records txt.
+type withTXT struct {
+	Resolver
+	txt []string
+}
+
+func (r withTXT) ResolveTXT(ctx context.Context, name string) ([]string, error) {
+	return r.txt, nil
+}
+
+func TestCacheResolverTXT(t *testing.T) {
+	defer func(fn func(context.Context, string) ([]string, error)) { lookupTXT = fn }(lookupTXT)
+	lookups := 0
+	txt := []string{"v=spf1 -all"}
+	lookupTXT = func(context.Context, string) ([]string, error) {
+		lookups++
+		return txt, nil
+	}
+	r := &CacheResolver{}
+	for i := 0; i < 2; i++ {
+		got, err := r.ResolveTXT(context.Background(), "example.com")
+		if err != nil {
+			t.Fatalf("ResolveTXT failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, txt) {
+			t.Fatalf("expected %q; got %q", txt, got)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("lookups: expected 1; got %d", lookups)
+	}
+}
+
+func TestResolveTXTUnsupported(t *testing.T) {
+	r := &CacheResolver{Resolver: staticResolver{}}
+	if _, err := r.ResolveTXT(context.Background(), "example.com"); !errors.Is(err, ErrUnsupportedLookup) {
+		t.Errorf("expected error %v; got %v", ErrUnsupportedLookup, err)
+	}
+}
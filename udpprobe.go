@@ -0,0 +1,75 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// defaultUDPProbeTimeout is the time a UDP probe waits for a reply
+// if the dial has no deadline.
+const defaultUDPProbeTimeout = time.Second
+
+// UDPReplyProbe returns a UDPProbe for a Dialer that writes payload
+// to each connection and requires a reply, which is discarded,
+// before the dial's deadline or, if it has none, within one second.
+// The payload should be a request to which the server replies, such
+// as an NTP or DNS query.
+func UDPReplyProbe(payload []byte) func(ctx context.Context, c net.Conn) error {
+	return func(ctx context.Context, c net.Conn) error {
+		_, err := probeUDP(ctx, c, payload, defaultUDPProbeTimeout)
+		return err
+	}
+}
+
+// UDPUnreachableProbe returns a UDPProbe for a Dialer that writes
+// payload to each connection and waits up to wait for an ICMP error,
+// such as port unreachable, reported by the operating system. Unlike
+// UDPReplyProbe, it accepts addresses that do not reply, but it
+// detects only those whose hosts or routers report errors.
+func UDPUnreachableProbe(payload []byte, wait time.Duration) func(ctx context.Context, c net.Conn) error {
+	return func(ctx context.Context, c net.Conn) error {
+		_, err := probeUDP(ctx, c, payload, wait)
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() && ctx.Err() == nil {
+			return nil
+		}
+		return err
+	}
+}
+
+// probeUDP writes payload to c and reads a reply within timeout,
+// or before the deadline of ctx if it is earlier.
+func probeUDP(ctx context.Context, c net.Conn, payload []byte, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+	defer c.SetDeadline(time.Time{})
+	if _, err := c.Write(payload); err != nil {
+		return 0, err
+	}
+	return c.Read(make([]byte, 1500))
+}
+
+// probeUDPDial returns fn wrapped to validate its UDP connections
+// with probe, closing those that fail.
+func probeUDPDial(probe func(ctx context.Context, c net.Conn) error, fn dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		c, err := fn(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if err := probe(ctx, c); err != nil {
+			c.Close()
+			return nil, &net.OpError{Op: "probe", Net: network, Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: err}
+		}
+		return c, nil
+	}
+}
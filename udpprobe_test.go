@@ -0,0 +1,70 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialUDPProbe(t *testing.T) {
+	c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go func() {
+		b := make([]byte, 16)
+		for {
+			n, addr, err := c.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			c.WriteTo(b[:n], addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(c.LocalAddr().String())
+
+	dead, live := parseIPs("127.0.0.2")[0], parseIPs("127.0.0.1")[0]
+	for _, probe := range []struct {
+		name string
+		d    *Dialer
+	}{
+		{"UDPReplyProbe", &Dialer{UDPProbe: UDPReplyProbe([]byte("ping"))}},
+		{"UDPUnreachableProbe", &Dialer{UDPProbe: UDPUnreachableProbe([]byte("ping"), 100*time.Millisecond)}},
+	} {
+		d := probe.d
+		d.Resolver = staticResolver{dead, live}
+		d.IPFilter = func(ips []net.IP) []net.IP { return ips }
+		conn, err := d.Dial("udp", "foo.com:"+port)
+		if err != nil {
+			t.Errorf("%s: Dial failed: %v", probe.name, err)
+			continue
+		}
+		if ip := conn.RemoteAddr().(*net.UDPAddr).IP; !ip.Equal(live) {
+			t.Errorf("%s: expected a connection to %v; got %v", probe.name, live, ip)
+		}
+		conn.Close()
+	}
+
+	// Without a reply, only UDPUnreachableProbe succeeds.
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	d := &Dialer{Timeout: 100 * time.Millisecond, UDPProbe: UDPReplyProbe([]byte("ping"))}
+	if conn, err := d.Dial("udp", silent.LocalAddr().String()); err == nil {
+		conn.Close()
+		t.Error("UDPReplyProbe: expected Dial to fail without a reply")
+	}
+	d.UDPProbe = UDPUnreachableProbe([]byte("ping"), 10*time.Millisecond)
+	conn, err := d.Dial("udp", silent.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("UDPUnreachableProbe: Dial failed: %v", err)
+	}
+	conn.Close()
+}
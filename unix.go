@@ -0,0 +1,57 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var errAbstractUnix = errors.New("abstract Unix domain socket addresses are only supported on Linux")
+
+// resolveUnixAddr returns the address of a Unix domain socket on the
+// named network. Addresses in Linux's abstract namespace may begin
+// with "@" or a NUL byte.
+func resolveUnixAddr(network, address string) (*net.UnixAddr, error) {
+	if strings.HasPrefix(address, "\x00") {
+		address = "@" + address[1:]
+	}
+	if strings.HasPrefix(address, "@") && runtime.GOOS != "linux" && runtime.GOOS != "android" {
+		return nil, errAbstractUnix
+	}
+	return &net.UnixAddr{Name: address, Net: network}, nil
+}
+
+// UnixPathPrefix returns a UnixFilter that selects the Unix domain
+// socket addresses beginning with one of the prefixes, such as
+// "/run/myapp/" to only allow the sockets of a directory. Paths are
+// cleaned before they are matched, so that "/run/myapp/../x" doesn't
+// match "/run/myapp/". Addresses in Linux's abstract namespace begin
+// with "@" and are matched as given.
+func UnixPathPrefix(prefixes ...string) func(name string) bool {
+	return func(name string) bool {
+		if !strings.HasPrefix(name, "@") {
+			name = filepath.Clean(name)
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// filterUnix returns ErrNoSuitableAddress if addrs is the address of
+// a Unix domain socket that filter doesn't select.
+func filterUnix(filter func(name string) bool, addrs addrList) error {
+	if list, ok := addrs.(unixList); ok && filter != nil && !filter(list[0].Name) {
+		return ErrNoSuitableAddress
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nett
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestUnixPathPrefix(t *testing.T) {
+	filter := UnixPathPrefix("/run/app/", "@app")
+	for _, tt := range []struct {
+		name string
+		ok   bool
+	}{
+		{"/run/app/sock", true},
+		{"/run/app/../other/sock", false},
+		{"/run/apple/sock", false},
+		{"@app.sock", true},
+		{"@other", false},
+	} {
+		if ok := filter(tt.name); ok != tt.ok {
+			t.Errorf("%q: expected %v; got %v", tt.name, tt.ok, ok)
+		}
+	}
+}
+
+func TestDialUnix(t *testing.T) {
+	switch runtime.GOOS {
+	case "plan9", "windows":
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	lc := &ListenConfig{UnixFilter: UnixPathPrefix(dir)}
+	d := &Dialer{UnixFilter: UnixPathPrefix(dir)}
+	for _, network := range []string{"unix", "unixpacket"} {
+		if network == "unixpacket" && runtime.GOOS != "linux" {
+			continue
+		}
+		path := filepath.Join(dir, network+".sock")
+		ln, err := lc.Listen(context.Background(), network, path)
+		if err != nil {
+			t.Fatalf("%s: Listen failed: %v", network, err)
+		}
+		defer ln.Close()
+		c, err := d.Dial(network, path)
+		if err != nil {
+			t.Fatalf("%s: Dial failed: %v", network, err)
+		}
+		c.Close()
+	}
+
+	path := filepath.Join(dir, "unixgram.sock")
+	pc, err := lc.ListenPacket(context.Background(), "unixgram", path)
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer pc.Close()
+	c, err := d.Dial("unixgram", path)
+	if err != nil {
+		t.Fatalf("unixgram: Dial failed: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("x")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var b [1]byte
+	if n, _, err := pc.ReadFrom(b[:]); err != nil || n != 1 {
+		t.Errorf("ReadFrom: expected 1 byte; got %d, %v", n, err)
+	}
+
+	d.UnixFilter = UnixPathPrefix("/nonexistent/")
+	if _, err := d.Dial("unix", filepath.Join(dir, "unix.sock")); !errors.Is(err, ErrNoSuitableAddress) {
+		t.Errorf("expected ErrNoSuitableAddress; got %v", err)
+	}
+}
+
+func TestDialUnixAbstract(t *testing.T) {
+	name := "nett-test-" + strconv.Itoa(os.Getpid())
+	if runtime.GOOS != "linux" {
+		if _, err := Listen("unix", "@"+name); !errors.Is(err, errAbstractUnix) {
+			t.Errorf("expected errAbstractUnix; got %v", err)
+		}
+		return
+	}
+	ln, err := Listen("unix", "@"+name)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	var d Dialer
+	c, err := d.Dial("unix", "\x00"+name)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	c.Close()
+}